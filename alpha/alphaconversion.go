@@ -26,16 +26,26 @@ func unboundError(name string) *AlphaConversionError {
 // Alpha Environment definition and methods
 // ======================================================================
 
-// Contains mappings to integers for unique identifiers
+// Contains mappings to integers for unique identifiers. Type
+// variables and the names of data types live in a separate
+// namespace from values
 type AlphaEnvironment struct {
-	store map[string]int
-	outer *AlphaEnvironment
+	store        map[string]int
+	constructors map[string]*ast.TypeStatement // Names in store bound to constructors
+	types        map[string]int
+	datatypes    map[string]bool // Names in types declared by a type statement
+	outer        *AlphaEnvironment
 }
 
 // Create a new empty α environment for α-conversion
 func NewAlphaEnvironment() *AlphaEnvironment {
-	s := make(map[string]int)
-	return &AlphaEnvironment{store: s, outer: nil}
+	return &AlphaEnvironment{
+		store:        make(map[string]int),
+		constructors: make(map[string]*ast.TypeStatement),
+		types:        make(map[string]int),
+		datatypes:    make(map[string]bool),
+		outer:        nil,
+	}
 }
 
 func NewAlphaEnvironmentExtension(a *AlphaEnvironment) *AlphaEnvironment {
@@ -56,7 +66,21 @@ func (a AlphaEnvironment) Get(name string) (ast.UniqueIdentifier, error) {
 	return ast.UniqueIdentifier{Value: name, Id: uid}, nil
 }
 
+// Get the names visible in the environment, with the unique
+// identifier each one currently refers to
+func (a *AlphaEnvironment) Names() map[string]ast.UniqueIdentifier {
+	names := map[string]ast.UniqueIdentifier{}
+	if a.outer != nil {
+		names = a.outer.Names()
+	}
+	for name, id := range a.store {
+		names[name] = ast.UniqueIdentifier{Value: name, Id: id}
+	}
+	return names
+}
+
 func (a *AlphaEnvironment) IdentifierAlphaConversion(uid ast.UniqueIdentifier) ast.UniqueIdentifier {
+	delete(a.constructors, uid.Value)
 	nuid, err := a.Get(uid.Value)
 	if err != nil {
 		a.store[uid.Value] = 0
@@ -68,12 +92,136 @@ func (a *AlphaEnvironment) IdentifierAlphaConversion(uid ast.UniqueIdentifier) a
 	return ast.UniqueIdentifier{Value: uid.Value, Id: nuid.Id + 1}
 }
 
+// Bind a name to a unique identifier generated in another environment
+func (a *AlphaEnvironment) bindIdentifier(uid ast.UniqueIdentifier) {
+	delete(a.constructors, uid.Value)
+	a.store[uid.Value] = uid.Id
+}
+
+// Search for a name bound to a constructor. Returns the
+// declaration of the data type of the constructor
+func (a *AlphaEnvironment) lookupConstructor(name string) (*ast.TypeStatement, bool) {
+	if _, ok := a.store[name]; !ok {
+		if a.outer != nil {
+			return a.outer.lookupConstructor(name)
+		}
+		return nil, false
+	}
+	def, ok := a.constructors[name]
+	return def, ok
+}
+
+// Search for a name in the types namespace. Returns the
+// environment in which the name is bound
+func (a *AlphaEnvironment) lookupType(name string) (*AlphaEnvironment, int, bool) {
+	uid, ok := a.types[name]
+	if !ok {
+		if a.outer != nil {
+			return a.outer.lookupType(name)
+		}
+		return nil, 0, false
+	}
+	return a, uid, true
+}
+
+// Search for a type variable or a data type in the environment
+func (a *AlphaEnvironment) GetTypeVar(name string) (ast.UniqueIdentifier, bool) {
+	_, uid, ok := a.lookupType(name)
+	return ast.UniqueIdentifier{Value: name, Id: uid}, ok
+}
+
+// Bind a name in the types namespace to a new unique identifier
+func (a *AlphaEnvironment) bindTypeName(uid ast.UniqueIdentifier, data bool) ast.UniqueIdentifier {
+	nuid, ok := a.GetTypeVar(uid.Value)
+	if ok {
+		nuid.Id++
+	}
+	a.types[uid.Value] = nuid.Id
+	if data {
+		a.datatypes[uid.Value] = true
+	} else {
+		delete(a.datatypes, uid.Value)
+	}
+	return nuid
+}
+
+// Bind a type variable to a new unique identifier
+func (a *AlphaEnvironment) TypeVarAlphaConversion(uid ast.UniqueIdentifier) ast.UniqueIdentifier {
+	return a.bindTypeName(uid, false)
+}
+
+// Bind the name of a data type to a new unique identifier
+func (a *AlphaEnvironment) DataTypeAlphaConversion(uid ast.UniqueIdentifier) ast.UniqueIdentifier {
+	return a.bindTypeName(uid, true)
+}
+
+// Convert the type variables of a type value. Names that are not
+// bound by a forall, such as builtin types, are left untouched
+func (a *AlphaEnvironment) TypeAlphaConversion(t ast.TypeValue) ast.TypeValue {
+	switch vt := t.(type) {
+	case *ast.VariableType:
+		env, id, ok := a.lookupType(vt.Identifier.Value)
+		if !ok {
+			return vt
+		}
+		uid := ast.UniqueIdentifier{Value: vt.Identifier.Value, Id: id}
+		if env.datatypes[uid.Value] {
+			return &ast.DataType{Identifier: uid}
+		}
+		return &ast.VariableType{Identifier: uid}
+	case *ast.DataType:
+		ndt := vt.Map(a.TypeAlphaConversion)
+		if uid, ok := a.GetTypeVar(vt.Identifier.Value); ok {
+			ndt.Identifier = uid
+		}
+		return ndt
+	case *ast.LambdaType:
+		return vt.Map(a.TypeAlphaConversion)
+	case *ast.ForAllType:
+		return NewAlphaEnvironmentExtension(a).quantifiersAlphaConversion(vt)
+	case *ast.MuType:
+		ext := NewAlphaEnvironmentExtension(a)
+		id := ext.TypeVarAlphaConversion(vt.Identifier)
+		return &ast.MuType{Identifier: id, Type: ext.TypeAlphaConversion(vt.Type)}
+	case *ast.RecordType:
+		return vt.Map(a.TypeAlphaConversion)
+	case *ast.TupleType:
+		return vt.Map(a.TypeAlphaConversion)
+	case *ast.ListType:
+		return vt.Map(a.TypeAlphaConversion)
+	case *ast.ArrayType:
+		return vt.Map(a.TypeAlphaConversion)
+	case *ast.MatrixType:
+		return vt.Map(a.TypeAlphaConversion)
+	case *ast.UnionType:
+		return vt.Map(a.TypeAlphaConversion)
+	default:
+		return vt
+	}
+}
+
+// Convert a type value, binding the type variables of its outermost
+// foralls in the environment itself
+func (a *AlphaEnvironment) quantifiersAlphaConversion(t ast.TypeValue) ast.TypeValue {
+	vt, ok := t.(*ast.ForAllType)
+	if !ok {
+		return a.TypeAlphaConversion(t)
+	}
+	return &ast.ForAllType{
+		Identifier:  a.TypeVarAlphaConversion(vt.Identifier),
+		Constraints: vt.Constraints,
+		Type:        a.quantifiersAlphaConversion(vt.Type),
+	}
+}
+
 func (a *AlphaEnvironment) ExpressionAlphaConversion(exp ast.Expression) (ast.Expression, error) {
 	// fmt.Println("uniquifying", exp, "in", a.store)
 
 	switch ve := exp.(type) {
 	case *ast.UnitLiteral:
 		return ve, nil
+	case *ast.EventExpr:
+		return ve, nil
 	case *ast.IntegerLiteral:
 		return ve, nil
 	case *ast.FloatLiteral:
@@ -99,6 +247,12 @@ func (a *AlphaEnvironment) ExpressionAlphaConversion(exp ast.Expression) (ast.Ex
 
 		nexpr.Right = nright
 		return &nexpr, nil
+	case *ast.PostfixExpression:
+		nleft, err := a.ExpressionAlphaConversion(ve.Left)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.PostfixExpression{Token: ve.Token, Left: nleft, Operator: ve.Operator}, nil
 	case *ast.InfixExpression:
 		nright, err := a.ExpressionAlphaConversion(ve.Right)
 		if err != nil {
@@ -130,6 +284,12 @@ func (a *AlphaEnvironment) ExpressionAlphaConversion(exp ast.Expression) (ast.Ex
 		newexpr.Identifier = uid
 		return &newexpr, nil
 	case *ast.FunctionLiteral:
+		// The type of the parameter is converted
+		// in the scope enclosing the function
+		var nty ast.TypeValue
+		if ve.ParamType != nil {
+			nty = a.TypeAlphaConversion(ve.ParamType)
+		}
 		na := NewAlphaEnvironmentExtension(a)
 		nid := na.IdentifierAlphaConversion(ve.Param.Identifier)
 
@@ -144,6 +304,7 @@ func (a *AlphaEnvironment) ExpressionAlphaConversion(exp ast.Expression) (ast.Ex
 			return nil, err
 		}
 		newexpr.Param.Identifier = nid
+		newexpr.ParamType = nty
 		newexpr.Body = nbody
 		return &newexpr, nil
 	case *ast.FixExpr:
@@ -204,8 +365,98 @@ func (a *AlphaEnvironment) ExpressionAlphaConversion(exp ast.Expression) (ast.Ex
 		nexpr.Alternative = nfbr
 
 		return &nexpr, nil
+	case *ast.LetExpression:
+		// The values are converted in the outer environment, the
+		// body in an extension that binds all the names
+		na := NewAlphaEnvironmentExtension(a)
+		nasss, err := a.assignmentsAlphaConversion(na, ve.Assignments)
+		if err != nil {
+			return nil, err
+		}
+		nbody, err := na.ExpressionAlphaConversion(ve.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.LetExpression{Token: ve.Token, Assignments: nasss, Body: nbody}, nil
+	case *ast.RecordLiteral:
+		nfields := make([]*ast.RecordField, 0, len(ve.Fields))
+		for _, f := range ve.Fields {
+			nval, err := a.ExpressionAlphaConversion(f.Value)
+			if err != nil {
+				return nil, err
+			}
+			nfields = append(nfields, &ast.RecordField{Token: f.Token, Label: f.Label, Value: nval})
+		}
+		return &ast.RecordLiteral{Token: ve.Token, Fields: nfields}, nil
+	case *ast.TupleLiteral:
+		nelems := make([]ast.Expression, 0, len(ve.Elements))
+		for _, e := range ve.Elements {
+			nelem, err := a.ExpressionAlphaConversion(e)
+			if err != nil {
+				return nil, err
+			}
+			nelems = append(nelems, nelem)
+		}
+		return &ast.TupleLiteral{Token: ve.Token, Elements: nelems}, nil
+	case *ast.ListLiteral:
+		nelems := make([]ast.Expression, 0, len(ve.Elements))
+		for _, e := range ve.Elements {
+			nelem, err := a.ExpressionAlphaConversion(e)
+			if err != nil {
+				return nil, err
+			}
+			nelems = append(nelems, nelem)
+		}
+		return &ast.ListLiteral{Token: ve.Token, Elements: nelems}, nil
+	case *ast.MatrixLiteral:
+		nrows := make([][]ast.Expression, 0, len(ve.Rows))
+		for _, row := range ve.Rows {
+			nrow := make([]ast.Expression, 0, len(row))
+			for _, e := range row {
+				nelem, err := a.ExpressionAlphaConversion(e)
+				if err != nil {
+					return nil, err
+				}
+				nrow = append(nrow, nelem)
+			}
+			nrows = append(nrows, nrow)
+		}
+		return &ast.MatrixLiteral{Token: ve.Token, Sparse: ve.Sparse, Rows: nrows}, nil
+	case *ast.AccessExpr:
+		nrec, err := a.ExpressionAlphaConversion(ve.Record)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AccessExpr{Token: ve.Token, Record: nrec, Field: ve.Field}, nil
+	case *ast.MatchExpr:
+		nscrut, err := a.ExpressionAlphaConversion(ve.Scrutinee)
+		if err != nil {
+			return nil, err
+		}
+		nexpr := &ast.MatchExpr{Token: ve.Token, Scrutinee: nscrut}
+		for _, arm := range ve.Arms {
+			// The variables of the pattern are visible in the body
+			na := NewAlphaEnvironmentExtension(a)
+			npat, err := na.PatternAlphaConversion(arm.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			nbody, err := na.ExpressionAlphaConversion(arm.Body)
+			if err != nil {
+				return nil, err
+			}
+			nexpr.Arms = append(nexpr.Arms, &ast.MatchArm{Token: arm.Token, Pattern: npat, Body: nbody})
+		}
+		return nexpr, nil
 	case *ast.AnnotExpr:
-		nbody, err := a.ExpressionAlphaConversion(ve.Body)
+		// The type variables quantified at the top of the
+		// annotation are visible in the annotated expression
+		na := NewAlphaEnvironmentExtension(a)
+		var nty ast.TypeValue
+		if ve.Type != nil {
+			nty = na.quantifiersAlphaConversion(ve.Type)
+		}
+		nbody, err := na.ExpressionAlphaConversion(ve.Body)
 		if err != nil {
 			return nil, err
 		}
@@ -215,35 +466,290 @@ func (a *AlphaEnvironment) ExpressionAlphaConversion(exp ast.Expression) (ast.Ex
 			return nil, err
 		}
 		nexpr.Body = nbody
+		nexpr.Type = nty
 		return &nexpr, nil
+	case *ast.EffectExpr:
+		nbody, err := a.ExpressionAlphaConversion(ve.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.EffectExpr{Token: ve.Token, Restriction: ve.Restriction, Body: nbody}, nil
 
 	default: // TODO other expressions
 		panic(fmt.Sprintf("alpha conversion not implemented yet for expression of type %T", ve))
 	}
 }
 
+// Convert the values of a list of assignments in the environment,
+// then bind their names, or the variables of their patterns, in
+// the target environment. Mutually recursive functions are converted
+// in an extension of the environment that already binds their names
+func (a *AlphaEnvironment) assignmentsAlphaConversion(target *AlphaEnvironment, asss []*ast.Assignment) ([]*ast.Assignment, error) {
+	rec := NewAlphaEnvironmentExtension(a)
+	group := map[*ast.Assignment]bool{}
+	for _, ass := range ast.RecursiveGroup(asss) {
+		rec.IdentifierAlphaConversion(ass.Name.Identifier)
+		group[ass] = true
+	}
+
+	nasss := make([]*ast.Assignment, 0, len(asss))
+	recursive := map[*ast.Assignment]bool{}
+	for _, ass := range asss {
+		env := a
+		if group[ass] {
+			env = rec
+		}
+		nval, err := env.ExpressionAlphaConversion(ass.Value)
+		if err != nil {
+			return nil, err
+		}
+		nass := &ast.Assignment{Token: ass.Token, Pattern: ass.Pattern, Value: nval}
+		if ass.Name != nil {
+			nass.Name = &ast.IdentifierExpr{Token: ass.Name.Token, Identifier: ass.Name.Identifier}
+		}
+		nasss = append(nasss, nass)
+		recursive[nass] = group[ass]
+	}
+	for _, nass := range nasss {
+		if nass.Pattern != nil {
+			npat, err := target.PatternAlphaConversion(nass.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			nass.Pattern = npat
+			continue
+		}
+		if recursive[nass] {
+			// The name refers to the function in the values
+			uid, _ := rec.Get(nass.Name.Identifier.Value)
+			target.bindIdentifier(uid)
+			nass.Name.Identifier = uid
+			continue
+		}
+		nass.Name.Identifier = target.IdentifierAlphaConversion(nass.Name.Identifier)
+	}
+	return nasss, nil
+}
+
+// Convert a pattern, binding its variables in the environment.
+// Names of constructors are resolved to their declarations, and a
+// variable cannot be bound twice by the same pattern
+func (a *AlphaEnvironment) PatternAlphaConversion(p ast.Pattern) (ast.Pattern, error) {
+	return a.patternAlphaConversion(p, map[string]bool{})
+}
+
+func (a *AlphaEnvironment) patternAlphaConversion(p ast.Pattern, bound map[string]bool) (ast.Pattern, error) {
+	switch vp := p.(type) {
+	case *ast.WildcardPattern, *ast.LiteralPattern, *ast.EmptyListPattern:
+		return vp, nil
+	case *ast.VariablePattern:
+		name := vp.Identifier.Value
+		if _, ok := a.lookupConstructor(name); ok {
+			// A constructor without arguments
+			return a.patternAlphaConversion(&ast.ConstructorPattern{
+				Token:       vp.Token,
+				Constructor: &ast.IdentifierExpr{Token: vp.Token, Identifier: vp.Identifier},
+			}, bound)
+		}
+		if bound[name] {
+			return nil, &AlphaConversionError{
+				Msg: fmt.Sprintf("variable %s is bound more than once in pattern", name),
+			}
+		}
+		bound[name] = true
+		return &ast.VariablePattern{Token: vp.Token, Identifier: a.IdentifierAlphaConversion(vp.Identifier)}, nil
+	case *ast.ConstructorPattern:
+		name := vp.Constructor.Identifier.Value
+		def, ok := a.lookupConstructor(name)
+		if !ok {
+			return nil, &AlphaConversionError{Msg: fmt.Sprintf("%s is not a constructor", name)}
+		}
+		uid, err := a.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		npat := &ast.ConstructorPattern{
+			Token:       vp.Token,
+			Constructor: &ast.IdentifierExpr{Token: vp.Constructor.Token, Identifier: uid},
+			Definition:  def,
+		}
+		for _, arg := range vp.Args {
+			narg, err := a.patternAlphaConversion(arg, bound)
+			if err != nil {
+				return nil, err
+			}
+			npat.Args = append(npat.Args, narg)
+		}
+		return npat, nil
+	case *ast.RecordPattern:
+		npat := &ast.RecordPattern{Token: vp.Token, Fields: make([]*ast.RecordFieldPattern, 0, len(vp.Fields))}
+		for _, f := range vp.Fields {
+			nfp, err := a.patternAlphaConversion(f.Pattern, bound)
+			if err != nil {
+				return nil, err
+			}
+			npat.Fields = append(npat.Fields, &ast.RecordFieldPattern{Token: f.Token, Label: f.Label, Pattern: nfp})
+		}
+		return npat, nil
+	case *ast.TuplePattern:
+		npat := &ast.TuplePattern{Token: vp.Token, Elements: make([]ast.Pattern, 0, len(vp.Elements))}
+		for _, e := range vp.Elements {
+			nelem, err := a.patternAlphaConversion(e, bound)
+			if err != nil {
+				return nil, err
+			}
+			npat.Elements = append(npat.Elements, nelem)
+		}
+		return npat, nil
+	case *ast.ConsPattern:
+		nhead, err := a.patternAlphaConversion(vp.Head, bound)
+		if err != nil {
+			return nil, err
+		}
+		ntail, err := a.patternAlphaConversion(vp.Tail, bound)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ConsPattern{Token: vp.Token, Head: nhead, Tail: ntail}, nil
+	default:
+		panic(fmt.Sprintf("alpha conversion not implemented yet for pattern of type %T", vp))
+	}
+}
+
+// Apply α-conversion to a top level statement. Names bound by let
+// statements are added to the environment, so that they are visible
+// in the following statements. The values of the assignments of a
+// statement are converted before binding any of its names.
+func (a *AlphaEnvironment) StatementAlphaConversion(stmt ast.Statement) (ast.Statement, error) {
+	switch vs := stmt.(type) {
+	case *ast.ExpressionStatement:
+		nexp, err := a.ExpressionAlphaConversion(vs.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ExpressionStatement{Token: vs.Token, Expression: nexp}, nil
+	case *ast.LetStatement:
+		nasss, err := a.assignmentsAlphaConversion(a, vs.Assignments)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.LetStatement{Token: vs.Token, Assignments: nasss}, nil
+	case *ast.TypeStatement:
+		// The name of the type is bound before converting the
+		// constructors, so that data types can be recursive
+		nstmt := &ast.TypeStatement{Token: vs.Token, Name: a.DataTypeAlphaConversion(vs.Name)}
+		na := NewAlphaEnvironmentExtension(a)
+		for _, param := range vs.Params {
+			nstmt.Params = append(nstmt.Params, na.TypeVarAlphaConversion(param))
+		}
+		for _, ctor := range vs.Constructors {
+			nctor := &ast.ConstructorDecl{Token: ctor.Token}
+			for _, arg := range ctor.Args {
+				nctor.Args = append(nctor.Args, na.TypeAlphaConversion(arg))
+			}
+			nctor.Name = &ast.IdentifierExpr{
+				Token:      ctor.Name.Token,
+				Identifier: a.IdentifierAlphaConversion(ctor.Name.Identifier),
+			}
+			nstmt.Constructors = append(nstmt.Constructors, nctor)
+			a.constructors[nctor.Name.Identifier.Value] = nstmt
+		}
+		return nstmt, nil
+	case *ast.AliasStatement:
+		// The name of the alias is bound after converting
+		// the type, aliases cannot be recursive. Uses of the
+		// alias are converted like the ones of a data type
+		nstmt := &ast.AliasStatement{Token: vs.Token}
+		na := NewAlphaEnvironmentExtension(a)
+		for _, param := range vs.Params {
+			nstmt.Params = append(nstmt.Params, na.TypeVarAlphaConversion(param))
+		}
+		nstmt.Type = na.TypeAlphaConversion(vs.Type)
+		nstmt.Name = a.DataTypeAlphaConversion(vs.Name)
+		return nstmt, nil
+	case *ast.NewtypeStatement:
+		// The new type is a type name like the builtin ones, the
+		// wrapping and unwrapping functions are bound as values
+		nstmt := &ast.NewtypeStatement{Token: vs.Token, Type: a.TypeAlphaConversion(vs.Type)}
+		nstmt.Name = a.TypeVarAlphaConversion(vs.Name)
+		nstmt.Wrap = &ast.IdentifierExpr{
+			Token:      vs.Wrap.Token,
+			Identifier: a.IdentifierAlphaConversion(vs.Wrap.Identifier),
+		}
+		nstmt.Unwrap = &ast.IdentifierExpr{
+			Token:      vs.Unwrap.Token,
+			Identifier: a.IdentifierAlphaConversion(vs.Unwrap.Identifier),
+		}
+		return nstmt, nil
+	case *ast.PolicyStatement:
+		// Events and states of policies are not bound names
+		return vs, nil
+	case *ast.InterfaceStatement:
+		// The methods are bound like the names of a let statement,
+		// the type variable is visible in their types
+		nstmt := &ast.InterfaceStatement{Token: vs.Token, Name: vs.Name}
+		na := NewAlphaEnvironmentExtension(a)
+		nstmt.Param = na.TypeVarAlphaConversion(vs.Param)
+		for _, m := range vs.Methods {
+			nstmt.Methods = append(nstmt.Methods, &ast.MethodDecl{
+				Token: m.Token,
+				Name: &ast.IdentifierExpr{
+					Token:      m.Name.Token,
+					Identifier: a.IdentifierAlphaConversion(m.Name.Identifier),
+				},
+				Type: na.TypeAlphaConversion(m.Type),
+			})
+		}
+		return nstmt, nil
+	case *ast.InstanceStatement:
+		// The names of the methods are labels of the dictionary
+		// of the instance, that is bound to a new name
+		nstmt := &ast.InstanceStatement{
+			Token:     vs.Token,
+			Interface: vs.Interface,
+			Type:      a.TypeAlphaConversion(vs.Type),
+		}
+		for _, m := range vs.Methods {
+			nval, err := a.ExpressionAlphaConversion(m.Value)
+			if err != nil {
+				return nil, err
+			}
+			nstmt.Methods = append(nstmt.Methods, &ast.Assignment{Token: m.Token, Name: m.Name, Value: nval})
+		}
+		nstmt.Identifier = ast.GenUID(vs.Interface + "(" + nstmt.Type.String() + ")")
+		return nstmt, nil
+	default:
+		panic(fmt.Sprintf("alpha conversion not implemented yet for statement of type %T", vs))
+	}
+}
+
 // TODO include primitives
 var default_alpha_environment = NewAlphaEnvironment()
 
-// // Apply α-conversion on a program
-// func ProgramAlphaConversion(p *ast.Program) (*ast.Program, error) {
-// 	np := &ast.Program{}
-// 	np.Statements = make([]ast.Statement, 0)
+// Apply α-conversion on a program
+func ProgramAlphaConversion(p *ast.Program) (*ast.Program, error) {
+	env := NewAlphaEnvironment()
+	return env.ProgramAlphaConversion(p)
+}
 
-// 	env := NewAlphaEnvironment()
+// Apply α-conversion on the statements of a program, in the given environment
+func (a *AlphaEnvironment) ProgramAlphaConversion(p *ast.Program) (*ast.Program, error) {
+	np := &ast.Program{Package: p.Package}
+	np.Statements = make([]ast.Statement, 0, len(p.Statements))
 
-// 	for _, stmt := range p.Statements {
-// 		newstmt, err := env.StatementAlphaConversion(stmt)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		np.Statements = append(np.Statements, newstmt)
-// 	}
+	for _, stmt := range p.Statements {
+		newstmt, err := a.StatementAlphaConversion(stmt)
+		if err != nil {
+			return nil, err
+		}
+		np.Statements = append(np.Statements, newstmt)
+	}
 
-// 	return np, nil
-// }
+	return np, nil
+}
 
-func ProgramAlphaConversion(p ast.Expression) (*ast.Expression, error) {
+// Apply α-conversion on a single expression
+func ExpressionAlphaConversion(p ast.Expression) (*ast.Expression, error) {
 	env := NewAlphaEnvironment()
 	np, err := env.ExpressionAlphaConversion(p)
 	if err != nil {
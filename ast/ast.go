@@ -6,6 +6,8 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/0x0f0f0f/gobba-golang/token"
+	"strings"
+	"unicode"
 )
 
 type Node interface {
@@ -15,63 +17,97 @@ type Node interface {
 
 // These interfaces contain dummy method
 // but exist so they can be identified correctly by the go compiler
-// type Statement interface {
-// 	Node
-// 	statementNode()
-// }
-//
+type Statement interface {
+	Node
+	statementNode()
+}
+
 type Expression interface {
 	Node
 	expressionNode()
 }
 
-// type Program struct {
-// 	Statements []Statement
-// }
+// The root node of a parsed source. Package is nil for
+// sources without a package declaration, such as REPL inputs
+type Program struct {
+	Package    *PackageStatement
+	Statements []Statement
+}
 
 // Get the first literal from a program. This is needed
 // so that the Program struct implements the Node interface
-// func (p *Program) TokenLiteral() string {
-// 	if len(p.Statements) > 0 {
-// 		return p.Statements[0].TokenLiteral()
-// 	} else {
-// 		return ""
-// 	}
-// }
-// func (p *Program) String() string {
-// 	var b bytes.Buffer
-
-// 	for _, s := range p.Statements {
-// 		b.WriteString(s.String())
-// 	}
-
-// 	return b.String()
-// }
+func (p *Program) TokenLiteral() string {
+	if p.Package != nil {
+		return p.Package.TokenLiteral()
+	}
+	if len(p.Statements) > 0 {
+		return p.Statements[0].TokenLiteral()
+	} else {
+		return ""
+	}
+}
+func (p *Program) String() string {
+	var b bytes.Buffer
+
+	if p.Package != nil {
+		b.WriteString(p.Package.String())
+	}
+
+	for _, s := range p.Statements {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(s.String())
+	}
+
+	return b.String()
+}
 
 // ======================================================================
 // AST nodes types definitions
 // ======================================================================
 
-// Contains a list of assignments without a body
-// type ExpressionStatement struct {
-// 	Token      token.Token
-// 	Expression Expression
-// }
-
-// func (es *ExpressionStatement) statementNode()       {}
-// func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
-// func (es *ExpressionStatement) String() string {
-// 	if es.Expression != nil {
-// 		return es.Expression.String() + ";"
-// 	}
-// 	return ""
-// }
-
-// Represents a symbol-value pair in the AST.
+// Represents `package name;`. The effects of the statements of a
+// package are restricted when Restriction is not nil, as in
+// `package name deny io;`
+type PackageStatement struct {
+	Token       token.Token
+	Name        *IdentifierExpr
+	Restriction *EffectRestriction
+}
+
+func (ps *PackageStatement) statementNode()       {}
+func (ps *PackageStatement) TokenLiteral() string { return ps.Token.Literal }
+func (ps *PackageStatement) String() string {
+	if ps.Restriction != nil {
+		return ps.TokenLiteral() + " " + ps.Name.Identifier.Value + " " + ps.Restriction.String() + ";"
+	}
+	return ps.TokenLiteral() + " " + ps.Name.Identifier.Value + ";"
+}
+
+// Contains an expression evaluated at the top level
+type ExpressionStatement struct {
+	Token      token.Token
+	Expression Expression
+}
+
+func (es *ExpressionStatement) statementNode()       {}
+func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) String() string {
+	if es.Expression != nil {
+		return es.Expression.String() + ";"
+	}
+	return ""
+}
+
+// Represents a symbol-value pair in the AST. When Pattern is not
+// nil, the value is taken apart by the pattern and Name is nil,
+// as in `let (x, y) = v`
 type Assignment struct {
-	Token token.Token
-	Name  *IdentifierExpr
-	Value Expression
+	Token   token.Token
+	Name    *IdentifierExpr
+	Pattern Pattern
+	Value   Expression
 }
 
 func (a *Assignment) expressionNode()      {}
@@ -79,12 +115,45 @@ func (a *Assignment) TokenLiteral() string { return a.Token.Literal }
 func (a *Assignment) String() string {
 	var b bytes.Buffer
 
-	b.WriteString(a.Name.String() + " = ")
+	if a.Pattern != nil {
+		b.WriteString(a.Pattern.String() + " = ")
+	} else {
+		b.WriteString(a.Name.String() + " = ")
+	}
 	b.WriteString(a.Value.String())
 
 	return b.String()
 }
 
+// Get the fixed point of the value of an assignment binding a
+// recursive function, possibly with restricted effects. Returns nil
+// if the value is not a recursive function
+func (a *Assignment) RecursiveFunction() *FixExpr {
+	v := a.Value
+	if e, ok := v.(*EffectExpr); ok {
+		v = e.Body
+	}
+	fix, _ := v.(*FixExpr)
+	return fix
+}
+
+// Get the assignments of a list that bind mutually recursive
+// functions: the names of the recursive functions of a list of
+// assignments joined by `and` are bound in all their values.
+// Returns nil if there are less than two recursive functions
+func RecursiveGroup(asss []*Assignment) []*Assignment {
+	group := []*Assignment{}
+	for _, ass := range asss {
+		if ass.Pattern == nil && ass.RecursiveFunction() != nil {
+			group = append(group, ass)
+		}
+	}
+	if len(group) < 2 {
+		return nil
+	}
+	return group
+}
+
 // Contains a list of assignments without a body
 type LetStatement struct {
 	Token       token.Token
@@ -108,11 +177,255 @@ func (ls *LetStatement) String() string {
 	return b.String()
 }
 
-// Represents `let var = val in body`
-type LetExpression struct {
+// Represents a constructor of an algebraic data type, with
+// the types of its arguments
+type ConstructorDecl struct {
+	Token token.Token
+	Name  *IdentifierExpr
+	Args  []TypeValue
+}
+
+func (cd *ConstructorDecl) String() string {
+	if len(cd.Args) == 0 {
+		return cd.Name.String()
+	}
+	args := make([]string, len(cd.Args))
+	for i, a := range cd.Args {
+		args[i] = a.String()
+	}
+	return cd.Name.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Represents the declaration of an algebraic data type,
+// `type tree(a) = Leaf | Node(tree(a), a, tree(a));`
+type TypeStatement struct {
+	Token        token.Token
+	Name         UniqueIdentifier
+	Params       []UniqueIdentifier
+	Constructors []*ConstructorDecl
+}
+
+func (ts *TypeStatement) statementNode()       {}
+func (ts *TypeStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TypeStatement) String() string {
+	var b bytes.Buffer
+
+	b.WriteString(ts.TokenLiteral() + " " + ts.Name.String() + paramsString(ts.Params))
+	b.WriteString(" =")
+	for i, c := range ts.Constructors {
+		if i > 0 {
+			b.WriteString(" |")
+		}
+		b.WriteString(" " + c.String())
+	}
+	b.WriteString(";")
+
+	return b.String()
+}
+
+// Print the parameters of a type declaration, if any
+func paramsString(params []UniqueIdentifier) string {
+	if len(params) == 0 {
+		return ""
+	}
+	ps := make([]string, len(params))
+	for i, p := range params {
+		ps[i] = p.String()
+	}
+	return "(" + strings.Join(ps, ", ") + ")"
+}
+
+// Represents the declaration of a type alias, `type vec = (float, float);`.
+// The alias is expanded by the typechecker, wherever it is used
+type AliasStatement struct {
+	Token  token.Token
+	Name   UniqueIdentifier
+	Params []UniqueIdentifier
+	Type   TypeValue
+}
+
+func (as *AliasStatement) statementNode()       {}
+func (as *AliasStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AliasStatement) String() string {
+	t := as.Type.String()
+	if unicode.IsUpper([]rune(t)[0]) {
+		// Without parens, the alias would be parsed as
+		// a data type with a single constructor
+		t = "(" + t + ")"
+	}
+	return as.TokenLiteral() + " " + as.Name.String() + paramsString(as.Params) + " = " + t + ";"
+}
+
+// Represents the declaration of a nominal type, `newtype Meters = float;`.
+// The new type is distinct from the one it wraps. Wrap and Unwrap
+// are the functions converting between the two, Meters and unMeters
+type NewtypeStatement struct {
+	Token  token.Token
+	Name   UniqueIdentifier
+	Type   TypeValue
+	Wrap   *IdentifierExpr
+	Unwrap *IdentifierExpr
+}
+
+func (ns *NewtypeStatement) statementNode()       {}
+func (ns *NewtypeStatement) TokenLiteral() string { return ns.Token.Literal }
+func (ns *NewtypeStatement) String() string {
+	return ns.TokenLiteral() + " " + ns.Name.String() + " = " + ns.Type.String() + ";"
+}
+
+// A transition of a usage policy, `q0 -open-> q1`
+type PolicyTransition struct {
+	Token token.Token
+	From  string
+	Event string
+	To    string
+}
+
+func (pt *PolicyTransition) String() string {
+	return pt.From + " -" + pt.Event + "-> " + pt.To
+}
+
+// Represents the declaration of a usage policy, a finite automaton
+// over the events performed by a program. The program must never
+// reach an offending state:
+// `policy once { init q0; q0 -open-> q1; q1 -open-> bad; offending bad; };`
+type PolicyStatement struct {
+	Token       token.Token
+	Name        string
+	Init        string
+	Transitions []*PolicyTransition
+	Offending   []string
+}
+
+func (ps *PolicyStatement) statementNode()       {}
+func (ps *PolicyStatement) TokenLiteral() string { return ps.Token.Literal }
+func (ps *PolicyStatement) String() string {
+	var b bytes.Buffer
+
+	b.WriteString(ps.TokenLiteral() + " " + ps.Name + " { init " + ps.Init + ";")
+	for _, t := range ps.Transitions {
+		b.WriteString(" " + t.String() + ";")
+	}
+	if len(ps.Offending) > 0 {
+		b.WriteString(" offending " + strings.Join(ps.Offending, ", ") + ";")
+	}
+	b.WriteString(" };")
+
+	return b.String()
+}
+
+// The state reached from a state with an event. Events without a
+// transition leave the state unchanged
+func (ps *PolicyStatement) Step(state, event string) string {
+	for _, t := range ps.Transitions {
+		if t.From == state && t.Event == event {
+			return t.To
+		}
+	}
+	return state
+}
+
+// Returns true if a state of the policy is offending
+func (ps *PolicyStatement) IsOffending(state string) bool {
+	for _, s := range ps.Offending {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// The declaration of a method of an interface, `show : a -> string`
+type MethodDecl struct {
+	Token token.Token
+	Name  *IdentifierExpr
+	Type  TypeValue
+}
+
+func (md *MethodDecl) String() string {
+	return md.Name.String() + " " + token.ANNOT + " " + md.Type.String()
+}
+
+// Represents the declaration of an interface, with the methods
+// of the types implementing it,
+// `interface Show(a) { show : a -> string; };`
+type InterfaceStatement struct {
+	Token   token.Token
+	Name    string
+	Param   UniqueIdentifier
+	Methods []*MethodDecl
+}
+
+func (is *InterfaceStatement) statementNode()       {}
+func (is *InterfaceStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *InterfaceStatement) String() string {
+	var b bytes.Buffer
+
+	b.WriteString(is.TokenLiteral() + " " + is.Name + "(" + is.Param.String() + ") {")
+	for _, m := range is.Methods {
+		b.WriteString(" " + m.String() + ";")
+	}
+	b.WriteString(" };")
+
+	return b.String()
+}
+
+// Get a method of the interface by name, nil if there is none
+func (is *InterfaceStatement) Method(name string) *MethodDecl {
+	for _, m := range is.Methods {
+		if m.Name.Identifier.Value == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// The type of a method, polymorphic in the types implementing
+// the interface, such as ∀(a: Show).a -> string
+func (is *InterfaceStatement) MethodType(m *MethodDecl) TypeValue {
+	return &ForAllType{Identifier: is.Param, Type: m.Type, Constraints: []string{is.Name}}
+}
+
+// Represents the declaration of an instance of an interface for a
+// type, `instance Show(bool) { show = fun(b) {if b then "y" else "n"}; };`.
+// The methods are the fields of the dictionary of the instance, that is
+// bound to Identifier by the α-conversion and passed to the values using it
+type InstanceStatement struct {
 	Token      token.Token
-	Assignment Assignment
-	Body       Expression
+	Interface  string
+	Type       TypeValue
+	Methods    []*Assignment
+	Identifier UniqueIdentifier
+}
+
+func (is *InstanceStatement) statementNode()       {}
+func (is *InstanceStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *InstanceStatement) String() string {
+	var b bytes.Buffer
+
+	b.WriteString(is.TokenLiteral() + " " + is.Interface + "(" + is.Type.String() + ") {")
+	for _, m := range is.Methods {
+		b.WriteString(" " + m.String() + ";")
+	}
+	b.WriteString(" };")
+
+	return b.String()
+}
+
+// The dictionary of the instance, a record of its methods
+func (is *InstanceStatement) Dictionary() *RecordLiteral {
+	dict := &RecordLiteral{Token: is.Token, Fields: make([]*RecordField, len(is.Methods))}
+	for i, m := range is.Methods {
+		dict.Fields[i] = &RecordField{Token: m.Token, Label: m.Name.Identifier.Value, Value: m.Value}
+	}
+	return dict
+}
+
+// Represents `let x = v1 and y = v2 in body`
+type LetExpression struct {
+	Token       token.Token
+	Assignments []*Assignment
+	Body        Expression
 }
 
 func (le *LetExpression) expressionNode()      {}
@@ -121,7 +434,12 @@ func (le *LetExpression) String() string {
 	var b bytes.Buffer
 
 	b.WriteString("(let ")
-	b.WriteString(le.Assignment.String())
+	for i, ass := range le.Assignments {
+		b.WriteString(ass.String())
+		if i < len(le.Assignments)-1 {
+			b.WriteString(" and ")
+		}
+	}
 	b.WriteString("; ")
 	b.WriteString(le.Body.String())
 	b.WriteString(")")
@@ -157,7 +475,9 @@ func (i *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token token.Token
 	Param *IdentifierExpr
-	Body  Expression
+	// The type annotation of the parameter, nil if it has none
+	ParamType TypeValue
+	Body      Expression
 }
 
 func (f *FunctionLiteral) expressionNode()      {}
@@ -167,6 +487,9 @@ func (f *FunctionLiteral) String() string {
 
 	b.WriteString("(λ ")
 	b.WriteString(f.Param.String())
+	if f.ParamType != nil {
+		b.WriteString(": " + f.ParamType.String())
+	}
 	b.WriteString(" . ")
 	b.WriteString(f.Body.String())
 	b.WriteString(")")
@@ -199,10 +522,22 @@ type PrefixExpression struct {
 	Token    token.Token
 	Operator string
 	Right    Expression
+	// The strict operator chosen by the typechecker for the
+	// negation of a number, such as -. in -(1.5)
+	Instance string
 }
 
 func (p *PrefixExpression) expressionNode()      {}
 func (p *PrefixExpression) TokenLiteral() string { return p.Token.Literal }
+
+// Get the operator that is applied at runtime: the instance of an
+// overloaded operator if it was resolved, the operator otherwise
+func (p *PrefixExpression) ResolvedOperator() string {
+	if p.Instance != "" {
+		return p.Instance
+	}
+	return p.Operator
+}
 func (p *PrefixExpression) String() string {
 	var b bytes.Buffer
 	b.WriteString("(")
@@ -212,16 +547,44 @@ func (p *PrefixExpression) String() string {
 	return b.String()
 }
 
+// Represents a postfix expression, such as the transpose m'
+type PostfixExpression struct {
+	Token    token.Token
+	Left     Expression
+	Operator string
+}
+
+func (p *PostfixExpression) expressionNode()      {}
+func (p *PostfixExpression) TokenLiteral() string { return p.Token.Literal }
+func (p *PostfixExpression) String() string {
+	return "(" + p.Left.String() + p.Operator + ")"
+}
+
 // Represents an infix expression
 type InfixExpression struct {
 	Token    token.Token
 	Left     Expression
 	Operator string
 	Right    Expression
+	// The strict operator chosen by the typechecker for an
+	// overloaded arithmetical operator, such as +. in 1 + 2.5
+	Instance string
+	// The shape of the values compared by an equality, set by
+	// the typechecker in elaborated programs
+	Shape *Shape
 }
 
 func (p *InfixExpression) expressionNode()      {}
 func (p *InfixExpression) TokenLiteral() string { return p.Token.Literal }
+
+// Get the operator that is applied at runtime: the instance of an
+// overloaded operator if it was resolved, the operator otherwise
+func (p *InfixExpression) ResolvedOperator() string {
+	if p.Instance != "" {
+		return p.Instance
+	}
+	return p.Operator
+}
 func (p *InfixExpression) String() string {
 	var b bytes.Buffer
 	b.WriteString("(")
@@ -260,6 +623,24 @@ func (i *AnnotExpr) String() string {
 	return "(" + i.Body.String() + ": " + i.Type.String() + ")"
 }
 
+// Represents the restriction of a value to the fields of the record
+// types of a shape, where the value is used as a value of a supertype
+// of its type with fewer fields, as in ({x = 1, y = 2} : {x: int}),
+// and the conversion of its numbers where they are used as numbers of
+// a larger type, as in (1 : float). Restrictions are only found in
+// elaborated programs
+type RestrictExpr struct {
+	Token token.Token
+	Body  Expression
+	Shape *Shape
+}
+
+func (i *RestrictExpr) expressionNode()      {}
+func (i *RestrictExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *RestrictExpr) String() string {
+	return i.Body.String()
+}
+
 // Represents a fixed point combinator
 type FixExpr struct {
 	Token token.Token
@@ -273,6 +654,173 @@ func (i *FixExpr) String() string {
 	return "(fix" + i.Param.String() + " . " + i.Body.String() + ")"
 }
 
+// Represents the effects allowed in an expression: either the listed
+// effects, as in `allow io, fail`, or all the effects but the
+// listed ones, as in `deny io`. `pure` allows no effect
+type EffectRestriction struct {
+	Token   token.Token
+	Deny    bool
+	Effects []string
+}
+
+// Returns true if an effect label is allowed by the restriction
+func (r *EffectRestriction) Allows(label string) bool {
+	for _, e := range r.Effects {
+		if e == label {
+			return !r.Deny
+		}
+	}
+	return r.Deny
+}
+
+func (r *EffectRestriction) String() string {
+	if r.Token.Type == token.PURE {
+		return r.Token.Literal
+	}
+	return r.Token.Literal + " " + strings.Join(r.Effects, ", ")
+}
+
+// Represents an expression whose effects are restricted,
+// `allow io in e`, `deny io in e` or `pure e`
+type EffectExpr struct {
+	Token       token.Token
+	Restriction *EffectRestriction
+	Body        Expression
+}
+
+func (i *EffectExpr) expressionNode()      {}
+func (i *EffectExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *EffectExpr) String() string {
+	if i.Restriction.Token.Type == token.PURE {
+		return "(" + i.Restriction.String() + " " + i.Body.String() + ")"
+	}
+	return "(" + i.Restriction.String() + " in " + i.Body.String() + ")"
+}
+
+// Represents the event of a usage policy, `event open`.
+// It performs the io effect and evaluates to unit
+type EventExpr struct {
+	Token token.Token
+	Name  string
+}
+
+func (i *EventExpr) expressionNode()      {}
+func (i *EventExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *EventExpr) String() string {
+	return "(" + i.TokenLiteral() + " " + i.Name + ")"
+}
+
+// Represents the projection of the field of a record, r.x
+type AccessExpr struct {
+	Token  token.Token
+	Record Expression
+	Field  string
+}
+
+func (i *AccessExpr) expressionNode()      {}
+func (i *AccessExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *AccessExpr) String() string {
+	return "(" + i.Record.String() + " . " + i.Field + ")"
+}
+
+// ======================================================================
+// Composite literals
+// ======================================================================
+
+// Represents a labeled field of a record literal
+type RecordField struct {
+	Token token.Token
+	Label string
+	Value Expression
+}
+
+func (f *RecordField) String() string {
+	return f.Label + " = " + f.Value.String()
+}
+
+// Represents a record literal {x = 1, y = 2}. Fields are kept in
+// the order in which they are written
+type RecordLiteral struct {
+	Token  token.Token
+	Fields []*RecordField
+}
+
+func (r *RecordLiteral) expressionNode()      {}
+func (r *RecordLiteral) TokenLiteral() string { return r.Token.Literal }
+func (r *RecordLiteral) String() string {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, f := range r.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f.String())
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Represents a tuple literal (1, true). Tuples have
+// at least two elements
+type TupleLiteral struct {
+	Token    token.Token
+	Elements []Expression
+}
+
+func (t *TupleLiteral) expressionNode()      {}
+func (t *TupleLiteral) TokenLiteral() string { return t.Token.Literal }
+func (t *TupleLiteral) String() string {
+	elems := make([]string, len(t.Elements))
+	for i, e := range t.Elements {
+		elems[i] = e.String()
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+// Represents a list literal [1, 2, 3]. All the elements
+// of a list have the same type
+type ListLiteral struct {
+	Token    token.Token
+	Elements []Expression
+}
+
+func (l *ListLiteral) expressionNode()      {}
+func (l *ListLiteral) TokenLiteral() string { return l.Token.Literal }
+func (l *ListLiteral) String() string {
+	elems := make([]string, len(l.Elements))
+	for i, e := range l.Elements {
+		elems[i] = e.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// A matrix literal [|1, 2; 3, 4|]. Rows are separated by semicolons
+// and have the same number of elements. Sparse matrix literals are
+// prefixed by the sparse keyword
+type MatrixLiteral struct {
+	Token  token.Token
+	Sparse bool
+	Rows   [][]Expression
+}
+
+func (m *MatrixLiteral) expressionNode()      {}
+func (m *MatrixLiteral) TokenLiteral() string { return m.Token.Literal }
+func (m *MatrixLiteral) String() string {
+	rows := make([]string, len(m.Rows))
+	for i, row := range m.Rows {
+		elems := make([]string, len(row))
+		for j, e := range row {
+			elems[j] = e.String()
+		}
+		rows[i] = strings.Join(elems, ", ")
+	}
+	s := "[|" + strings.Join(rows, "; ") + "|]"
+	if m.Sparse {
+		return token.SPARSE + " " + s
+	}
+	return s
+}
+
 // ======================================================================
 // Terminal values: literals
 // ======================================================================
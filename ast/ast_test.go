@@ -2,10 +2,40 @@ package ast
 
 import (
 	"github.com/0x0f0f0f/gobba-golang/token"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
-// TODO assert AST
-// func TestString(t *testing.T) {
-// program
-// }
+func TestString(t *testing.T) {
+	x := &IdentifierExpr{
+		Token:      token.Token{Type: token.IDENT, Literal: "x"},
+		Identifier: UniqueIdentifier{Value: "x"},
+	}
+	program := &ApplyExpr{
+		Function: &FunctionLiteral{
+			Token: token.Token{Type: token.LAMBDA, Literal: "fun"},
+			Param: x,
+			Body: &InfixExpression{
+				Token:    token.Token{Type: token.PLUS, Literal: "+"},
+				Left:     x,
+				Operator: "+",
+				Right:    &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+			},
+		},
+		Arg: &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+	}
+
+	assert.Equal(t, "(λ x . (x + 1))(2)", program.String())
+}
+
+func TestFancyNames(t *testing.T) {
+	occ := map[UniqueIdentifier]int{}
+	names := []string{}
+	for i := 0; i < 28; i++ {
+		names = append(names, genFancy(occ, GenUID("t")))
+	}
+	assert.Equal(t, "a", names[0])
+	assert.Equal(t, "z", names[25])
+	assert.Equal(t, "a1", names[26])
+	assert.Equal(t, "b1", names[27])
+}
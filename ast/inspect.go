@@ -0,0 +1,208 @@
+package ast
+
+// Traverse an expression in depth-first order, calling f on the
+// expression and then on each of its subexpressions. The children of
+// an expression are not visited when f returns false
+func Inspect(exp Expression, f func(Expression) bool) {
+	if exp == nil || !f(exp) {
+		return
+	}
+
+	switch ve := exp.(type) {
+	case *PrefixExpression:
+		Inspect(ve.Right, f)
+	case *PostfixExpression:
+		Inspect(ve.Left, f)
+	case *InfixExpression:
+		Inspect(ve.Left, f)
+		Inspect(ve.Right, f)
+	case *IfExpression:
+		Inspect(ve.Condition, f)
+		Inspect(ve.Consequence, f)
+		Inspect(ve.Alternative, f)
+	case *FunctionLiteral:
+		Inspect(ve.Body, f)
+	case *FixExpr:
+		Inspect(ve.Body, f)
+	case *ApplyExpr:
+		Inspect(ve.Function, f)
+		Inspect(ve.Arg, f)
+	case *LetExpression:
+		for _, ass := range ve.Assignments {
+			Inspect(ass.Value, f)
+		}
+		Inspect(ve.Body, f)
+	case *AnnotExpr:
+		Inspect(ve.Body, f)
+	case *RestrictExpr:
+		Inspect(ve.Body, f)
+	case *EffectExpr:
+		Inspect(ve.Body, f)
+	case *AccessExpr:
+		Inspect(ve.Record, f)
+	case *RecordLiteral:
+		for _, field := range ve.Fields {
+			Inspect(field.Value, f)
+		}
+	case *TupleLiteral:
+		for _, e := range ve.Elements {
+			Inspect(e, f)
+		}
+	case *ListLiteral:
+		for _, e := range ve.Elements {
+			Inspect(e, f)
+		}
+	case *MatrixLiteral:
+		for _, row := range ve.Rows {
+			for _, e := range row {
+				Inspect(e, f)
+			}
+		}
+	case *MatchExpr:
+		Inspect(ve.Scrutinee, f)
+		for _, arm := range ve.Arms {
+			Inspect(arm.Body, f)
+		}
+	}
+}
+
+// Copy an expression. The subexpressions of the expression are copied
+// first, then f is called on the expression and on its copy, and its
+// result is the copy. The original expression identifies the copied one
+func Rewrite(exp Expression, f func(old, copy Expression) Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+
+	var res Expression
+	switch ve := exp.(type) {
+	case *PrefixExpression:
+		n := *ve
+		n.Right = Rewrite(ve.Right, f)
+		res = &n
+	case *PostfixExpression:
+		n := *ve
+		n.Left = Rewrite(ve.Left, f)
+		res = &n
+	case *InfixExpression:
+		n := *ve
+		n.Left = Rewrite(ve.Left, f)
+		n.Right = Rewrite(ve.Right, f)
+		res = &n
+	case *IfExpression:
+		n := *ve
+		n.Condition = Rewrite(ve.Condition, f)
+		n.Consequence = Rewrite(ve.Consequence, f)
+		n.Alternative = Rewrite(ve.Alternative, f)
+		res = &n
+	case *FunctionLiteral:
+		n := *ve
+		n.Body = Rewrite(ve.Body, f)
+		res = &n
+	case *FixExpr:
+		n := *ve
+		n.Body = Rewrite(ve.Body, f)
+		res = &n
+	case *ApplyExpr:
+		n := *ve
+		n.Function = Rewrite(ve.Function, f)
+		n.Arg = Rewrite(ve.Arg, f)
+		res = &n
+	case *LetExpression:
+		n := *ve
+		n.Assignments = RewriteAssignments(ve.Assignments, f)
+		n.Body = Rewrite(ve.Body, f)
+		res = &n
+	case *AnnotExpr:
+		n := *ve
+		n.Body = Rewrite(ve.Body, f)
+		res = &n
+	case *RestrictExpr:
+		n := *ve
+		n.Body = Rewrite(ve.Body, f)
+		res = &n
+	case *EffectExpr:
+		n := *ve
+		n.Body = Rewrite(ve.Body, f)
+		res = &n
+	case *AccessExpr:
+		n := *ve
+		n.Record = Rewrite(ve.Record, f)
+		res = &n
+	case *RecordLiteral:
+		n := *ve
+		n.Fields = make([]*RecordField, len(ve.Fields))
+		for i, field := range ve.Fields {
+			nf := *field
+			nf.Value = Rewrite(field.Value, f)
+			n.Fields[i] = &nf
+		}
+		res = &n
+	case *TupleLiteral:
+		n := *ve
+		n.Elements = rewriteAll(ve.Elements, f)
+		res = &n
+	case *ListLiteral:
+		n := *ve
+		n.Elements = rewriteAll(ve.Elements, f)
+		res = &n
+	case *MatrixLiteral:
+		n := *ve
+		n.Rows = make([][]Expression, len(ve.Rows))
+		for i, row := range ve.Rows {
+			n.Rows[i] = rewriteAll(row, f)
+		}
+		res = &n
+	case *MatchExpr:
+		n := *ve
+		n.Scrutinee = Rewrite(ve.Scrutinee, f)
+		n.Arms = make([]*MatchArm, len(ve.Arms))
+		for i, arm := range ve.Arms {
+			na := *arm
+			na.Body = Rewrite(arm.Body, f)
+			n.Arms[i] = &na
+		}
+		res = &n
+	default:
+		// Leaves are shared, f can replace them
+		res = exp
+	}
+	return f(exp, res)
+}
+
+// Copy a list of assignments, rewriting their values. See Rewrite
+func RewriteAssignments(asss []*Assignment, f func(old, copy Expression) Expression) []*Assignment {
+	res := make([]*Assignment, len(asss))
+	for i, ass := range asss {
+		nass := *ass
+		nass.Value = Rewrite(ass.Value, f)
+		res[i] = &nass
+	}
+	return res
+}
+
+func rewriteAll(exps []Expression, f func(old, copy Expression) Expression) []Expression {
+	res := make([]Expression, len(exps))
+	for i, e := range exps {
+		res[i] = Rewrite(e, f)
+	}
+	return res
+}
+
+// Traverse the expressions of the statements of a program. See Inspect
+func InspectProgram(p *Program, f func(Expression) bool) {
+	for _, stmt := range p.Statements {
+		switch vs := stmt.(type) {
+		case *ExpressionStatement:
+			Inspect(vs.Expression, f)
+		case *LetStatement:
+			for _, ass := range vs.Assignments {
+				Inspect(ass.Value, f)
+			}
+		case *InstanceStatement:
+			for _, ass := range vs.Methods {
+				Inspect(ass.Value, f)
+			}
+		}
+	}
+}
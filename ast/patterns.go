@@ -0,0 +1,203 @@
+package ast
+
+import (
+	"bytes"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"strings"
+)
+
+// This file contains the definitions of match expressions and
+// of the patterns that take values apart
+
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// Represents a match expression,
+// `match e with | p1 -> e1 | p2 -> e2`
+type MatchExpr struct {
+	Token     token.Token
+	Scrutinee Expression
+	Arms      []*MatchArm
+}
+
+func (m *MatchExpr) expressionNode()      {}
+func (m *MatchExpr) TokenLiteral() string { return m.Token.Literal }
+func (m *MatchExpr) String() string {
+	var b bytes.Buffer
+
+	b.WriteString("(match ")
+	b.WriteString(m.Scrutinee.String())
+	b.WriteString(" with")
+	for _, arm := range m.Arms {
+		b.WriteString(" | ")
+		b.WriteString(arm.String())
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
+
+// Represents an arm of a match expression, a pattern and
+// the expression evaluated when the pattern matches
+type MatchArm struct {
+	Token   token.Token
+	Pattern Pattern
+	Body    Expression
+}
+
+func (a *MatchArm) String() string {
+	return a.Pattern.String() + " -> " + a.Body.String()
+}
+
+// Represents the pattern _, matching any value
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (p *WildcardPattern) patternNode()         {}
+func (p *WildcardPattern) TokenLiteral() string { return p.Token.Literal }
+func (p *WildcardPattern) String() string       { return "_" }
+
+// Represents a pattern matching any value and binding it to a name
+type VariablePattern struct {
+	Token      token.Token
+	Identifier UniqueIdentifier
+}
+
+func (p *VariablePattern) patternNode()         {}
+func (p *VariablePattern) TokenLiteral() string { return p.Token.Literal }
+func (p *VariablePattern) String() string       { return p.Identifier.String() }
+
+// Represents a pattern matching the value of a literal. Value is
+// a literal expression, or a negated integer literal
+type LiteralPattern struct {
+	Token token.Token
+	Value Expression
+}
+
+func (p *LiteralPattern) patternNode()         {}
+func (p *LiteralPattern) TokenLiteral() string { return p.Token.Literal }
+func (p *LiteralPattern) String() string       { return p.Value.String() }
+
+// Represents a pattern matching the values built by a constructor
+// whose arguments match the patterns in Args. Definition is the
+// declaration of the data type of the constructor, and is resolved
+// by α-conversion
+type ConstructorPattern struct {
+	Token       token.Token
+	Constructor *IdentifierExpr
+	Args        []Pattern
+	Definition  *TypeStatement
+}
+
+func (p *ConstructorPattern) patternNode()         {}
+func (p *ConstructorPattern) TokenLiteral() string { return p.Token.Literal }
+func (p *ConstructorPattern) String() string {
+	if len(p.Args) == 0 {
+		return p.Constructor.String()
+	}
+	args := make([]string, len(p.Args))
+	for i, a := range p.Args {
+		args[i] = a.String()
+	}
+	return p.Constructor.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Represents a labeled field of a record pattern
+type RecordFieldPattern struct {
+	Token   token.Token
+	Label   string
+	Pattern Pattern
+}
+
+func (f *RecordFieldPattern) String() string {
+	return f.Label + " = " + f.Pattern.String()
+}
+
+// Represents a pattern {x = p1, y = p2} matching records whose fields
+// match the given patterns. Fields not in the pattern are ignored
+type RecordPattern struct {
+	Token  token.Token
+	Fields []*RecordFieldPattern
+}
+
+func (p *RecordPattern) patternNode()         {}
+func (p *RecordPattern) TokenLiteral() string { return p.Token.Literal }
+func (p *RecordPattern) String() string {
+	fields := make([]string, len(p.Fields))
+	for i, f := range p.Fields {
+		fields[i] = f.String()
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Represents a pattern (p1, p2) matching tuples whose
+// elements match the given patterns
+type TuplePattern struct {
+	Token    token.Token
+	Elements []Pattern
+}
+
+func (p *TuplePattern) patternNode()         {}
+func (p *TuplePattern) TokenLiteral() string { return p.Token.Literal }
+func (p *TuplePattern) String() string {
+	elems := make([]string, len(p.Elements))
+	for i, e := range p.Elements {
+		elems[i] = e.String()
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+// Represents the pattern [], matching the empty list
+type EmptyListPattern struct {
+	Token token.Token
+}
+
+func (p *EmptyListPattern) patternNode()         {}
+func (p *EmptyListPattern) TokenLiteral() string { return p.Token.Literal }
+func (p *EmptyListPattern) String() string       { return "[]" }
+
+// Represents a pattern h :: t matching the lists that are not
+// empty, whose head matches Head and whose tail matches Tail
+type ConsPattern struct {
+	Token token.Token
+	Head  Pattern
+	Tail  Pattern
+}
+
+func (p *ConsPattern) patternNode()         {}
+func (p *ConsPattern) TokenLiteral() string { return p.Token.Literal }
+func (p *ConsPattern) String() string {
+	return "(" + p.Head.String() + " :: " + p.Tail.String() + ")"
+}
+
+// Get the identifiers bound by a pattern, from left to right
+func PatternVariables(p Pattern) []UniqueIdentifier {
+	switch vp := p.(type) {
+	case *VariablePattern:
+		return []UniqueIdentifier{vp.Identifier}
+	case *ConstructorPattern:
+		ids := []UniqueIdentifier{}
+		for _, arg := range vp.Args {
+			ids = append(ids, PatternVariables(arg)...)
+		}
+		return ids
+	case *RecordPattern:
+		ids := []UniqueIdentifier{}
+		for _, f := range vp.Fields {
+			ids = append(ids, PatternVariables(f.Pattern)...)
+		}
+		return ids
+	case *TuplePattern:
+		ids := []UniqueIdentifier{}
+		for _, e := range vp.Elements {
+			ids = append(ids, PatternVariables(e)...)
+		}
+		return ids
+	case *ConsPattern:
+		return append(PatternVariables(vp.Head), PatternVariables(vp.Tail)...)
+	}
+	return []UniqueIdentifier{}
+}
@@ -1,5 +1,9 @@
 package ast
 
+import (
+	"sort"
+)
+
 // This file contains definitions of types
 // See https://github.com/chrisnevers/bidirectional-typechecking/blob/master/lib/ast/type.ml
 
@@ -26,46 +30,212 @@ type VariableType struct {
 	Identifier UniqueIdentifier
 }
 
-// Denoted with ∀α. A in the paper
+// Denoted with ∀α. A in the paper. ADDITION: the interfaces
+// that the type variable must implement, sorted by name
 type ForAllType struct {
+	Identifier  UniqueIdentifier
+	Type        TypeValue
+	Constraints []string
+}
+
+// ADDITION: recursive types μt. A, where the type variable t stands
+// for the recursive type itself. A recursive type is equivalent to its
+// unfolding A[μt. A/t], the typechecker folds and unfolds it as needed
+type MuType struct {
 	Identifier UniqueIdentifier
 	Type       TypeValue
 }
 
-// Denoted with A → B in the paper
+// Denoted with A → B in the paper. ADDITION: the effects
+// performed when applying the function, nil if it is pure
 type LambdaType struct {
 	Domain   TypeValue
 	Codomain TypeValue
+	Effects  *EffectRow
+}
+
+// ADDITION: record types {x: A, y: B}. The order of
+// the fields is not relevant
+type RecordType struct {
+	Fields map[string]TypeValue
+}
+
+// Get the labels of the fields of a record type, sorted alphabetically
+func (u *RecordType) Labels() []string {
+	labels := make([]string, 0, len(u.Fields))
+	for label := range u.Fields {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Build a record type with the same labels, applying
+// a function to the type of every field
+func (u *RecordType) Map(f func(TypeValue) TypeValue) *RecordType {
+	fields := make(map[string]TypeValue, len(u.Fields))
+	for label, t := range u.Fields {
+		fields[label] = f(t)
+	}
+	return &RecordType{Fields: fields}
+}
+
+// Build a data type with the same identifier, applying
+// a function to every type argument
+func (u *DataType) Map(f func(TypeValue) TypeValue) *DataType {
+	args := make([]TypeValue, len(u.Args))
+	for i, t := range u.Args {
+		args[i] = f(t)
+	}
+	return &DataType{Identifier: u.Identifier, Args: args}
 }
 
-// ADDITION: encoded with A U B
-// type UnionType struct {
-// 	Left  TypeValue
-// 	Right TypeValue
-// }
+// ADDITION: algebraic data types declared with a type statement,
+// applied to their type arguments, as in tree(int)
+type DataType struct {
+	Identifier UniqueIdentifier
+	Args       []TypeValue
+}
+
+// ADDITION: tuple types (A, B). Tuples have at least two elements
+type TupleType struct {
+	Elements []TypeValue
+}
+
+// Build a tuple type applying a function to the type of every element
+func (u *TupleType) Map(f func(TypeValue) TypeValue) *TupleType {
+	elems := make([]TypeValue, len(u.Elements))
+	for i, t := range u.Elements {
+		elems[i] = f(t)
+	}
+	return &TupleType{Elements: elems}
+}
+
+// ADDITION: list types []A. Lists are immutable, a list
+// type is covariant in the type of its elements
+type ListType struct {
+	Element TypeValue
+}
+
+// Build a list type applying a function to the type of the elements
+func (u *ListType) Map(f func(TypeValue) TypeValue) *ListType {
+	return &ListType{Element: f(u.Element)}
+}
+
+// ADDITION: matrix types. A matrix is dense or sparse and holds
+// either floating point (matrix) or complex numbers (cmatrix).
+// The number of rows and columns are dimensions, they are nil
+// when the size of the matrix is not known, as in matrix
+type MatrixType struct {
+	Sparse  bool
+	Complex bool
+	Rows    TypeValue
+	Cols    TypeValue
+}
+
+// Returns true if the number of rows and columns of a matrix is known
+func (u *MatrixType) IsSized() bool {
+	return u.Rows != nil && u.Cols != nil
+}
+
+// Build a matrix type with the same density and numbers,
+// applying a function to its dimensions
+func (u *MatrixType) Map(f func(TypeValue) TypeValue) *MatrixType {
+	if !u.IsSized() {
+		return u
+	}
+	return &MatrixType{Sparse: u.Sparse, Complex: u.Complex, Rows: f(u.Rows), Cols: f(u.Cols)}
+}
+
+// ADDITION: fixed size array types [n]A. At runtime, arrays are
+// lists whose length is known by the typechecker
+type ArrayType struct {
+	Size    TypeValue
+	Element TypeValue
+}
+
+// Build an array type applying a function
+// to its size and to the type of the elements
+func (u *ArrayType) Map(f func(TypeValue) TypeValue) *ArrayType {
+	return &ArrayType{Size: f(u.Size), Element: f(u.Element)}
+}
+
+// ADDITION: a dimension literal, the size of an array or the number
+// of rows or columns of a matrix. Dimensions are indices of types,
+// dimension variables are type variables
+type DimensionType struct {
+	Size int64
+}
+
+// ADDITION: union types, written A | B. A value of type A | B
+// is either a value of type A or a value of type B
+type UnionType struct {
+	Left  TypeValue
+	Right TypeValue
+}
+
+// Build a union type applying a function to both sides
+func (u *UnionType) Map(f func(TypeValue) TypeValue) TypeValue {
+	return NewUnionType(f(u.Left), f(u.Right))
+}
 
 // Denoted with α^ in the paper
 type ExistsType struct {
 	Identifier UniqueIdentifier
 }
 
-func (u *UnitType) typeValue()     {}
-func (u *VariableType) typeValue() {}
-func (u *ForAllType) typeValue()   {}
-func (u *LambdaType) typeValue()   {}
-
-// func (u *UnionType) typeValue()    {}
-func (u *ExistsType) typeValue() {}
+func (u *UnitType) typeValue()      {}
+func (u *VariableType) typeValue()  {}
+func (u *ForAllType) typeValue()    {}
+func (u *MuType) typeValue()        {}
+func (u *LambdaType) typeValue()    {}
+func (u *RecordType) typeValue()    {}
+func (u *DataType) typeValue()      {}
+func (u *TupleType) typeValue()     {}
+func (u *ListType) typeValue()      {}
+func (u *MatrixType) typeValue()    {}
+func (u *ArrayType) typeValue()     {}
+func (u *DimensionType) typeValue() {}
+func (u *UnionType) typeValue()     {}
+func (u *EffectRow) typeValue()     {}
+func (u *ExistsType) typeValue()    {}
 
 func (u *UnitType) IsMonotype() bool     { return true }
 func (u *VariableType) IsMonotype() bool { return true }
 func (u *ForAllType) IsMonotype() bool   { return false }
+func (u *MuType) IsMonotype() bool       { return u.Type.IsMonotype() }
 func (u *ExistsType) IsMonotype() bool   { return true }
 func (u *LambdaType) IsMonotype() bool   { return u.Domain.IsMonotype() && u.Codomain.IsMonotype() }
-
-// func (u *UnionType) IsMonotype() bool    { return u.Left.IsMonotype() && u.Right.IsMonotype() }
-
-// TODO record types
+func (u *DataType) IsMonotype() bool {
+	for _, t := range u.Args {
+		if !t.IsMonotype() {
+			return false
+		}
+	}
+	return true
+}
+func (u *TupleType) IsMonotype() bool {
+	for _, t := range u.Elements {
+		if !t.IsMonotype() {
+			return false
+		}
+	}
+	return true
+}
+func (u *RecordType) IsMonotype() bool {
+	for _, t := range u.Fields {
+		if !t.IsMonotype() {
+			return false
+		}
+	}
+	return true
+}
+func (u *ListType) IsMonotype() bool      { return u.Element.IsMonotype() }
+func (u *MatrixType) IsMonotype() bool    { return true }
+func (u *ArrayType) IsMonotype() bool     { return u.Element.IsMonotype() }
+func (u *DimensionType) IsMonotype() bool { return true }
+func (u *UnionType) IsMonotype() bool     { return u.Left.IsMonotype() && u.Right.IsMonotype() }
+func (u *EffectRow) IsMonotype() bool     { return true }
 
 // Default variable types
 
@@ -73,12 +243,14 @@ func NewVariableType(name string) *VariableType {
 	return &VariableType{Identifier: UniqueIdentifier{Value: name}}
 }
 
-// func NewUnionType(left, right TypeValue) TypeValue {
-// 	if CompareTypeValues(left, right) {
-// 		return left
-// 	}
-// 	return &UnionType{Left: left, Right: right}
-// }
+// Build the union of two types. The union of
+// a type with itself is the type itself
+func NewUnionType(left, right TypeValue) TypeValue {
+	if CompareTypeValues(left, right) {
+		return left
+	}
+	return &UnionType{Left: left, Right: right}
+}
 
 func CompareTypeValues(a, b TypeValue) bool {
 	switch va := a.(type) {
@@ -93,13 +265,91 @@ func CompareTypeValues(a, b TypeValue) bool {
 		return ok && va.Identifier == vb.Identifier
 	case *ForAllType:
 		vb, ok := b.(*ForAllType)
+		if !ok || va.Identifier != vb.Identifier || len(va.Constraints) != len(vb.Constraints) {
+			return false
+		}
+		for i := range va.Constraints {
+			if va.Constraints[i] != vb.Constraints[i] {
+				return false
+			}
+		}
+		return CompareTypeValues(va.Type, vb.Type)
+	case *MuType:
+		vb, ok := b.(*MuType)
 		return ok && va.Identifier == vb.Identifier && CompareTypeValues(va.Type, vb.Type)
-	// case *UnionType:
-	// 	vb, ok := b.(*UnionType)
-	// 	return ok && CompareTypeValues(va.Left, vb.Left) && CompareTypeValues(va.Right, vb.Right)
+	case *ListType:
+		vb, ok := b.(*ListType)
+		return ok && CompareTypeValues(va.Element, vb.Element)
+	case *MatrixType:
+		vb, ok := b.(*MatrixType)
+		if !ok || va.Sparse != vb.Sparse || va.Complex != vb.Complex || va.IsSized() != vb.IsSized() {
+			return false
+		}
+		return !va.IsSized() || CompareTypeValues(va.Rows, vb.Rows) && CompareTypeValues(va.Cols, vb.Cols)
+	case *ArrayType:
+		vb, ok := b.(*ArrayType)
+		return ok && CompareTypeValues(va.Size, vb.Size) && CompareTypeValues(va.Element, vb.Element)
+	case *DimensionType:
+		vb, ok := b.(*DimensionType)
+		return ok && va.Size == vb.Size
+	case *UnionType:
+		vb, ok := b.(*UnionType)
+		return ok && CompareTypeValues(va.Left, vb.Left) && CompareTypeValues(va.Right, vb.Right)
 	case *LambdaType:
 		vb, ok := b.(*LambdaType)
-		return ok && CompareTypeValues(va.Domain, vb.Domain) && CompareTypeValues(va.Codomain, vb.Codomain)
+		return ok && CompareTypeValues(va.Domain, vb.Domain) && CompareTypeValues(va.Codomain, vb.Codomain) &&
+			CompareTypeValues(va.Row(), vb.Row())
+	case *EffectRow:
+		// Labels are sorted, row variables are compared as a set
+		vb, ok := b.(*EffectRow)
+		if !ok || len(va.Labels) != len(vb.Labels) || len(va.Tails) != len(vb.Tails) {
+			return false
+		}
+		for i := range va.Labels {
+			if va.Labels[i] != vb.Labels[i] {
+				return false
+			}
+		}
+		for _, t := range va.Tails {
+			if !vb.HasTail(t) {
+				return false
+			}
+		}
+		return true
+	case *DataType:
+		vb, ok := b.(*DataType)
+		if !ok || va.Identifier != vb.Identifier || len(va.Args) != len(vb.Args) {
+			return false
+		}
+		for i := range va.Args {
+			if !CompareTypeValues(va.Args[i], vb.Args[i]) {
+				return false
+			}
+		}
+		return true
+	case *TupleType:
+		vb, ok := b.(*TupleType)
+		if !ok || len(va.Elements) != len(vb.Elements) {
+			return false
+		}
+		for i := range va.Elements {
+			if !CompareTypeValues(va.Elements[i], vb.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *RecordType:
+		vb, ok := b.(*RecordType)
+		if !ok || len(va.Fields) != len(vb.Fields) {
+			return false
+		}
+		for label, t := range va.Fields {
+			bt, ok := vb.Fields[label]
+			if !ok || !CompareTypeValues(t, bt) {
+				return false
+			}
+		}
+		return true
 	}
 	return false
 }
@@ -0,0 +1,133 @@
+package ast
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"sort"
+)
+
+// This file contains definitions of effect rows
+
+// The effects that a function can perform when applied: input and
+// output, mutable state, nondeterminism and failure
+var EffectLabels = []string{token.EFAIL, token.EIO, token.ENONDET, token.ESTATE}
+
+// Returns true if a name is an effect label
+func IsEffectLabel(name string) bool {
+	for _, l := range EffectLabels {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ADDITION: effect rows, the effects that a function may perform
+// when applied. A row is a set of effect labels and a set of row
+// variables, type variables standing for other rows, written
+// {io, fail, e}. A row without labels and variables is pure.
+// The history of the row orders the events that it performs, and
+// is written after the effects as in {io | open · read}
+type EffectRow struct {
+	Labels  []string // sorted alphabetically
+	Tails   []TypeValue
+	History History
+}
+
+// Build an effect row from a list of labels and row variables.
+// Row variables that are rows themselves are merged in the row.
+// The history of the row is the sequence of the histories
+// of the row variables
+func NewEffectRow(labels []string, tails []TypeValue) *EffectRow {
+	row := &EffectRow{Labels: []string{}, Tails: []TypeValue{}}
+	row.add(labels, tails)
+	sort.Strings(row.Labels)
+	return row
+}
+
+func (u *EffectRow) add(labels []string, tails []TypeValue) {
+	for _, l := range labels {
+		if !u.Has(l) {
+			u.Labels = append(u.Labels, l)
+		}
+	}
+	for _, t := range tails {
+		if row, ok := t.(*EffectRow); ok {
+			u.add(row.Labels, row.Tails)
+			u.History = NewSeqHistory(u.History, row.History)
+			continue
+		}
+		if !u.HasTail(t) {
+			u.Tails = append(u.Tails, t)
+			u.History = NewSeqHistory(u.History, &RowHistory{Row: t})
+		}
+	}
+}
+
+// Returns true if a row has no effects
+func (u *EffectRow) IsPure() bool {
+	return len(u.Labels) == 0 && len(u.Tails) == 0
+}
+
+// Returns true if a row contains an effect label
+func (u *EffectRow) Has(label string) bool {
+	for _, l := range u.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if a row contains a row variable
+func (u *EffectRow) HasTail(t TypeValue) bool {
+	for _, tail := range u.Tails {
+		if CompareTypeValues(tail, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// The union of two effect rows, the events
+// of the first row happen before the others
+func (u *EffectRow) Union(v *EffectRow) *EffectRow {
+	row := NewEffectRow(append(append([]string{}, u.Labels...), v.Labels...), append(append([]TypeValue{}, u.Tails...), v.Tails...))
+	row.History = NewSeqHistory(u.History, v.History)
+	return row
+}
+
+// Build an effect row with the same effects and another history
+func (u *EffectRow) WithHistory(h History) *EffectRow {
+	return &EffectRow{Labels: u.Labels, Tails: u.Tails, History: h}
+}
+
+// Build an effect row with the same labels, applying
+// a function to its row variables
+func (u *EffectRow) Map(f func(TypeValue) TypeValue) *EffectRow {
+	tails := make([]TypeValue, len(u.Tails))
+	for i, t := range u.Tails {
+		tails[i] = f(t)
+	}
+	row := NewEffectRow(u.Labels, tails)
+	row.History = MapHistory(u.History, f)
+	return row
+}
+
+// Get the effects of a function type, a function
+// type without an effect row is pure
+func (u *LambdaType) Row() *EffectRow {
+	if u.Effects == nil {
+		return NewEffectRow(nil, nil)
+	}
+	return u.Effects
+}
+
+// Build a function type applying a function to
+// its domain, codomain and effect row
+func (u *LambdaType) Map(f func(TypeValue) TypeValue) *LambdaType {
+	var effects *EffectRow
+	if u.Effects != nil {
+		effects = u.Effects.Map(f)
+	}
+	return &LambdaType{Domain: f(u.Domain), Codomain: f(u.Codomain), Effects: effects}
+}
@@ -0,0 +1,166 @@
+package ast
+
+// This file contains definitions of history expressions
+
+// ADDITION: history expressions, an approximation of the sequences of
+// events that an expression performs when it is evaluated. They are
+// attached to effect rows and are checked against usage policies.
+// The empty history ε is nil
+type History interface {
+	historyNode()
+}
+
+// A single event, `event open`
+type EventHistory struct {
+	Name string
+}
+
+// The events of Left followed by the events of Right, H1 · H2
+type SeqHistory struct {
+	Left  History
+	Right History
+}
+
+// The events of either Left or Right, H1 + H2
+type ChoiceHistory struct {
+	Left  History
+	Right History
+}
+
+// The history of a row variable, known when the variable is solved
+// or when the function is applied
+type RowHistory struct {
+	Row TypeValue
+}
+
+// The history of a recursive function, μh.H, where h stands for the
+// history of the recursive calls in H
+type RecHistory struct {
+	Identifier UniqueIdentifier
+	Body       History
+}
+
+// The history of the recursive calls bound by a RecHistory
+type RecVarHistory struct {
+	Identifier UniqueIdentifier
+}
+
+func (h *EventHistory) historyNode()  {}
+func (h *SeqHistory) historyNode()    {}
+func (h *ChoiceHistory) historyNode() {}
+func (h *RowHistory) historyNode()    {}
+func (h *RecHistory) historyNode()    {}
+func (h *RecVarHistory) historyNode() {}
+
+// Sequence two histories. The empty history is a unit
+func NewSeqHistory(a, b History) History {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &SeqHistory{Left: a, Right: b}
+}
+
+// Choose between two histories. A choice between equal histories
+// is the history itself
+func NewChoiceHistory(a, b History) History {
+	if CompareHistories(a, b) {
+		return a
+	}
+	return &ChoiceHistory{Left: a, Right: b}
+}
+
+// Returns true if a history performs at least an event
+func HasEvents(h History) bool {
+	switch vh := h.(type) {
+	case *EventHistory:
+		return true
+	case *SeqHistory:
+		return HasEvents(vh.Left) || HasEvents(vh.Right)
+	case *ChoiceHistory:
+		return HasEvents(vh.Left) || HasEvents(vh.Right)
+	case *RecHistory:
+		return HasEvents(vh.Body)
+	}
+	return false
+}
+
+// Returns true if two histories are structurally equal
+func CompareHistories(a, b History) bool {
+	switch va := a.(type) {
+	case nil:
+		return b == nil
+	case *EventHistory:
+		vb, ok := b.(*EventHistory)
+		return ok && va.Name == vb.Name
+	case *SeqHistory:
+		vb, ok := b.(*SeqHistory)
+		return ok && CompareHistories(va.Left, vb.Left) && CompareHistories(va.Right, vb.Right)
+	case *ChoiceHistory:
+		vb, ok := b.(*ChoiceHistory)
+		return ok && CompareHistories(va.Left, vb.Left) && CompareHistories(va.Right, vb.Right)
+	case *RowHistory:
+		vb, ok := b.(*RowHistory)
+		return ok && CompareTypeValues(va.Row, vb.Row)
+	case *RecHistory:
+		vb, ok := b.(*RecHistory)
+		return ok && va.Identifier == vb.Identifier && CompareHistories(va.Body, vb.Body)
+	case *RecVarHistory:
+		vb, ok := b.(*RecVarHistory)
+		return ok && va.Identifier == vb.Identifier
+	}
+	return false
+}
+
+// Build a history replacing the histories of row variables
+// with the result of a function
+func MapRowHistories(h History, f func(TypeValue) History) History {
+	switch vh := h.(type) {
+	case *SeqHistory:
+		return NewSeqHistory(MapRowHistories(vh.Left, f), MapRowHistories(vh.Right, f))
+	case *ChoiceHistory:
+		return NewChoiceHistory(MapRowHistories(vh.Left, f), MapRowHistories(vh.Right, f))
+	case *RecHistory:
+		return &RecHistory{Identifier: vh.Identifier, Body: MapRowHistories(vh.Body, f)}
+	case *RowHistory:
+		return f(vh.Row)
+	}
+	return h
+}
+
+// Build a history applying a function to its row variables. Row
+// variables that become rows are replaced by the history of the row
+func MapHistory(h History, f func(TypeValue) TypeValue) History {
+	return MapRowHistories(h, func(t TypeValue) History {
+		nt := f(t)
+		if row, ok := nt.(*EffectRow); ok {
+			return row.History
+		}
+		return &RowHistory{Row: nt}
+	})
+}
+
+// Print a history, with a given representation of row variables
+func HistoryString(h History, str func(TypeValue) string) string {
+	switch vh := h.(type) {
+	case *EventHistory:
+		return vh.Name
+	case *SeqHistory:
+		return HistoryString(vh.Left, str) + " · " + HistoryString(vh.Right, str)
+	case *ChoiceHistory:
+		return "(" + HistoryString(vh.Left, str) + " + " + HistoryString(vh.Right, str) + ")"
+	case *RowHistory:
+		return str(vh.Row)
+	case *RecHistory:
+		body := HistoryString(vh.Body, str)
+		if _, ok := vh.Body.(*SeqHistory); ok {
+			body = "(" + body + ")"
+		}
+		return "μ" + vh.Identifier.String() + "." + body
+	case *RecVarHistory:
+		return vh.Identifier.String()
+	}
+	return "ε"
+}
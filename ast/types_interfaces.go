@@ -0,0 +1,77 @@
+package ast
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+// This file contains the definitions of the builtin interfaces
+
+// ADDITION: the builtin interfaces. Eq and Ord have no methods, they
+// are implemented by the types whose values can be compared by the
+// comparison operators. The arithmetical operators on the types of
+// Num instances other than the number types are applied by the methods
+// of the instances, and fromint converts the integers they are mixed
+// with, see NumMethods. The instances of Num and Show for the
+// primitive types are bound by the evaluator, see eval.Instances
+var BuiltinInterfaces = []*InterfaceStatement{
+	newBuiltinInterface(token.IEQ),
+	newBuiltinInterface(token.IORD),
+	newBuiltinInterface(token.INUM,
+		newMethod("add", &LambdaType{Domain: tSelf, Codomain: &LambdaType{Domain: tSelf, Codomain: tSelf}}),
+		newMethod("sub", &LambdaType{Domain: tSelf, Codomain: &LambdaType{Domain: tSelf, Codomain: tSelf}}),
+		newMethod("mul", &LambdaType{Domain: tSelf, Codomain: &LambdaType{Domain: tSelf, Codomain: tSelf}}),
+		newMethod("div", &LambdaType{Domain: tSelf, Codomain: &LambdaType{Domain: tSelf, Codomain: tSelf, Effects: tFail}}),
+		newMethod("pow", &LambdaType{Domain: tSelf, Codomain: &LambdaType{Domain: tSelf, Codomain: tSelf, Effects: tFail}}),
+		newMethod("neg", &LambdaType{Domain: tSelf, Codomain: tSelf}),
+		newMethod("zero", tSelf),
+		newMethod("fromint", &LambdaType{Domain: TINT, Codomain: tSelf}),
+	),
+	newBuiltinInterface(token.ISHOW,
+		newMethod("show", &LambdaType{Domain: tSelf, Codomain: TSTRING}),
+	),
+}
+
+// The primitive types implementing Ord. All the primitive types
+// implement Eq, and so do the types built from comparable types
+var OrderedTypes = []*VariableType{TINT, TFLOAT, TRUNE, TSTRING, TBOOL}
+
+// The type variable of the builtin interfaces
+var tSelf = NewVariableType("a")
+
+// The effects of the division and the power of Num, that fail
+// on integers, see PartialOperators
+var tFail = NewEffectRow([]string{token.EFAIL}, nil)
+
+func newBuiltinInterface(name string, methods ...*MethodDecl) *InterfaceStatement {
+	return &InterfaceStatement{
+		Token:   token.Token{Type: token.INTERFACE, Literal: token.INTERFACE},
+		Name:    name,
+		Param:   tSelf.Identifier,
+		Methods: methods,
+	}
+}
+
+func newMethod(name string, t TypeValue) *MethodDecl {
+	tok := token.Token{Type: token.IDENT, Literal: name}
+	return &MethodDecl{
+		Token: tok,
+		Name:  &IdentifierExpr{Token: tok, Identifier: UniqueIdentifier{Value: name}},
+		Type:  t,
+	}
+}
+
+// The identifier bound to the dictionary of a builtin instance,
+// that cannot be written in programs
+func InstanceIdentifier(iface string, t TypeValue) UniqueIdentifier {
+	return UniqueIdentifier{Value: iface + "(" + t.String() + ")"}
+}
+
+// Get a builtin interface by name, nil if there is none
+func BuiltinInterface(name string) *InterfaceStatement {
+	for _, i := range BuiltinInterfaces {
+		if i.Name == name {
+			return i
+		}
+	}
+	return nil
+}
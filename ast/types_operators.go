@@ -67,6 +67,51 @@ var InfixOperatorTypes map[string]*InfixOperatorType = map[string]*InfixOperator
 	token.OR:      NewInfixOperatorType(TBOOL, TBOOL, TBOOL),
 }
 
+// The arithmetical operators that are overloaded on the numeric
+// tower. The instance of an operator is the strict operator on
+// the least upper bound of the types of the operands
+var NumericInstances map[string]map[string]string = map[string]map[string]string{
+	token.PLUS:   {token.TINT: token.PLUS, token.TFLOAT: token.FPLUS, token.TCOMPLEX: token.CPLUS},
+	token.MINUS:  {token.TINT: token.MINUS, token.TFLOAT: token.FMINUS, token.TCOMPLEX: token.CMINUS},
+	token.TIMES:  {token.TINT: token.TIMES, token.TFLOAT: token.FTIMES, token.TCOMPLEX: token.CTIMES},
+	token.DIVIDE: {token.TINT: token.DIVIDE, token.TFLOAT: token.FDIVIDE, token.TCOMPLEX: token.CDIVIDE},
+	token.TOPOW:  {token.TINT: token.TOPOW, token.TFLOAT: token.FTOPOW, token.TCOMPLEX: token.CTOPOW},
+}
+
+// The matrix operators applying the arithmetical operators when one
+// of the operands is a matrix
+var MatrixInstances map[string]string = map[string]string{
+	token.PLUS:   token.MPLUS,
+	token.MINUS:  token.MMINUS,
+	token.TIMES:  token.MTIMES,
+	token.DIVIDE: token.MDIVIDE,
+}
+
+// The methods of the Num instances applying the arithmetical operators
+// to the types that are not in the numeric tower, see NumericInstances.
+// The negation is applied by neg
+var NumMethods map[string]string = map[string]string{
+	token.PLUS:   "add",
+	token.MINUS:  "sub",
+	token.TIMES:  "mul",
+	token.DIVIDE: "div",
+	token.TOPOW:  "pow",
+}
+
+// The strict operators that fail on some operands: integer division
+// and modulo by zero, and integer powers with a negative exponent
+var PartialOperators = map[string]bool{token.DIVIDE: true, token.MODULO: true, token.TOPOW: true}
+
+// The types of the numeric tower, int <: float <: complex
+var NumericTower = []*VariableType{TINT, TFLOAT, TCOMPLEX}
+
+// The instances of the negation on the types of the numeric tower
+var NegationInstances map[string]string = map[string]string{
+	token.TINT:     token.MINUS,
+	token.TFLOAT:   token.FMINUS,
+	token.TCOMPLEX: token.CMINUS,
+}
+
 var PrefixOperatorTypes map[string]*PrefixOperatorType = map[string]*PrefixOperatorType{
 	token.MINUS:  NewPrefixOperatorType(TINT, TINT),
 	token.FMINUS: NewPrefixOperatorType(TFLOAT, TFLOAT),
@@ -0,0 +1,30 @@
+package ast
+
+// The shape of the values of a type: the fields of its record types
+// that are known statically. A value of a record type can have more
+// fields than its type, as in ({x = 1, y = 2} : {x: int}), that are
+// ignored when comparing it. A nil shape is the shape of the types
+// without record types, whose values are compared as they are.
+// The shapes of recursive types are cyclic.
+//
+// Shapes also describe the coercion of a value to a supertype of its
+// type: its numbers are converted along the numeric tower, as in
+// (1 : float), and functions convert their arguments and results, as
+// in (fun(x: float) {x} : int -> float). A nil shape is then the shape
+// of the values that are used as they are
+type Shape struct {
+	// The shapes of the fields of a record type
+	Fields map[string]*Shape
+	// The shapes of the elements of a tuple type
+	Elements []*Shape
+	// The shape of the elements of a list or array type
+	Element *Shape
+	// The shapes of the arguments of the constructors of a data type
+	Constructors map[string][]*Shape
+	// The types of the numeric tower numbers are converted to, in
+	// the order of the tower. A number is converted to the first
+	// one that is not smaller than its type
+	Numbers []string
+	// The shapes of the argument and of the result of a function
+	Domain, Codomain *Shape
+}
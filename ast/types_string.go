@@ -1,7 +1,11 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"strconv"
+	"strings"
 )
 
 // This file contains string representation of type values
@@ -10,38 +14,213 @@ func (u *UnitType) String() string     { return "unit" }
 func (u *ExistsType) String() string   { return "∃'" + u.Identifier.String() }
 func (u *VariableType) String() string { return u.Identifier.String() }
 func (u *ForAllType) String() string {
-	return fmt.Sprintf("∀%s.%s", u.Identifier.String(), u.Type.String())
+	return fmt.Sprintf("∀%s.%s", u.binderString(u.Identifier.String()), u.Type.String())
+}
+
+// Print the type variable of a polymorphic type, with the
+// interfaces it must implement as in ∀(a: Eq, Show).
+func (u *ForAllType) binderString(name string) string {
+	if len(u.Constraints) == 0 {
+		return name
+	}
+	return "(" + name + token.ANNOT + " " + strings.Join(u.Constraints, ", ") + ")"
+}
+
+func (u *MuType) String() string {
+	return fmt.Sprintf("μ%s.%s", u.Identifier.String(), u.Type.String())
 }
 
 func (u *LambdaType) String() string {
-	return fmt.Sprintf("%s -> %s", u.Domain.String(), u.Codomain.String())
+	return fmt.Sprintf("%s %s %s", parenDomain(u.Domain, u.Domain.String()), u.arrowString(u.Row().String()), u.Codomain.String())
+}
+
+// Print the arrow of a function type, with the effect row between
+// the dash and the head of the arrow as in -{io}-> when not pure
+func (u *LambdaType) arrowString(row string) string {
+	if u.Row().IsPure() {
+		return token.RARROW
+	}
+	return token.MINUS + row + token.RARROW
+}
+
+func (u *EffectRow) String() string {
+	return u.effectsString(func(t TypeValue) string { return t.String() })
 }
 
-// func (u *UnionType) String() string {
-// 	return fmt.Sprintf("%s U %s", u.Left.String(), u.Left.String())
-// }
+// Print the labels of an effect row followed by its row
+// variables, with a given representation of the variables.
+// The history is printed when the row performs events
+func (u *EffectRow) effectsString(str func(TypeValue) string) string {
+	effects := append([]string{}, u.Labels...)
+	for _, t := range u.Tails {
+		effects = append(effects, str(t))
+	}
+	if HasEvents(u.History) {
+		return "{" + strings.Join(effects, ", ") + " | " + HistoryString(u.History, str) + "}"
+	}
+	return "{" + strings.Join(effects, ", ") + "}"
+}
+
+// The arrow is right associative, function types, polymorphic and
+// recursive types in the domain of a function type need parens
+func parenDomain(domain TypeValue, s string) string {
+	switch domain.(type) {
+	case *LambdaType, *ForAllType, *MuType:
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (u *DataType) String() string {
+	return u.argsString(u.Identifier.String(), func(t TypeValue) string { return t.String() })
+}
+
+// Print a data type applied to its arguments, with a given
+// representation of the types of the arguments
+func (u *DataType) argsString(name string, str func(TypeValue) string) string {
+	if len(u.Args) == 0 {
+		return name
+	}
+	args := make([]string, len(u.Args))
+	for i, t := range u.Args {
+		args[i] = str(t)
+	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (u *TupleType) String() string {
+	return u.elementsString(func(t TypeValue) string { return t.String() })
+}
+
+// Print the types of the elements of a tuple type with a given representation
+func (u *TupleType) elementsString(str func(TypeValue) string) string {
+	elems := make([]string, len(u.Elements))
+	for i, t := range u.Elements {
+		elems[i] = str(t)
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+func (u *RecordType) String() string {
+	return u.fieldsString(func(t TypeValue) string { return t.String() })
+}
+
+// Print the fields of a record type sorted by label, with
+// a given representation of the types of the fields
+func (u *RecordType) fieldsString(str func(TypeValue) string) string {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, label := range u.Labels() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(label + ": " + str(u.Fields[label]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (u *ListType) String() string {
+	return "[]" + parenElement(u.Element, u.Element.String())
+}
+
+func (u *MatrixType) String() string {
+	return u.dimensionsString(func(t TypeValue) string { return t.String() })
+}
+
+// Print a matrix type, with a given representation of its dimensions
+func (u *MatrixType) dimensionsString(str func(TypeValue) string) string {
+	s := token.DENSE
+	if u.Sparse {
+		s = token.SPARSE
+	}
+	if u.Complex {
+		s += " " + token.TCMATRIX
+	} else {
+		s += " " + token.TMATRIX
+	}
+	if u.IsSized() {
+		s += "(" + str(u.Rows) + ", " + str(u.Cols) + ")"
+	}
+	return s
+}
+
+func (u *ArrayType) String() string {
+	return "[" + u.Size.String() + "]" + parenElement(u.Element, u.Element.String())
+}
+
+func (u *DimensionType) String() string {
+	return strconv.FormatInt(u.Size, 10)
+}
+
+// The type of the elements of a list is an atom, compound
+// types other than tuples, records and lists need parens
+func parenElement(t TypeValue, s string) string {
+	switch t.(type) {
+	case *LambdaType, *ForAllType, *MuType, *UnionType:
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (u *UnionType) String() string {
+	return fmt.Sprintf("%s | %s", parenUnion(u.Left, u.Left.String()), parenUnion(u.Right, u.Right.String()))
+}
+
+// Function types, polymorphic and recursive types in a union need parens
+func parenUnion(t TypeValue, s string) string {
+	switch t.(type) {
+	case *LambdaType, *ForAllType, *MuType:
+		return "(" + s + ")"
+	}
+	return s
+}
 
 func (u *UnitType) FullString() string     { return u.String() }
 func (u *VariableType) FullString() string { return u.Identifier.FullString() }
 func (u *ForAllType) FullString() string {
-	return fmt.Sprintf("∀%s.%s", u.Identifier.FullString(), u.Type.String())
+	return fmt.Sprintf("∀%s.%s", u.binderString(u.Identifier.FullString()), u.Type.String())
+}
+func (u *MuType) FullString() string {
+	return fmt.Sprintf("μ%s.%s", u.Identifier.FullString(), u.Type.FullString())
 }
 func (u *LambdaType) FullString() string {
-	return fmt.Sprintf("%s -> %s", u.Domain.FullString(), u.Codomain.FullString())
+	return fmt.Sprintf("%s %s %s", parenDomain(u.Domain, u.Domain.FullString()), u.arrowString(u.Row().FullString()), u.Codomain.FullString())
+}
+func (u *EffectRow) FullString() string {
+	return u.effectsString(func(t TypeValue) string { return t.FullString() })
+}
+func (u *DataType) FullString() string {
+	return u.argsString(u.Identifier.FullString(), func(t TypeValue) string { return t.FullString() })
+}
+func (u *TupleType) FullString() string {
+	return u.elementsString(func(t TypeValue) string { return t.FullString() })
+}
+func (u *RecordType) FullString() string {
+	return u.fieldsString(func(t TypeValue) string { return t.FullString() })
 }
 
-// func (u *UnionType) FullString() string {
-// 	return fmt.Sprintf("%s U %s", u.Left.FullString(), u.Right.FullString())
-// }
+func (u *ListType) FullString() string {
+	return "[]" + parenElement(u.Element, u.Element.FullString())
+}
+func (u *MatrixType) FullString() string {
+	return u.dimensionsString(func(t TypeValue) string { return t.FullString() })
+}
+func (u *ArrayType) FullString() string {
+	return "[" + u.Size.FullString() + "]" + parenElement(u.Element, u.Element.FullString())
+}
+func (u *DimensionType) FullString() string { return u.String() }
+func (u *UnionType) FullString() string {
+	return fmt.Sprintf("%s | %s", parenUnion(u.Left, u.Left.FullString()), parenUnion(u.Right, u.Right.FullString()))
+}
 func (u *ExistsType) FullString() string { return "∃'" + u.Identifier.FullString() }
 
-// helper for generating fancy names
+// helper for generating fancy names: a, b, ..., z, a1, b1, ...
 func genFancy(occ map[UniqueIdentifier]int, id UniqueIdentifier) string {
 	if num, ok := occ[id]; ok {
-		return string(rune(num + 97))
+		return fancyName(num)
 	}
 
-	// FIXME generate decent names
 	max := -1
 	for _, v := range occ {
 		if v > max {
@@ -50,7 +229,16 @@ func genFancy(occ map[UniqueIdentifier]int, id UniqueIdentifier) string {
 	}
 
 	occ[id] = max + 1
-	return string(rune(max + 1 + 97))
+	return fancyName(max + 1)
+}
+
+// The name of the num-th type variable of a fancy string
+func fancyName(num int) string {
+	name := string(rune(num%26 + 'a'))
+	if num >= 26 {
+		name += strconv.Itoa(num / 26)
+	}
+	return name
 
 }
 
@@ -59,15 +247,47 @@ func (u *ExistsType) FancyString(occ map[UniqueIdentifier]int) string {
 	return "'" + genFancy(occ, u.Identifier)
 }
 func (u *VariableType) FancyString(occ map[UniqueIdentifier]int) string {
+	// Type variables bound by an enclosing ForAllType
+	if _, ok := occ[u.Identifier]; ok {
+		return genFancy(occ, u.Identifier)
+	}
 	return u.String()
 }
 func (u *ForAllType) FancyString(occ map[UniqueIdentifier]int) string {
-	return fmt.Sprintf("∀%s.%s", genFancy(occ, u.Identifier), u.Type.FancyString(occ))
+	return fmt.Sprintf("∀%s.%s", u.binderString(genFancy(occ, u.Identifier)), u.Type.FancyString(occ))
+}
+func (u *MuType) FancyString(occ map[UniqueIdentifier]int) string {
+	return fmt.Sprintf("μ%s.%s", genFancy(occ, u.Identifier), u.Type.FancyString(occ))
 }
 func (u *LambdaType) FancyString(occ map[UniqueIdentifier]int) string {
-	return fmt.Sprintf("%s -> %s", u.Domain.FancyString(occ), u.Codomain.FancyString(occ))
+	domain := parenDomain(u.Domain, u.Domain.FancyString(occ))
+	arrow := u.arrowString(u.Row().FancyString(occ))
+	return fmt.Sprintf("%s %s %s", domain, arrow, u.Codomain.FancyString(occ))
+}
+func (u *EffectRow) FancyString(occ map[UniqueIdentifier]int) string {
+	return u.effectsString(func(t TypeValue) string { return t.FancyString(occ) })
+}
+
+func (u *DataType) FancyString(occ map[UniqueIdentifier]int) string {
+	return u.argsString(u.Identifier.String(), func(t TypeValue) string { return t.FancyString(occ) })
+}
+func (u *TupleType) FancyString(occ map[UniqueIdentifier]int) string {
+	return u.elementsString(func(t TypeValue) string { return t.FancyString(occ) })
+}
+func (u *RecordType) FancyString(occ map[UniqueIdentifier]int) string {
+	return u.fieldsString(func(t TypeValue) string { return t.FancyString(occ) })
 }
 
-// func (u *UnionType) FancyString(occ map[UniqueIdentifier]int) string {
-// 	return fmt.Sprintf("%s U %s", u.Left.FancyString(occ), u.Right.FancyString(occ))
-// }
+func (u *ListType) FancyString(occ map[UniqueIdentifier]int) string {
+	return "[]" + parenElement(u.Element, u.Element.FancyString(occ))
+}
+func (u *MatrixType) FancyString(occ map[UniqueIdentifier]int) string {
+	return u.dimensionsString(func(t TypeValue) string { return t.FancyString(occ) })
+}
+func (u *ArrayType) FancyString(occ map[UniqueIdentifier]int) string {
+	return "[" + u.Size.FancyString(occ) + "]" + parenElement(u.Element, u.Element.FancyString(occ))
+}
+func (u *DimensionType) FancyString(occ map[UniqueIdentifier]int) string { return u.String() }
+func (u *UnionType) FancyString(occ map[UniqueIdentifier]int) string {
+	return fmt.Sprintf("%s | %s", parenUnion(u.Left, u.Left.FancyString(occ)), parenUnion(u.Right, u.Right.FancyString(occ)))
+}
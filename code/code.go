@@ -0,0 +1,349 @@
+// Contains the definition of gobba's bytecode: opcodes, their operands
+// and helpers to encode and decode instructions
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	// Push a value from the constant pool. Operand: constant index
+	OpConstant Opcode = iota
+	// Discard the value on top of the stack
+	OpPop
+	// Push the unit value
+	OpUnit
+	OpTrue
+	OpFalse
+
+	// Arithmetical operators
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpFAdd
+	OpFSub
+	OpFMul
+	OpFDiv
+	OpFPow
+	OpCAdd
+	OpCSub
+	OpCMul
+	OpCDiv
+	OpCPow
+	OpMAdd
+	OpMSub
+	OpMMul
+	OpMDiv
+
+	// Comparison operators. Boolean operators short circuit and
+	// are compiled to jumps
+	OpEqual
+	OpNotEqual
+	OpLess
+	OpLessEq
+	OpGreater
+	OpGreaterEq
+
+	// List operators
+	OpCons
+	OpConcat
+
+	// Element of a matrix, m @ (i, j)
+	OpAt
+
+	// Prefix operators
+	OpMinus
+	OpFMinus
+	OpCMinus
+	OpNot
+
+	// Postfix operators
+	OpTranspose
+
+	// Unconditional jump. Operand: absolute address
+	OpJump
+	// Pop a boolean and jump if it is false. Operand: absolute address
+	OpJumpNotTrue
+
+	// Operand: global slot
+	OpGetGlobal
+	OpSetGlobal
+	// Operand: local slot in the current frame
+	OpGetLocal
+	OpSetLocal
+	// Operand: index in the upvalues of the current closure
+	OpGetUpvalue
+	// Push the closure that is currently being executed
+	OpCurrentClosure
+	// Pop a value and a closure, and replace an upvalue of the closure
+	// with the value. Closes the upvalues of mutually recursive closures,
+	// built before the closures they capture. Operand: index in the
+	// upvalues of the closure
+	OpSetUpvalue
+
+	// Build a closure. Operands: constant index of the compiled
+	// function and number of upvalues to pop from the stack
+	OpClosure
+	// Apply the function below the top of the stack to the argument
+	// on top of the stack
+	OpCall
+	// Like OpCall, but replaces the frame of the caller, which
+	// would immediately return the value of the call
+	OpTailCall
+	// Return from a function with the value on top of the stack
+	OpReturnValue
+
+	// Build a record from pairs of labels and values on the stack.
+	// Operand: number of fields
+	OpRecord
+	// Replace the record on top of the stack with the value of one
+	// of its fields. Operand: constant index of the label
+	OpAccess
+	// Build a tuple from the values on the stack.
+	// Operand: number of elements
+	OpTuple
+	// Build a list from the values on the stack.
+	// Operand: number of elements
+	OpList
+	// Build a matrix from its elements on the stack, in row-major
+	// order. Operands: number of rows, number of columns and 1
+	// if the matrix is sparse
+	OpMatrix
+
+	// Pop a value and match it against a pattern. If the value matches,
+	// push the values bound by the pattern and true, otherwise push the
+	// value back and false. Operand: constant index of the pattern
+	OpMatch
+	// Fail with the value on top of the stack, that matched no pattern
+	OpMatchFailure
+)
+
+// Human readable name and width in bytes of the operands of an opcode
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:       {"OpConstant", []int{2}},
+	OpPop:            {"OpPop", []int{}},
+	OpUnit:           {"OpUnit", []int{}},
+	OpTrue:           {"OpTrue", []int{}},
+	OpFalse:          {"OpFalse", []int{}},
+	OpAdd:            {"OpAdd", []int{}},
+	OpSub:            {"OpSub", []int{}},
+	OpMul:            {"OpMul", []int{}},
+	OpDiv:            {"OpDiv", []int{}},
+	OpMod:            {"OpMod", []int{}},
+	OpPow:            {"OpPow", []int{}},
+	OpFAdd:           {"OpFAdd", []int{}},
+	OpFSub:           {"OpFSub", []int{}},
+	OpFMul:           {"OpFMul", []int{}},
+	OpFDiv:           {"OpFDiv", []int{}},
+	OpFPow:           {"OpFPow", []int{}},
+	OpCAdd:           {"OpCAdd", []int{}},
+	OpCSub:           {"OpCSub", []int{}},
+	OpCMul:           {"OpCMul", []int{}},
+	OpCDiv:           {"OpCDiv", []int{}},
+	OpCPow:           {"OpCPow", []int{}},
+	OpMAdd:           {"OpMAdd", []int{}},
+	OpMSub:           {"OpMSub", []int{}},
+	OpMMul:           {"OpMMul", []int{}},
+	OpMDiv:           {"OpMDiv", []int{}},
+	OpEqual:          {"OpEqual", []int{}},
+	OpNotEqual:       {"OpNotEqual", []int{}},
+	OpLess:           {"OpLess", []int{}},
+	OpLessEq:         {"OpLessEq", []int{}},
+	OpGreater:        {"OpGreater", []int{}},
+	OpGreaterEq:      {"OpGreaterEq", []int{}},
+	OpCons:           {"OpCons", []int{}},
+	OpConcat:         {"OpConcat", []int{}},
+	OpAt:             {"OpAt", []int{}},
+	OpMinus:          {"OpMinus", []int{}},
+	OpFMinus:         {"OpFMinus", []int{}},
+	OpCMinus:         {"OpCMinus", []int{}},
+	OpNot:            {"OpNot", []int{}},
+	OpTranspose:      {"OpTranspose", []int{}},
+	OpJump:           {"OpJump", []int{2}},
+	OpJumpNotTrue:    {"OpJumpNotTrue", []int{2}},
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetUpvalue:     {"OpGetUpvalue", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpSetUpvalue:     {"OpSetUpvalue", []int{1}},
+	OpClosure:        {"OpClosure", []int{2, 1}},
+	OpCall:           {"OpCall", []int{}},
+	OpTailCall:       {"OpTailCall", []int{}},
+	OpReturnValue:    {"OpReturnValue", []int{}},
+	OpRecord:         {"OpRecord", []int{2}},
+	OpAccess:         {"OpAccess", []int{2}},
+	OpTuple:          {"OpTuple", []int{2}},
+	OpList:           {"OpList", []int{2}},
+	OpMatrix:         {"OpMatrix", []int{2, 2, 1}},
+	OpMatch:          {"OpMatch", []int{2}},
+	OpMatchFailure:   {"OpMatchFailure", []int{}},
+}
+
+// Opcodes of the infix operators, indexed by operator
+var InfixOpcodes = map[string]Opcode{
+	token.PLUS:      OpAdd,
+	token.MINUS:     OpSub,
+	token.TIMES:     OpMul,
+	token.DIVIDE:    OpDiv,
+	token.MODULO:    OpMod,
+	token.TOPOW:     OpPow,
+	token.FPLUS:     OpFAdd,
+	token.FMINUS:    OpFSub,
+	token.FTIMES:    OpFMul,
+	token.FDIVIDE:   OpFDiv,
+	token.FTOPOW:    OpFPow,
+	token.CPLUS:     OpCAdd,
+	token.CMINUS:    OpCSub,
+	token.CTIMES:    OpCMul,
+	token.CDIVIDE:   OpCDiv,
+	token.CTOPOW:    OpCPow,
+	token.MPLUS:     OpMAdd,
+	token.MMINUS:    OpMSub,
+	token.MTIMES:    OpMMul,
+	token.MDIVIDE:   OpMDiv,
+	token.EQUALS:    OpEqual,
+	token.DIFFERS:   OpNotEqual,
+	token.LESS:      OpLess,
+	token.LESSEQ:    OpLessEq,
+	token.GREATER:   OpGreater,
+	token.GREATEREQ: OpGreaterEq,
+	token.CONS:      OpCons,
+	token.CONCAT:    OpConcat,
+	token.AT:        OpAt,
+}
+
+// Opcodes of the prefix operators, indexed by operator
+var PrefixOpcodes = map[string]Opcode{
+	token.MINUS:  OpMinus,
+	token.FMINUS: OpFMinus,
+	token.CMINUS: OpCMinus,
+	token.NOT:    OpNot,
+}
+
+// Opcodes of the postfix operators, indexed by operator
+var PostfixOpcodes = map[string]Opcode{
+	token.TRANSPOSE: OpTranspose,
+}
+
+// Operators of the infix opcodes, the inverse of InfixOpcodes
+var InfixOperators = map[Opcode]string{}
+
+// Operators of the prefix opcodes, the inverse of PrefixOpcodes
+var PrefixOperators = map[Opcode]string{}
+
+func init() {
+	for op, opcode := range InfixOpcodes {
+		InfixOperators[opcode] = op
+	}
+	for op, opcode := range PrefixOpcodes {
+		PrefixOperators[opcode] = op
+	}
+}
+
+// Get the definition of an opcode
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Encode an instruction
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+	return instruction
+}
+
+// Decode the operands of an instruction. Return the operands
+// and the number of bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// Disassemble instructions
+func (ins Instructions) String() string {
+	var b bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&b, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&b, "%04d %s\n", i, fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return b.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	s := def.Name
+	for _, o := range operands {
+		s += fmt.Sprintf(" %d", o)
+	}
+	return s
+}
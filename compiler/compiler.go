@@ -0,0 +1,696 @@
+// Contains the compiler from typechecked, α-converted gobba
+// expressions to bytecode for the virtual machine
+package compiler
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/code"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+const COMPILED_FUNCTION_VALUE = "compiled function"
+
+// Maximum number of constants and of globals of a program, that
+// are indexed by the two byte operands of the instructions
+const MaxConstants = 1 << 16
+const MaxGlobals = 1 << 16
+
+// The body of a function literal, compiled to bytecode.
+// Compiled functions are stored in the constant pool
+type CompiledFunction struct {
+	Instructions code.Instructions
+	NumLocals    int
+}
+
+func (f *CompiledFunction) Type() eval.ValueType { return COMPILED_FUNCTION_VALUE }
+func (f *CompiledFunction) String() string {
+	return fmt.Sprintf("<compiled function %p>", f)
+}
+
+type CompileError struct {
+	Msg string
+}
+
+func (ce CompileError) Error() string {
+	return fmt.Sprintf("compile error: %s", ce.Msg)
+}
+
+// The output of the compiler
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []eval.Value
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// The instructions of the function being compiled
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+type Compiler struct {
+	constants   []eval.Value
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+}
+
+// Create a new compiler with an empty global scope
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+	}
+
+	return &Compiler{
+		constants:   []eval.Value{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// Create a new compiler that keeps the global symbols and the
+// constants of a previous compilation, so that new code can refer
+// to globals defined earlier
+func NewWithState(s *SymbolTable, constants []eval.Value) *Compiler {
+	c := New()
+	c.symbolTable = s
+	c.constants = constants
+	return c
+}
+
+// Get the global symbol table
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+// Get the compiled program
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+	}
+}
+
+// Compile an expression. The value of the expression is left
+// on top of the stack
+func (c *Compiler) Compile(exp ast.Expression) error {
+	if err := c.compile(exp, false); err != nil {
+		return err
+	}
+	return c.checkConstants()
+}
+
+// Compile the statements of a program. Names bound by let statements
+// become globals and the value of the last statement is left on top
+// of the stack
+func (c *Compiler) CompileProgram(p *ast.Program) error {
+	for i, stmt := range p.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+		if i < len(p.Statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+	if len(p.Statements) == 0 {
+		c.emit(code.OpUnit)
+	}
+	return c.checkConstants()
+}
+
+// Fail if the constant pool grew past the constants that the
+// instructions can refer to. The constants of previous compilations
+// kept by NewWithState count
+func (c *Compiler) checkConstants() error {
+	if len(c.constants) > MaxConstants {
+		return &CompileError{fmt.Sprintf("too many constants in program, the limit is %d", MaxConstants)}
+	}
+	return nil
+}
+
+// Compile a top level statement, leaving its value on the stack
+func (c *Compiler) compileStatement(stmt ast.Statement) error {
+	switch vs := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return c.compile(vs.Expression, false)
+	case *ast.LetStatement:
+		if err := c.compileAssignments(vs.Assignments); err != nil {
+			return err
+		}
+		c.emit(code.OpUnit)
+		return nil
+	case *ast.TypeStatement:
+		// Constructors are constant values bound to globals
+		for _, ctor := range vs.Constructors {
+			v := eval.NewConstructor(ctor.Name.Identifier.Value, len(ctor.Args))
+			c.emit(code.OpConstant, c.addConstant(v))
+			if err := c.storeSymbol(c.symbolTable.Define(ctor.Name.Identifier)); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpUnit)
+		return nil
+	case *ast.AliasStatement:
+		c.emit(code.OpUnit)
+		return nil
+	case *ast.NewtypeStatement:
+		for _, f := range []*ast.IdentifierExpr{vs.Wrap, vs.Unwrap} {
+			c.emit(code.OpConstant, c.addConstant(eval.NewWrapper(f.Identifier.Value)))
+			if err := c.storeSymbol(c.symbolTable.Define(f.Identifier)); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpUnit)
+		return nil
+	case *ast.PolicyStatement:
+		c.emit(code.OpUnit)
+		return nil
+	case *ast.InterfaceStatement:
+		// Methods take the dictionary of an instance
+		for _, m := range vs.Methods {
+			c.emit(code.OpConstant, c.addConstant(eval.NewMethod(m.Name.Identifier.Value)))
+			if err := c.storeSymbol(c.symbolTable.Define(m.Name.Identifier)); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpUnit)
+		return nil
+	case *ast.InstanceStatement:
+		// The dictionary is visible in the methods of the instance
+		symbol := c.symbolTable.Define(vs.Identifier)
+		if err := c.compile(vs.Dictionary(), false); err != nil {
+			return err
+		}
+		if err := c.storeSymbol(symbol); err != nil {
+			return err
+		}
+		c.emit(code.OpUnit)
+		return nil
+	}
+	return &CompileError{fmt.Sprintf("cannot compile statement %s", stmt)}
+}
+
+// Compile a list of assignments, binding the names and the variables
+// of the patterns in the current scope. All the values are computed
+// before binding any name. The names of mutually recursive functions
+// are defined before compiling the values: their closures are built
+// capturing the functions that are not bound yet, and their upvalues
+// are closed once all the names are bound
+func (c *Compiler) compileAssignments(asss []*ast.Assignment) error {
+	group := map[*ast.Assignment]Symbol{}
+	for _, ass := range ast.RecursiveGroup(asss) {
+		group[ass] = c.symbolTable.Define(ass.Name.Identifier)
+	}
+	upvalues := map[*ast.Assignment][]Symbol{}
+	for _, ass := range asss {
+		if _, ok := group[ass]; ok {
+			fix := ass.RecursiveFunction()
+			fn, ok := fix.Body.(*ast.FunctionLiteral)
+			if !ok {
+				return &CompileError{"fixed point of a value that is not a function"}
+			}
+			ups, err := c.compileFunction(fn, &fix.Param.Identifier)
+			if err != nil {
+				return err
+			}
+			upvalues[ass] = ups
+			continue
+		}
+		if err := c.compile(ass.Value, false); err != nil {
+			return err
+		}
+	}
+	symbols := make([][]Symbol, len(asss))
+	for i, ass := range asss {
+		if s, ok := group[ass]; ok {
+			symbols[i] = []Symbol{s}
+			continue
+		}
+		if ass.Pattern == nil {
+			symbols[i] = []Symbol{c.symbolTable.Define(ass.Name.Identifier)}
+			continue
+		}
+		for _, id := range ast.PatternVariables(ass.Pattern) {
+			symbols[i] = append(symbols[i], c.symbolTable.Define(id))
+		}
+	}
+	for i := len(asss) - 1; i >= 0; i-- {
+		if asss[i].Pattern == nil {
+			if err := c.storeSymbol(symbols[i][0]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.compileBindPattern(asss[i].Pattern, symbols[i]); err != nil {
+			return err
+		}
+	}
+	return c.closeRecursiveUpvalues(asss, group, upvalues)
+}
+
+// Replace the upvalues of the closures of mutually recursive functions
+// that capture functions of the group with the bound closures
+func (c *Compiler) closeRecursiveUpvalues(asss []*ast.Assignment, group map[*ast.Assignment]Symbol, upvalues map[*ast.Assignment][]Symbol) error {
+	recursive := map[Symbol]bool{}
+	for _, s := range group {
+		recursive[s] = true
+	}
+	for _, ass := range asss {
+		for i, up := range upvalues[ass] {
+			if !recursive[up] {
+				continue
+			}
+			if i > 255 {
+				return &CompileError{"too many upvalues in function"}
+			}
+			c.loadSymbol(group[ass])
+			c.loadSymbol(up)
+			c.emit(code.OpSetUpvalue, i)
+		}
+	}
+	return nil
+}
+
+// Take apart the value on top of the stack with a pattern, storing
+// the values bound by the pattern in the symbols of its variables.
+// Fails at runtime if the value does not match
+func (c *Compiler) compileBindPattern(p ast.Pattern, symbols []Symbol) error {
+	c.emit(code.OpMatch, c.addConstant(&CompiledPattern{Pattern: p}))
+	jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+	for i := len(symbols) - 1; i >= 0; i-- {
+		if err := c.storeSymbol(symbols[i]); err != nil {
+			return err
+		}
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+	c.emit(code.OpMatchFailure)
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// Compile an expression. If tail is true, the expression is in tail
+// position in the body of a function, and calls are compiled to tail
+// calls that do not grow the call stack
+func (c *Compiler) compile(exp ast.Expression, tail bool) error {
+	switch ve := exp.(type) {
+	case *ast.UnitLiteral, *ast.EventExpr:
+		// Events are only checked against policies by the typechecker
+		c.emit(code.OpUnit)
+	case *ast.BoolLiteral:
+		if ve.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&eval.IntegerValue{Value: ve.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&eval.FloatValue{Value: ve.Value}))
+	case *ast.ComplexLiteral:
+		c.emit(code.OpConstant, c.addConstant(&eval.ComplexValue{Value: ve.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&eval.StringValue{Value: ve.Value}))
+	case *ast.RuneLiteral:
+		r := []rune(ve.Value)
+		if len(r) != 1 {
+			return &CompileError{fmt.Sprintf("invalid rune literal %s", ve.Value)}
+		}
+		c.emit(code.OpConstant, c.addConstant(&eval.RuneValue{Value: r[0]}))
+
+	case *ast.IdentifierExpr:
+		symbol, ok := c.symbolTable.Resolve(ve.Identifier)
+		if !ok {
+			// The dictionaries of the builtin instances that
+			// are not bound are constants
+			if inst := eval.BuiltinInstance(ve.Identifier); inst != nil {
+				c.emit(code.OpConstant, c.addConstant(inst.Value))
+				return nil
+			}
+			return &CompileError{fmt.Sprintf("unbound identifier %s", ve.Identifier.FullString())}
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.AnnotExpr:
+		return c.compile(ve.Body, tail)
+
+	case *ast.EffectExpr:
+		return c.compile(ve.Body, tail)
+
+	case *ast.RestrictExpr:
+		c.emit(code.OpConstant, c.addConstant(eval.NewRestriction(ve.Shape)))
+		if err := c.compile(ve.Body, false); err != nil {
+			return err
+		}
+		c.emit(code.OpCall)
+
+	case *ast.RecordLiteral:
+		for _, f := range ve.Fields {
+			c.emit(code.OpConstant, c.addConstant(&eval.StringValue{Value: f.Label}))
+			if err := c.compile(f.Value, false); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpRecord, len(ve.Fields))
+
+	case *ast.TupleLiteral:
+		for _, e := range ve.Elements {
+			if err := c.compile(e, false); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpTuple, len(ve.Elements))
+
+	case *ast.ListLiteral:
+		for _, e := range ve.Elements {
+			if err := c.compile(e, false); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpList, len(ve.Elements))
+
+	case *ast.MatrixLiteral:
+		for _, row := range ve.Rows {
+			for _, e := range row {
+				if err := c.compile(e, false); err != nil {
+					return err
+				}
+			}
+		}
+		sparse := 0
+		if ve.Sparse {
+			sparse = 1
+		}
+		c.emit(code.OpMatrix, len(ve.Rows), len(ve.Rows[0]), sparse)
+
+	case *ast.AccessExpr:
+		if err := c.compile(ve.Record, false); err != nil {
+			return err
+		}
+		c.emit(code.OpAccess, c.addConstant(&eval.StringValue{Value: ve.Field}))
+
+	case *ast.MatchExpr:
+		return c.compileMatch(ve, tail)
+
+	case *ast.PrefixExpression:
+		op, ok := code.PrefixOpcodes[ve.ResolvedOperator()]
+		if !ok {
+			return &CompileError{fmt.Sprintf("unknown operator %s", ve.Operator)}
+		}
+		if err := c.compile(ve.Right, false); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.PostfixExpression:
+		op, ok := code.PostfixOpcodes[ve.Operator]
+		if !ok {
+			return &CompileError{fmt.Sprintf("unknown operator %s", ve.Operator)}
+		}
+		if err := c.compile(ve.Left, false); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.InfixExpression:
+		return c.compileInfixExpr(ve, tail)
+
+	case *ast.IfExpression:
+		if err := c.compile(ve.Condition, false); err != nil {
+			return err
+		}
+		jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+
+		if err := c.compile(ve.Consequence, tail); err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+		if err := c.compile(ve.Alternative, tail); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.FunctionLiteral:
+		_, err := c.compileFunction(ve, nil)
+		return err
+
+	case *ast.FixExpr:
+		fn, ok := ve.Body.(*ast.FunctionLiteral)
+		if !ok {
+			return &CompileError{"fixed point of a value that is not a function"}
+		}
+		_, err := c.compileFunction(fn, &ve.Param.Identifier)
+		return err
+
+	case *ast.LetExpression:
+		// Let bindings live in the scope of the enclosing function
+		if err := c.compileAssignments(ve.Assignments); err != nil {
+			return err
+		}
+		return c.compile(ve.Body, tail)
+
+	case *ast.ApplyExpr:
+		// Immediate applications of a function literal bind the
+		// argument in the current scope instead of allocating a closure
+		if fn, ok := ve.Function.(*ast.FunctionLiteral); ok {
+			if err := c.compile(ve.Arg, false); err != nil {
+				return err
+			}
+			if err := c.storeSymbol(c.symbolTable.Define(fn.Param.Identifier)); err != nil {
+				return err
+			}
+			return c.compile(fn.Body, tail)
+		}
+
+		if err := c.compile(ve.Function, false); err != nil {
+			return err
+		}
+		if err := c.compile(ve.Arg, false); err != nil {
+			return err
+		}
+		if tail {
+			c.emit(code.OpTailCall)
+		} else {
+			c.emit(code.OpCall)
+		}
+
+	default:
+		return &CompileError{fmt.Sprintf("cannot compile expression %s", exp)}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileInfixExpr(exp *ast.InfixExpression, tail bool) error {
+	if err := c.compileOperand(exp, exp.Left); err != nil {
+		return err
+	}
+
+	switch exp.Operator {
+	case token.SEMI:
+		c.emit(code.OpPop)
+		return c.compile(exp.Right, tail)
+	case token.LAND:
+		jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+		if err := c.compile(exp.Right, tail); err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	case token.OR:
+		jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+		c.emit(code.OpTrue)
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+		if err := c.compile(exp.Right, tail); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+
+	op, ok := code.InfixOpcodes[exp.ResolvedOperator()]
+	if !ok {
+		return &CompileError{fmt.Sprintf("unknown operator %s", exp.Operator)}
+	}
+	if err := c.compileOperand(exp, exp.Right); err != nil {
+		return err
+	}
+	c.emit(op)
+	return nil
+}
+
+// Compile an operand of an infix expression. The operands of an
+// equality are restricted to the shape of the compared values
+func (c *Compiler) compileOperand(exp *ast.InfixExpression, operand ast.Expression) error {
+	if exp.Shape == nil {
+		return c.compile(operand, false)
+	}
+	c.emit(code.OpConstant, c.addConstant(eval.NewRestriction(exp.Shape)))
+	if err := c.compile(operand, false); err != nil {
+		return err
+	}
+	c.emit(code.OpCall)
+	return nil
+}
+
+// Compile a match expression. The matched value stays on the stack
+// until an arm matches it. The variables bound by the pattern of the
+// arm live in the scope of the enclosing function, like let bindings
+func (c *Compiler) compileMatch(m *ast.MatchExpr, tail bool) error {
+	if err := c.compile(m.Scrutinee, false); err != nil {
+		return err
+	}
+
+	jumpPositions := []int{}
+	for _, arm := range m.Arms {
+		c.emit(code.OpMatch, c.addConstant(&CompiledPattern{Pattern: arm.Pattern}))
+		jumpNotTruePos := c.emit(code.OpJumpNotTrue, 9999)
+
+		vars := ast.PatternVariables(arm.Pattern)
+		for i := len(vars) - 1; i >= 0; i-- {
+			if err := c.storeSymbol(c.symbolTable.Define(vars[i])); err != nil {
+				return err
+			}
+		}
+		if err := c.compile(arm.Body, tail); err != nil {
+			return err
+		}
+		jumpPositions = append(jumpPositions, c.emit(code.OpJump, 9999))
+		c.changeOperand(jumpNotTruePos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpMatchFailure)
+
+	for _, pos := range jumpPositions {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// Compile a function literal into a closure. If self is not nil,
+// the function can refer to itself with that identifier. Returns
+// the symbols captured by the closure, in the order of its upvalues
+func (c *Compiler) compileFunction(fn *ast.FunctionLiteral, self *ast.UniqueIdentifier) ([]Symbol, error) {
+	c.enterScope()
+
+	if self != nil {
+		c.symbolTable.DefineFunctionName(*self)
+	}
+	c.symbolTable.Define(fn.Param.Identifier)
+
+	if err := c.compile(fn.Body, true); err != nil {
+		c.leaveScope()
+		return nil, err
+	}
+	c.emit(code.OpReturnValue)
+
+	upvalues := c.symbolTable.Upvalues
+	numLocals := c.symbolTable.NumDefinitions()
+	instructions := c.leaveScope()
+
+	if numLocals > 256 {
+		return nil, &CompileError{"too many local bindings in function"}
+	}
+
+	for _, s := range upvalues {
+		c.loadSymbol(s)
+	}
+
+	compiled := &CompiledFunction{
+		Instructions: instructions,
+		NumLocals:    numLocals,
+	}
+	c.emit(code.OpClosure, c.addConstant(compiled), len(upvalues))
+	return upvalues, nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case UpvalueScope:
+		c.emit(code.OpGetUpvalue, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		if s.Index >= MaxGlobals {
+			return &CompileError{"too many global bindings in program"}
+		}
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		if s.Index > 255 {
+			return &CompileError{"too many local bindings in function"}
+		}
+		c.emit(code.OpSetLocal, s.Index)
+	default:
+		return &CompileError{fmt.Sprintf("cannot bind %s", s.Identifier.FullString())}
+	}
+	return nil
+}
+
+// ======================================================================
+// Instruction emission helpers
+// ======================================================================
+
+func (c *Compiler) addConstant(v eval.Value) int {
+	c.constants = append(c.constants, v)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+// Emit an instruction and return its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+	return pos
+}
+
+// Replace the operand of an already emitted instruction
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	ins := code.Make(op, operand)
+	copy(c.scopes[c.scopeIndex].instructions[opPos:], ins)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
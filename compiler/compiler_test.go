@@ -0,0 +1,217 @@
+package compiler
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/alpha"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/code"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/lexer"
+	"github.com/0x0f0f0f/gobba-golang/parser"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func concatInstructions(s ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestResolveUpvalues(t *testing.T) {
+	a := ast.UniqueIdentifier{Value: "a"}
+	b := ast.UniqueIdentifier{Value: "b"}
+	c := ast.UniqueIdentifier{Value: "c"}
+
+	global := NewSymbolTable()
+	global.Define(a)
+	first := NewEnclosedSymbolTable(global)
+	first.Define(b)
+	second := NewEnclosedSymbolTable(first)
+	second.Define(c)
+
+	expected := map[ast.UniqueIdentifier]Symbol{
+		a: {Identifier: a, Scope: GlobalScope, Index: 0},
+		b: {Identifier: b, Scope: UpvalueScope, Index: 0},
+		c: {Identifier: c, Scope: LocalScope, Index: 0},
+	}
+	for id, sym := range expected {
+		result, ok := second.Resolve(id)
+		assert.True(t, ok)
+		assert.Equal(t, sym, result)
+	}
+	assert.Equal(t, []Symbol{{Identifier: b, Scope: LocalScope, Index: 0}}, second.Upvalues)
+
+	_, ok := second.Resolve(ast.UniqueIdentifier{Value: "d"})
+	assert.False(t, ok)
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input        string
+		instructions code.Instructions
+	}{
+		{"1 + 2", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAdd),
+		)},
+		{"if true then 1 else 2", concatInstructions(
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTrue, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpJump, 13),
+			code.Make(code.OpConstant, 1),
+		)},
+		{"let x = 1; x", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+		)},
+		{"{x = 1, y = true}.y", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpConstant, 2),
+			code.Make(code.OpTrue),
+			code.Make(code.OpRecord, 2),
+			code.Make(code.OpAccess, 3),
+		)},
+		{"fun(x) { fun(y) { x + y } }(2)", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpClosure, 1, 0),
+		)},
+		{"match 1 with | 2 -> true | x -> x", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpMatch, 1),
+			code.Make(code.OpJumpNotTrue, 13),
+			code.Make(code.OpTrue),
+			code.Make(code.OpJump, 29),
+			code.Make(code.OpMatch, 2),
+			code.Make(code.OpJumpNotTrue, 28),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpJump, 29),
+			code.Make(code.OpMatchFailure),
+		)},
+		{"(1, true)", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpTrue),
+			code.Make(code.OpTuple, 2),
+		)},
+		{"[1, true]", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpTrue),
+			code.Make(code.OpList, 2),
+		)},
+		{"1 :: []", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpList, 0),
+			code.Make(code.OpCons),
+		)},
+		{"sparse [|1; 2|]'", concatInstructions(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpMatrix, 2, 1, 1),
+			code.Make(code.OpTranspose),
+		)},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseSingleExpression()
+		assert.Len(t, p.Errors(), 0)
+		alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
+		assert.Nil(t, err)
+
+		c := New()
+		err = c.Compile(*alphaconv_program)
+		if assert.Nil(t, err, tt.input) {
+			assert.Equal(t, tt.instructions.String(), c.Bytecode().Instructions.String(), tt.input)
+		}
+	}
+}
+
+func TestCompileClosure(t *testing.T) {
+	p := parser.New(lexer.New("fun(x) { fun(y) { x + y } }"))
+	program := p.ParseSingleExpression()
+	assert.Len(t, p.Errors(), 0)
+	alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
+	assert.Nil(t, err)
+
+	c := New()
+	assert.Nil(t, c.Compile(*alphaconv_program))
+	bytecode := c.Bytecode()
+
+	inner, ok := bytecode.Constants[0].(*CompiledFunction)
+	assert.True(t, ok)
+	assert.Equal(t, concatInstructions(
+		code.Make(code.OpGetUpvalue, 0),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	).String(), inner.Instructions.String())
+
+	outer, ok := bytecode.Constants[1].(*CompiledFunction)
+	assert.True(t, ok)
+	assert.Equal(t, concatInstructions(
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpClosure, 0, 1),
+		code.Make(code.OpReturnValue),
+	).String(), outer.Instructions.String())
+
+	assert.Equal(t, code.Make(code.OpClosure, 1, 0), []byte(bytecode.Instructions))
+}
+
+func TestCompileTailCall(t *testing.T) {
+	p := parser.New(lexer.New("fun(f, x) { if x then f(1) else f(2) + 1 }"))
+	program := p.ParseSingleExpression()
+	assert.Len(t, p.Errors(), 0)
+	alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
+	assert.Nil(t, err)
+
+	c := New()
+	assert.Nil(t, c.Compile(*alphaconv_program))
+	bytecode := c.Bytecode()
+
+	// Only the call in the then branch is in tail position
+	inner, ok := bytecode.Constants[3].(*CompiledFunction)
+	assert.True(t, ok)
+	assert.Equal(t, concatInstructions(
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpJumpNotTrue, 14),
+		code.Make(code.OpGetUpvalue, 0),
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpTailCall),
+		code.Make(code.OpJump, 24),
+		code.Make(code.OpGetUpvalue, 0),
+		code.Make(code.OpConstant, 1),
+		code.Make(code.OpCall),
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	).String(), inner.Instructions.String())
+}
+
+func TestCompileLimits(t *testing.T) {
+	input := "[" + strings.Repeat("1, ", MaxConstants) + "1]"
+	p := parser.New(lexer.New(input))
+	program := p.ParseSingleExpression()
+	assert.Len(t, p.Errors(), 0)
+	alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
+	assert.Nil(t, err)
+	assert.IsType(t, &CompileError{}, New().Compile(*alphaconv_program))
+
+	p = parser.New(lexer.New("package main; let x = 1"))
+	prog := p.ParseProgram()
+	assert.Len(t, p.Errors(), 0)
+	alphaconv_prog, err := alpha.ProgramAlphaConversion(prog)
+	assert.Nil(t, err)
+	s := NewSymbolTable()
+	for i := 0; i < MaxGlobals; i++ {
+		s.Define(ast.UniqueIdentifier{Value: "g", Id: i})
+	}
+	assert.IsType(t, &CompileError{}, NewWithState(s, []eval.Value{}).CompileProgram(alphaconv_prog))
+}
@@ -0,0 +1,20 @@
+package compiler
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+)
+
+// This file contains the patterns of the compiled programs. The
+// analysis of the patterns is done by the typechecker, see
+// typecheck.ProgramWarnings
+
+const PATTERN_VALUE = "pattern"
+
+// A pattern in the constant pool, matched by OpMatch
+type CompiledPattern struct {
+	Pattern ast.Pattern
+}
+
+func (p *CompiledPattern) Type() eval.ValueType { return PATTERN_VALUE }
+func (p *CompiledPattern) String() string       { return "<pattern " + p.Pattern.String() + ">" }
@@ -0,0 +1,116 @@
+package compiler
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	UpvalueScope  SymbolScope = "UPVALUE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Describes where the value bound to an identifier can be found at runtime
+type Symbol struct {
+	Identifier ast.UniqueIdentifier
+	Scope      SymbolScope
+	Index      int
+}
+
+// A symbol table holds the symbols of a function being compiled.
+// Since the AST is α-converted, symbols are indexed by unique identifiers
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[ast.UniqueIdentifier]Symbol
+	numDefinitions int
+	// Symbols of the enclosing functions captured by this function
+	Upvalues []Symbol
+}
+
+// Create a new symbol table for the global scope
+func NewSymbolTable() *SymbolTable {
+	s := make(map[ast.UniqueIdentifier]Symbol)
+	return &SymbolTable{store: s, Upvalues: []Symbol{}}
+}
+
+// Create a new symbol table for a function enclosed by outer
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Copy a symbol table, so that symbols can be defined in the
+// copy without changing the original one
+func (s *SymbolTable) Copy() *SymbolTable {
+	c := &SymbolTable{
+		Outer:          s.Outer,
+		store:          make(map[ast.UniqueIdentifier]Symbol, len(s.store)),
+		numDefinitions: s.numDefinitions,
+		Upvalues:       append([]Symbol{}, s.Upvalues...),
+	}
+	for id, symbol := range s.store {
+		c.store[id] = symbol
+	}
+	return c
+}
+
+// Define a new global or local symbol
+func (s *SymbolTable) Define(id ast.UniqueIdentifier) Symbol {
+	symbol := Symbol{Identifier: id, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[id] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// Define the name a recursive function uses to refer to itself
+func (s *SymbolTable) DefineFunctionName(id ast.UniqueIdentifier) Symbol {
+	symbol := Symbol{Identifier: id, Scope: FunctionScope, Index: 0}
+	s.store[id] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineUpvalue(original Symbol) Symbol {
+	s.Upvalues = append(s.Upvalues, original)
+
+	symbol := Symbol{
+		Identifier: original.Identifier,
+		Scope:      UpvalueScope,
+		Index:      len(s.Upvalues) - 1,
+	}
+	s.store[original.Identifier] = symbol
+	return symbol
+}
+
+// Search for a symbol. Symbols that are local to an enclosing
+// function are captured as upvalues
+func (s *SymbolTable) Resolve(id ast.UniqueIdentifier) (Symbol, bool) {
+	symbol, ok := s.store[id]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(id)
+	if !ok {
+		return symbol, ok
+	}
+	if symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+	return s.defineUpvalue(symbol), true
+}
+
+// Number of slots needed for the symbols defined in this scope
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
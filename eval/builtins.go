@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"io"
+	"math/rand"
+	"os"
+)
+
+// This file contains the functions of the prelude that are implemented
+// in Go. The rest of the prelude is written in gobba, see repl/prelude.go
+
+// A function implemented in Go. Builtins are curried like constructors,
+// the function is called once all of its arguments are given
+type BuiltinValue struct {
+	Name  string
+	Arity int
+	Args  []Value
+	Fn    func(args []Value) (Value, error)
+}
+
+func (v *BuiltinValue) Type() ValueType { return CLOSURE_VALUE }
+func (v *BuiltinValue) String() string  { return "<fun>" }
+
+// Apply a builtin to its next argument
+func (v *BuiltinValue) Apply(arg Value) (Value, error) {
+	args := make([]Value, len(v.Args), len(v.Args)+1)
+	copy(args, v.Args)
+	args = append(args, arg)
+	if len(args) == v.Arity {
+		return v.Fn(args)
+	}
+	return &BuiltinValue{Name: v.Name, Arity: v.Arity, Args: args, Fn: v.Fn}, nil
+}
+
+// The function wrapping or unwrapping the values of a nominal type.
+// Nominal types are erased at runtime, it returns its argument
+func NewWrapper(name string) *BuiltinValue {
+	return &BuiltinValue{Name: name, Arity: 1, Args: []Value{}, Fn: func(args []Value) (Value, error) {
+		return args[0], nil
+	}}
+}
+
+// A builtin function with its type
+type Builtin struct {
+	Name  string
+	Type  ast.TypeValue
+	Value *BuiltinValue
+}
+
+func newBuiltin(name string, t ast.TypeValue, arity int, fn func([]Value) (Value, error)) *Builtin {
+	return &Builtin{
+		Name:  name,
+		Type:  t,
+		Value: &BuiltinValue{Name: name, Arity: arity, Args: []Value{}, Fn: fn},
+	}
+}
+
+// The builtin functions, in the order in which they are bound
+var Builtins = []*Builtin{
+	newBuiltin("head", forAllElements(&ast.LambdaType{Domain: listOfElements, Codomain: tElement, Effects: effects(token.EFAIL)}), 1, listHead),
+	newBuiltin("tail", forAllElements(&ast.LambdaType{Domain: listOfElements, Codomain: listOfElements, Effects: effects(token.EFAIL)}), 1, listTail),
+	newBuiltin("length", forAllElements(&ast.LambdaType{Domain: listOfElements, Codomain: ast.TINT}), 1, listLength),
+	newBuiltin("empty", forAllElements(&ast.LambdaType{Domain: listOfElements, Codomain: ast.TBOOL}), 1, listEmpty),
+	newBuiltin("print", &ast.LambdaType{Domain: ast.TSTRING, Codomain: &ast.UnitType{}, Effects: effects(token.EIO)}, 1, printString),
+	newBuiltin("random", &ast.LambdaType{Domain: &ast.UnitType{}, Codomain: ast.TFLOAT, Effects: effects(token.ENONDET)}, 1, randomFloat),
+	newBuiltin("fail", forAllElements(&ast.LambdaType{Domain: ast.TSTRING, Codomain: tElement, Effects: effects(token.EFAIL)}), 1, failWith),
+}
+
+// The writer where the output of print goes
+var Stdout io.Writer = os.Stdout
+
+// The effect row of a builtin
+func effects(labels ...string) *ast.EffectRow {
+	return ast.NewEffectRow(labels, nil)
+}
+
+// The types of the list functions are polymorphic
+// in the type of the elements of the list
+var tElement = ast.NewVariableType("a")
+var listOfElements = &ast.ListType{Element: tElement}
+
+func forAllElements(t ast.TypeValue) ast.TypeValue {
+	return &ast.ForAllType{Identifier: tElement.Identifier, Type: t}
+}
+
+// ======================================================================
+// Lists
+// ======================================================================
+
+func listHead(args []Value) (Value, error) {
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if l.IsEmpty() {
+		return nil, emptyListError("head")
+	}
+	return l.Head, nil
+}
+
+func listTail(args []Value) (Value, error) {
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if l.IsEmpty() {
+		return nil, emptyListError("tail")
+	}
+	return l.Tail, nil
+}
+
+func listEmpty(args []Value) (Value, error) {
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return boolValue(l.IsEmpty()), nil
+}
+
+func listLength(args []Value) (Value, error) {
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	n := int64(0)
+	for ; !l.IsEmpty(); l = l.Tail {
+		n++
+	}
+	return &IntegerValue{n}, nil
+}
+
+// ======================================================================
+// Effects
+// ======================================================================
+
+func printString(args []Value) (Value, error) {
+	s, ok := args[0].(*StringValue)
+	if !ok {
+		return nil, typeMismatch(STRING_VALUE, args[0])
+	}
+	fmt.Fprintln(Stdout, s.Value)
+	return unit, nil
+}
+
+func randomFloat(args []Value) (Value, error) {
+	return &FloatValue{rand.Float64()}, nil
+}
+
+func failWith(args []Value) (Value, error) {
+	s, ok := args[0].(*StringValue)
+	if !ok {
+		return nil, typeMismatch(STRING_VALUE, args[0])
+	}
+	return nil, &RuntimeError{s.Value}
+}
@@ -0,0 +1,41 @@
+package eval
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// Contains the values bound to unique identifiers.
+// Since the AST is α-converted before evaluation, identifiers
+// are already disambiguated and lookup is just a chain of maps.
+type Environment struct {
+	store map[ast.UniqueIdentifier]Value
+	outer *Environment
+}
+
+// Create a new empty environment
+func NewEnvironment() *Environment {
+	s := make(map[ast.UniqueIdentifier]Value)
+	return &Environment{store: s, outer: nil}
+}
+
+// Create a new environment enclosed by an outer one
+func NewEnvironmentExtension(e *Environment) *Environment {
+	n := NewEnvironment()
+	n.outer = e
+	return n
+}
+
+// Search for the value of an identifier in the environment
+func (e *Environment) Get(id ast.UniqueIdentifier) (Value, bool) {
+	v, ok := e.store[id]
+	if !ok && e.outer != nil {
+		return e.outer.Get(id)
+	}
+	return v, ok
+}
+
+// Bind a value to an identifier in the innermost environment
+func (e *Environment) Set(id ast.UniqueIdentifier, v Value) Value {
+	e.store[id] = v
+	return v
+}
@@ -0,0 +1,55 @@
+package eval
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains definitions for runtime errors
+
+type RuntimeError struct {
+	Msg string
+}
+
+func (re RuntimeError) Error() string {
+	return fmt.Sprintf("runtime error: %s", re.Msg)
+}
+
+func unboundError(id ast.UniqueIdentifier) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("unbound identifier %s", id.FullString())}
+}
+
+func notAFunctionError(v Value) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("cannot apply a value of type %s", v.Type())}
+}
+
+func unknownOperatorError(op string) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("unknown operator %s", op)}
+}
+
+func divisionByZeroError() *RuntimeError {
+	return &RuntimeError{"division by zero"}
+}
+
+func matchFailureError(v Value) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("no pattern matches the value %s", v)}
+}
+
+func emptyListError(fn string) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("%s of an empty list", fn)}
+}
+
+func notComparableError(v Value) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("values of type %s cannot be compared", v.Type())}
+}
+
+func dimensionMismatchError(op string, l, r *MatrixValue) *RuntimeError {
+	return &RuntimeError{
+		fmt.Sprintf("dimension mismatch: cannot apply %s to a %dx%d and a %dx%d matrix",
+			op, l.Rows, l.Cols, r.Rows, r.Cols),
+	}
+}
+
+func indexOutOfBoundsError(i, j int64, m *MatrixValue) *RuntimeError {
+	return &RuntimeError{fmt.Sprintf("index (%d, %d) out of bounds of a %dx%d matrix", i, j, m.Rows, m.Cols)}
+}
@@ -0,0 +1,426 @@
+package eval
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+// This file contains the tree-walking evaluator for α-converted expressions.
+// Expressions in tail position (branches of an if, arms of a match, the
+// body of an applied function, the right operand of sequencing and of
+// boolean operators) are evaluated by the same loop iteration instead of
+// a recursive call, so that tail calls, including mutually recursive
+// ones, run in constant stack space.
+
+// Maximum depth of the nested evaluations, the evaluations of
+// expressions that are not in tail position
+const MaxDepth = 1 << 19
+
+// Depth of the nested evaluations in progress
+var depth int
+
+// Evaluate an α-converted and typechecked expression in an environment
+func (env *Environment) EvalExpr(exp ast.Expression) (Value, error) {
+	if depth >= MaxDepth {
+		return nil, &RuntimeError{"stack overflow"}
+	}
+	depth++
+	defer func() { depth-- }()
+	return env.evalExpr(exp)
+}
+
+func (env *Environment) evalExpr(exp ast.Expression) (Value, error) {
+	for {
+		switch ve := exp.(type) {
+		case *ast.UnitLiteral:
+			return unit, nil
+		case *ast.EventExpr:
+			// Events are checked against policies by the typechecker
+			return unit, nil
+		case *ast.IntegerLiteral:
+			return &IntegerValue{ve.Value}, nil
+		case *ast.FloatLiteral:
+			return &FloatValue{ve.Value}, nil
+		case *ast.ComplexLiteral:
+			return &ComplexValue{ve.Value}, nil
+		case *ast.BoolLiteral:
+			return boolValue(ve.Value), nil
+		case *ast.StringLiteral:
+			return &StringValue{ve.Value}, nil
+		case *ast.RuneLiteral:
+			r := []rune(ve.Value)
+			if len(r) != 1 {
+				return nil, &RuntimeError{fmt.Sprintf("invalid rune literal %s", ve.Value)}
+			}
+			return &RuneValue{r[0]}, nil
+
+		case *ast.IdentifierExpr:
+			v, ok := env.Get(ve.Identifier)
+			if !ok {
+				if inst := BuiltinInstance(ve.Identifier); inst != nil {
+					return inst.Value, nil
+				}
+				return nil, unboundError(ve.Identifier)
+			}
+			return v, nil
+
+		case *ast.LetExpression:
+			nenv, err := env.bindAssignments(ve.Assignments)
+			if err != nil {
+				return nil, err
+			}
+			exp = ve.Body
+			env = nenv
+
+		case *ast.RecordLiteral:
+			fields := make(map[string]Value, len(ve.Fields))
+			for _, f := range ve.Fields {
+				v, err := env.EvalExpr(f.Value)
+				if err != nil {
+					return nil, err
+				}
+				fields[f.Label] = v
+			}
+			return &RecordValue{Fields: fields}, nil
+
+		case *ast.TupleLiteral:
+			elems := make([]Value, len(ve.Elements))
+			for i, e := range ve.Elements {
+				v, err := env.EvalExpr(e)
+				if err != nil {
+					return nil, err
+				}
+				elems[i] = v
+			}
+			return &TupleValue{Elements: elems}, nil
+
+		case *ast.ListLiteral:
+			elems := make([]Value, len(ve.Elements))
+			for i, e := range ve.Elements {
+				v, err := env.EvalExpr(e)
+				if err != nil {
+					return nil, err
+				}
+				elems[i] = v
+			}
+			return NewListValue(elems), nil
+
+		case *ast.MatrixLiteral:
+			elems := make([]Value, 0, len(ve.Rows)*len(ve.Rows[0]))
+			for _, row := range ve.Rows {
+				for _, e := range row {
+					v, err := env.EvalExpr(e)
+					if err != nil {
+						return nil, err
+					}
+					elems = append(elems, v)
+				}
+			}
+			return NewMatrixValue(len(ve.Rows), len(ve.Rows[0]), ve.Sparse, elems)
+
+		case *ast.AccessExpr:
+			rec, err := env.EvalExpr(ve.Record)
+			if err != nil {
+				return nil, err
+			}
+			rv, ok := rec.(*RecordValue)
+			if !ok {
+				return nil, typeMismatch(RECORD_VALUE, rec)
+			}
+			return rv.Access(ve.Field)
+
+		case *ast.MatchExpr:
+			v, err := env.EvalExpr(ve.Scrutinee)
+			if err != nil {
+				return nil, err
+			}
+			arm, nenv, err := env.matchArms(ve.Arms, v)
+			if err != nil {
+				return nil, err
+			}
+			exp = arm.Body
+			env = nenv
+
+		case *ast.AnnotExpr:
+			// Type annotations have no runtime meaning
+			exp = ve.Body
+
+		case *ast.EffectExpr:
+			// Effects are checked by the typechecker
+			exp = ve.Body
+
+		case *ast.RestrictExpr:
+			v, err := env.EvalExpr(ve.Body)
+			if err != nil {
+				return nil, err
+			}
+			return Restrict(v, ve.Shape), nil
+
+		case *ast.PrefixExpression:
+			right, err := env.EvalExpr(ve.Right)
+			if err != nil {
+				return nil, err
+			}
+			return ApplyPrefix(ve.ResolvedOperator(), right)
+
+		case *ast.PostfixExpression:
+			left, err := env.EvalExpr(ve.Left)
+			if err != nil {
+				return nil, err
+			}
+			return ApplyPostfix(ve.Operator, left)
+
+		case *ast.InfixExpression:
+			left, err := env.EvalExpr(ve.Left)
+			if err != nil {
+				return nil, err
+			}
+			tail, err := tailOperand(ve.Operator, left)
+			if err != nil {
+				return nil, err
+			}
+			if tail {
+				exp = ve.Right
+				continue
+			}
+			if ve.Operator == token.LAND || ve.Operator == token.OR {
+				// Short circuited
+				return left, nil
+			}
+			right, err := env.EvalExpr(ve.Right)
+			if err != nil {
+				return nil, err
+			}
+			if ve.Shape != nil {
+				left, right = Restrict(left, ve.Shape), Restrict(right, ve.Shape)
+			}
+			return ApplyInfix(ve.ResolvedOperator(), left, right)
+
+		case *ast.IfExpression:
+			cond, err := env.EvalExpr(ve.Condition)
+			if err != nil {
+				return nil, err
+			}
+			b, err := toBool(cond)
+			if err != nil {
+				return nil, err
+			}
+			if b {
+				exp = ve.Consequence
+			} else {
+				exp = ve.Alternative
+			}
+
+		case *ast.FunctionLiteral:
+			return &ClosureValue{
+				Param: ve.Param.Identifier,
+				Body:  ve.Body,
+				Env:   env,
+			}, nil
+
+		case *ast.FixExpr:
+			// The closure is defined in an environment
+			// that binds the closure itself to the fixed point parameter
+			fn, ok := ve.Body.(*ast.FunctionLiteral)
+			if !ok {
+				return nil, &RuntimeError{"fixed point of a value that is not a function"}
+			}
+			fenv := NewEnvironmentExtension(env)
+			clos := &ClosureValue{
+				Param: fn.Param.Identifier,
+				Body:  fn.Body,
+				Env:   fenv,
+			}
+			fenv.Set(ve.Param.Identifier, clos)
+			return clos, nil
+
+		case *ast.ApplyExpr:
+			fun, err := env.EvalExpr(ve.Function)
+			if err != nil {
+				return nil, err
+			}
+			arg, err := env.EvalExpr(ve.Arg)
+			if err != nil {
+				return nil, err
+			}
+			if ctor, ok := fun.(*ConstructorValue); ok {
+				return ctor.Apply(arg), nil
+			}
+			if builtin, ok := fun.(*BuiltinValue); ok {
+				return builtin.Apply(arg)
+			}
+			if coerced, ok := fun.(*CoercedFunction); ok {
+				// The result is converted after the call returns
+				return coerced.Apply(arg)
+			}
+			clos, ok := fun.(*ClosureValue)
+			if !ok {
+				return nil, notAFunctionError(fun)
+			}
+			// Tail call: continue with the body of the function
+			env = NewEnvironmentExtension(clos.Env)
+			env.Set(clos.Param, arg)
+			exp = clos.Body
+
+		default:
+			return nil, &RuntimeError{fmt.Sprintf("cannot evaluate expression %s", exp)}
+		}
+	}
+}
+
+// Returns true if the right operand of an infix operator is in tail
+// position: it is evaluated and its value is the value of the whole
+// expression. Otherwise, both operands are evaluated before applying the
+// operator, or the boolean operator is short circuited
+func tailOperand(op string, left Value) (bool, error) {
+	switch op {
+	case token.SEMI:
+		// Sequencing discards the value of the left operand
+		return true, nil
+	case token.LAND, token.OR:
+		b, err := toBool(left)
+		if err != nil {
+			return false, err
+		}
+		return b != (op == token.OR), nil
+	}
+	return false, nil
+}
+
+// Apply a functional value to an argument
+func Apply(fun, arg Value) (Value, error) {
+	if ctor, ok := fun.(*ConstructorValue); ok {
+		return ctor.Apply(arg), nil
+	}
+	if builtin, ok := fun.(*BuiltinValue); ok {
+		return builtin.Apply(arg)
+	}
+	if coerced, ok := fun.(*CoercedFunction); ok {
+		return coerced.Apply(arg)
+	}
+	clos, ok := fun.(*ClosureValue)
+	if !ok {
+		return nil, notAFunctionError(fun)
+	}
+	nenv := NewEnvironmentExtension(clos.Env)
+	nenv.Set(clos.Param, arg)
+	return nenv.EvalExpr(clos.Body)
+}
+
+// Evaluate the values of a list of assignments
+func (env *Environment) evalAssignments(asss []*ast.Assignment) ([]Value, error) {
+	values := make([]Value, len(asss))
+	for i, ass := range asss {
+		v, err := env.EvalExpr(ass.Value)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// Evaluate the values of a list of assignments and bind them in a
+// new extension of the environment. The values are evaluated in the
+// extension, so that mutually recursive functions see each other
+func (env *Environment) bindAssignments(asss []*ast.Assignment) (*Environment, error) {
+	nenv := NewEnvironmentExtension(env)
+	values, err := nenv.evalAssignments(asss)
+	if err != nil {
+		return nil, err
+	}
+	if err := nenv.setAssignments(asss, values); err != nil {
+		return nil, err
+	}
+	return nenv, nil
+}
+
+// Bind the names of a list of assignments, or the variables of their
+// patterns, to the values of the assignments in the environment
+func (env *Environment) setAssignments(asss []*ast.Assignment, values []Value) error {
+	for i, ass := range asss {
+		if ass.Pattern != nil {
+			if err := env.bindPattern(ass.Pattern, values[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		env.Set(ass.Name.Identifier, values[i])
+	}
+	return nil
+}
+
+// Evaluate a top level statement. Let statements evaluate all their
+// values before binding the names in the environment, and have value
+// unit. Type declarations bind their constructors, newtype declarations
+// bind their wrapping functions, aliases and policy declarations have
+// no runtime meaning. Interface declarations bind
+// their methods, instance declarations bind their dictionary
+func (env *Environment) EvalStatement(stmt ast.Statement) (Value, error) {
+	switch vs := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return env.EvalExpr(vs.Expression)
+	case *ast.LetStatement:
+		values, err := env.evalAssignments(vs.Assignments)
+		if err != nil {
+			return nil, err
+		}
+		if err := env.setAssignments(vs.Assignments, values); err != nil {
+			return nil, err
+		}
+		return unit, nil
+	case *ast.TypeStatement:
+		for _, ctor := range vs.Constructors {
+			env.Set(ctor.Name.Identifier, NewConstructor(ctor.Name.Identifier.Value, len(ctor.Args)))
+		}
+		return unit, nil
+	case *ast.AliasStatement:
+		return unit, nil
+	case *ast.NewtypeStatement:
+		env.Set(vs.Wrap.Identifier, NewWrapper(vs.Wrap.Identifier.Value))
+		env.Set(vs.Unwrap.Identifier, NewWrapper(vs.Unwrap.Identifier.Value))
+		return unit, nil
+	case *ast.PolicyStatement:
+		return unit, nil
+	case *ast.InterfaceStatement:
+		for _, m := range vs.Methods {
+			env.Set(m.Name.Identifier, NewMethod(m.Name.Identifier.Value))
+		}
+		return unit, nil
+	case *ast.InstanceStatement:
+		dict, err := env.EvalExpr(vs.Dictionary())
+		if err != nil {
+			return nil, err
+		}
+		env.Set(vs.Identifier, dict)
+		return unit, nil
+	}
+	return nil, &RuntimeError{fmt.Sprintf("cannot evaluate statement %s", stmt)}
+}
+
+// Evaluate the statements of a program in order. The value of a
+// program is the value of its last statement
+func (env *Environment) EvalProgram(p *ast.Program) (Value, error) {
+	var result Value = unit
+	for _, stmt := range p.Statements {
+		v, err := env.EvalStatement(stmt)
+		if err != nil {
+			return nil, err
+		}
+		result = v
+	}
+	return result, nil
+}
+
+// Evaluate an α-converted program in a new environment
+func ProgramEval(p *ast.Program) (Value, error) {
+	env := NewEnvironment()
+	return env.EvalProgram(p)
+}
+
+// Evaluate an α-converted expression in a new environment
+func ExpressionEval(p ast.Expression) (Value, error) {
+	env := NewEnvironment()
+	return env.EvalExpr(p)
+}
@@ -0,0 +1,107 @@
+package eval
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+// This file contains the instances of the builtin interfaces for the
+// primitive types, see ast.BuiltinInterfaces. The dictionary of an
+// instance is a record of its methods, passed by the typechecker to
+// the overloaded values using the instance
+
+// A builtin instance of an interface for a type
+type Instance struct {
+	Interface string
+	Type      ast.TypeValue
+	Value     *RecordValue
+}
+
+// The identifier bound to the dictionary of the instance
+func (i *Instance) Identifier() ast.UniqueIdentifier {
+	return ast.InstanceIdentifier(i.Interface, i.Type)
+}
+
+// Get the builtin instance whose dictionary is bound to an identifier,
+// nil if there is none. The dictionaries of the Num instances of the
+// number types are passed by the typechecker even where the builtin
+// instances are not bound, see typecheck.findInstance
+func BuiltinInstance(id ast.UniqueIdentifier) *Instance {
+	for _, inst := range Instances {
+		if inst.Identifier() == id {
+			return inst
+		}
+	}
+	return nil
+}
+
+// The builtin instances, in the order in which they are bound
+var Instances = []*Instance{
+	numInstance(ast.TINT, &IntegerValue{0}),
+	numInstance(ast.TFLOAT, &FloatValue{0}),
+	numInstance(ast.TCOMPLEX, &ComplexValue{0}),
+	showInstance(ast.TINT),
+	showInstance(ast.TFLOAT),
+	showInstance(ast.TCOMPLEX),
+	showInstance(ast.TBOOL),
+	showInstance(ast.TRUNE),
+	showInstance(ast.TSTRING),
+	showInstance(&ast.UnitType{}),
+}
+
+// A method of an interface, that takes the dictionary of
+// an instance and returns the method of the instance
+func NewMethod(name string) *BuiltinValue {
+	return &BuiltinValue{Name: name, Arity: 1, Args: []Value{}, Fn: func(args []Value) (Value, error) {
+		dict, ok := args[0].(*RecordValue)
+		if !ok {
+			return nil, typeMismatch(RECORD_VALUE, args[0])
+		}
+		return dict.Access(name)
+	}}
+}
+
+func newInstanceMethod(name string, arity int, fn func([]Value) (Value, error)) *BuiltinValue {
+	return &BuiltinValue{Name: name, Arity: arity, Args: []Value{}, Fn: fn}
+}
+
+// The arithmetical operators of a type of the numeric tower
+func numInstance(t *ast.VariableType, zero Value) *Instance {
+	infix := func(name, op string) *BuiltinValue {
+		op = ast.NumericInstances[op][t.Identifier.Value]
+		return newInstanceMethod(name, 2, func(args []Value) (Value, error) {
+			return ApplyInfix(op, args[0], args[1])
+		})
+	}
+	return &Instance{
+		Interface: token.INUM,
+		Type:      t,
+		Value: &RecordValue{Fields: map[string]Value{
+			"add": infix("add", token.PLUS),
+			"sub": infix("sub", token.MINUS),
+			"mul": infix("mul", token.TIMES),
+			"div": infix("div", token.DIVIDE),
+			"pow": infix("pow", token.TOPOW),
+			"neg": newInstanceMethod("neg", 1, func(args []Value) (Value, error) {
+				return ApplyPrefix(ast.NegationInstances[t.Identifier.Value], args[0])
+			}),
+			"zero": zero,
+			"fromint": newInstanceMethod("fromint", 1, func(args []Value) (Value, error) {
+				return convertNumber(args[0], ValueType(t.Identifier.Value)), nil
+			}),
+		}},
+	}
+}
+
+// The string representation of the values of a primitive type
+func showInstance(t ast.TypeValue) *Instance {
+	return &Instance{
+		Interface: token.ISHOW,
+		Type:      t,
+		Value: &RecordValue{Fields: map[string]Value{
+			"show": newInstanceMethod("show", 1, func(args []Value) (Value, error) {
+				return &StringValue{args[0].String()}, nil
+			}),
+		}},
+	}
+}
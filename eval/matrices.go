@@ -0,0 +1,266 @@
+package eval
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"strings"
+)
+
+// This file contains the runtime representation of matrices and the
+// semantics of the operators on matrices. Elements are stored as
+// complex numbers, matrices of floats only differ when printed
+
+// A matrix of floating point or complex numbers. Dense matrices store
+// all of their elements in row-major order, sparse matrices only
+// store the elements that are not zero
+type MatrixValue struct {
+	Rows, Cols int
+	Sparse     bool
+	Complex    bool
+	// Elements of a dense matrix
+	Data []complex128
+	// Nonzero elements of a sparse matrix, indexed by row*Cols+col
+	Entries map[int]complex128
+}
+
+func (v *MatrixValue) Type() ValueType { return MATRIX_VALUE }
+func (v *MatrixValue) String() string {
+	rows := make([]string, v.Rows)
+	elems := make([]string, v.Cols)
+	for i := 0; i < v.Rows; i++ {
+		for j := 0; j < v.Cols; j++ {
+			elems[j] = v.formatElement(v.At(i, j))
+		}
+		rows[i] = strings.Join(elems, ", ")
+	}
+	s := "[|" + strings.Join(rows, "; ") + "|]"
+	if v.Sparse {
+		return token.SPARSE + " " + s
+	}
+	return s
+}
+
+func (v *MatrixValue) formatElement(x complex128) string {
+	if v.Complex {
+		return (&ComplexValue{x}).String()
+	}
+	return formatFloat(real(x))
+}
+
+// Create a matrix of zeros
+func newMatrix(rows, cols int, sparse, complex bool) *MatrixValue {
+	m := &MatrixValue{Rows: rows, Cols: cols, Sparse: sparse, Complex: complex}
+	if sparse {
+		m.Entries = map[int]complex128{}
+	} else {
+		m.Data = make([]complex128, rows*cols)
+	}
+	return m
+}
+
+// Create a matrix from its elements in row-major order. The matrix
+// holds complex numbers if one of the elements is complex
+func NewMatrixValue(rows, cols int, sparse bool, elems []Value) (*MatrixValue, error) {
+	complex := false
+	for _, e := range elems {
+		if _, ok := e.(*ComplexValue); ok {
+			complex = true
+		}
+	}
+	m := newMatrix(rows, cols, sparse, complex)
+	for k, e := range elems {
+		x, err := toElement(e)
+		if err != nil {
+			return nil, err
+		}
+		m.set(k/cols, k%cols, x)
+	}
+	return m, nil
+}
+
+// Get the element at a row and a column
+func (v *MatrixValue) At(i, j int) complex128 {
+	if v.Sparse {
+		return v.Entries[i*v.Cols+j]
+	}
+	return v.Data[i*v.Cols+j]
+}
+
+func (v *MatrixValue) set(i, j int, x complex128) {
+	if !v.Sparse {
+		v.Data[i*v.Cols+j] = x
+	} else if x != 0 {
+		v.Entries[i*v.Cols+j] = x
+	} else {
+		delete(v.Entries, i*v.Cols+j)
+	}
+}
+
+// Build a matrix applying a function to the
+// elements of a matrix of the given size
+func (v *MatrixValue) mapElements(rows, cols int, sparse, complex bool, f func(i, j int) complex128) *MatrixValue {
+	m := newMatrix(rows, cols, sparse, complex)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			m.set(i, j, f(i, j))
+		}
+	}
+	return m
+}
+
+// Get the transpose of a matrix
+func (v *MatrixValue) Transpose() *MatrixValue {
+	return v.mapElements(v.Cols, v.Rows, v.Sparse, v.Complex, func(i, j int) complex128 {
+		return v.At(j, i)
+	})
+}
+
+// Returns true if two matrices have the same size and elements
+func (v *MatrixValue) Equals(w *MatrixValue) bool {
+	if v.Rows != w.Rows || v.Cols != w.Cols {
+		return false
+	}
+	for i := 0; i < v.Rows; i++ {
+		for j := 0; j < v.Cols; j++ {
+			if v.At(i, j) != w.At(i, j) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Add or subtract two matrices of the same size
+func (v *MatrixValue) sum(op string, w *MatrixValue) (*MatrixValue, error) {
+	if v.Rows != w.Rows || v.Cols != w.Cols {
+		return nil, dimensionMismatchError(op, v, w)
+	}
+	sign := complex128(1)
+	if op == token.MINUS {
+		sign = -1
+	}
+	return v.mapElements(v.Rows, v.Cols, v.Sparse && w.Sparse, v.Complex || w.Complex, func(i, j int) complex128 {
+		return v.At(i, j) + sign*w.At(i, j)
+	}), nil
+}
+
+// Multiply two matrices. The number of columns of
+// the left matrix is the number of rows of the right one
+func (v *MatrixValue) product(w *MatrixValue) (*MatrixValue, error) {
+	if v.Cols != w.Rows {
+		return nil, dimensionMismatchError(token.TIMES, v, w)
+	}
+	return v.mapElements(v.Rows, w.Cols, v.Sparse && w.Sparse, v.Complex || w.Complex, func(i, j int) complex128 {
+		x := complex128(0)
+		for k := 0; k < v.Cols; k++ {
+			x += v.At(i, k) * w.At(k, j)
+		}
+		return x
+	}), nil
+}
+
+// Apply an arithmetical operator to every element of a matrix and
+// a scalar. The scalar is the left operand if scalarLeft is true.
+// Products and quotients keep the density of the matrix
+func (v *MatrixValue) broadcast(op string, s complex128, scalarLeft, complex bool) *MatrixValue {
+	sparse := v.Sparse && (op == token.TIMES || op == token.DIVIDE)
+	return v.mapElements(v.Rows, v.Cols, sparse, v.Complex || complex, func(i, j int) complex128 {
+		l, r := v.At(i, j), s
+		if scalarLeft {
+			l, r = r, l
+		}
+		switch op {
+		case token.PLUS:
+			return l + r
+		case token.MINUS:
+			return l - r
+		case token.TIMES:
+			return l * r
+		}
+		if sparse && l == 0 {
+			// Elements that are not stored stay zero
+			return 0
+		}
+		return l / r
+	})
+}
+
+// Get the element of a matrix at the position given by a tuple (i, j)
+func (v *MatrixValue) access(pos Value) (Value, error) {
+	tuple, ok := pos.(*TupleValue)
+	if !ok || len(tuple.Elements) != 2 {
+		return nil, typeMismatch(TUPLE_VALUE, pos)
+	}
+	i, err := toInt(tuple.Elements[0])
+	if err != nil {
+		return nil, err
+	}
+	j, err := toInt(tuple.Elements[1])
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= int64(v.Rows) || j < 0 || j >= int64(v.Cols) {
+		return nil, indexOutOfBoundsError(i, j, v)
+	}
+	x := v.At(int(i), int(j))
+	if v.Complex {
+		return &ComplexValue{x}, nil
+	}
+	return &FloatValue{real(x)}, nil
+}
+
+// The arithmetical operators applied by the matrix operators
+var matrixElementOperators = map[string]string{
+	token.MPLUS:   token.PLUS,
+	token.MMINUS:  token.MINUS,
+	token.MTIMES:  token.TIMES,
+	token.MDIVIDE: token.DIVIDE,
+}
+
+// Apply a matrix operator. The typechecker chose it because one of
+// the operands is a matrix, the other one is a matrix or a scalar
+func matrixOperation(mop string, l, r Value) (Value, error) {
+	op := matrixElementOperators[mop]
+	lm, lok := l.(*MatrixValue)
+	rm, rok := r.(*MatrixValue)
+
+	switch {
+	case lok && rok:
+		switch op {
+		case token.PLUS, token.MINUS:
+			return lm.sum(op, rm)
+		case token.TIMES:
+			return lm.product(rm)
+		}
+	case op == token.PLUS || op == token.MINUS || op == token.TIMES || op == token.DIVIDE:
+		m, scalar, scalarLeft := lm, r, false
+		if !lok {
+			m, scalar, scalarLeft = rm, l, true
+		}
+		if scalarLeft && op == token.DIVIDE {
+			break
+		}
+		s, err := toElement(scalar)
+		if err != nil {
+			return nil, err
+		}
+		_, complex := scalar.(*ComplexValue)
+		return m.broadcast(op, s, scalarLeft, complex), nil
+	}
+	return nil, unknownOperatorError(mop)
+}
+
+// Get the stored form of an element of a matrix. The typechecker
+// converted the integers to floats
+func toElement(v Value) (complex128, error) {
+	if fv, ok := v.(*FloatValue); ok {
+		return complex(fv.Value, 0), nil
+	}
+	return toComplex(v)
+}
+
+func toMatrix(v Value) (*MatrixValue, error) {
+	if mv, ok := v.(*MatrixValue); ok {
+		return mv, nil
+	}
+	return nil, typeMismatch(MATRIX_VALUE, v)
+}
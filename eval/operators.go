@@ -0,0 +1,532 @@
+package eval
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// This file contains the runtime semantics of infix and prefix operators.
+// Operands have already been typechecked, and operators are resolved
+// to the strict operator of the type of their operands. The numbers
+// used as numbers of a bigger type of the numerical tower (int <: float
+// <: complex) are converted where the typechecker elaborated their
+// coercion, see Restrict, so operands are never promoted here.
+
+func toInt(v Value) (int64, error) {
+	if iv, ok := v.(*IntegerValue); ok {
+		return iv.Value, nil
+	}
+	return 0, typeMismatch(INT_VALUE, v)
+}
+
+func toFloat(v Value) (float64, error) {
+	if fv, ok := v.(*FloatValue); ok {
+		return fv.Value, nil
+	}
+	return 0, typeMismatch(FLOAT_VALUE, v)
+}
+
+func toComplex(v Value) (complex128, error) {
+	if cv, ok := v.(*ComplexValue); ok {
+		return cv.Value, nil
+	}
+	return 0, typeMismatch(COMPLEX_VALUE, v)
+}
+
+func toBool(v Value) (bool, error) {
+	if bv, ok := v.(*BoolValue); ok {
+		return bv.Value, nil
+	}
+	return false, typeMismatch(BOOL_VALUE, v)
+}
+
+func toList(v Value) (*ListValue, error) {
+	if lv, ok := v.(*ListValue); ok {
+		return lv, nil
+	}
+	return nil, typeMismatch(LIST_VALUE, v)
+}
+
+// Integer exponentiation by squaring
+func intPow(base, exp int64) (int64, error) {
+	if exp < 0 {
+		return 0, &RuntimeError{"negative integer exponent"}
+	}
+	res := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			res *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return res, nil
+}
+
+func intOperation(op string, l, r int64) (Value, error) {
+	switch op {
+	case token.PLUS:
+		return &IntegerValue{l + r}, nil
+	case token.MINUS:
+		return &IntegerValue{l - r}, nil
+	case token.TIMES:
+		return &IntegerValue{l * r}, nil
+	case token.DIVIDE:
+		if r == 0 {
+			return nil, divisionByZeroError()
+		}
+		return &IntegerValue{l / r}, nil
+	case token.MODULO:
+		if r == 0 {
+			return nil, divisionByZeroError()
+		}
+		return &IntegerValue{l % r}, nil
+	case token.TOPOW:
+		res, err := intPow(l, r)
+		if err != nil {
+			return nil, err
+		}
+		return &IntegerValue{res}, nil
+	}
+	return nil, unknownOperatorError(op)
+}
+
+func floatOperation(op string, l, r float64) (Value, error) {
+	switch op {
+	case token.FPLUS:
+		return &FloatValue{l + r}, nil
+	case token.FMINUS:
+		return &FloatValue{l - r}, nil
+	case token.FTIMES:
+		return &FloatValue{l * r}, nil
+	case token.FDIVIDE:
+		return &FloatValue{l / r}, nil
+	case token.FTOPOW:
+		return &FloatValue{math.Pow(l, r)}, nil
+	}
+	return nil, unknownOperatorError(op)
+}
+
+func complexOperation(op string, l, r complex128) (Value, error) {
+	switch op {
+	case token.CPLUS:
+		return &ComplexValue{l + r}, nil
+	case token.CMINUS:
+		return &ComplexValue{l - r}, nil
+	case token.CTIMES:
+		return &ComplexValue{l * r}, nil
+	case token.CDIVIDE:
+		return &ComplexValue{l / r}, nil
+	case token.CTOPOW:
+		return &ComplexValue{cmplx.Pow(l, r)}, nil
+	}
+	return nil, unknownOperatorError(op)
+}
+
+// Returns true if values of the kind of v can be compared for equality
+func isComparable(v Value) bool {
+	switch v.(type) {
+	case *IntegerValue, *FloatValue, *ComplexValue, *BoolValue, *StringValue,
+		*RuneValue, *UnitValue, *RecordValue, *TupleValue, *ListValue, *DataValue, *MatrixValue:
+		return true
+	}
+	return false
+}
+
+// Get the type of the numeric tower a number of type t is converted
+// to by the numbers of a shape, see ast.Shape
+func numberTarget(t ValueType, numbers []string) ValueType {
+	rank := func(t string) int {
+		for i, nt := range ast.NumericTower {
+			if nt.Identifier.Value == t {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, n := range numbers {
+		if rank(n) >= rank(string(t)) {
+			return ValueType(n)
+		}
+	}
+	return t
+}
+
+// Convert a number to a type of the numeric tower
+func convertNumber(v Value, t ValueType) Value {
+	switch vv := v.(type) {
+	case *IntegerValue:
+		switch t {
+		case FLOAT_VALUE:
+			return &FloatValue{float64(vv.Value)}
+		case COMPLEX_VALUE:
+			return &ComplexValue{complex(float64(vv.Value), 0)}
+		}
+	case *FloatValue:
+		if t == COMPLEX_VALUE {
+			return &ComplexValue{complex(vv.Value, 0)}
+		}
+	}
+	return v
+}
+
+// Restrict a value to the fields of the record types of a shape,
+// dropping the fields that are not known statically, and convert it
+// to the numbers and functions of the shape. See ast.Shape
+func Restrict(v Value, s *ast.Shape) Value {
+	if s == nil {
+		return v
+	}
+	if s.Domain != nil || s.Codomain != nil {
+		return &CoercedFunction{Function: v, Shape: s}
+	}
+	switch vv := v.(type) {
+	case *IntegerValue, *FloatValue, *ComplexValue:
+		return convertNumber(v, numberTarget(v.Type(), s.Numbers))
+	case *MatrixValue:
+		if vv.Complex || numberTarget(FLOAT_VALUE, s.Numbers) != COMPLEX_VALUE {
+			return v
+		}
+		m := *vv
+		m.Complex = true
+		return &m
+	case *RecordValue:
+		if s.Fields == nil {
+			return v
+		}
+		fields := map[string]Value{}
+		for label, fs := range s.Fields {
+			if f, ok := vv.Fields[label]; ok {
+				fields[label] = Restrict(f, fs)
+			}
+		}
+		return &RecordValue{Fields: fields}
+	case *TupleValue:
+		if len(s.Elements) != len(vv.Elements) {
+			return v
+		}
+		elems := make([]Value, len(vv.Elements))
+		for i, e := range vv.Elements {
+			elems[i] = Restrict(e, s.Elements[i])
+		}
+		return &TupleValue{Elements: elems}
+	case *ListValue:
+		elems := vv.Elements()
+		for i, e := range elems {
+			elems[i] = Restrict(e, s.Element)
+		}
+		return NewListValue(elems)
+	case *DataValue:
+		shapes, ok := s.Constructors[vv.Constructor]
+		if !ok || len(shapes) != len(vv.Args) {
+			return v
+		}
+		args := make([]Value, len(vv.Args))
+		for i, a := range vv.Args {
+			args[i] = Restrict(a, shapes[i])
+		}
+		return &DataValue{Constructor: vv.Constructor, Args: args}
+	}
+	return v
+}
+
+// A builtin restricting its argument to a shape, see Restrict
+func NewRestriction(s *ast.Shape) *BuiltinValue {
+	return &BuiltinValue{Name: "restrict", Arity: 1, Args: []Value{}, Fn: func(args []Value) (Value, error) {
+		return Restrict(args[0], s), nil
+	}}
+}
+
+// Returns true if two values are structurally equal
+func valuesEqual(l, r Value) (bool, error) {
+	switch lv := l.(type) {
+	case *IntegerValue:
+		if rv, ok := r.(*IntegerValue); ok {
+			return lv.Value == rv.Value, nil
+		}
+	case *FloatValue:
+		if rv, ok := r.(*FloatValue); ok {
+			return lv.Value == rv.Value, nil
+		}
+	case *ComplexValue:
+		if rv, ok := r.(*ComplexValue); ok {
+			return lv.Value == rv.Value, nil
+		}
+	case *BoolValue:
+		if rv, ok := r.(*BoolValue); ok {
+			return lv.Value == rv.Value, nil
+		}
+	case *StringValue:
+		if rv, ok := r.(*StringValue); ok {
+			return lv.Value == rv.Value, nil
+		}
+	case *RuneValue:
+		if rv, ok := r.(*RuneValue); ok {
+			return lv.Value == rv.Value, nil
+		}
+	case *UnitValue:
+		_, ok := r.(*UnitValue)
+		return ok, nil
+	case *RecordValue:
+		rv, ok := r.(*RecordValue)
+		if !ok || len(lv.Fields) != len(rv.Fields) {
+			return false, nil
+		}
+		for label, lf := range lv.Fields {
+			rf, ok := rv.Fields[label]
+			if !ok {
+				return false, nil
+			}
+			eq, err := valuesEqual(lf, rf)
+			if err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	case *TupleValue:
+		rv, ok := r.(*TupleValue)
+		if !ok || len(lv.Elements) != len(rv.Elements) {
+			return false, nil
+		}
+		for i := range lv.Elements {
+			eq, err := valuesEqual(lv.Elements[i], rv.Elements[i])
+			if err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	case *ListValue:
+		rv, ok := r.(*ListValue)
+		if !ok {
+			return false, nil
+		}
+		for ; !lv.IsEmpty() && !rv.IsEmpty(); lv, rv = lv.Tail, rv.Tail {
+			eq, err := valuesEqual(lv.Head, rv.Head)
+			if err != nil || !eq {
+				return false, err
+			}
+		}
+		return lv.IsEmpty() && rv.IsEmpty(), nil
+	case *MatrixValue:
+		rv, ok := r.(*MatrixValue)
+		return ok && lv.Equals(rv), nil
+	case *DataValue:
+		rv, ok := r.(*DataValue)
+		if !ok || lv.Constructor != rv.Constructor || len(lv.Args) != len(rv.Args) {
+			return false, nil
+		}
+		for i := range lv.Args {
+			eq, err := valuesEqual(lv.Args[i], rv.Args[i])
+			if err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	if !isComparable(l) {
+		return false, notComparableError(l)
+	}
+	if !isComparable(r) {
+		return false, notComparableError(r)
+	}
+
+	// Values of different kinds, which meet when comparing
+	// values of a union type, are never equal
+	return false, nil
+}
+
+// Returns -1, 0 or 1 if l is respectively less than, equal
+// or greater than r
+func compareValues(l, r Value) (int, error) {
+	switch lv := l.(type) {
+	case *IntegerValue:
+		if rv, ok := r.(*IntegerValue); ok {
+			return compareInts(lv.Value, rv.Value), nil
+		}
+	case *StringValue:
+		if rv, ok := r.(*StringValue); ok {
+			return strings.Compare(lv.Value, rv.Value), nil
+		}
+	case *RuneValue:
+		if rv, ok := r.(*RuneValue); ok {
+			return compareInts(int64(lv.Value), int64(rv.Value)), nil
+		}
+	case *BoolValue:
+		if rv, ok := r.(*BoolValue); ok {
+			return compareInts(boolToInt(lv.Value), boolToInt(rv.Value)), nil
+		}
+	case *FloatValue:
+		if rv, ok := r.(*FloatValue); ok {
+			switch {
+			case lv.Value < rv.Value:
+				return -1, nil
+			case lv.Value > rv.Value:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	default:
+		return 0, notComparableError(l)
+	}
+	return 0, notComparableError(r)
+}
+
+func compareInts(l, r int64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Apply an infix operator to two already evaluated operands.
+// Short circuiting operators and sequencing are handled by the evaluator
+func ApplyInfix(op string, l, r Value) (Value, error) {
+	switch op {
+	case token.PLUS, token.MINUS, token.TIMES, token.DIVIDE, token.MODULO, token.TOPOW:
+		li, err := toInt(l)
+		if err != nil {
+			return nil, err
+		}
+		ri, err := toInt(r)
+		if err != nil {
+			return nil, err
+		}
+		return intOperation(op, li, ri)
+	case token.FPLUS, token.FMINUS, token.FTIMES, token.FDIVIDE, token.FTOPOW:
+		lf, err := toFloat(l)
+		if err != nil {
+			return nil, err
+		}
+		rf, err := toFloat(r)
+		if err != nil {
+			return nil, err
+		}
+		return floatOperation(op, lf, rf)
+	case token.CPLUS, token.CMINUS, token.CTIMES, token.CDIVIDE, token.CTOPOW:
+		lc, err := toComplex(l)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := toComplex(r)
+		if err != nil {
+			return nil, err
+		}
+		return complexOperation(op, lc, rc)
+	case token.MPLUS, token.MMINUS, token.MTIMES, token.MDIVIDE:
+		return matrixOperation(op, l, r)
+	case token.AT:
+		lm, err := toMatrix(l)
+		if err != nil {
+			return nil, err
+		}
+		return lm.access(r)
+	case token.LAND, token.OR:
+		lb, err := toBool(l)
+		if err != nil {
+			return nil, err
+		}
+		rb, err := toBool(r)
+		if err != nil {
+			return nil, err
+		}
+		if op == token.LAND {
+			return boolValue(lb && rb), nil
+		}
+		return boolValue(lb || rb), nil
+	case token.CONS:
+		rl, err := toList(r)
+		if err != nil {
+			return nil, err
+		}
+		return rl.Cons(l), nil
+	case token.CONCAT:
+		ll, err := toList(l)
+		if err != nil {
+			return nil, err
+		}
+		rl, err := toList(r)
+		if err != nil {
+			return nil, err
+		}
+		return ll.Concat(rl), nil
+	case token.EQUALS, token.DIFFERS:
+		eq, err := valuesEqual(l, r)
+		if err != nil {
+			return nil, err
+		}
+		return boolValue(eq == (op == token.EQUALS)), nil
+	case token.LESS, token.LESSEQ, token.GREATER, token.GREATEREQ:
+		cmp, err := compareValues(l, r)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case token.LESS:
+			return boolValue(cmp < 0), nil
+		case token.LESSEQ:
+			return boolValue(cmp <= 0), nil
+		case token.GREATER:
+			return boolValue(cmp > 0), nil
+		}
+		return boolValue(cmp >= 0), nil
+	}
+	return nil, unknownOperatorError(op)
+}
+
+// Apply a prefix operator to an already evaluated operand
+func ApplyPrefix(op string, r Value) (Value, error) {
+	switch op {
+	case token.MINUS:
+		i, err := toInt(r)
+		if err != nil {
+			return nil, err
+		}
+		return &IntegerValue{-i}, nil
+	case token.FMINUS:
+		f, err := toFloat(r)
+		if err != nil {
+			return nil, err
+		}
+		return &FloatValue{-f}, nil
+	case token.CMINUS:
+		c, err := toComplex(r)
+		if err != nil {
+			return nil, err
+		}
+		return &ComplexValue{-c}, nil
+	case token.NOT:
+		b, err := toBool(r)
+		if err != nil {
+			return nil, err
+		}
+		return boolValue(!b), nil
+	}
+	return nil, unknownOperatorError(op)
+}
+
+// Apply a postfix operator to an already evaluated operand
+func ApplyPostfix(op string, l Value) (Value, error) {
+	switch op {
+	case token.TRANSPOSE:
+		m, err := toMatrix(l)
+		if err != nil {
+			return nil, err
+		}
+		return m.Transpose(), nil
+	}
+	return nil, unknownOperatorError(op)
+}
@@ -0,0 +1,135 @@
+package eval
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the matching of values against patterns
+
+// Match a value against a pattern. If the value matches, returns the
+// values bound to the variables of the pattern, in the order given
+// by ast.PatternVariables
+func MatchPattern(p ast.Pattern, v Value) ([]Value, bool, error) {
+	values := []Value{}
+	ok, err := matchPattern(p, v, &values)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+func matchPattern(p ast.Pattern, v Value, values *[]Value) (bool, error) {
+	switch vp := p.(type) {
+	case *ast.WildcardPattern:
+		return true, nil
+	case *ast.VariablePattern:
+		*values = append(*values, v)
+		return true, nil
+	case *ast.LiteralPattern:
+		lit, err := ExpressionEval(vp.Value)
+		if err != nil {
+			return false, err
+		}
+		return valuesEqual(lit, v)
+	case *ast.ConstructorPattern:
+		dv, ok := v.(*DataValue)
+		if !ok {
+			return false, typeMismatch(DATA_VALUE, v)
+		}
+		if dv.Constructor != vp.Constructor.Identifier.Value || len(dv.Args) != len(vp.Args) {
+			return false, nil
+		}
+		for i, arg := range vp.Args {
+			if ok, err := matchPattern(arg, dv.Args[i], values); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case *ast.RecordPattern:
+		rv, ok := v.(*RecordValue)
+		if !ok {
+			return false, typeMismatch(RECORD_VALUE, v)
+		}
+		for _, f := range vp.Fields {
+			fv, err := rv.Access(f.Label)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := matchPattern(f.Pattern, fv, values); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case *ast.TuplePattern:
+		tv, ok := v.(*TupleValue)
+		if !ok {
+			return false, typeMismatch(TUPLE_VALUE, v)
+		}
+		if len(tv.Elements) != len(vp.Elements) {
+			return false, nil
+		}
+		for i, e := range vp.Elements {
+			if ok, err := matchPattern(e, tv.Elements[i], values); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case *ast.EmptyListPattern:
+		lv, ok := v.(*ListValue)
+		if !ok {
+			return false, typeMismatch(LIST_VALUE, v)
+		}
+		return lv.IsEmpty(), nil
+	case *ast.ConsPattern:
+		lv, ok := v.(*ListValue)
+		if !ok {
+			return false, typeMismatch(LIST_VALUE, v)
+		}
+		if lv.IsEmpty() {
+			return false, nil
+		}
+		if ok, err := matchPattern(vp.Head, lv.Head, values); err != nil || !ok {
+			return false, err
+		}
+		return matchPattern(vp.Tail, lv.Tail, values)
+	}
+	return false, &RuntimeError{fmt.Sprintf("cannot match pattern %s", p)}
+}
+
+// Bind the variables of a pattern to the parts of a value
+// taken apart by the pattern, failing if the value does not match
+func (env *Environment) bindPattern(p ast.Pattern, v Value) error {
+	values, ok, err := MatchPattern(p, v)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return matchFailureError(v)
+	}
+	for i, id := range ast.PatternVariables(p) {
+		env.Set(id, values[i])
+	}
+	return nil
+}
+
+// Find the first arm of a match expression whose pattern matches a
+// value, and bind the variables of the pattern in a new extension
+// of the environment, in which the body of the arm is evaluated
+func (env *Environment) matchArms(arms []*ast.MatchArm, v Value) (*ast.MatchArm, *Environment, error) {
+	for _, arm := range arms {
+		values, ok, err := MatchPattern(arm.Pattern, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		nenv := NewEnvironmentExtension(env)
+		for i, id := range ast.PatternVariables(arm.Pattern) {
+			nenv.Set(id, values[i])
+		}
+		return arm, nenv, nil
+	}
+	return nil, nil, matchFailureError(v)
+}
@@ -0,0 +1,308 @@
+// Contains the definitions of the runtime values of gobba programs
+// and of the environments in which expressions are evaluated
+package eval
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ValueType string
+
+const (
+	INT_VALUE     = "int"
+	FLOAT_VALUE   = "float"
+	COMPLEX_VALUE = "complex"
+	BOOL_VALUE    = "bool"
+	STRING_VALUE  = "string"
+	RUNE_VALUE    = "rune"
+	UNIT_VALUE    = "unit"
+	CLOSURE_VALUE = "closure"
+	RECORD_VALUE  = "record"
+	DATA_VALUE    = "data"
+	TUPLE_VALUE   = "tuple"
+	LIST_VALUE    = "list"
+	MATRIX_VALUE  = "matrix"
+)
+
+// Every value produced by the evaluation of a gobba
+// expression implements this interface
+type Value interface {
+	Type() ValueType
+	String() string
+}
+
+// ======================================================================
+// Primitive values
+// ======================================================================
+
+type IntegerValue struct {
+	Value int64
+}
+
+func (v *IntegerValue) Type() ValueType { return INT_VALUE }
+func (v *IntegerValue) String() string  { return strconv.FormatInt(v.Value, 10) }
+
+type FloatValue struct {
+	Value float64
+}
+
+func (v *FloatValue) Type() ValueType { return FLOAT_VALUE }
+func (v *FloatValue) String() string  { return formatFloat(v.Value) }
+
+type ComplexValue struct {
+	Value complex128
+}
+
+func (v *ComplexValue) Type() ValueType { return COMPLEX_VALUE }
+func (v *ComplexValue) String() string {
+	im := strconv.FormatFloat(imag(v.Value), 'g', -1, 64)
+	if !strings.HasPrefix(im, "-") {
+		im = "+" + im
+	}
+	return strconv.FormatFloat(real(v.Value), 'g', -1, 64) + im + "i"
+}
+
+type BoolValue struct {
+	Value bool
+}
+
+func (v *BoolValue) Type() ValueType { return BOOL_VALUE }
+func (v *BoolValue) String() string  { return strconv.FormatBool(v.Value) }
+
+type StringValue struct {
+	Value string
+}
+
+func (v *StringValue) Type() ValueType { return STRING_VALUE }
+func (v *StringValue) String() string  { return strconv.Quote(v.Value) }
+
+type RuneValue struct {
+	Value rune
+}
+
+func (v *RuneValue) Type() ValueType { return RUNE_VALUE }
+func (v *RuneValue) String() string  { return strconv.QuoteRune(v.Value) }
+
+type UnitValue struct{}
+
+func (v *UnitValue) Type() ValueType { return UNIT_VALUE }
+func (v *UnitValue) String() string  { return "()" }
+
+// ======================================================================
+// Composite values
+// ======================================================================
+
+// A record maps labels to the values of its fields
+type RecordValue struct {
+	Fields map[string]Value
+}
+
+func (v *RecordValue) Type() ValueType { return RECORD_VALUE }
+func (v *RecordValue) String() string {
+	labels := make([]string, 0, len(v.Fields))
+	for label := range v.Fields {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(label + " = " + v.Fields[label].String())
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Get the value of a field of a record
+func (v *RecordValue) Access(label string) (Value, error) {
+	f, ok := v.Fields[label]
+	if !ok {
+		return nil, &RuntimeError{fmt.Sprintf("record has no field %s", label)}
+	}
+	return f, nil
+}
+
+// A tuple holds the values of its elements in order
+type TupleValue struct {
+	Elements []Value
+}
+
+func (v *TupleValue) Type() ValueType { return TUPLE_VALUE }
+func (v *TupleValue) String() string {
+	elems := make([]string, len(v.Elements))
+	for i, e := range v.Elements {
+		elems[i] = e.String()
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+// Lists are immutable and singly linked, so that :: and the tail of
+// a list share the elements of the list they are built from. The
+// empty list has no head and no tail
+type ListValue struct {
+	Head Value
+	Tail *ListValue
+}
+
+// The empty list is immutable, there is no need to allocate more than one
+var emptyList = &ListValue{}
+
+// Build a list holding the given values in order
+func NewListValue(elems []Value) *ListValue {
+	list := emptyList
+	for i := len(elems) - 1; i >= 0; i-- {
+		list = list.Cons(elems[i])
+	}
+	return list
+}
+
+func (v *ListValue) Type() ValueType { return LIST_VALUE }
+func (v *ListValue) String() string {
+	elems := v.Elements()
+	strs := make([]string, len(elems))
+	for i, e := range elems {
+		strs[i] = e.String()
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+func (v *ListValue) IsEmpty() bool { return v.Tail == nil }
+
+// Build a list with a new value in front of the list
+func (v *ListValue) Cons(head Value) *ListValue {
+	return &ListValue{Head: head, Tail: v}
+}
+
+// Get the values held by a list, in order
+func (v *ListValue) Elements() []Value {
+	elems := []Value{}
+	for l := v; !l.IsEmpty(); l = l.Tail {
+		elems = append(elems, l.Head)
+	}
+	return elems
+}
+
+// Build the list of the values of a list followed by
+// the values of another one, which is shared
+func (v *ListValue) Concat(r *ListValue) *ListValue {
+	elems := v.Elements()
+	list := r
+	for i := len(elems) - 1; i >= 0; i-- {
+		list = list.Cons(elems[i])
+	}
+	return list
+}
+
+// A value of an algebraic data type: a constructor
+// applied to all of its arguments
+type DataValue struct {
+	Constructor string
+	Args        []Value
+}
+
+func (v *DataValue) Type() ValueType { return DATA_VALUE }
+func (v *DataValue) String() string {
+	if len(v.Args) == 0 {
+		return v.Constructor
+	}
+	args := make([]string, len(v.Args))
+	for i, a := range v.Args {
+		args[i] = a.String()
+	}
+	return v.Constructor + "(" + strings.Join(args, ", ") + ")"
+}
+
+// ======================================================================
+// Functional values
+// ======================================================================
+
+// A function literal paired with the environment
+// in which it was defined
+type ClosureValue struct {
+	Param ast.UniqueIdentifier
+	Body  ast.Expression
+	Env   *Environment
+}
+
+func (v *ClosureValue) Type() ValueType { return CLOSURE_VALUE }
+func (v *ClosureValue) String() string  { return "<fun>" }
+
+// A constructor of an algebraic data type that has not been applied
+// to all of its arguments yet. Constructors are curried functions
+type ConstructorValue struct {
+	Name  string
+	Arity int
+	Args  []Value
+}
+
+func (v *ConstructorValue) Type() ValueType { return CLOSURE_VALUE }
+func (v *ConstructorValue) String() string  { return "<fun>" }
+
+// Create the value bound to a constructor with the given number of
+// arguments. Constructors without arguments are data values
+func NewConstructor(name string, arity int) Value {
+	if arity == 0 {
+		return &DataValue{Constructor: name, Args: []Value{}}
+	}
+	return &ConstructorValue{Name: name, Arity: arity, Args: []Value{}}
+}
+
+// Apply a constructor to its next argument
+func (v *ConstructorValue) Apply(arg Value) Value {
+	args := make([]Value, len(v.Args), len(v.Args)+1)
+	copy(args, v.Args)
+	args = append(args, arg)
+	if len(args) == v.Arity {
+		return &DataValue{Constructor: v.Name, Args: args}
+	}
+	return &ConstructorValue{Name: v.Name, Arity: v.Arity, Args: args}
+}
+
+// A function used as a function of a supertype of its type, that
+// converts its argument and its result, see ast.Shape
+type CoercedFunction struct {
+	Function Value
+	Shape    *ast.Shape
+}
+
+func (v *CoercedFunction) Type() ValueType { return CLOSURE_VALUE }
+func (v *CoercedFunction) String() string  { return "<fun>" }
+
+// Apply a coerced function evaluated by the tree walking evaluator
+func (v *CoercedFunction) Apply(arg Value) (Value, error) {
+	res, err := Apply(v.Function, Restrict(arg, v.Shape.Domain))
+	if err != nil {
+		return nil, err
+	}
+	return Restrict(res, v.Shape.Codomain), nil
+}
+
+// Format a float so that it is always distinguishable from an integer
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// Unit is immutable, there is no need to allocate more than one
+var unit = &UnitValue{}
+
+func boolValue(b bool) *BoolValue {
+	return &BoolValue{Value: b}
+}
+
+func typeMismatch(expected ValueType, found Value) *RuntimeError {
+	return &RuntimeError{
+		Msg: fmt.Sprintf("expected a value of type %s, found %s", expected, found.Type()),
+	}
+}
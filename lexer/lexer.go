@@ -108,6 +108,7 @@ func isCharAllowedInNumber(ch byte) bool {
 // 0+12e-1i
 // 12e+1i
 // 12e1i
+// 2i
 func (l *Lexer) readNumber(hasReal bool) (token.TokenType, string) {
 	start_pos := l.position
 	var kind token.TokenType = token.INT
@@ -154,15 +155,11 @@ func (l *Lexer) readNumber(hasReal bool) (token.TokenType, string) {
 			kind = token.FLOAT
 		}
 
-		// If the number ends with an 'i', it is an imaginary part number
+		// If the number ends with an 'i', it is an imaginary part
+		// number, or an imaginary number without a real part
 		if l.ch == 'i' {
 			l.readChar()
-			if hasReal {
-				kind = token.COMPLEX
-			} else {
-				kind = token.ILLEGAL
-			}
-			return kind, string(l.input[start_pos:l.position])
+			return token.COMPLEX, string(l.input[start_pos:l.position])
 		}
 
 		// If the number contains two dots, that's a problem
@@ -189,6 +186,16 @@ func (l *Lexer) readNumber(hasReal bool) (token.TokenType, string) {
 	return kind, l.input[start_pos:l.position]
 }
 
+// Returns true if the current character immediately follows
+// the end of an operand, such as an identifier or a closing paren
+func (l *Lexer) followsOperand() bool {
+	if l.position == 0 {
+		return false
+	}
+	prev := l.input[l.position-1]
+	return isIdentifier(prev) || isDigit(prev) || prev == ')' || prev == ']' || prev == '\''
+}
+
 // Read the next character without incrementing position
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
@@ -268,6 +275,18 @@ func (l *Lexer) NextToken() token.Token {
 		tok = l.newToken(token.LBRACKET, string(l.ch))
 	case '}':
 		tok = l.newToken(token.RBRACKET, string(l.ch))
+	case '[':
+		tok = l.newToken(token.LSQUARE, string(l.ch))
+	case ']':
+		tok = l.newToken(token.RSQUARE, string(l.ch))
+	case '\'':
+		// A quote right after an operand is the transpose
+		// operator, otherwise it starts a rune literal
+		if l.followsOperand() {
+			tok = l.newToken(token.TRANSPOSE, string(l.ch))
+		} else {
+			tok = l.newToken(token.ILLEGAL, string(l.ch))
+		}
 	case '*':
 		if l.peekChar() == '.' {
 			ch := l.ch
@@ -295,7 +314,17 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '^':
-		tok = l.newToken(token.TOPOW, string(l.ch))
+		if l.peekChar() == '.' {
+			ch := l.ch
+			l.readChar()
+			tok = l.newToken(token.FTOPOW, string(ch)+string(l.ch))
+		} else if l.peekChar() == ':' {
+			ch := l.ch
+			l.readChar()
+			tok = l.newToken(token.CTOPOW, string(ch)+string(l.ch))
+		} else {
+			tok = l.newToken(token.TOPOW, string(l.ch))
+		}
 	case '=':
 		tok = l.newToken(token.EQUALS, string(l.ch))
 	case '%':
@@ -330,7 +359,7 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			tok = l.newToken(token.OR, string(ch)+string(l.ch))
 		} else {
-			tok = l.newToken(token.ILLEGAL, string(l.ch))
+			tok = l.newToken(token.BAR, string(l.ch))
 		}
 	case ':':
 		if l.peekChar() == ':' {
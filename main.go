@@ -2,24 +2,49 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/eval"
 	"github.com/0x0f0f0f/gobba-golang/repl"
 	"github.com/0x0f0f0f/gobba-golang/typecheck"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"runtime/pprof"
 	"syscall"
 )
 
+// Exit codes
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1 // The program could not be read, parsed, typechecked or evaluated
+	EXIT_USAGE = 2 // Wrong command line usage
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n")
+	fmt.Fprintf(out, "  %s [flags]                 start the interactive REPL\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] run file.gb     evaluate a file\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] check file.gb   parse and typecheck a file\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] -e 'expr'       evaluate an expression\n", os.Args[0])
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
 func main() {
 	opts := &repl.ReplOptions{}
+	var expr string
 
 	flag.BoolVar(&opts.ShowAST, "vast", false, "print the AST before evaluation")
 	flag.BoolVar(&opts.ShowTok, "vtok", false, "print lexed tokens before parsing")
 	flag.BoolVar(&typecheck.DebugTypeCheck, "vtype", false, "print type checking algorithm steps")
 	flag.BoolVar(&opts.DebugParser, "dparser", false, "enable parser debugging")
+	flag.BoolVar(&opts.UseVM, "vm", false, "evaluate programs with the bytecode virtual machine")
+	flag.StringVar(&expr, "e", "", "evaluate an expression and exit")
+	flag.Usage = usage
 
 	flag.Parse()
-	r := repl.New(opts)
 
 	// Intercept sighup
 	sigc := make(chan os.Signal, 1)
@@ -37,5 +62,82 @@ func main() {
 		}
 	}()
 
-	r.Start()
+	// An empty expression is evaluated too
+	exprSet := false
+	flag.Visit(func(f *flag.Flag) {
+		exprSet = exprSet || f.Name == "e"
+	})
+	if exprSet {
+		if flag.NArg() != 0 {
+			flag.Usage()
+			os.Exit(EXIT_USAGE)
+		}
+		os.Exit(evalSource((*repl.Session).Interpret, opts, "-e", expr, true))
+	}
+
+	if flag.NArg() == 0 {
+		r := repl.New(opts)
+		r.Start()
+		return
+	}
+
+	command := flag.Arg(0)
+	switch command {
+	case "run", "check":
+		// Allow flags to follow the subcommand
+		flag.CommandLine.Parse(flag.Args()[1:])
+		if flag.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "%s: expected exactly one file\n", command)
+			flag.Usage()
+			os.Exit(EXIT_USAGE)
+		}
+		os.Exit(evalFile(opts, flag.Arg(0), command == "run"))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(EXIT_USAGE)
+	}
+}
+
+// Read a source file and run it through the interpreter, returning the
+// exit code of the process
+func evalFile(opts *repl.ReplOptions, filename string, evaluate bool) int {
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_ERROR
+	}
+	return evalSource((*repl.Session).InterpretFile, opts, filename, string(source), evaluate)
+}
+
+// The signature of repl.Session.Interpret and repl.Session.InterpretFile
+type interpreter func(*repl.Session, string, bool) (ast.TypeValue, eval.Value, error)
+
+// Run a source text through the interpreter. When evaluate is true the
+// resulting value is printed, unless it is unit, otherwise the type
+// of the program is printed. Diagnostics are prefixed with the name
+// of the source and printed on stderr.
+func evalSource(interpret interpreter, opts *repl.ReplOptions, name, source string, evaluate bool) int {
+	session := repl.NewSession(opts)
+	ty, value, err := interpret(session, source, evaluate)
+	for _, w := range session.Warnings() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, w)
+	}
+	if err != nil {
+		if errs, ok := err.(repl.SyntaxErrors); ok {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, e)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		}
+		return EXIT_ERROR
+	}
+
+	if !evaluate {
+		fmt.Printf("- : %s\n", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+	} else if value.Type() != eval.UNIT_VALUE {
+		fmt.Println(value)
+	}
+	return EXIT_OK
 }
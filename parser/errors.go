@@ -45,6 +45,13 @@ func (p *Parser) Errors() []string {
 	return s
 }
 
+// Returns true if parsing failed only because the input ended too
+// early, so that more input could make it valid. This is the case of
+// unclosed parentheses, trailing operators or let expressions without a body
+func (p *Parser) UnexpectedEOF() bool {
+	return len(p.errors) > 0 && p.errors[0].Token.Type == token.EOF
+}
+
 // Add an error when a peekToken is not the expected one
 func (p *Parser) peekError(expected token.TokenType, t token.Token) {
 	e := ParserError{t.Line, t.Column, t, &expected, ""}
@@ -8,6 +8,12 @@ import (
 )
 
 func (p *Parser) ParseExpression(prec int) ast.Expression {
+	// An expression at the lowest precedence extends over `;`
+	if prec == LOWEST {
+		unsequenced := p.unsequenced
+		p.unsequenced = false
+		defer func() { p.unsequenced = unsequenced }()
+	}
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken)
@@ -28,6 +34,30 @@ func (p *Parser) ParseExpression(prec int) ast.Expression {
 	return leftExp
 }
 
+// Parse an expression that does not extend over a `;`, such as a
+// statement or the value of an assignment. Composition binds
+// looser than sequencing, so the operands of `>=>` and `<=<` are
+// parsed here up to the semicolon. So is the else branch of a
+// conditional, see parseIfExpression
+func (p *Parser) parseUnsequenced() ast.Expression {
+	unsequenced := p.unsequenced
+	p.unsequenced = true
+	defer func() { p.unsequenced = unsequenced }()
+
+	exp := p.ParseExpression(SEQUENCING)
+	for exp != nil && p.peekPrecedence() == COMPOSITION {
+		p.nextToken()
+		infix := &ast.InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: exp}
+		p.nextToken()
+		infix.Right = p.ParseExpression(SEQUENCING)
+		if infix.Right == nil {
+			return nil
+		}
+		exp = infix
+	}
+	return exp
+}
+
 // Parse a simple terminal symbol
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.IdentifierExpr{
@@ -70,6 +100,10 @@ func (p *Parser) parseComplexLiteral() ast.Expression {
 
 	value := 0 + 0i
 	_, err := fmt.Sscanf(p.curToken.Literal, "%f", &value)
+	if err != nil {
+		// Imaginary numbers such as 2i have no real part
+		_, err = fmt.Sscanf("0+"+p.curToken.Literal, "%f", &value)
+	}
 	if err != nil {
 		p.customError(nil, p.curToken, "could not parse as complex")
 		return nil
@@ -152,8 +186,10 @@ func (p *Parser) parseInfixRightAssocExpression(left ast.Expression) ast.Express
 	return exp
 }
 
-// Parse a subexpression grouped by ()
+// Parse a subexpression grouped by (), a tuple literal (e1, e2)
+// or a type annotation in the form (expr : type)
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tok := p.curToken
 	p.nextToken()
 
 	if p.curTokenIs(token.RPAREN) {
@@ -162,6 +198,21 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 	exp := p.ParseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COMMA) {
+		return p.parseTupleLiteral(tok, exp)
+	}
+
+	if p.peekTokenIs(token.ANNOT) {
+		p.nextToken()
+		annot := &ast.AnnotExpr{Token: p.curToken, Body: exp}
+		p.nextToken()
+		annot.Type = p.parseTypeValue(TLOWEST)
+		if annot.Type == nil {
+			return nil
+		}
+		exp = annot
+	}
+
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
@@ -169,6 +220,117 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 	return exp
 }
 
+// Parse the elements of a tuple literal after the first one
+func (p *Parser) parseTupleLiteral(tok token.Token, first ast.Expression) ast.Expression {
+	tuple := &ast.TupleLiteral{Token: tok, Elements: []ast.Expression{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		elem := p.ParseExpression(LOWEST)
+		if elem == nil {
+			return nil
+		}
+		tuple.Elements = append(tuple.Elements, elem)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return tuple
+}
+
+// Parse a list literal [e1, e2, e3], or a matrix literal if
+// the opening bracket is followed by a bar
+func (p *Parser) parseListLiteral() ast.Expression {
+	if p.peekTokenIs(token.BAR) {
+		return p.parseMatrixLiteral(p.curToken, false)
+	}
+	list := &ast.ListLiteral{Token: p.curToken, Elements: []ast.Expression{}}
+
+	if p.peekTokenIs(token.RSQUARE) {
+		p.nextToken()
+		return list
+	}
+
+	for {
+		p.nextToken()
+		elem := p.parseUnsequenced()
+		if elem == nil {
+			return nil
+		}
+		list.Elements = append(list.Elements, elem)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RSQUARE) {
+		return nil
+	}
+	return list
+}
+
+// Parse a matrix literal prefixed by its density,
+// as in sparse [|1, 0; 0, 1|]
+func (p *Parser) parseDensityMatrixLiteral() ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(token.LSQUARE) {
+		return nil
+	}
+	if !p.peekTokenIs(token.BAR) {
+		p.customError(nil, p.peekToken, "expected a matrix literal")
+		return nil
+	}
+	return p.parseMatrixLiteral(tok, tok.Type == token.SPARSE)
+}
+
+// Parse the rows of a matrix literal [|1, 2; 3, 4|]. The current
+// token is the opening bracket. All the rows must have the same
+// number of elements
+func (p *Parser) parseMatrixLiteral(tok token.Token, sparse bool) ast.Expression {
+	matrix := &ast.MatrixLiteral{Token: tok, Sparse: sparse}
+	p.nextToken()
+
+	row := []ast.Expression{}
+	for {
+		p.nextToken()
+		elem := p.parseUnsequenced()
+		if elem == nil {
+			return nil
+		}
+		row = append(row, elem)
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			continue
+		}
+		if len(matrix.Rows) > 0 && len(row) != len(matrix.Rows[0]) {
+			p.customError(nil, p.curToken, "the rows of a matrix must have the same number of elements")
+			return nil
+		}
+		matrix.Rows = append(matrix.Rows, row)
+		if !p.peekTokenIs(token.SEMI) {
+			break
+		}
+		p.nextToken()
+		row = []ast.Expression{}
+	}
+
+	if !p.expectPeek(token.BAR) || !p.expectPeek(token.RSQUARE) {
+		return nil
+	}
+	return matrix
+}
+
+// Parse a postfix operator, such as the transpose m'
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Operator: p.curToken.Literal,
+	}
+}
+
 // Parse an expression grouped by {}
 func (p *Parser) parseBraceGroupedExpression() ast.Expression {
 	p.nextToken()
@@ -192,8 +354,12 @@ func (p *Parser) parseDollarExpression() ast.Expression {
 	return exp
 }
 
+// Parse a conditional. The else branch extends over a following `;`,
+// but in a statement or in another expression that does not, as in
+// `if c then a else b; d`, where d is evaluated after the conditional
 func (p *Parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
+	unsequenced := p.unsequenced
 
 	p.nextToken()
 
@@ -213,7 +379,11 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 	p.nextToken()
 
-	exp.Alternative = p.ParseExpression(LOWEST)
+	if unsequenced {
+		exp.Alternative = p.parseUnsequenced()
+	} else {
+		exp.Alternative = p.ParseExpression(LOWEST)
+	}
 	return exp
 }
 func (p *Parser) parseApplyExpression(f ast.Expression) ast.Expression {
@@ -263,61 +433,145 @@ func (p *Parser) parseApplyArguments() []ast.Expression {
 	return args
 }
 
-// Parse a let expression
-// Use the let over lambda principle TODO review
-// let x = 1 in x + 2 === (lambda x -> x + 2) 1
-// let x = 1 and y = 2 in x + y === (lambda y -> (lambda x -> x + y)(1))(2)
+// Parse a let expression. The body can be introduced either by `in`
+// or by `;`, and is unit when the input ends after the assignments
 func (p *Parser) parseLetExpression() ast.Expression {
-	// exp := &ast.LetExpression{Token: p.curToken}
-	inner_app := &ast.ApplyExpr{Token: p.curToken}
-	inner_fun := &ast.FunctionLiteral{Token: p.curToken}
-	inner_app.Function = inner_fun
-
-	// Parse the first assignment
-	ass := p.parseAssignment()
-	if ass == nil {
+	exp := &ast.LetExpression{Token: p.curToken}
+	exp.Assignments = p.parseAssignments()
+	if exp.Assignments == nil {
 		return nil
 	}
 
-	inner_fun.Param = ass.Name
-	inner_app.Arg = ass.Value
+	if p.peekTokenIs(token.EOF) {
+		exp.Body = &ast.UnitLiteral{Token: p.curToken}
+		return exp
+	}
 
-	curr_app := inner_app
-	for !p.peekTokenIs(token.SEMI) && !p.peekTokenIs(token.EOF) {
-		p.expectPeek(token.AND)
+	if p.peekTokenIs(token.IN) {
+		p.nextToken()
+	} else if !p.expectPeek(token.SEMI) {
+		return nil
+	}
 
-		ass := p.parseAssignment()
-		if ass == nil {
-			return nil
-		}
-		curr_fun := &ast.FunctionLiteral{Token: p.curToken}
-		curr_fun.Param = ass.Name
-		curr_fun.Body = curr_app
+	if p.peekTokenIs(token.EOF) {
+		exp.Body = &ast.UnitLiteral{Token: p.curToken}
+		return exp
+	}
+
+	p.nextToken()
 
-		// Replace
-		curr_app = &ast.ApplyExpr{Token: p.curToken}
-		curr_app.Function = curr_fun
-		curr_app.Arg = ass.Value
+	exp.Body = p.ParseExpression(LOWEST)
+	return exp
+}
 
+// Parse an expression whose effects are restricted, in the form
+// `allow io, fail in e`, `deny io in e` or `pure e`. The body extends
+// as far to the right as possible, up to the end of a sequence
+func (p *Parser) parseEffectExpression() ast.Expression {
+	exp := &ast.EffectExpr{Token: p.curToken}
+	exp.Restriction = p.parseEffectRestriction()
+	if exp.Restriction == nil {
+		return nil
 	}
+	if !p.curTokenIs(token.PURE) && !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
 
-	if p.peekTokenIs(token.EOF) {
-		inner_fun.Body = &ast.UnitLiteral{}
-		return curr_app
+	exp.Body = p.parseUnsequenced()
+	if exp.Body == nil {
+		return nil
 	}
+	return exp
+}
 
-	if !p.expectPeek(token.SEMI) {
+// Parse `pure` or `allow` and `deny` followed by a list of
+// effect labels separated by commas
+func (p *Parser) parseEffectRestriction() *ast.EffectRestriction {
+	r := &ast.EffectRestriction{
+		Token:   p.curToken,
+		Deny:    p.curTokenIs(token.DENY),
+		Effects: []string{},
+	}
+	if p.curTokenIs(token.PURE) {
+		return r
+	}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if !ast.IsEffectLabel(p.curToken.Literal) {
+			p.customError(nil, p.curToken, "unknown effect "+p.curToken.Literal)
+			return nil
+		}
+		r.Effects = append(r.Effects, p.curToken.Literal)
+		if !p.peekTokenIs(token.COMMA) {
+			return r
+		}
+		p.nextToken()
+	}
+}
+
+// Parse the event of a usage policy, `event name`
+func (p *Parser) parseEventExpression() ast.Expression {
+	exp := &ast.EventExpr{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
+	exp.Name = p.curToken.Literal
+	return exp
+}
 
-	if p.peekTokenIs(token.EOF) {
-		inner_fun.Body = &ast.UnitLiteral{}
-		return curr_app
+// Parse a record literal in the form {x = 1, y = 2}
+func (p *Parser) parseRecordLiteral() ast.Expression {
+	rec := &ast.RecordLiteral{Token: p.curToken, Fields: []*ast.RecordField{}}
+
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return rec
 	}
 
-	p.nextToken()
+	labels := map[string]bool{}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.RecordField{Token: p.curToken, Label: p.curToken.Literal}
+		if labels[field.Label] {
+			p.customError(nil, p.curToken, "duplicate field "+field.Label+" in record")
+			return nil
+		}
+		labels[field.Label] = true
 
-	inner_fun.Body = p.ParseExpression(LOWEST)
+		if !p.expectPeek(token.EQUALS) {
+			return nil
+		}
+		p.nextToken()
+		field.Value = p.parseUnsequenced()
+		if field.Value == nil {
+			return nil
+		}
+		rec.Fields = append(rec.Fields, field)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return rec
+}
 
-	return curr_app
+// Parse the projection of a field of a record, r.x
+func (p *Parser) parseAccessExpression(record ast.Expression) ast.Expression {
+	exp := &ast.AccessExpr{Token: p.curToken, Record: record}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Field = p.curToken.Literal
+	return exp
 }
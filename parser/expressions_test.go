@@ -13,9 +13,9 @@ func TestIdentifierExpression(t *testing.T) {
 
 	l := lexer.New(input)
 	p := New(l)
-	program := p.ParseProgram()
+	program := p.ParseSingleExpression()
 	CheckParserErrors(t, p)
-	testUniqueIdentifier(t, program, ast.UniqueIdentifier{"foobar", 0})
+	testUniqueIdentifier(t, program, ast.UniqueIdentifier{Value: "foobar", Id: 0})
 }
 
 func TestBooleanExpression(t *testing.T) {
@@ -23,7 +23,7 @@ func TestBooleanExpression(t *testing.T) {
 
 	l := lexer.New(input)
 	p := New(l)
-	program := p.ParseProgram()
+	program := p.ParseSingleExpression()
 	CheckParserErrors(t, p)
 
 	stmt, ok := program.(*ast.InfixExpression)
@@ -37,7 +37,7 @@ func TestIntegerLiteralExpression(t *testing.T) {
 
 	l := lexer.New(input)
 	p := New(l)
-	program := p.ParseProgram()
+	program := p.ParseSingleExpression()
 	CheckParserErrors(t, p)
 
 	literal, ok := program.(*ast.IntegerLiteral)
@@ -59,7 +59,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	for _, tt := range prefixTests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		CheckParserErrors(t, p)
 
 		exp, ok := program.(*ast.PrefixExpression)
@@ -80,6 +80,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 + 5;", 5, "+", 5},
 		{"5 - 5;", 5, "-", 5},
 		{"5.243 - 2.23e2;", 5.243, "-", 2.23e2},
+		{"1.5 + 2i;", 1.5, "+", complex(0, 2)},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
 		{"5 > 5;", 5, ">", 5},
@@ -95,7 +96,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 	for _, tt := range infixTests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		CheckParserErrors(t, p)
 
 		testInfixExpression(t, program, tt.leftValue, tt.operator, tt.rightValue)
@@ -107,7 +108,10 @@ func TestLetExpression(t *testing.T) {
 		input    string
 		expected string
 	}{
-		{"let x = 5; x", "(λ x . x)(5)"},
+		{"let x = 5; x", "(let x = 5; x)"},
+		{"let x = 5 and y = 4 in x + y", "(let x = 5 and y = 4; (x + y))"},
+		{"let f = fun(x) {x} in f(1)", "(let f = (fixf . (λ x . x)); f(1))"},
+		{"let x = 5", "(let x = 5; ())"},
 		// {"let x = 5 and y = 4;", []string{"x", "y"}, []interface{}{5, 4}},
 		// {"let y = true;", []string{"y"}, []interface{}{true}},
 		// {"let foobar = y;", []string{"foobar"}, []interface{}{"y"}},
@@ -117,7 +121,7 @@ func TestLetExpression(t *testing.T) {
 		l := lexer.New(tt.input)
 		p := New(l)
 
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		CheckParserErrors(t, p)
 
 		actual := program.String()
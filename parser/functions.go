@@ -8,30 +8,50 @@ import (
 )
 
 // Parse the arguments of a function definition/literal (TODO allow type annotations)
-// and return them as a slice
-func (p *Parser) parseFunArgs() []ast.Expression {
+// and return them as a slice. The patterns of the arguments that take
+// apart their values are returned in a second slice, that holds nil
+// for the other arguments
+func (p *Parser) parseFunArgs() ([]ast.Expression, []ast.Pattern) {
 	args := []ast.Expression{}
+	patterns := []ast.Pattern{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return args
+		return args, patterns
 	}
 
-	p.nextToken()
-	eannot := p.parseFunArgAnnot()
-	args = append(args, eannot)
-	for p.peekTokenIs(token.COMMA) {
+	for {
 		p.nextToken()
+		if p.curTokenIs(token.LPAREN) {
+			// The parameter is named after the pattern, a name
+			// that cannot be written in the body
+			tok := p.curToken
+			pattern := p.parsePattern()
+			if pattern == nil {
+				return nil, nil
+			}
+			param := &ast.IdentifierExpr{
+				Token:      tok,
+				Identifier: ast.UniqueIdentifier{Value: pattern.String()},
+			}
+			args = append(args, p.parseParamAnnot(param))
+			patterns = append(patterns, pattern)
+		} else {
+			args = append(args, p.parseFunArgAnnot())
+			patterns = append(patterns, nil)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		eannot := p.parseFunArgAnnot()
-		args = append(args, eannot)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return args
+	return args, patterns
 }
 
 // NOTE: Function literals hold a single parameter. Multi-parameter
@@ -44,7 +64,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	args := p.parseFunArgs()
+	args, patterns := p.parseFunArgs()
 
 	if !p.expectPeek(token.LBRACKET) {
 		return nil
@@ -56,7 +76,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 			Token: start_token,
 			Param: &ast.IdentifierExpr{
 				Token:      p.curToken,
-				Identifier: ast.UniqueIdentifier{"_", 0},
+				Identifier: ast.UniqueIdentifier{Value: "_", Id: 0},
 			},
 			Body: body,
 		}
@@ -76,6 +96,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		} else {
 			new_fun.Body = cur_fun
 		}
+		if patterns[k] != nil {
+			new_fun.Body = bindParamPattern(el, patterns[k], new_fun.Body)
+		}
 
 		new_fun = replaceTypedFun(new_fun, el)
 
@@ -85,9 +108,27 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return cur_fun
 }
 
-// If the parsed parameter is an annotation, return a wrapper abstraction
-// that applies the original function
-// to the type annotation
+// Take apart the argument of a function with the pattern of its
+// parameter, as in `let (x, y) = param in body`
+func bindParamPattern(param ast.Expression, pattern ast.Pattern, body ast.Expression) ast.Expression {
+	id, ok := param.(*ast.IdentifierExpr)
+	if annot, isAnnot := param.(*ast.AnnotExpr); isAnnot {
+		id, ok = annot.Body.(*ast.IdentifierExpr)
+	}
+	if !ok {
+		panic("expected an identifier or an annotation")
+	}
+	return &ast.LetExpression{
+		Token: id.Token,
+		Assignments: []*ast.Assignment{
+			{Token: id.Token, Pattern: pattern, Value: id},
+		},
+		Body: body,
+	}
+}
+
+// Set the parameter of a function literal. If the parsed
+// parameter is an annotation, its type is the type of the parameter
 func replaceTypedFun(old_fun *ast.FunctionLiteral, param ast.Expression) *ast.FunctionLiteral {
 	annot, ok := param.(*ast.AnnotExpr)
 	if !ok {
@@ -106,20 +147,7 @@ func replaceTypedFun(old_fun *ast.FunctionLiteral, param ast.Expression) *ast.Fu
 	}
 
 	old_fun.Param = iid
-
-	// If the parameter is an annotation
-	appl_annot_expr := &ast.ApplyExpr{
-		Token:    annot.Token,
-		Function: old_fun,
-		Arg:      annot,
-	}
-
-	new_fun := &ast.FunctionLiteral{
-		Token: appl_annot_expr.Token,
-		Param: iid,
-		Body:  appl_annot_expr,
-	}
-
-	return new_fun
-
+	old_fun.ParamType = annot.Type
+	old_fun.Token = iid.Token
+	return old_fun
 }
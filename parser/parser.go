@@ -60,6 +60,7 @@ var precedences = map[token.TokenType]int{
 	token.CTOPOW:    POWER,
 	token.ACCESS:    ACCESS,
 	token.AT:        ACCESS,
+	token.TRANSPOSE: ACCESS,
 	// function application
 	token.LPAREN: CALL,
 }
@@ -88,7 +89,12 @@ type Parser struct {
 	// operators either found in prefix or infix position
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
-	TraceOnError   bool // Debug option to print a stack trace on error
+	// Lookup tables of the type expression parser
+	prefixTypeParseFns map[token.TokenType]prefixTypeParseFn
+	infixTypeParseFns  map[token.TokenType]infixTypeParseFn
+	TraceOnError       bool // Debug option to print a stack trace on error
+	// The expression being parsed ends at a `;`, see parseUnsequenced
+	unsequenced bool
 }
 
 // Create a new parser from a given Lexer
@@ -109,8 +115,6 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	// TODO rune
 	// TODO vectors ???
-	// TODO lists
-	// TODO records
 
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.NOT, p.parsePrefixExpression)
@@ -119,6 +123,15 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.LAMBDA, p.parseFunctionLiteral)
 	p.registerPrefix(token.LET, p.parseLetExpression)
+	p.registerPrefix(token.LBRACKET, p.parseRecordLiteral)
+	p.registerPrefix(token.LSQUARE, p.parseListLiteral)
+	p.registerPrefix(token.DENSE, p.parseDensityMatrixLiteral)
+	p.registerPrefix(token.SPARSE, p.parseDensityMatrixLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.ALLOW, p.parseEffectExpression)
+	p.registerPrefix(token.DENY, p.parseEffectExpression)
+	p.registerPrefix(token.PURE, p.parseEffectExpression)
+	p.registerPrefix(token.EVENT, p.parseEventExpression)
 
 	// Registration of infix operators
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -151,8 +164,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.CDIVIDE, p.parseInfixExpression)
 	p.registerInfix(token.CTOPOW, p.parseInfixExpression)
 
-	p.registerInfix(token.ACCESS, p.parseInfixExpression)
+	p.registerInfix(token.ACCESS, p.parseAccessExpression)
 	p.registerInfix(token.AT, p.parseInfixExpression)
+	p.registerInfix(token.TRANSPOSE, p.parsePostfixExpression)
 
 	p.registerInfix(token.CONS, p.parseInfixRightAssocExpression)
 
@@ -161,6 +175,8 @@ func New(l *lexer.Lexer) *Parser {
 	// function application
 	p.registerInfix(token.LPAREN, p.parseApplyExpression)
 
+	p.registerTypeParseFns()
+
 	// Read two tokens so that curToken and peekToken are both set
 	p.nextToken()
 	p.nextToken()
@@ -241,8 +257,24 @@ func (p *Parser) curPrecedence() int {
 // Actual parsing functions
 // ======================================================================
 
-// Parse an assignment
+// Parse an assignment. An assignment starting with a paren
+// takes the value apart with a pattern, as in `let (x, y) = v`
 func (p *Parser) parseAssignment() *ast.Assignment {
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		ass := &ast.Assignment{Token: p.curToken}
+		ass.Pattern = p.parsePattern()
+		if ass.Pattern == nil || !p.expectPeek(token.EQUALS) {
+			return nil
+		}
+		p.nextToken()
+		ass.Value = p.parseUnsequenced()
+		if ass.Value == nil {
+			return nil
+		}
+		return ass
+	}
+
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -260,26 +292,87 @@ func (p *Parser) parseAssignment() *ast.Assignment {
 	}
 
 	p.nextToken()
-	ass.Value = p.ParseExpression(SEQUENCING)
-
-	if f, ok := ass.Value.(*ast.FunctionLiteral); ok {
-		// Combinator for recursion
-		fix := &ast.FixExpr{
-			Token: f.Token,
-			Param: *ass.Name,
-			Body:  f,
+	ass.Value = p.parseUnsequenced()
+
+	ass.Value = fixFunction(ass.Value, *ass.Name)
+	return ass
+}
+
+// Wrap a function literal bound to a name in the combinator for
+// recursion. Functions with restricted effects and annotated functions
+// are recursive too
+func fixFunction(exp ast.Expression, name ast.IdentifierExpr) ast.Expression {
+	switch ve := exp.(type) {
+	case *ast.FunctionLiteral:
+		return &ast.FixExpr{Token: ve.Token, Param: name, Body: ve}
+	case *ast.EffectExpr:
+		ve.Body = fixFunction(ve.Body, name)
+	case *ast.AnnotExpr:
+		ve.Body = fixFunction(ve.Body, name)
+	}
+	return exp
+}
+
+// Remove the combinator for recursion from a function literal bound
+// to a name, see fixFunction
+func unfixFunction(exp ast.Expression) ast.Expression {
+	switch ve := exp.(type) {
+	case *ast.FixExpr:
+		return ve.Body
+	case *ast.EffectExpr:
+		ve.Body = unfixFunction(ve.Body)
+	case *ast.AnnotExpr:
+		ve.Body = unfixFunction(ve.Body)
+	}
+	return exp
+}
+
+// Parse a list of assignments separated by the and keyword
+func (p *Parser) parseAssignments() []*ast.Assignment {
+	asss := []*ast.Assignment{}
+	for {
+		ass := p.parseAssignment()
+		if ass == nil {
+			return nil
 		}
+		asss = append(asss, ass)
 
-		ass.Value = fix
+		if !p.peekTokenIs(token.AND) {
+			return asss
+		}
+		p.nextToken()
+	}
+}
 
-		return ass
+// Parse a source file: a package declaration followed by a
+// sequence of statements
+func (p *Parser) ParseProgram() *ast.Program {
+	if !p.curTokenIs(token.PACKAGE) {
+		expected := token.TokenType(token.PACKAGE)
+		p.customError(&expected, p.curToken, "a program must start with a package declaration")
+		return nil
+	}
+	pkg := p.parsePackageStatement()
+	if pkg == nil {
+		return nil
+	}
+	p.nextToken()
 
+	program := p.parseStatements()
+	if program != nil {
+		program.Package = pkg
 	}
-	return ass
+	return program
+}
+
+// Parse a sequence of statements without a package declaration,
+// such as a line typed in the REPL
+func (p *Parser) ParseStatements() *ast.Program {
+	return p.parseStatements()
 }
 
-func (p *Parser) ParseProgram() ast.Expression {
-	ast.ResetUIDCounter()
+// Parse a single expression spanning the whole input
+func (p *Parser) ParseSingleExpression() ast.Expression {
 	expr := p.ParseExpression(LOWEST)
 	if !p.expectPeek(token.EOF) {
 		return nil
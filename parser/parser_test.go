@@ -28,7 +28,7 @@ func TestFailures(t *testing.T) {
 	for _, tt := range tests {
 		l := lexer.New(tt)
 		p := New(l)
-		_ = p.ParseProgram()
+		_ = p.ParseSingleExpression()
 		assert.NotEqual(t, 0, p.errors)
 	}
 }
@@ -150,12 +150,40 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"h.f :: a :: b ++ c",
 			"((h . f) :: (a :: (b ++ c)))",
 		},
+		{
+			"{x = 1, y = a + b}.y",
+			"({x = 1, y = (a + b)} . y)",
+		},
+		{
+			"r.a.b(1) + {}.c",
+			"(((r . a) . b)(1) + ({} . c))",
+		},
+		{
+			"[1, a + b] ++ [] :: l",
+			"(([1, (a + b)] ++ []) :: l)",
+		},
+		{
+			"[[1], [f(x)]]",
+			"[[1], [f(x)]]",
+		},
+		{
+			"[|1, a + b; -c, 2|]",
+			"[|1, (a + b); (-c), 2|]",
+		},
+		{
+			"a * b' + sparse [|1|] @ (0, 0)",
+			"((a * (b')) + (sparse [|1|] @ (0, 0)))",
+		},
+		{
+			"m'' * f(x)'",
+			"(((m')') * (f(x)'))",
+		},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		CheckParserErrors(t, p)
 		actual := program.String()
 		assert.Equal(t, tt.expected, actual)
@@ -174,7 +202,7 @@ func TestFunctionParameterParsing(t *testing.T) {
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		CheckParserErrors(t, p)
 
 		assert.Len(t, p.Errors(), 0)
@@ -190,3 +218,193 @@ func TestFunctionParameterParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestProgramParsing(t *testing.T) {
+	tests := map[string]string{
+		"package main;":                                                                        "package main;",
+		"package main; 1 + 2;":                                                                 "package main; (1 + 2);",
+		"package main; let x = 1 and y = 2; x;":                                                "package main; let x = 1 and y = 2; x;",
+		"package main; let x = 1 in x + 1":                                                     "package main; (let x = 1; (x + 1));",
+		"package main; let f = fun(x) {x}; f(1)":                                               "package main; let f = (fixf . (λ x . x)); f(1);",
+		"package main; if a then b else c; d":                                                  "package main; (if a then b else c); d;",
+		"package main; if a then b else if c then d else e; f":                                 "package main; (if a then b else (if c then d else e)); f;",
+		"package main; let x = if a then b else c; d":                                          "package main; let x = (if a then b else c); d;",
+		"package main; 1 + if a then b else c; d":                                              "package main; (1 + (if a then b else c)); d;",
+		"package main; f(if a then b else c; d)":                                               "package main; f((if a then b else (c ; d)));",
+		"package main; fun(x) {if a then b else c; d}":                                         "package main; (λ x . (if a then b else (c ; d)));",
+		"package main; (if a then b else c); d":                                                "package main; (if a then b else c); d;",
+		"package main; f >=> g; h":                                                             "package main; (f >=> g); h;",
+		"package main; (f; g >=> h)":                                                           "package main; ((f ; g) >=> h);",
+		"package main; let h = f >=> g; h":                                                     "package main; let h = (f >=> g); h;",
+		"package main; type color = Red | Green":                                               "package main; type color = Red | Green;",
+		"package main; type tree(a) = | Leaf | Node(tree(a), a, tree(a)); Leaf":                "package main; type tree(a) = Leaf | Node(tree(a), a, tree(a)); Leaf;",
+		"package main; type pair(a, b) = Pair(a, b -> b)":                                      "package main; type pair(a, b) = Pair(a, b -> b);",
+		"package main; match x with None -> 0 | Some(y) -> y":                                  "package main; (match x with | None -> 0 | Some(y) -> y);",
+		"package main; match r with | {a = _, b = (1)} -> () | -1 -> 2; z":                     "package main; (match r with | {a = _, b = 1} -> () | (-1) -> 2); z;",
+		"package main; match t with | Node(Leaf, x, r) -> x | _ -> \"none\"":                   "package main; (match t with | Node(Leaf, x, r) -> x | _ -> none);",
+		"package main; (1, (2, 3))":                                                            "package main; (1, (2, 3));",
+		"package main; let (x, _) = p; x":                                                      "package main; let (x, _) = p; x;",
+		"package main; fun((x, y)) {x}":                                                        "package main; (λ (x, y) . (let (x, y) = (x, y); x));",
+		"package main; match p with | (0, y) -> y | (x, _) -> x":                               "package main; (match p with | (0, y) -> y | (x, _) -> x);",
+		"package main; match l with | [] -> 0 | x :: y :: _ -> y":                              "package main; (match l with | [] -> 0 | (x :: (y :: _)) -> y);",
+		"package main deny io; f(1)":                                                           "package main deny io; f(1);",
+		"package main pure; let x = pure f(1); x":                                              "package main pure; let x = (pure f(1)); x;",
+		"package main; allow io, fail in f(1) + 1":                                             "package main; (allow io, fail in (f(1) + 1));",
+		"package main; let f = deny io in fun(x) {f(x)}; f":                                    "package main; let f = (deny io in (fixf . (λ x . f(x)))); f;",
+		"package main; deny io in fun(x) {x}":                                                  "package main; (deny io in (λ x . x));",
+		"package main; let f = (fun(x) {f(x)} : int -> int); f":                                "package main; let f = ((fixf . (λ x . f(x))): int -> int); f;",
+		"package main; event open; f(1)":                                                       "package main; (event open); f(1);",
+		"package main; policy p { init q0; q0 -open-> q1; q1 -open-> bad; offending bad; }; 1": "package main; policy p { init q0; q0 -open-> q1; q1 -open-> bad; offending bad; }; 1;",
+		"package main; policy p { offending b, c; init a; a -x-> b; a -y-> c; }":               "package main; policy p { init a; a -x-> b; a -y-> c; offending b, c; };",
+		"package main; policy p { init a; }":                                                   "package main; policy p { init a; };",
+		"package main; interface Size(a) { size : a -> int; zero : a; }; size(1)":              "package main; interface Size(a) { size : a -> int; zero : a; }; size(1);",
+		"package main; interface Empty(a) {}":                                                  "package main; interface Empty(a) { };",
+		"package main; instance Size(bool) { size = fun(b) {1}; zero = false; }":               "package main; instance Size(bool) { size = (λ b . 1); zero = false; };",
+		"package main; instance Size([]int) { size = length; }":                                "package main; instance Size([]int) { size = length; };",
+		"package main; type vec = (float, float)":                                              "package main; type vec = (float, float);",
+		"package main; type pair(a, b) = a -> b":                                               "package main; type pair(a, b) = a -> b;",
+		"package main; type s = (Shape)":                                                       "package main; type s = (Shape);",
+		"package main; type f = (Shape -> int)":                                                "package main; type f = (Shape -> int);",
+		"package main; newtype Meters = float; Meters(1.0)":                                    "package main; newtype Meters = float; Meters(1.0);",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		CheckParserErrors(t, p)
+		assert.Equal(t, expected, program.String())
+	}
+}
+
+func TestProgramParsingFailures(t *testing.T) {
+	tests := []string{
+		"1 + 2",
+		"package; 1",
+		"package main 1",
+		"package main; let x = 1 2",
+		"package main; 1 2",
+		"package main; type = A",
+		"package main; type t = A | A",
+		"package main; type t(a, a) = C(a)",
+		"package main; type t(a = A",
+		"package main; type t() = A",
+		"package main; type t = ",
+		"package main; type t = A(int",
+		"package main; type t = A()",
+		"package main; match x with",
+		"package main; match x with | -> 1",
+		"package main; match x | A -> 1",
+		"package main; match x with | A( -> 1",
+		"package main; match x with | {a = 1, a = 2} -> 1",
+		"package main; match x with | - a -> 1",
+		"package main; match x with | [1] -> 1",
+		"package main; match x with | x :: -> 1",
+		"package main; (1, )",
+		"package main; (1, 2",
+		"package main; let (x, y = p",
+		"package main; let (x, 1 + 2) = p",
+		"package main; fun((x, y) {x}",
+		"package main; [1, ",
+		"package main; [1, 2",
+		"package main; [, ]",
+		"package main; [|1, 2; 3|]",
+		"package main; [|1, 2|",
+		"package main; [||]",
+		"package main; sparse [1]",
+		"package main; dense 1",
+		"package main; m '",
+		"package main; allow in 1",
+		"package main; deny io 1",
+		"package main; allow io, in 1",
+		"package main; deny console in 1",
+		"package main pure",
+		"package main allow; 1",
+		"package main; event",
+		"package main; event 1",
+		"package main; policy { init a; }",
+		"package main; policy p { a -x-> b; }",
+		"package main; policy p { init a; a -x-> b; a -x-> c; }",
+		"package main; policy p { init a; a -x b; }",
+		"package main; policy p { init a }",
+		"package main; policy p { init a; offending; }",
+		"package main; policy p { init a;",
+		"package main; interface { f : a; }",
+		"package main; interface S { f : a; }",
+		"package main; interface S(a) { f : a }",
+		"package main; interface S(a) { f : a; f : a -> a; }",
+		"package main; interface S(a) { f = 1; }",
+		"package main; instance S() { f = 1; }",
+		"package main; instance S(int) { f : int; }",
+		"package main; instance S(int) { f = 1; f = 2; }",
+		"package main; instance S(int) { (x, y) = (1, 2); }",
+		"package main; type t = ",
+		"package main; type t(a) = a ->",
+		"package main; newtype M(a) = a",
+		"package main; newtype M = ",
+		"package main; newtype = float",
+	}
+
+	for _, input := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		assert.NotEqual(t, 0, len(p.Errors()))
+	}
+}
+
+func TestStatementsParsing(t *testing.T) {
+	p := New(lexer.New("let x = 1; x + 1"))
+	program := p.ParseStatements()
+	CheckParserErrors(t, p)
+	assert.Nil(t, program.Package)
+	if assert.Len(t, program.Statements, 2) {
+		_, ok := program.Statements[0].(*ast.LetStatement)
+		assert.True(t, ok, "casting to *ast.LetStatement")
+		_, ok = program.Statements[1].(*ast.ExpressionStatement)
+		assert.True(t, ok, "casting to *ast.ExpressionStatement")
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	tests := map[string]bool{
+		"1 + 2":                  false,
+		"let x = 1;":             false,
+		"(1 + 2":                 true,
+		"fun (x) { x +":          true,
+		"fun (x":                 true,
+		"1 +":                    true,
+		"let x = 1 in":           true,
+		"let x = 1 and":          true,
+		"if true then 1":         true,
+		"1 + )":                  false,
+		"let x = 1 2":            false,
+		"fun (x) { x } + (1 2 3": false,
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := New(lexer.New(input))
+		p.ParseStatements()
+		assert.Equal(t, expected, p.UnexpectedEOF(), input)
+	}
+}
+
+func TestRecordParsingFailures(t *testing.T) {
+	tests := []string{
+		"{x = 1, x = 2}",
+		"{x 1}",
+		"{x = 1,}",
+		"{1 = x}",
+		"{x = 1",
+		"r.1",
+		"(r : {x: int, x: int})",
+	}
+
+	for _, input := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := New(lexer.New(input))
+		p.ParseSingleExpression()
+		assert.NotEqual(t, 0, len(p.Errors()), input)
+	}
+}
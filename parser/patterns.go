@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+// Parse a match expression, `match e with | p1 -> e1 | p2 -> e2`.
+// The bar before the first arm is optional. Like the alternative of
+// an if expression, the body of an arm does not extend over a sequence
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpr{Token: p.curToken}
+
+	p.nextToken()
+	exp.Scrutinee = p.ParseExpression(LOWEST)
+	if exp.Scrutinee == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.WITH) {
+		return nil
+	}
+	if p.peekTokenIs(token.BAR) {
+		p.nextToken()
+	}
+
+	for {
+		p.nextToken()
+		arm := &ast.MatchArm{Token: p.curToken}
+		arm.Pattern = p.parsePattern()
+		if arm.Pattern == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RARROW) {
+			return nil
+		}
+		p.nextToken()
+		arm.Body = p.parseUnsequenced()
+		if arm.Body == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.BAR) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return exp
+}
+
+// Parse a pattern. The :: of cons patterns is right associative,
+// h :: t :: l is parsed as h :: (t :: l)
+func (p *Parser) parsePattern() ast.Pattern {
+	pat := p.parseAtomicPattern()
+	if pat == nil || !p.peekTokenIs(token.CONS) {
+		return pat
+	}
+	p.nextToken()
+	cons := &ast.ConsPattern{Token: p.curToken, Head: pat}
+	p.nextToken()
+	cons.Tail = p.parsePattern()
+	if cons.Tail == nil {
+		return nil
+	}
+	return cons
+}
+
+// Parse a pattern that is not a cons pattern. A name that is not
+// followed by arguments is parsed as a variable, α-conversion
+// tells apart the names of constructors without arguments
+func (p *Parser) parseAtomicPattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		if p.peekTokenIs(token.LPAREN) {
+			return p.parseConstructorPattern()
+		}
+		return &ast.VariablePattern{
+			Token:      p.curToken,
+			Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal},
+		}
+	case token.INT, token.FLOAT, token.COMPLEX, token.STRING, token.TRUE, token.FALSE:
+		return p.parseLiteralPattern()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) {
+			p.customError(nil, p.peekToken, "expected an integer after - in pattern")
+			return nil
+		}
+		return p.parseLiteralPattern()
+	case token.LPAREN:
+		if p.peekTokenIs(token.RPAREN) {
+			tok := p.curToken
+			p.nextToken()
+			return &ast.LiteralPattern{Token: tok, Value: &ast.UnitLiteral{Token: p.curToken}}
+		}
+		tok := p.curToken
+		p.nextToken()
+		pat := p.parsePattern()
+		if pat == nil {
+			return nil
+		}
+		if p.peekTokenIs(token.COMMA) {
+			pat = p.parseTuplePattern(tok, pat)
+			if pat == nil {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		return pat
+	case token.LBRACKET:
+		return p.parseRecordPattern()
+	case token.LSQUARE:
+		pat := &ast.EmptyListPattern{Token: p.curToken}
+		if !p.expectPeek(token.RSQUARE) {
+			return nil
+		}
+		return pat
+	}
+
+	p.customError(nil, p.curToken, "expected a pattern")
+	return nil
+}
+
+// Parse a literal, or a negated integer literal, as a pattern
+func (p *Parser) parseLiteralPattern() ast.Pattern {
+	pat := &ast.LiteralPattern{Token: p.curToken}
+	if p.curTokenIs(token.MINUS) {
+		pat.Value = p.parsePrefixExpression()
+	} else {
+		pat.Value = p.prefixParseFns[p.curToken.Type]()
+	}
+	if pat.Value == nil {
+		return nil
+	}
+	return pat
+}
+
+// Parse a constructor applied to the patterns of its arguments, C(p1, p2)
+func (p *Parser) parseConstructorPattern() ast.Pattern {
+	pat := &ast.ConstructorPattern{
+		Token: p.curToken,
+		Constructor: &ast.IdentifierExpr{
+			Token:      p.curToken,
+			Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal},
+		},
+	}
+	p.nextToken()
+
+	for {
+		p.nextToken()
+		arg := p.parsePattern()
+		if arg == nil {
+			return nil
+		}
+		pat.Args = append(pat.Args, arg)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return pat
+}
+
+// Parse the elements of a tuple pattern after the first one.
+// The closing paren is left to the caller
+func (p *Parser) parseTuplePattern(tok token.Token, first ast.Pattern) ast.Pattern {
+	pat := &ast.TuplePattern{Token: tok, Elements: []ast.Pattern{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		elem := p.parsePattern()
+		if elem == nil {
+			return nil
+		}
+		pat.Elements = append(pat.Elements, elem)
+	}
+	return pat
+}
+
+// Parse a record pattern in the form {x = p1, y = p2}
+func (p *Parser) parseRecordPattern() ast.Pattern {
+	pat := &ast.RecordPattern{Token: p.curToken, Fields: []*ast.RecordFieldPattern{}}
+
+	labels := map[string]bool{}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.RecordFieldPattern{Token: p.curToken, Label: p.curToken.Literal}
+		if labels[field.Label] {
+			p.customError(nil, p.curToken, "duplicate field "+field.Label+" in record pattern")
+			return nil
+		}
+		labels[field.Label] = true
+
+		if !p.expectPeek(token.EQUALS) {
+			return nil
+		}
+		p.nextToken()
+		field.Pattern = p.parsePattern()
+		if field.Pattern == nil {
+			return nil
+		}
+		pat.Fields = append(pat.Fields, field)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pat
+}
@@ -0,0 +1,422 @@
+package parser
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"unicode"
+)
+
+// Parse the `package name;` declaration at the start of a program.
+// The name can be followed by a restriction of the effects of the
+// statements, as in `package name deny io;`
+func (p *Parser) parsePackageStatement() *ast.PackageStatement {
+	stmt := &ast.PackageStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.IdentifierExpr{
+		Token:      p.curToken,
+		Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal},
+	}
+
+	if p.peekTokenIs(token.ALLOW) || p.peekTokenIs(token.DENY) || p.peekTokenIs(token.PURE) {
+		p.nextToken()
+		stmt.Restriction = p.parseEffectRestriction()
+		if stmt.Restriction == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.SEMI) {
+		return nil
+	}
+	return stmt
+}
+
+// Parse statements until the end of the input. Every statement
+// is terminated by a semicolon, that can be omitted after the last one.
+func (p *Parser) parseStatements() *ast.Program {
+	program := &ast.Program{Statements: []ast.Statement{}}
+
+	for !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if stmt == nil || len(p.errors) > 0 {
+			return nil
+		}
+		program.Statements = append(program.Statements, stmt)
+
+		if !p.peekTokenIs(token.EOF) && !p.expectPeek(token.SEMI) {
+			return nil
+		}
+		p.nextToken()
+	}
+
+	return program
+}
+
+// Parse a let statement, a type or newtype declaration, a policy
+// declaration, an interface or instance declaration or an
+// expression statement
+func (p *Parser) parseStatement() ast.Statement {
+	switch p.curToken.Type {
+	case token.LET:
+		return p.parseLetStatement()
+	case token.TYPE:
+		return p.parseTypeStatement()
+	case token.POLICY:
+		return p.parsePolicyStatement()
+	case token.NEWTYPE:
+		return p.parseNewtypeStatement()
+	case token.INTERFACE:
+		return p.parseInterfaceStatement()
+	case token.INSTANCE:
+		return p.parseInstanceStatement()
+	}
+
+	return p.parseExpressionStatement()
+}
+
+// Parse an expression up to the semicolon terminating the statement
+func (p *Parser) parseExpressionStatement() ast.Statement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseUnsequenced()
+	if stmt.Expression == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse `let x = e1 and y = e2;`. When the assignments are
+// followed by `in`, this is a let expression used as a statement.
+func (p *Parser) parseLetStatement() ast.Statement {
+	tok := p.curToken
+	asss := p.parseAssignments()
+	if asss == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IN) {
+		p.nextToken()
+		p.nextToken()
+		return &ast.ExpressionStatement{
+			Token: tok,
+			Expression: &ast.LetExpression{
+				Token:       tok,
+				Assignments: asss,
+				Body:        p.ParseExpression(LOWEST),
+			},
+		}
+	}
+
+	return &ast.LetStatement{Token: tok, Assignments: asss}
+}
+
+// Parse the declaration of an algebraic data type, in the form
+// `type name(a, b) = C1 | C2(type, ...) | ...`, or of a type alias.
+// The bar before the first constructor is optional
+func (p *Parser) parseTypeStatement() ast.Statement {
+	stmt := &ast.TypeStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = ast.UniqueIdentifier{Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			for _, param := range stmt.Params {
+				if param.Value == p.curToken.Literal {
+					p.customError(nil, p.curToken, "duplicate type parameter "+p.curToken.Literal)
+					return nil
+				}
+			}
+			stmt.Params = append(stmt.Params, ast.UniqueIdentifier{Value: p.curToken.Literal})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.EQUALS) {
+		return nil
+	}
+	if !p.peekTokenIs(token.BAR) && !p.peekConstructor() {
+		return p.parseAliasStatement(stmt)
+	}
+	if p.peekTokenIs(token.BAR) {
+		p.nextToken()
+	}
+
+	names := map[string]bool{}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		ctor := p.parseConstructorDecl()
+		if ctor == nil {
+			return nil
+		}
+		if names[ctor.Name.Identifier.Value] {
+			p.customError(nil, ctor.Token, "duplicate constructor "+ctor.Name.Identifier.Value)
+			return nil
+		}
+		names[ctor.Name.Identifier.Value] = true
+		stmt.Constructors = append(stmt.Constructors, ctor)
+
+		if !p.peekTokenIs(token.BAR) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Returns true if the next token is the name of a constructor.
+// Constructors are capitalized, the names of types are not
+func (p *Parser) peekConstructor() bool {
+	return p.peekTokenIs(token.IDENT) && unicode.IsUpper([]rune(p.peekToken.Literal)[0])
+}
+
+// Parse the type of a type alias, `type vec = (float, float)`. A type
+// starting with a capitalized name must be grouped in parens, otherwise
+// the declaration is parsed as a data type with a single constructor
+func (p *Parser) parseAliasStatement(ts *ast.TypeStatement) ast.Statement {
+	stmt := &ast.AliasStatement{Token: ts.Token, Name: ts.Name, Params: ts.Params}
+
+	p.nextToken()
+	stmt.Type = p.parseTypeValue(TLOWEST)
+	if stmt.Type == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse the declaration of a nominal type, in the form `newtype name = type`.
+// The functions wrapping and unwrapping the values are name and unname
+func (p *Parser) parseNewtypeStatement() ast.Statement {
+	stmt := &ast.NewtypeStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = ast.UniqueIdentifier{Value: p.curToken.Literal}
+	stmt.Wrap = &ast.IdentifierExpr{Token: p.curToken, Identifier: stmt.Name}
+	unwrap := token.Token{Type: token.IDENT, Literal: "un" + p.curToken.Literal}
+	stmt.Unwrap = &ast.IdentifierExpr{Token: unwrap, Identifier: ast.UniqueIdentifier{Value: unwrap.Literal}}
+
+	if !p.expectPeek(token.EQUALS) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Type = p.parseTypeValue(TLOWEST)
+	if stmt.Type == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse a constructor of a type declaration and the types of its arguments
+func (p *Parser) parseConstructorDecl() *ast.ConstructorDecl {
+	ctor := &ast.ConstructorDecl{
+		Token: p.curToken,
+		Name: &ast.IdentifierExpr{
+			Token:      p.curToken,
+			Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal},
+		},
+	}
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return ctor
+	}
+	p.nextToken()
+	args := p.parseTypeArgs()
+	if args == nil {
+		return nil
+	}
+	ctor.Args = args
+	return ctor
+}
+
+// Parse the declaration of a usage policy, in the form
+// `policy name { init q0; q0 -event-> q1; offending q1; }`.
+// The initial state is required, and there is at most
+// one transition from a state with an event
+func (p *Parser) parsePolicyStatement() ast.Statement {
+	stmt := &ast.PolicyStatement{Token: p.curToken, Offending: []string{}}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = p.curToken.Literal
+	if !p.expectPeek(token.LBRACKET) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		switch p.curToken.Literal {
+		case "init":
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Init = p.curToken.Literal
+		case "offending":
+			for {
+				if !p.expectPeek(token.IDENT) {
+					return nil
+				}
+				stmt.Offending = append(stmt.Offending, p.curToken.Literal)
+				if !p.peekTokenIs(token.COMMA) {
+					break
+				}
+				p.nextToken()
+			}
+		default:
+			t := p.parsePolicyTransition()
+			if t == nil {
+				return nil
+			}
+			for _, old := range stmt.Transitions {
+				if old.From == t.From && old.Event == t.Event {
+					p.customError(nil, t.Token, "duplicate transition from "+t.From+" with event "+t.Event)
+					return nil
+				}
+			}
+			stmt.Transitions = append(stmt.Transitions, t)
+		}
+		if !p.expectPeek(token.SEMI) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	if stmt.Init == "" {
+		p.customError(nil, stmt.Token, "missing initial state of policy "+stmt.Name)
+		return nil
+	}
+	return stmt
+}
+
+// Parse a transition of a usage policy, `q0 -event-> q1`
+func (p *Parser) parsePolicyTransition() *ast.PolicyTransition {
+	t := &ast.PolicyTransition{Token: p.curToken, From: p.curToken.Literal}
+	if !p.expectPeek(token.MINUS) || !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	t.Event = p.curToken.Literal
+	if !p.expectPeek(token.RARROW) || !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	t.To = p.curToken.Literal
+	return t
+}
+
+// Parse the declaration of an interface, in the form
+// `interface name(a) { method : type; ... }`. Every
+// method is terminated by a semicolon
+func (p *Parser) parseInterfaceStatement() ast.Statement {
+	stmt := &ast.InterfaceStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = p.curToken.Literal
+	if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Param = ast.UniqueIdentifier{Value: p.curToken.Literal}
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACKET) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		m := &ast.MethodDecl{
+			Token: p.curToken,
+			Name: &ast.IdentifierExpr{
+				Token:      p.curToken,
+				Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal},
+			},
+		}
+		if stmt.Method(m.Name.Identifier.Value) != nil {
+			p.customError(nil, m.Token, "duplicate method "+m.Name.Identifier.Value)
+			return nil
+		}
+		if !p.expectPeek(token.ANNOT) {
+			return nil
+		}
+		p.nextToken()
+		m.Type = p.parseTypeValue(TLOWEST)
+		if m.Type == nil {
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, m)
+		if !p.expectPeek(token.SEMI) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return stmt
+}
+
+// Parse the declaration of an instance of an interface for a type,
+// in the form `instance name(type) { method = value; ... }`. Every
+// method is terminated by a semicolon
+func (p *Parser) parseInstanceStatement() ast.Statement {
+	stmt := &ast.InstanceStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Interface = p.curToken.Literal
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Type = p.parseTypeValue(TLOWEST)
+	if stmt.Type == nil || !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACKET) {
+		return nil
+	}
+
+	names := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACKET) {
+		ass := p.parseAssignment()
+		if ass == nil {
+			return nil
+		}
+		if ass.Pattern != nil {
+			p.customError(nil, ass.Token, "a method of an instance cannot be a pattern")
+			return nil
+		}
+		if names[ass.Name.Identifier.Value] {
+			p.customError(nil, ass.Token, "duplicate method "+ass.Name.Identifier.Value)
+			return nil
+		}
+		names[ass.Name.Identifier.Value] = true
+		// Methods are not recursive, their names
+		// refer to the overloaded methods
+		ass.Value = unfixFunction(ass.Value)
+		stmt.Methods = append(stmt.Methods, ass)
+		if !p.expectPeek(token.SEMI) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return stmt
+}
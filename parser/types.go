@@ -4,26 +4,453 @@ import (
 	// "fmt"
 	"github.com/0x0f0f0f/gobba-golang/ast"
 	"github.com/0x0f0f0f/gobba-golang/token"
-	// "strconv"
+	"sort"
+	"strconv"
 )
 
-// Default types map
-var defaultTypes = map[token.TokenType]string{
-	token.TBOOL: "bool",
-	token.TINT:  "int",
+// Type expressions are parsed with a separate, smaller Pratt parser.
+// The same tokens have a different meaning in types: -> is the
+// function type constructor, | builds a union type and . closes
+// the binders of a forall and of a mu
+
+// Precedence levels for type operators
+const (
+	_       int = iota
+	TLOWEST     // Terminal type
+	TARROW      // -> and -{effects}->
+	TUNION      // |
+)
+
+var typePrecedences = map[token.TokenType]int{
+	token.RARROW: TARROW,
+	token.MINUS:  TARROW,
+	token.BAR:    TUNION,
 }
 
-// Parse a type value
-func (p *Parser) parseTypeValue() ast.TypeValue {
-	if p.curTokenIs(token.IDENT) {
-		return &ast.VariableType{Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal}}
-	} else if p.curTokenIs(token.UNIT) {
+var rightAssociativeTypes = map[token.TokenType]bool{
+	token.RARROW: true,
+	token.MINUS:  true,
+}
+
+type prefixTypeParseFn func() ast.TypeValue
+type infixTypeParseFn func(ast.TypeValue) ast.TypeValue
+
+// Register the type parsing functions
+func (p *Parser) registerTypeParseFns() {
+	p.prefixTypeParseFns = map[token.TokenType]prefixTypeParseFn{
+		token.IDENT:    p.parseTypeVariable,
+		token.LPAREN:   p.parseGroupedType,
+		token.FORALL:   p.parseForAllType,
+		token.MU:       p.parseMuType,
+		token.LBRACKET: p.parseRecordType,
+		token.LSQUARE:  p.parseListType,
+		token.DENSE:    p.parseMatrixType,
+		token.SPARSE:   p.parseMatrixType,
+	}
+	p.infixTypeParseFns = map[token.TokenType]infixTypeParseFn{
+		token.RARROW: p.parseArrowType,
+		token.MINUS:  p.parseArrowType,
+		token.BAR:    p.parseUnionType,
+	}
+}
+
+// Get the next token precedence level in a type
+func (p *Parser) peekTypePrecedence() int {
+	if p, ok := typePrecedences[p.peekToken.Type]; ok {
+		return p
+	}
+	return TLOWEST
+}
+
+// Parse a type value with a given precedence
+func (p *Parser) parseTypeValue(precedence int) ast.TypeValue {
+	prefix := p.prefixTypeParseFns[p.curToken.Type]
+	if prefix == nil {
+		p.expectedType(p.curToken)
+		return nil
+	}
+	left := prefix()
+
+	for left != nil && precedence < p.peekTypePrecedence() {
+		infix := p.infixTypeParseFns[p.peekToken.Type]
+		if infix == nil {
+			return left
+		}
+		p.nextToken()
+		left = infix(left)
+	}
+
+	return left
+}
+
+// Parse a type variable, the name of a builtin type or the
+// name of a data type applied to its arguments, as in tree(int)
+func (p *Parser) parseTypeVariable() ast.TypeValue {
+	switch p.curToken.Literal {
+	case token.TUNIT:
 		return &ast.UnitType{}
+	case token.TMATRIX, token.TCMATRIX:
+		// Matrices are dense if the density is not given
+		return p.parseMatrixDimensions(&ast.MatrixType{Complex: p.curToken.Literal == token.TCMATRIX})
+	}
+	id := ast.UniqueIdentifier{Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return &ast.VariableType{Identifier: id}
+	}
+	p.nextToken()
+	args := p.parseTypeArgs()
+	if args == nil {
+		return nil
+	}
+	return &ast.DataType{Identifier: id, Args: args}
+}
+
+// Parse a non empty list of types separated by commas and enclosed in
+// parens. The current token is the opening paren
+func (p *Parser) parseTypeArgs() []ast.TypeValue {
+	args := []ast.TypeValue{}
+	for {
+		p.nextToken()
+		ty := p.parseTypeValue(TLOWEST)
+		if ty == nil {
+			return nil
+		}
+		args = append(args, ty)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return args
+}
+
+// Parse the unit type (), a type grouped by parens or
+// a tuple type (a, b)
+func (p *Parser) parseGroupedType() ast.TypeValue {
+	p.nextToken()
+
+	if p.curTokenIs(token.RPAREN) {
+		return &ast.UnitType{}
+	}
+
+	ty := p.parseTypeValue(TLOWEST)
+	if ty == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.COMMA) {
+		tuple := &ast.TupleType{Elements: []ast.TypeValue{ty}}
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			p.nextToken()
+			elem := p.parseTypeValue(TLOWEST)
+			if elem == nil {
+				return nil
+			}
+			tuple.Elements = append(tuple.Elements, elem)
+		}
+		ty = tuple
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return ty
+}
+
+// Parse a record type in the form {x: int, y: bool}
+func (p *Parser) parseRecordType() ast.TypeValue {
+	rec := &ast.RecordType{Fields: map[string]ast.TypeValue{}}
+
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return rec
+	}
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		label := p.curToken.Literal
+		if _, ok := rec.Fields[label]; ok {
+			p.customError(nil, p.curToken, "duplicate field "+label+" in record type")
+			return nil
+		}
+
+		if !p.expectPeek(token.ANNOT) {
+			return nil
+		}
+		p.nextToken()
+		ty := p.parseTypeValue(TLOWEST)
+		if ty == nil {
+			return nil
+		}
+		rec.Fields[label] = ty
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return rec
+}
+
+// Parse a list type []a or an array type [n]a. The type of the
+// elements is an atom, []a -> b is parsed as ([]a) -> b
+func (p *Parser) parseListType() ast.TypeValue {
+	var size ast.TypeValue
+	if !p.peekTokenIs(token.RSQUARE) {
+		p.nextToken()
+		size = p.parseDimension()
+		if size == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RSQUARE) {
+		return nil
+	}
+	p.nextToken()
+
+	elem := p.parseTypeValue(TUNION)
+	if elem == nil {
+		return nil
+	}
+	if size != nil {
+		return &ast.ArrayType{Size: size, Element: elem}
+	}
+	return &ast.ListType{Element: elem}
+}
+
+// Parse a matrix type preceded by its density, as in sparse cmatrix
+func (p *Parser) parseMatrixType() ast.TypeValue {
+	sparse := p.curTokenIs(token.SPARSE)
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	switch p.curToken.Literal {
+	case token.TMATRIX, token.TCMATRIX:
+		return p.parseMatrixDimensions(&ast.MatrixType{Sparse: sparse, Complex: p.curToken.Literal == token.TCMATRIX})
 	}
-	p.expectedType(p.curToken)
+	p.customError(nil, p.curToken, "expected matrix or cmatrix")
 	return nil
 }
 
+// Parse the optional number of rows and columns of a matrix
+// type, as in matrix(2, n). The current token is matrix or cmatrix
+func (p *Parser) parseMatrixDimensions(mt *ast.MatrixType) ast.TypeValue {
+	if !p.peekTokenIs(token.LPAREN) {
+		return mt
+	}
+	p.nextToken()
+	p.nextToken()
+	if mt.Rows = p.parseDimension(); mt.Rows == nil {
+		return nil
+	}
+	if !p.expectPeek(token.COMMA) {
+		return nil
+	}
+	p.nextToken()
+	if mt.Cols = p.parseDimension(); mt.Cols == nil {
+		return nil
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return mt
+}
+
+// Parse a dimension, an integer literal or a dimension variable
+func (p *Parser) parseDimension() ast.TypeValue {
+	switch p.curToken.Type {
+	case token.INT:
+		size, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+		if err != nil {
+			p.customError(nil, p.curToken, "could not parse as integer")
+			return nil
+		}
+		return &ast.DimensionType{Size: size}
+	case token.IDENT:
+		return &ast.VariableType{Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal}}
+	}
+	p.customError(nil, p.curToken, "expected a dimension")
+	return nil
+}
+
+// Parse a function type. The arrow is right associative,
+// a -> b -> c is parsed as a -> (b -> c). The effects of
+// a function are written in the arrow, as in a -{io}-> b
+func (p *Parser) parseArrowType(left ast.TypeValue) ast.TypeValue {
+	precedence := typePrecedences[p.curToken.Type]
+	if rightAssociativeTypes[p.curToken.Type] {
+		precedence--
+	}
+
+	var effects *ast.EffectRow
+	if p.curTokenIs(token.MINUS) {
+		if !p.expectPeek(token.LBRACKET) {
+			return nil
+		}
+		effects = p.parseEffectRow()
+		if effects == nil || !p.expectPeek(token.RARROW) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	right := p.parseTypeValue(precedence)
+	if right == nil {
+		return nil
+	}
+
+	return &ast.LambdaType{Domain: left, Codomain: right, Effects: effects}
+}
+
+// Parse an effect row in the form {io, fail, e}. Names that are not
+// effect labels are row variables. The current token is the opening brace
+func (p *Parser) parseEffectRow() *ast.EffectRow {
+	labels := []string{}
+	tails := []ast.TypeValue{}
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return ast.NewEffectRow(labels, tails)
+	}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if ast.IsEffectLabel(p.curToken.Literal) {
+			labels = append(labels, p.curToken.Literal)
+		} else {
+			tails = append(tails, &ast.VariableType{Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal}})
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return ast.NewEffectRow(labels, tails)
+}
+
+// Parse a union type. The union binds tighter than the arrow,
+// a | b -> c is parsed as (a | b) -> c
+func (p *Parser) parseUnionType(left ast.TypeValue) ast.TypeValue {
+	p.nextToken()
+
+	right := p.parseTypeValue(TUNION)
+	if right == nil {
+		return nil
+	}
+
+	return &ast.UnionType{Left: left, Right: right}
+}
+
+// Parse a polymorphic type in the form forall a b. T, which stands
+// for forall a. forall b. T. The quantified type extends as far
+// to the right as possible. The interfaces that a type variable must
+// implement follow it in parens, as in forall (a: Eq, Show) b. T
+func (p *Parser) parseForAllType() ast.TypeValue {
+	if !p.peekTokenIs(token.LPAREN) && !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	if p.curTokenIs(token.FORALL) {
+		p.nextToken()
+	}
+
+	binders := []*ast.ForAllType{}
+	for p.curTokenIs(token.IDENT) || p.curTokenIs(token.LPAREN) {
+		binder := p.parseForAllBinder()
+		if binder == nil {
+			return nil
+		}
+		binders = append(binders, binder)
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.ACCESS) {
+		expected := token.TokenType(token.ACCESS)
+		p.customError(&expected, p.curToken, "expected a . after the type variables of forall")
+		return nil
+	}
+	p.nextToken()
+
+	ty := p.parseTypeValue(TLOWEST)
+	if ty == nil {
+		return nil
+	}
+
+	for i := len(binders) - 1; i >= 0; i-- {
+		binders[i].Type = ty
+		ty = binders[i]
+	}
+	return ty
+}
+
+// Parse a recursive type in the form mu t. T, where t stands for
+// the whole type in T. Like forall, the type extends as far to the
+// right as possible
+func (p *Parser) parseMuType() ast.TypeValue {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	mt := &ast.MuType{Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal}}
+	if !p.peekTokenIs(token.ACCESS) {
+		expected := token.TokenType(token.ACCESS)
+		p.customError(&expected, p.peekToken, "expected a . after the type variable of mu")
+		return nil
+	}
+	p.nextToken()
+	p.nextToken()
+
+	mt.Type = p.parseTypeValue(TLOWEST)
+	if mt.Type == nil {
+		return nil
+	}
+	return mt
+}
+
+// Parse a type variable bound by a forall, or a type variable
+// with the interfaces it implements, (a: Eq, Show)
+func (p *Parser) parseForAllBinder() *ast.ForAllType {
+	if p.curTokenIs(token.IDENT) {
+		return &ast.ForAllType{Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal}}
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	binder := &ast.ForAllType{Identifier: ast.UniqueIdentifier{Value: p.curToken.Literal}}
+	if !p.expectPeek(token.ANNOT) {
+		return nil
+	}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		binder.Constraints = append(binder.Constraints, p.curToken.Literal)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	sort.Strings(binder.Constraints)
+	return binder
+}
+
 // Parse a type annotation
 func (p *Parser) parseFunArgAnnot() ast.Expression {
 	name := p.parseIdentifier()
@@ -31,14 +458,18 @@ func (p *Parser) parseFunArgAnnot() ast.Expression {
 	if !ok {
 		panic("fatal parsing error")
 	}
+	return p.parseParamAnnot(iid)
+}
 
+// Parse the optional type annotation of a function parameter
+func (p *Parser) parseParamAnnot(iid *ast.IdentifierExpr) ast.Expression {
 	if !p.peekTokenIs(token.ANNOT) {
 		return iid
 	}
 	p.nextToken()
 	p.nextToken()
 
-	ty := p.parseTypeValue()
+	ty := p.parseTypeValue(TLOWEST)
 
 	return &ast.AnnotExpr{
 		Token: iid.Token,
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/lexer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTypeParsing(t *testing.T) {
+	tests := map[string]string{
+		"(x : int)":                               "(x: int)",
+		"(x : unit)":                              "(x: unit)",
+		"(x : ())":                                "(x: unit)",
+		"(x : int -> int)":                        "(x: int -> int)",
+		"(x : a -> b -> c)":                       "(x: a -> b -> c)",
+		"(x : (a -> b) -> c)":                     "(x: (a -> b) -> c)",
+		"(x : ((a)))":                             "(x: a)",
+		"(x : forall a. a -> a)":                  "(x: ∀a.a -> a)",
+		"(x : forall a b. a -> b)":                "(x: ∀a.∀b.a -> b)",
+		"(x : (forall a. a -> a) -> int)":         "(x: (∀a.a -> a) -> int)",
+		"(x : int -> forall a. a)":                "(x: int -> ∀a.a)",
+		"(x : forall (a: Show). a -> string)":     "(x: ∀(a: Show).a -> string)",
+		"(x : forall (a: Show, Eq) b. a -> b)":    "(x: ∀(a: Eq, Show).∀b.a -> b)",
+		"(x : mu t. unit | (int, t))":             "(x: μt.unit | (int, t))",
+		"(x : mu s. (int, unit -> s))":            "(x: μs.(int, unit -> s))",
+		"(x : (mu t. int -> t) -> int)":           "(x: (μt.int -> t) -> int)",
+		"(x : forall a. mu t. option((t, a, t)))": "(x: ∀a.μt.option((t, a, t)))",
+		"(x : [](mu t. {x: int, next: []t}))":     "(x: [](μt.{next: []t, x: int}))",
+		"(x : {})":                                "(x: {})",
+		"(x : {y: int, x: bool -> bool})":         "(x: {x: bool -> bool, y: int})",
+		"(x : (int, bool))":                       "(x: (int, bool))",
+		"(x : (a, b) -> (b, a))":                  "(x: (a, b) -> (b, a))",
+		"(x : int | bool)":                        "(x: int | bool)",
+		"(x : int | bool -> int | bool)":          "(x: int | bool -> int | bool)",
+		"(x : (int -> int) | bool)":               "(x: (int -> int) | bool)",
+		"(x : []int)":                             "(x: []int)",
+		"(x : [][]int -> int)":                    "(x: [][]int -> int)",
+		"(x : [](int -> int))":                    "(x: [](int -> int))",
+		"(x : [](int | bool))":                    "(x: [](int | bool))",
+		"(x : matrix)":                            "(x: dense matrix)",
+		"(x : sparse cmatrix -> dense matrix)":    "(x: sparse cmatrix -> dense matrix)",
+		"(x : []sparse matrix)":                   "(x: []sparse matrix)",
+		"(x : matrix(2, n))":                      "(x: dense matrix(2, n))",
+		"(x : sparse cmatrix(m, 3))":              "(x: sparse cmatrix(m, 3))",
+		"(x : [3]int -> [n][]bool)":               "(x: [3]int -> [n][]bool)",
+		"(x : int -{io}-> int)":                   "(x: int -{io}-> int)",
+		"(x : int -{io, fail, e}-> int -> int)":   "(x: int -{fail, io, e}-> int -> int)",
+		"(x : (a -{e}-> b) -{}-> b)":              "(x: (a -{e}-> b) -> b)",
+		"(f(x) + 1 : int)":                        "((f(x) + 1): int)",
+		"fun (f: int -> int, x: int) {f(x)}":      "(λ f: int -> int . (λ x: int . f(x)))",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := New(lexer.New(input))
+		expr := p.ParseSingleExpression()
+		CheckParserErrors(t, p)
+		assert.Equal(t, expected, expr.String(), input)
+	}
+}
+
+func TestTypeParsingFailures(t *testing.T) {
+	tests := []string{
+		"(x : )",
+		"(x : int ->)",
+		"(x : forall . a)",
+		"(x : forall a a)",
+		"(x : forall (a). a)",
+		"(x : forall (a: ). a)",
+		"(x : forall (a: Eq,). a)",
+		"(x : forall (a: Eq. a)",
+		"(x : mu . int)",
+		"(x : mu t int)",
+		"(x : mu t u. int)",
+		"(x : mu t.)",
+		"(x : (int)",
+		"(x : (int, ))",
+		"(x : int | )",
+		"(x : | int)",
+		"(x : [int])",
+		"(x : [)",
+		"(x : sparse int)",
+		"(x : dense)",
+		"(x : matrix(2))",
+		"(x : matrix([]int, 2))",
+		"(x : [1.5]int)",
+		"(x : int -{io-> int)",
+		"(x : int -{io}- int)",
+		"(x : int -io-> int)",
+		"(x : int -{1}-> int)",
+		"fun (x: 1) {x}",
+	}
+
+	for _, input := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := New(lexer.New(input))
+		p.ParseSingleExpression()
+		assert.NotEqual(t, 0, len(p.Errors()), input)
+	}
+}
@@ -0,0 +1,121 @@
+package repl
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// This file contains the meta-commands of the REPL. Input lines
+// starting with a colon are handled as commands instead of being
+// lexed as gobba code.
+
+type metaCommand struct {
+	Name  string
+	Usage string
+	Help  string
+	Run   func(r *Repl, arg string)
+}
+
+// Returns true if the usage of a command has an argument
+func (cmd metaCommand) takesArg() bool {
+	return strings.Contains(cmd.Usage, " ")
+}
+
+// Commands in the order in which they are listed by :help
+var metaCommands []metaCommand
+
+func init() {
+	metaCommands = []metaCommand{
+		{"type", ":type expr", "print the type of an input without evaluating it", (*Repl).typeCommand},
+		{"ast", ":ast expr", "evaluate an input, printing its AST", (*Repl).astCommand},
+		{"tokens", ":tokens expr", "evaluate an input, printing its tokens", (*Repl).tokensCommand},
+		{"load", ":load file.gb", "evaluate a source file in the current session", (*Repl).loadCommand},
+		{"reset", ":reset", "forget all the bindings of the session", (*Repl).resetCommand},
+		{"context", ":context", "print the bindings and type definitions in the typing context", (*Repl).contextCommand},
+		{"help", ":help", "print this message", (*Repl).helpCommand},
+	}
+}
+
+// Returns true if an input line is a meta-command
+func isMetaCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), ":")
+}
+
+// Parse and run a meta-command line
+func (r *Repl) runMetaCommand(line string) {
+	line = strings.TrimPrefix(strings.TrimSpace(line), ":")
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i:])
+	}
+
+	for _, cmd := range metaCommands {
+		if cmd.Name == name {
+			if arg == "" && cmd.takesArg() {
+				fmt.Fprintf(os.Stderr, "missing argument. usage: %s\n", cmd.Usage)
+				return
+			}
+			cmd.Run(r, arg)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command :%s. type :help for a list of commands\n", name)
+}
+
+func (r *Repl) typeCommand(arg string) {
+	ty, _, err := r.session.Interpret(arg, false)
+	r.printWarnings()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Printf("- : %s\n", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+}
+
+func (r *Repl) astCommand(arg string) {
+	show := r.Options.ShowAST
+	r.Options.ShowAST = true
+	r.executor(arg)
+	r.Options.ShowAST = show
+}
+
+func (r *Repl) tokensCommand(arg string) {
+	show := r.Options.ShowTok
+	r.Options.ShowTok = true
+	r.executor(arg)
+	r.Options.ShowTok = show
+}
+
+func (r *Repl) loadCommand(arg string) {
+	source, err := ioutil.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	ty, value, err := r.session.InterpretFile(string(source), true)
+	for _, w := range r.session.Warnings() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", arg, w)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", arg, err)
+		return
+	}
+	fmt.Printf("- : %s = %s\n", ty.FancyString(map[ast.UniqueIdentifier]int{}), value)
+}
+
+func (r *Repl) resetCommand(arg string) {
+	r.session = NewSession(r.Options)
+}
+
+func (r *Repl) contextCommand(arg string) {
+	fmt.Print(r.session.Context().FancyString())
+}
+
+func (r *Repl) helpCommand(arg string) {
+	for _, cmd := range metaCommands {
+		fmt.Printf("  %-16s %s\n", cmd.Usage, cmd.Help)
+	}
+}
@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// This file contains the tab completion of the REPL
+
+// A completion candidate, with an optional description that is
+// printed after it, such as the type of an identifier
+type suggestion struct {
+	Text        string
+	Description string
+}
+
+// Returns true for runes that can be part of an identifier
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Find the suggestions for the word under the cursor. Meta-commands
+// are completed at the start of the line, type names after the colon
+// of a type annotation, keywords and the identifiers bound in the
+// session everywhere else.
+func (r *Repl) suggest(line string, pos int) (string, []suggestion, string) {
+	runes := []rune(line)
+	head, tail := string(runes[:pos]), string(runes[pos:])
+
+	if strings.HasPrefix(head, ":") && !strings.ContainsAny(head, " \t") {
+		sugg := []suggestion{}
+		for _, cmd := range metaCommands {
+			if strings.HasPrefix(":"+cmd.Name, head) {
+				sugg = append(sugg, suggestion{":" + cmd.Name, "  " + cmd.Help})
+			}
+		}
+		return "", sugg, tail
+	}
+
+	start := pos
+	for start > 0 && isIdentifierRune(runes[start-1]) {
+		start--
+	}
+	word := string(runes[start:pos])
+	head = string(runes[:start])
+
+	// Skip the word if it is a number
+	if word != "" && unicode.IsDigit([]rune(word)[0]) {
+		return head, nil, tail
+	}
+
+	sugg := []suggestion{}
+	if strings.HasSuffix(strings.TrimRightFunc(head, unicode.IsSpace), ":") {
+		for name := range ast.DefaultVariableTypes {
+			if strings.HasPrefix(name, word) {
+				sugg = append(sugg, suggestion{Text: name})
+			}
+		}
+		for _, name := range []string{token.TUNIT, token.TMATRIX, token.TCMATRIX} {
+			if strings.HasPrefix(name, word) {
+				sugg = append(sugg, suggestion{Text: name})
+			}
+		}
+	} else {
+		for _, kw := range token.Keywords() {
+			if strings.HasPrefix(kw, word) {
+				sugg = append(sugg, suggestion{Text: kw})
+			}
+		}
+		for _, b := range r.session.Bindings() {
+			if strings.HasPrefix(b.Name, word) {
+				desc := " : " + b.Type.FancyString(map[ast.UniqueIdentifier]int{})
+				sugg = append(sugg, suggestion{b.Name, desc})
+			}
+		}
+	}
+
+	sort.SliceStable(sugg, func(i, j int) bool {
+		return sugg[i].Text < sugg[j].Text
+	})
+	return head, sugg, tail
+}
+
+// Word completer for liner. The line is completed directly when there
+// is a single candidate. Otherwise liner prints the candidates, showing
+// their descriptions, and completes the line up to their longest common
+// prefix, which never extends past the shortest name, since names cannot
+// contain spaces.
+func (r *Repl) complete(line string, pos int) (string, []string, string) {
+	head, sugg, tail := r.suggest(line, pos)
+	if len(sugg) == 1 {
+		return head, []string{sugg[0].Text}, tail
+	}
+
+	candidates := make([]string, len(sugg))
+	for i, s := range sugg {
+		candidates[i] = s.Text + s.Description
+	}
+	return head, candidates, tail
+}
@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/typecheck"
+)
+
+// The part of the prelude that is written in gobba. It is interpreted
+// in every new session, after the builtins are bound
+const prelude = `
+let map = fun(f, l) {
+	match l with
+	| [] -> []
+	| x :: xs -> f(x) :: map(f, xs)
+};
+let fold = fun(f, z, l) {
+	match l with
+	| [] -> z
+	| x :: xs -> fold(f, f(z, x), xs)
+};
+`
+
+// Bind the builtin functions, the methods of the builtin interfaces
+// and the dictionaries of the builtin instances in the session, in all
+// the stages of the interpreter
+func (s *Session) bindBuiltins() {
+	for _, b := range eval.Builtins {
+		s.bindBuiltin(ast.UniqueIdentifier{Value: b.Name}, b.Type, b.Value)
+	}
+	for _, iface := range ast.BuiltinInterfaces {
+		for _, m := range iface.Methods {
+			s.bindBuiltin(m.Name.Identifier, iface.MethodType(m), eval.NewMethod(m.Name.Identifier.Value))
+		}
+	}
+	for _, inst := range eval.Instances {
+		s.context = s.context.InsertHead(&typecheck.InstanceDefinition{
+			Interface:  inst.Interface,
+			Type:       inst.Type,
+			Dictionary: inst.Identifier(),
+		})
+		s.bindValue(inst.Identifier(), inst.Value)
+	}
+}
+
+// Bind a name with its type and value
+func (s *Session) bindBuiltin(id ast.UniqueIdentifier, t ast.TypeValue, v eval.Value) {
+	uid := s.alphaEnv.IdentifierAlphaConversion(id)
+	s.context = s.context.InsertHead(&typecheck.TypeAnnotation{Identifier: uid, Value: t})
+	s.bindValue(uid, v)
+}
+
+// Bind an α-converted identifier to a value in the
+// evaluator and in the virtual machine
+func (s *Session) bindValue(uid ast.UniqueIdentifier, v eval.Value) {
+	s.env.Set(uid, v)
+	sym := s.symbolTable.Define(uid)
+	s.globals[sym.Index] = v
+}
+
+// Load the prelude in the session. The prelude is part of the
+// interpreter, an error here is a bug
+func (s *Session) loadPrelude() {
+	s.bindBuiltins()
+	// Do not print the tokens, the AST and the typechecking of the prelude
+	o, debug := s.Options, typecheck.DebugTypeCheck
+	s.Options = &ReplOptions{UseVM: o.UseVM}
+	typecheck.DebugTypeCheck = false
+	defer func() { s.Options, typecheck.DebugTypeCheck = o, debug }()
+	if _, _, err := s.Interpret(prelude, true); err != nil {
+		panic("error in the prelude: " + err.Error())
+	}
+}
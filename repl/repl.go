@@ -2,23 +2,22 @@ package repl
 
 import (
 	"fmt"
-	"github.com/0x0f0f0f/gobba-golang/alpha"
 	"github.com/0x0f0f0f/gobba-golang/ast"
 	"github.com/0x0f0f0f/gobba-golang/lexer"
 	"github.com/0x0f0f0f/gobba-golang/parser"
-	"github.com/0x0f0f0f/gobba-golang/token"
-	"github.com/0x0f0f0f/gobba-golang/typecheck"
 	"github.com/alecthomas/repr"
 	// "github.com/c-bata/go-prompt"
 	"github.com/peterh/liner"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type ReplOptions struct {
 	ShowAST         bool
 	ShowTok         bool
 	DebugParser     bool
+	UseVM           bool
 	PromptString    string
 	HistoryFilename string
 }
@@ -26,7 +25,8 @@ type ReplOptions struct {
 type Repl struct {
 	Options *ReplOptions
 	// prompt  *prompt.Prompt
-	line *liner.State
+	line    *liner.State
+	session *Session
 }
 
 // TODO go-prompt live prefix
@@ -63,18 +63,42 @@ func New(o *ReplOptions) *Repl {
 
 	r.Options = o
 	r.line = line
+	r.session = NewSession(o)
+
+	line.SetWordCompleter(r.complete)
+	line.SetTabCompletionStyle(liner.TabPrints)
 	return r
 }
 
+// Prompt shown while collecting the lines of an incomplete input
+const continuationPrompt = "| "
+
 func (r *Repl) Start() {
 	run := true
+	input := &pendingInput{}
 	for run {
-		if l, err := r.line.Prompt("> "); err == nil {
-			r.line.AppendHistory(l)
-			r.executor(l)
+		prompt := "> "
+		if len(input.lines) > 0 {
+			prompt = continuationPrompt
+		}
+
+		if l, err := r.line.Prompt(prompt); err == nil {
+			ready, aborted := input.add(l)
+			if aborted {
+				fmt.Fprintln(os.Stderr, "Aborted")
+			}
+			for _, in := range ready {
+				r.line.AppendHistory(strings.Replace(in, "\n", " ", -1))
+				r.executor(in)
+			}
 		} else if err == liner.ErrPromptAborted {
+			input.flush()
 			fmt.Fprintln(os.Stderr, "Aborted")
 		} else if err.Error() == "EOF" {
+			// A complete input waiting for more match arms is executed
+			if input.open {
+				r.executor(input.flush())
+			}
 			run = false
 		} else {
 			repr.Println(err)
@@ -94,60 +118,84 @@ func (r *Repl) Start() {
 	r.line.Close()
 }
 
-func (r *Repl) executor(line string) {
-	if r.Options.ShowTok {
-		l := lexer.New(line)
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+// The lines of an input that is not complete yet
+type pendingInput struct {
+	lines []string
+	// The lines are a complete input that can continue with
+	// lines starting with |, such as the arms of a match
+	open bool
+}
+
+// Add a line typed at the prompt to the pending input. Returns the
+// inputs that are complete, in the order in which they must be
+// executed. An input that can continue with more match arms is
+// complete when the next line does not start with |, an empty line
+// completes it. A meta-command typed while an input is pending aborts
+// the input, and is executed
+func (p *pendingInput) add(line string) (ready []string, aborted bool) {
+	if p.open && !strings.HasPrefix(strings.TrimSpace(line), "|") {
+		ready = append(ready, p.flush())
+		if strings.TrimSpace(line) == "" {
+			return ready, false
 		}
 	}
+	if len(p.lines) > 0 && isMetaCommand(line) {
+		p.lines, p.open = p.lines[:0], false
+		return []string{line}, true
+	}
+	p.lines = append(p.lines, line)
+	input := strings.Join(p.lines, "\n")
+	if !isMetaCommand(input) && incompleteInput(input) {
+		p.open = false
+		return ready, false
+	}
+	if !isMetaCommand(input) && openInput(input) {
+		p.open = true
+		return ready, false
+	}
+	return append(ready, p.flush()), false
+}
 
-	l := lexer.New(line)
-	p := parser.New(l)
-	p.TraceOnError = r.Options.DebugParser
+// Take the lines of the pending input, leaving it empty
+func (p *pendingInput) flush() string {
+	input := strings.Join(p.lines, "\n")
+	p.lines, p.open = p.lines[:0], false
+	return input
+}
 
-	pri := repr.New(os.Stdout, repr.Hide(token.Token{}))
-	program := p.ParseProgram()
+// Returns true if an input is a valid prefix of a sequence of
+// statements that needs more lines to be complete
+func incompleteInput(input string) bool {
+	p := parser.New(lexer.New(input))
+	p.ParseStatements()
+	return p.UnexpectedEOF()
+}
 
-	if r.Options.ShowAST {
-		pri.Println(program)
-	}
+// Returns true if a complete input can continue with a line starting
+// with |, as a match expression with more arms
+func openInput(input string) bool {
+	return incompleteInput(input + "\n|")
+}
 
-	if len(p.Errors()) != 0 {
-		for _, err := range p.Errors() {
-			fmt.Fprintf(os.Stderr, "%s\n", err)
-		}
+func (r *Repl) executor(line string) {
+	if isMetaCommand(line) {
+		r.runMetaCommand(line)
 		return
 	}
 
-	// Do alpha conversion on the program (generate unique identifiers)
-	alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+	ty, value, err := r.session.Interpret(line, true)
+	r.printWarnings()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 
-	if r.Options.ShowAST {
-		pri.Println(alphaconv_program)
-		fmt.Println((*alphaconv_program).String())
-	}
+	fmt.Printf("- : %s = %s\n", ty.FancyString(map[ast.UniqueIdentifier]int{}), value)
+}
 
-	// Typecheck
-	// TODO default context with primitives
-	// TODO preserve context between statements in the repl
-	ctx := typecheck.NewContext()
-	ast.ResetUIDCounter()
-	ty, err := ctx.SynthExpr(*alphaconv_program)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return
+// Print the warnings found by the typechecker in the last input
+func (r *Repl) printWarnings() {
+	for _, w := range r.session.Warnings() {
+		fmt.Fprintln(os.Stderr, w)
 	}
-
-	fmt.Printf("- : %s\n", ty.FancyString(map[ast.UniqueIdentifier]int{}))
-
-	// TODO evaluation
 }
-
-// func (r *Repl) completer(t prompt.Document) []prompt.Suggest {
-// return []prompt.Suggest{}
-// }
@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIncompleteInput(t *testing.T) {
+	tests := map[string]bool{
+		"let f = fun(x) {":           true,
+		"let f = fun(x) {\nx * 2\n}": false,
+		"let x = 1 in":               true,
+		"let x = 1 in\nx +":          true,
+		"let x = 1 in\nx +\n2":       false,
+		"1 + )":                      false,
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		assert.Equal(t, expected, incompleteInput(input))
+	}
+}
+
+func TestPendingInput(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		ready   []string
+		aborted bool
+	}{
+		{[]string{"let f = fun(x) {", "x * 2", "}"}, []string{"let f = fun(x) {\nx * 2\n}"}, false},
+		{[]string{":type 1"}, []string{":type 1"}, false},
+		// Meta-commands abort the pending input
+		{[]string{"foo(", ":reset"}, []string{":reset"}, true},
+		{[]string{"let x = 1 in", "  :type 2"}, []string{"  :type 2"}, true},
+		// Match arms can be typed on the following lines
+		{[]string{"match 1 with", "| 1 -> true", "  | _ -> false", ""}, []string{"match 1 with\n| 1 -> true\n  | _ -> false"}, false},
+		{[]string{"match 1 with | 1 -> true", "| _ -> false", "2"}, []string{"match 1 with | 1 -> true\n| _ -> false", "2"}, false},
+		{[]string{"match 1 with | _ -> 1", ":type 2"}, []string{"match 1 with | _ -> 1", ":type 2"}, false},
+		{[]string{"match 1 with | _ -> 1", "let x = (", "2)"}, []string{"match 1 with | _ -> 1", "let x = (\n2)"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Log("--- TEST CASE", tt.lines, "---")
+		input := &pendingInput{}
+		ready, aborted := []string{}, false
+		for _, line := range tt.lines {
+			r, a := input.add(line)
+			ready = append(ready, r...)
+			aborted = aborted || a
+		}
+		assert.Equal(t, tt.ready, ready)
+		assert.Equal(t, tt.aborted, aborted)
+		assert.Empty(t, input.lines)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r := &Repl{Options: &ReplOptions{}}
+	r.session = NewSession(r.Options)
+	_, _, err := r.session.Interpret("let fact = fun(n) {if n <= 1 then 1 else n * fact(n - 1)} and flag = true;", true)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		line       string
+		pos        int
+		head       string
+		candidates []string
+		tail       string
+	}{
+		{"fac", 3, "", []string{"fact"}, ""},
+		{"fa", 2, "", []string{"fact : int -> int", "fail : ∀a.string -{fail}-> a", "false"}, ""},
+		{"f", 1, "", []string{"fact : int -> int", "fail : ∀a.string -{fail}-> a", "false", "flag : bool", "fold : ∀a.∀b.∀c.∀d.(a -{b}-> c -{d}-> a) -> a -> []c -{b, d}-> a", "forall", "fromint : ∀(a: Num).int -> a", "fun"}, ""},
+		{"1 + fl", 6, "1 + ", []string{"flag"}, ""},
+		{"le(x)", 2, "", []string{"length : ∀a.[]a -> int", "let"}, "(x)"},
+		{"fun(x: i", 8, "fun(x: ", []string{"int"}, ""},
+		{":t", 2, "", []string{":type  print the type of an input without evaluating it", ":tokens  evaluate an input, printing its tokens"}, ""},
+		{":lo", 3, "", []string{":load"}, ""},
+		{"12", 2, "", []string{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Log("--- TEST CASE", tt.line, "---")
+		head, candidates, tail := r.complete(tt.line, tt.pos)
+		assert.Equal(t, tt.head, head)
+		assert.Equal(t, tt.candidates, candidates)
+		assert.Equal(t, tt.tail, tail)
+	}
+}
+
+func TestMetaCommandArgs(t *testing.T) {
+	takesArg := map[string]bool{
+		"type": true, "ast": true, "tokens": true, "load": true,
+		"reset": false, "context": false, "help": false,
+	}
+	for _, cmd := range metaCommands {
+		assert.Equal(t, takesArg[cmd.Name], cmd.takesArg(), cmd.Name)
+	}
+}
@@ -0,0 +1,208 @@
+package repl
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/alpha"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/compiler"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/lexer"
+	"github.com/0x0f0f0f/gobba-golang/parser"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"github.com/0x0f0f0f/gobba-golang/typecheck"
+	"github.com/0x0f0f0f/gobba-golang/vm"
+	"github.com/alecthomas/repr"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Holds all the errors reported by the parser on a source text
+type SyntaxErrors []string
+
+func (se SyntaxErrors) Error() string {
+	return strings.Join(se, "\n")
+}
+
+// A session holds the state of the interpreter that is kept between
+// inputs: the names known to the α-converter, the typing context and
+// the values bound by the evaluator or by the virtual machine.
+// Every input is processed in extensions of the session state, that
+// are committed only if the whole input succeeds, so that a failing
+// input never leaves a name half bound. Rebinding a name gives it a new
+// unique identifier, shadowing the old binding without overwriting it.
+type Session struct {
+	Options  *ReplOptions
+	alphaEnv *alpha.AlphaEnvironment
+	context  typecheck.Context
+	env      *eval.Environment
+	// State of the bytecode virtual machine
+	symbolTable *compiler.SymbolTable
+	constants   []eval.Value
+	globals     []eval.Value
+	// Warnings about the last input
+	warnings []typecheck.Warning
+}
+
+// Create a new session, where only the prelude is bound
+func NewSession(o *ReplOptions) *Session {
+	s := &Session{
+		Options:     o,
+		alphaEnv:    alpha.NewAlphaEnvironment(),
+		context:     *typecheck.NewContext(),
+		env:         eval.NewEnvironment(),
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []eval.Value{},
+		globals:     make([]eval.Value, vm.GlobalsSize),
+	}
+	s.loadPrelude()
+	return s
+}
+
+// Run a sequence of statements in a new session. See Session.Interpret
+func Interpret(o *ReplOptions, input string, evaluate bool) (ast.TypeValue, eval.Value, error) {
+	return NewSession(o).Interpret(input, evaluate)
+}
+
+// Run the contents of a source file in a new session. See Session.InterpretFile
+func InterpretFile(o *ReplOptions, input string, evaluate bool) (ast.TypeValue, eval.Value, error) {
+	return NewSession(o).InterpretFile(input, evaluate)
+}
+
+// Run a sequence of statements through the whole interpreter pipeline:
+// lexing, parsing, alpha conversion, type checking and, if evaluate is
+// true, evaluation. Tokens and ASTs are printed when requested by the
+// options. The value is nil when evaluate is false, and the session
+// is only updated by inputs that are evaluated successfully. Warnings
+// found in inputs that typecheck are available from Warnings.
+func (s *Session) Interpret(input string, evaluate bool) (ast.TypeValue, eval.Value, error) {
+	return s.interpret(input, (*parser.Parser).ParseStatements, evaluate)
+}
+
+// Like Interpret, for the contents of a source file, that must start
+// with a package declaration
+func (s *Session) InterpretFile(input string, evaluate bool) (ast.TypeValue, eval.Value, error) {
+	return s.interpret(input, (*parser.Parser).ParseProgram, evaluate)
+}
+
+func (s *Session) interpret(input string, parse func(*parser.Parser) *ast.Program, evaluate bool) (ast.TypeValue, eval.Value, error) {
+	o := s.Options
+	s.warnings = nil
+	if o.ShowTok {
+		l := lexer.New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			fmt.Printf("%+v\n", tok)
+		}
+	}
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	p.TraceOnError = o.DebugParser
+
+	pri := repr.New(os.Stdout, repr.Hide(token.Token{}))
+	program := parse(p)
+
+	if o.ShowAST {
+		pri.Println(program)
+	}
+
+	if len(p.Errors()) != 0 {
+		return nil, nil, SyntaxErrors(p.Errors())
+	}
+
+	// Do alpha conversion on the program (generate unique identifiers)
+	alphaEnv := alpha.NewAlphaEnvironmentExtension(s.alphaEnv)
+	alphaconv_program, err := alphaEnv.ProgramAlphaConversion(program)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if o.ShowAST {
+		pri.Println(alphaconv_program)
+		fmt.Println(alphaconv_program.String())
+	}
+
+	// Typecheck
+	ty, elaborated, context, err := s.context.CheckProgram(alphaconv_program)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.warnings = typecheck.ProgramWarnings(alphaconv_program)
+
+	if !evaluate {
+		return ty, nil, nil
+	}
+
+	var value eval.Value
+	if o.UseVM {
+		value, err = s.runVM(elaborated)
+	} else {
+		env := eval.NewEnvironmentExtension(s.env)
+		value, err = env.EvalProgram(elaborated)
+		if err == nil {
+			s.env = env
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.alphaEnv = alphaEnv
+	s.context = context
+	return ty, value, nil
+}
+
+// Get the warnings found by the typechecker in the last input, such as
+// match expressions that are not exhaustive
+func (s *Session) Warnings() []typecheck.Warning {
+	return s.warnings
+}
+
+// Get the typing context of the session
+func (s *Session) Context() typecheck.Context {
+	return s.context
+}
+
+// A name bound in a session, with its type
+type Binding struct {
+	Name string
+	Type ast.TypeValue
+}
+
+// Get the names bound in the session, sorted by name. Shadowed
+// bindings are not included
+func (s *Session) Bindings() []Binding {
+	bindings := []Binding{}
+	for name, id := range s.alphaEnv.Names() {
+		annot := s.context.GetAnnotation(id)
+		if annot == nil {
+			continue
+		}
+		bindings = append(bindings, Binding{name, s.context.Apply(*annot)})
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+	return bindings
+}
+
+// Compile a program to bytecode and execute it in the virtual machine,
+// keeping the global bindings of the session. The program is compiled
+// against copies of the symbol table and of the constants, that replace
+// them only if the program runs successfully
+func (s *Session) runVM(program *ast.Program) (eval.Value, error) {
+	symbolTable := s.symbolTable.Copy()
+	comp := compiler.NewWithState(symbolTable, append([]eval.Value{}, s.constants...))
+	if err := comp.CompileProgram(program); err != nil {
+		return nil, err
+	}
+	bytecode := comp.Bytecode()
+
+	machine := vm.NewWithGlobals(bytecode, s.globals)
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	s.symbolTable = symbolTable
+	s.constants = bytecode.Constants
+	return machine.Result(), nil
+}
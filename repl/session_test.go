@@ -0,0 +1,513 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/typecheck"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestInterpret(t *testing.T) {
+	tcs := map[string]string{
+		"1 + 2":                          "int = 3",
+		"let x = 2.5; x *. 2.0":          "float = 5.0",
+		"if 1 < 2 then \"a\" else \"b\"": "string = \"a\"",
+		// Records are compared by the fields of their type
+		"({x = 1, y = 2} : {x: int}) = {x = 1}":                                              "bool = true",
+		"({x = 1, y = 2} : {x: int}) != {x = 1}":                                             "bool = false",
+		"let f = fun(r: {x: int}) {r = {x = 1}}; f({x = 1, y = 2})":                          "bool = true",
+		"[(({a = 1, b = true} : {a: int}), 2)] = [({a = 1}, 2)]":                             "bool = true",
+		"type option(a) = None | Some(a); Some(({x = 1, y = 2} : {x: int})) = Some({x = 1})": "bool = true",
+		"{x = 1, y = 2} = {x = 1, y = 3}":                                                    "bool = false",
+		// Values are restricted where they lose fields, not where they are compared
+		"let eq = fun(a, b) {a = b}; eq(({x = 1, y = 2} : {x: int}), {x = 1})":                     "bool = true",
+		"let g = fun(r: {x: int}) {r}; let eq = fun(a, b) {a = b}; eq(g({x = 1, y = 2}), {x = 1})": "bool = true",
+		"let eq = fun(a, b) {a = b}; eq(if true then {x = 1, y = 2} else {x = 1}, {x = 1})":        "bool = true",
+		"let eq = fun(a, b) {a = b}; eq(head([{x = 1, y = 2}, {x = 2}]), {x = 1})":                 "bool = true",
+		// Numbers are converted where they are used as numbers of a larger type
+		"show(1.0) = show((1 : float))":                  "bool = true",
+		"let f = fun(x: float) { x } in f(3)":            "float = 3.0",
+		"map(show, [1.5, 2])":                            "[]string = [\"1.5\", \"2.0\"]",
+		"(fun(x: float) { x } : int -> float)(2) /. 4.0": "float = 0.5",
+		"({x = 1, y = 2} : {x: complex})":                "{x: complex} = {x = 1+0i}",
+	}
+
+	for _, useVM := range []bool{false, true} {
+		opts := &ReplOptions{UseVM: useVM}
+		for input, expected := range tcs {
+			t.Log("--- TEST CASE", input, "---")
+			ty, value, err := Interpret(opts, input, true)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+		}
+	}
+}
+
+func TestInterpretCheckOnly(t *testing.T) {
+	ty, value, err := Interpret(&ReplOptions{}, "1 / 0", false)
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	assert.Equal(t, "int", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+}
+
+func TestInterpretFail(t *testing.T) {
+	_, _, err := Interpret(&ReplOptions{}, "1 +", true)
+	_, ok := err.(SyntaxErrors)
+	assert.True(t, ok)
+
+	_, _, err = Interpret(&ReplOptions{}, "1 + true", true)
+	assert.Error(t, err)
+
+	_, _, err = Interpret(&ReplOptions{}, "1 / 0", true)
+	assert.Error(t, err)
+}
+
+func TestInterpretFile(t *testing.T) {
+	ty, value, err := InterpretFile(&ReplOptions{}, "package main; let x = 2 and y = 3; x * y;", true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "int", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+		assert.Equal(t, "6", value.String())
+	}
+
+	_, _, err = InterpretFile(&ReplOptions{}, "let x = 2; x", true)
+	_, ok := err.(SyntaxErrors)
+	assert.True(t, ok)
+}
+
+func TestSessionBindings(t *testing.T) {
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;", "unit = ()"},
+		{"let f = fun(y) {x + y};", "unit = ()"},
+		{"f(2)", "int = 3"},
+		// Rebinding shadows the old binding, f still sees the old x
+		{"let x = true;", "unit = ()"},
+		{"x", "bool = true"},
+		{"f(2)", "int = 3"},
+		{"let fact = fun(n) {if n <= 1 then 1 else n * fact(n - 1)};", "unit = ()"},
+		{"fact(5)", "int = 120"},
+		// Bindings are generalized and can be used at different types
+		{"let id = fun(x) {x};", "unit = ()"},
+		{"id(1)", "int = 1"},
+		{"id(\"a\")", "string = \"a\""},
+		// Constructors of data types are bound like values
+		{"type option(a) = None | Some(a);", "unit = ()"},
+		{"Some(id(2))", "option(int) = Some(2)"},
+		// Tuple patterns bind each of their variables
+		{"let (a, b) = (1, id(true));", "unit = ()"},
+		{"(b, a)", "(bool, int) = (true, 1)"},
+	}
+
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		for _, tt := range inputs {
+			t.Log("--- TEST CASE", tt.input, "---")
+			ty, value, err := s.Interpret(tt.input, true)
+			if assert.Nil(t, err) {
+				assert.Equal(t, tt.expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+			}
+		}
+	}
+}
+
+func TestSessionFailedInput(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		_, _, err := s.Interpret("let x = 1;", true)
+		assert.Nil(t, err)
+
+		// Nothing is bound by inputs that fail to typecheck or evaluate
+		_, _, err = s.Interpret("let x = true; let y = x + 1;", true)
+		assert.Error(t, err)
+		_, _, err = s.Interpret("let y = 2 and z = 1 / 0;", true)
+		assert.Error(t, err)
+		_, _, err = s.Interpret("y", true)
+		assert.Error(t, err)
+
+		ty, value, err := s.Interpret("x + 1", true)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "int", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+			assert.Equal(t, "2", value.String())
+		}
+	}
+}
+
+func TestSessionFailedInputVM(t *testing.T) {
+	s := NewSession(&ReplOptions{UseVM: true})
+	symbols, constants := s.symbolTable.NumDefinitions(), len(s.constants)
+
+	// The globals defined by an input that fails at runtime are dropped
+	_, _, err := s.Interpret("let y = [1.5] and z = 1 / 0;", true)
+	assert.Error(t, err)
+	assert.Equal(t, symbols, s.symbolTable.NumDefinitions())
+	assert.Equal(t, constants, len(s.constants))
+
+	ty, value, err := s.Interpret("let y = 2; let z = y + 1; (y, z)", true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "(int, int) = (2, 3)", ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+	}
+}
+
+func TestSessionContextSize(t *testing.T) {
+	inputs := []struct {
+		input string
+		// The number of entries added to the context by the input
+		added int
+	}{
+		{"let f = fun(n) {if n = 0 then 0 else g(n - 1)} and g = fun(n) {f(n)};", 2},
+		{"let (a, b) = (fun(x) {x}, 2);", 2},
+		{"show(1)", 0},
+		{"fun(x) {x + 1}", 0},
+	}
+
+	s := NewSession(&ReplOptions{})
+	for i := 0; i < 3; i++ {
+		for _, tt := range inputs {
+			t.Log("--- TEST CASE", tt.input, "---")
+			before := len(s.Context().Contents)
+			_, _, err := s.Interpret(tt.input, true)
+			if assert.Nil(t, err) {
+				assert.Equal(t, before+tt.added, len(s.Context().Contents))
+			}
+		}
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	tcs := map[string]string{
+		"head([1, 2])":                                      "int = 1",
+		"tail([1, 2])":                                      "[]int = [2]",
+		"length([]) + length([true, false])":                "int = 2",
+		"map(fun(x) {x * 2}, [1, 2, 3])":                    "[]int = [2, 4, 6]",
+		"fold(fun(acc, x) {x :: acc}, [], [1, 2, 3])":       "[]int = [3, 2, 1]",
+		"let sum = fold(fun(a, b) {a + b}, 0); sum([1, 2])": "int = 3",
+		// The types of the elements of list arguments are joined
+		"length([1, 2.5])":          "int = 2",
+		"head([1, 2.5])":            "float = 1.0",
+		"map(fun(x) {x}, [1, 2.5])": "[]float = [1.0, 2.5]",
+		"length([1, true])":         "int = 2",
+		// map and fold take lists apart with patterns and cannot fail
+		"pure map(fun(x) {x}, [1])":               "[]int = [1]",
+		"pure fold(fun(a, b) {a + b}, 0, [1, 2])": "int = 3",
+	}
+
+	for _, useVM := range []bool{false, true} {
+		opts := &ReplOptions{UseVM: useVM}
+		for input, expected := range tcs {
+			t.Log("--- TEST CASE", input, "---")
+			ty, value, err := Interpret(opts, input, true)
+			if assert.Nil(t, err) {
+				assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+			}
+		}
+
+		_, _, err := Interpret(opts, "head(tail([1]))", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "head of an empty list")
+		}
+	}
+}
+
+func TestPreludeDebug(t *testing.T) {
+	// The typechecking of the prelude is not printed
+	r, w, err := os.Pipe()
+	if !assert.Nil(t, err) {
+		return
+	}
+	var out bytes.Buffer
+	done := make(chan bool)
+	go func() {
+		out.ReadFrom(r)
+		done <- true
+	}()
+
+	stderr := os.Stderr
+	os.Stderr = w
+	typecheck.DebugTypeCheck = true
+	NewSession(&ReplOptions{})
+	os.Stderr = stderr
+	w.Close()
+	<-done
+
+	assert.True(t, typecheck.DebugTypeCheck)
+	typecheck.DebugTypeCheck = false
+	assert.Equal(t, "", out.String())
+}
+
+func TestEffects(t *testing.T) {
+	var out bytes.Buffer
+	eval.Stdout = &out
+	defer func() { eval.Stdout = os.Stdout }()
+
+	for _, useVM := range []bool{false, true} {
+		out.Reset()
+		opts := &ReplOptions{UseVM: useVM}
+		ty, value, err := Interpret(opts, "map(fun(s) {print(s)}, [\"a\", \"b\"])", true)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "[]unit = [(), ()]", ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+			assert.Equal(t, "a\nb\n", out.String())
+		}
+
+		ty, _, err = Interpret(opts, "fun(x) {if random(()) < 0.5 then fail(x) else x}", true)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "string -{fail, nondet}-> string", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+		}
+
+		_, _, err = Interpret(opts, "pure fun(x) {print(x)}", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "effect io, which is not allowed by pure")
+		}
+
+		_, _, err = Interpret(opts, "fail(\"boom\")", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "boom")
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		_, _, err := s.Interpret("policy leak { init start; start -secret-> read; read -net-> bad; offending bad; };", true)
+		assert.Nil(t, err)
+		_, _, err = s.Interpret("let send = fun(x) {event net; x};", true)
+		assert.Nil(t, err)
+		_, _, err = s.Interpret("send(1)", true)
+		assert.Nil(t, err)
+
+		// The states of the policy are kept between inputs
+		_, _, err = s.Interpret("event secret", true)
+		assert.Nil(t, err)
+		_, _, err = s.Interpret("send(2)", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "policy leak is violated by the events net, secret, net")
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		inputs := []string{
+			"interface Size(a) { size : a -> int; };",
+			"instance Size(bool) { size = fun(b) {if b then 1 else 0}; };",
+			"instance Size((bool, bool)) { size = fun((x, y)) {size(x) + size(y)}; };",
+			"let twice = fun(x) {size(x) + size(x)};",
+		}
+		for _, input := range inputs {
+			_, _, err := s.Interpret(input, true)
+			assert.Nil(t, err, input)
+		}
+
+		tests := map[string]string{
+			"twice(true) + twice((true, true))": "int = 6",
+			"show(1.5) = show(1.5)":             "bool = true",
+			"add(sub(5, 2), mul(zero, 7))":      "int = 3",
+			"neg(2.5)":                          "float = -2.5",
+			"let s = (fun(x) {show(x)} : forall (a: Show). a -> string) in s(true)": "string = \"true\"",
+			"twice":                              "∀(a: Size).a -> int = <fun>",
+			"show":                               "∀(a: Show).a -> string = <fun>",
+			"let f = fun(x, y) {x = y}; f":       "∀(a: Eq).a -> a -> bool = <fun>",
+			"let f = fun(x, y) {x = y}; f(1, 1)": "bool = true",
+		}
+		for input, expected := range tests {
+			ty, value, err := s.Interpret(input, true)
+			if assert.Nil(t, err, input) {
+				assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String(), input)
+			}
+		}
+
+		_, _, err := s.Interpret("twice(1.5)", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "type float does not implement the interface Size")
+		}
+		_, _, err = s.Interpret("map = map", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "does not implement the interface Eq")
+		}
+	}
+}
+
+func TestNewtypes(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		inputs := []string{
+			"type vec = (float, float);",
+			"newtype Meters = float;",
+			"let d = Meters(1.5);",
+		}
+		for _, input := range inputs {
+			_, _, err := s.Interpret(input, true)
+			assert.Nil(t, err, input)
+		}
+
+		tests := map[string]string{
+			"unMeters(d) + 1.0":           "float = 2.5",
+			"d < Meters(2.0)":             "bool = true",
+			"((unMeters(d), 2.0) : vec)":  "(float, float) = (1.5, 2.0)",
+			"show(unMeters(Meters(3.0)))": "string = \"3.0\"",
+		}
+		for input, expected := range tests {
+			ty, value, err := s.Interpret(input, true)
+			if assert.Nil(t, err, input) {
+				assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String(), input)
+			}
+		}
+
+		_, _, err := s.Interpret("add(d, d)", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "type Meters does not implement the interface Num")
+		}
+		_, _, err = s.Interpret("instance Num(Meters) { add = fun(x, y) {Meters(unMeters(x) + unMeters(y))}; sub = fun(x, y) {Meters(unMeters(x) - unMeters(y))}; mul = fun(x, y) {Meters(unMeters(x) * unMeters(y))}; div = fun(x, y) {Meters(unMeters(x) / unMeters(y))}; pow = fun(x, y) {Meters(unMeters(x) ^ unMeters(y))}; neg = fun(x) {Meters(0.0 - unMeters(x))}; zero = Meters(0.0); fromint = fun(n) {Meters((n : float))}; };", true)
+		assert.Nil(t, err)
+		ty, value, err := s.Interpret("unMeters(add(d, d))", true)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "float = 3.0", ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+		}
+		// The arithmetical operators apply the methods of the instance
+		for input, expected := range map[string]string{
+			"unMeters(Meters(1.0) + Meters(2.0))":                     "float = 3.0",
+			"unMeters(-d * d)":                                        "float = -2.25",
+			"let half = fun(x) {x / 2}; (half(7), unMeters(half(d)))": "(int, float) = (3, 0.75)",
+		} {
+			ty, value, err := s.Interpret(input, true)
+			if assert.Nil(t, err, input) {
+				assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String(), input)
+			}
+		}
+
+		// A newtype declared again is a distinct type
+		_, _, err = s.Interpret("let f = fun(x: Meters) {unMeters(x)}; newtype Meters = int;", true)
+		assert.Nil(t, err)
+		ty, value, err = s.Interpret("f(d)", true)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "float = 1.5", ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String())
+		}
+		_, _, err = s.Interpret("f(Meters(1))", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "a distinct type declared with the same name")
+		}
+	}
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		inputs := []string{
+			"type option(a) = None | Some(a);",
+			"type tree = mu t. option((t, int, t));",
+			"let node = fun(l: tree, v: int, r: tree) {(Some((l, v, r)) : tree)};",
+			"let sum = fun(t: tree) {match t with | None -> 0 | Some((l, v, r)) -> sum(l) + v + sum(r)};",
+			"type stream = mu s. (int, unit -> s);",
+			"let from = fun(n) {((n, fun(u) {from(n + 1)}) : stream)};",
+			"let take = fun(n: int, s: stream) {if n = 0 then [] else (let (x, next) = s in x :: take(n - 1, next(())))};",
+		}
+		for _, input := range inputs {
+			_, _, err := s.Interpret(input, true)
+			assert.Nil(t, err, input)
+		}
+
+		tests := map[string]string{
+			"sum(node(None, 1, node(None, 2, None)))":   "int = 3",
+			"node(None, 1, None) = node(None, 1, None)": "bool = true",
+			"take(3, from(5))":                          "[]int = [5, 6, 7]",
+			"let (x, _) = from(1) in x":                 "int = 1",
+		}
+		for input, expected := range tests {
+			ty, value, err := s.Interpret(input, true)
+			if assert.Nil(t, err, input) {
+				assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String(), input)
+			}
+		}
+
+		_, _, err := s.Interpret("node(None, true, None)", true)
+		assert.Error(t, err)
+
+		// Unions cannot be matched, recursive types are named as in the results
+		_, _, err = s.Interpret("fun(x: mu l. unit | (int, l)) {match x with | (h, _) -> h | _ -> 0}", true)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "cannot match values of type unit | (int, μa.unit | (int, a))")
+		}
+	}
+}
+
+func TestMutualRecursion(t *testing.T) {
+	for _, useVM := range []bool{false, true} {
+		s := NewSession(&ReplOptions{UseVM: useVM})
+		inputs := []string{
+			"let x = 10;",
+			// The functions of a top level group see each other
+			"let even = fun(n) {if n = 0 then true else odd(n - 1)} and odd = fun(n) {if n = 0 then false else even(n - 1)};",
+		}
+		for _, input := range inputs {
+			_, _, err := s.Interpret(input, true)
+			assert.Nil(t, err, input)
+		}
+
+		tests := map[string]string{
+			"(even(4), odd(4))": "(bool, bool) = (true, false)",
+			"odd(1000001)":      "bool = true",
+			// In a local group, the closures capture each other
+			"let f = fun(k) {let a = fun(n) {if n = 0 then k else b(n - 1)} and b = fun(n) {if n = 0 then 0 else a(n - 1)} in a(k)} in f(4)": "int = 4",
+			// The other values of the group are not visible
+			"let x = 1 and g = fun(n) {x + h(n)} and h = fun(n) {if n = 0 then 0 else g(n - 1)} in g(1)": "int = 20",
+		}
+		for input, expected := range tests {
+			ty, value, err := s.Interpret(input, true)
+			if assert.Nil(t, err, input) {
+				assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{})+" = "+value.String(), input)
+			}
+		}
+
+		ty, _, err := s.Interpret("even", false)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "int -> bool", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+		}
+	}
+}
+
+func TestSessionCheckOnly(t *testing.T) {
+	s := NewSession(&ReplOptions{})
+	prelude := s.Context().FancyString()
+	ty, value, err := s.Interpret("let x = 1; x", false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "int", ty.FancyString(map[ast.UniqueIdentifier]int{}))
+		assert.Nil(t, value)
+	}
+
+	// Inputs that are not evaluated do not bind names
+	_, _, err = s.Interpret("x", true)
+	assert.Error(t, err)
+	assert.Equal(t, prelude, s.Context().FancyString())
+}
+
+func TestSessionWarnings(t *testing.T) {
+	s := NewSession(&ReplOptions{})
+	_, value, err := s.Interpret("match true with | true -> 1", true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "1", value.String())
+		if assert.Len(t, s.Warnings(), 1) {
+			assert.Equal(t, "warning: match on true is not exhaustive, false is not matched", s.Warnings()[0].String())
+		}
+	}
+
+	// Warnings are reset on each input
+	_, _, err = s.Interpret("1", true)
+	assert.Nil(t, err)
+	assert.Len(t, s.Warnings(), 0)
+
+	// Files have warnings too
+	_, _, err = s.InterpretFile("package main; let f = fun(x) { match x with | 0 -> 1 };", true)
+	assert.Nil(t, err)
+	assert.Len(t, s.Warnings(), 1)
+
+	// Inputs that are only typechecked too
+	_, _, err = s.Interpret("match 1 with | 1 -> true", false)
+	assert.Nil(t, err)
+	assert.Len(t, s.Warnings(), 1)
+}
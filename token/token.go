@@ -1,5 +1,9 @@
 package token
 
+import (
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
@@ -49,6 +53,13 @@ const (
 	CTOPOW  = "^:"
 	CDIVIDE = "/:"
 
+	// Matrix operators, chosen by the typechecker for the arithmetical
+	// operators applied to matrices. They cannot be written in programs
+	MPLUS   = "+#"
+	MMINUS  = "-#"
+	MTIMES  = "*#"
+	MDIVIDE = "/#"
+
 	// Comparison Operators
 	EQUALS    = "="
 	DIFFERS   = "!="
@@ -69,7 +80,10 @@ const (
 	ANNOT    = ":"
 	LAND     = "&&"
 	OR       = "||"
+	BAR      = "|"
 	ACCESS   = "."
+	// Postfix operators
+	TRANSPOSE = "'"
 
 	// Delimiters
 	COMMA       = ","
@@ -78,6 +92,8 @@ const (
 	RPAREN      = ")"
 	LBRACKET    = "{"
 	RBRACKET    = "}"
+	LSQUARE     = "["
+	RSQUARE     = "]"
 	LINECOMMENT = "//"
 	LCOMMENT    = "/*"
 	RCOMMENT    = "*/"
@@ -92,6 +108,26 @@ const (
 	IF     = "if"
 	THEN   = "then"
 	ELSE   = "else"
+	FORALL = "forall"
+	MU     = "mu"
+	MATCH  = "match"
+	WITH   = "with"
+	// Effect annotations
+	ALLOW = "allow"
+	DENY  = "deny"
+	PURE  = "pure"
+	// Events of usage policies
+	EVENT = "event"
+	// Keywords for top level statements
+	PACKAGE   = "package"
+	TYPE      = "type"
+	POLICY    = "policy"
+	INTERFACE = "interface"
+	INSTANCE  = "instance"
+	NEWTYPE   = "newtype"
+	// Density of matrices
+	DENSE  = "dense"
+	SPARSE = "sparse"
 	// Keyword types
 	TBOOL    = "bool"
 	TINT     = "int"
@@ -101,20 +137,48 @@ const (
 	TRUNE    = "rune"
 	TSTRING  = "string"
 	TUNIT    = "unit"
+	TMATRIX  = "matrix"
+	TCMATRIX = "cmatrix"
+	// Effect labels, they are not reserved
+	EIO     = "io"
+	ESTATE  = "state"
+	ENONDET = "nondet"
+	EFAIL   = "fail"
+	// Builtin interfaces, they are not reserved
+	IEQ   = "Eq"
+	IORD  = "Ord"
+	INUM  = "Num"
+	ISHOW = "Show"
 )
 
 // Table of internal keywords
 var keywords = map[string]TokenType{
-	"lambda": LAMBDA,
-	"fun":    LAMBDA,
-	"let":    LET,
-	"in":     IN,
-	"and":    AND,
-	"if":     IF,
-	"then":   THEN,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
+	"lambda":    LAMBDA,
+	"fun":       LAMBDA,
+	"let":       LET,
+	"in":        IN,
+	"and":       AND,
+	"if":        IF,
+	"then":      THEN,
+	"else":      ELSE,
+	"true":      TRUE,
+	"false":     FALSE,
+	"forall":    FORALL,
+	"mu":        MU,
+	"match":     MATCH,
+	"with":      WITH,
+	"allow":     ALLOW,
+	"deny":      DENY,
+	"pure":      PURE,
+	"event":     EVENT,
+	"package":   PACKAGE,
+	"type":      TYPE,
+	"policy":    POLICY,
+	"interface": INTERFACE,
+	"instance":  INSTANCE,
+	"newtype":   NEWTYPE,
+	"dense":     DENSE,
+	"sparse":    SPARSE,
 	// Keyword types
 	// "bool":    TBOOL,
 	// "int":     TINT,
@@ -125,6 +189,16 @@ var keywords = map[string]TokenType{
 	// "string":  TSTRING,
 }
 
+// Get the list of reserved keywords, sorted alphabetically
+func Keywords() []string {
+	kws := make([]string, 0, len(keywords))
+	for kw := range keywords {
+		kws = append(kws, kw)
+	}
+	sort.Strings(kws)
+	return kws
+}
+
 // Check the keywords table to see whether the given
 // identifier is a reserved keyword
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,136 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of the declarations of
+// type aliases and of nominal types
+
+// Replace the aliases in a type with the types they stand for. An alias
+// applied to the wrong number of arguments is an error. Types are
+// expanded before checking that they are well formed, so the other
+// rules never see an alias
+func (c Context) ExpandAliases(t ast.TypeValue) (ast.TypeValue, error) {
+	var err error
+	var expand func(t ast.TypeValue) ast.TypeValue
+	expand = func(t ast.TypeValue) ast.TypeValue {
+		switch vt := t.(type) {
+		case *ast.DataType:
+			ndt := vt.Map(expand)
+			def := c.GetAliasDefinition(vt.Identifier)
+			if def == nil {
+				return ndt
+			}
+			if len(def.Params) != len(ndt.Args) {
+				if err == nil {
+					err = c.aliasArityError(vt, len(def.Params))
+				}
+				return ndt
+			}
+			// The type of the definition is already expanded
+			res := def.Type
+			for i, param := range def.Params {
+				res = Substitution(res, ndt.Args[i], param)
+			}
+			return res
+		case *ast.ForAllType:
+			return &ast.ForAllType{
+				Identifier:  vt.Identifier,
+				Constraints: vt.Constraints,
+				Type:        expand(vt.Type),
+			}
+		case *ast.MuType:
+			return &ast.MuType{Identifier: vt.Identifier, Type: expand(vt.Type)}
+		case *ast.LambdaType:
+			return vt.Map(expand)
+		case *ast.RecordType:
+			return vt.Map(expand)
+		case *ast.TupleType:
+			return vt.Map(expand)
+		case *ast.ListType:
+			return vt.Map(expand)
+		case *ast.ArrayType:
+			return vt.Map(expand)
+		case *ast.MatrixType:
+			return vt.Map(expand)
+		case *ast.UnionType:
+			return vt.Map(expand)
+		}
+		return t
+	}
+	t = expand(t)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Returns true if a declared type has the name of a builtin type
+func isBuiltinTypeName(id ast.UniqueIdentifier) bool {
+	_, ok := ast.DefaultVariableTypes[id.Value]
+	return ok
+}
+
+// Extend the context with the definition of a type alias. The type
+// must be well formed, it can only mention the parameters of the alias
+func (c Context) synthAliasStatement(stmt *ast.AliasStatement) (ast.TypeValue, Context, error) {
+	c.debugRule("Alias")
+
+	if isBuiltinTypeName(stmt.Name) {
+		c.debugRuleFail("Alias")
+		return nil, c, c.builtinTypeNameError(stmt.Name)
+	}
+	t, err := c.ExpandAliases(stmt.Type)
+	if err != nil {
+		c.debugRuleFail("Alias")
+		return nil, c, err
+	}
+	gamma := c
+	for _, param := range stmt.Params {
+		gamma = gamma.InsertHead(&UniversalVariable{Identifier: param})
+	}
+	if !gamma.IsWellFormed(t) {
+		c.debugRuleFail("Alias")
+		return nil, c, c.malformedAliasError(stmt)
+	}
+
+	theta := c.InsertHead(&AliasDefinition{Identifier: stmt.Name, Params: stmt.Params, Type: t})
+	theta.debugRuleOut("Alias")
+	return &ast.UnitType{}, theta, nil
+}
+
+// Extend the context with the definition of a nominal type and with
+// the functions wrapping and unwrapping its values. The wrapped type
+// must be a well formed type without type variables
+func (c Context) synthNewtypeStatement(stmt *ast.NewtypeStatement) (ast.TypeValue, Context, error) {
+	c.debugRule("Newtype")
+
+	if isBuiltinTypeName(stmt.Name) {
+		c.debugRuleFail("Newtype")
+		return nil, c, c.builtinTypeNameError(stmt.Name)
+	}
+	t, err := c.ExpandAliases(stmt.Type)
+	if err != nil {
+		c.debugRuleFail("Newtype")
+		return nil, c, err
+	}
+	if !t.IsMonotype() || !c.IsWellFormed(t) || len(FreeExistentials(t)) > 0 {
+		c.debugRuleFail("Newtype")
+		return nil, c, c.malformedNewtypeError(stmt)
+	}
+
+	nt := &ast.VariableType{Identifier: stmt.Name}
+	theta := c.InsertHead(&NewtypeDefinition{Identifier: stmt.Name, Type: t})
+	theta = theta.InsertHead(&TypeAnnotation{
+		Identifier: stmt.Wrap.Identifier,
+		Value:      &ast.LambdaType{Domain: t, Codomain: nt},
+	})
+	theta = theta.InsertHead(&TypeAnnotation{
+		Identifier: stmt.Unwrap.Identifier,
+		Value:      &ast.LambdaType{Domain: nt, Codomain: t},
+	})
+
+	theta.debugRuleOut("Newtype")
+	return &ast.UnitType{}, theta, nil
+}
@@ -12,6 +12,12 @@ func (c Context) checkVariable(ty ast.TypeValue, name string) bool {
 	return ok && vty.Identifier.Value == name
 }
 
+// Returns true if an expression is a variable
+func isVariable(expr ast.Expression) bool {
+	_, ok := expr.(*ast.IdentifierExpr)
+	return ok
+}
+
 // TODO document
 func (c Context) CheckAgainst(expr ast.Expression, ty ast.TypeValue) (Context, error) {
 	c.debugSection("check", expr.String(), "<=", ty.FullString())
@@ -81,21 +87,98 @@ func (c Context) CheckAgainst(expr ast.Expression, ty ast.TypeValue) (Context, e
 			return c, nil
 		}
 
+	case *ast.LetExpression:
+		return c.checkLet(vexpr, ty)
+
+	case *ast.MatchExpr:
+		return c.checkMatch(vexpr, ty)
+
+	case *ast.EffectExpr:
+		return c.checkEffectExpr(vexpr, ty)
+
+	case *ast.RecordLiteral:
+		if rty, ok := ty.(*ast.RecordType); ok {
+			return c.checkRecord(vexpr, rty)
+		}
+
+	case *ast.TupleLiteral:
+		if tty, ok := ty.(*ast.TupleType); ok && len(tty.Elements) == len(vexpr.Elements) {
+			return c.checkTuple(vexpr, tty)
+		}
+
+	case *ast.ListLiteral:
+		switch lty := ty.(type) {
+		case *ast.ListType:
+			return c.checkList(vexpr, lty)
+		case *ast.ArrayType:
+			return c.checkArray(vexpr, lty)
+		}
+
+	case *ast.ApplyExpr:
+		if dty, ok := ty.(*ast.DataType); ok {
+			if delta, ok, err := c.checkConstruction(vexpr, dty); ok {
+				return delta, err
+			}
+		}
+
+	case *ast.FixExpr:
+		// Rule fixI<=. The recursive name has the type the function
+		// is checked against. The type variables of a constrained
+		// type are introduced first, see synthConstrainedAnno
+		if _, ok := ty.(*ast.ExistsType); !ok && constrainedPrefix(ty) == 0 {
+			c.debugRule("fixI<=")
+
+			annot := &TypeAnnotation{Identifier: vexpr.Param.Identifier, Value: ty}
+			delta, err := c.InsertHead(annot).CheckAgainst(vexpr.Body, ty)
+			if err != nil {
+				c.debugRuleFail("fixI<=")
+				return c, err
+			}
+			c.debugRuleOut("fixI<=")
+			return delta.Drop(annot), nil
+		}
+
 	case *ast.FunctionLiteral:
 		// Rule ->l
 		c.debugRule("->l")
 
 		if lty, ok := ty.(*ast.LambdaType); ok {
+			// An annotated parameter has the type of its
+			// annotation, the domain must be a subtype of it
+			gamma, domain := c, lty.Domain
+			if vexpr.ParamType != nil {
+				pt, err := c.paramType(vexpr)
+				if err != nil {
+					c.debugRuleFail("->l")
+					return c, err
+				}
+				if gamma, err = c.Subtype(lty.Domain, pt); err != nil {
+					c.debugRuleFail("->l")
+					return c, err
+				}
+				domain = pt
+			}
 			typedvar := &TypeAnnotation{
 				Identifier: vexpr.Param.Identifier,
-				Value:      lty.Domain,
+				Value:      domain,
 			}
-			nc := c.InsertHead(typedvar)
-			subcheck, err := nc.CheckAgainst(vexpr.Body, lty.Codomain)
+			// The effects of the body must be allowed by the type
+			nc, scope := gamma.InsertHead(typedvar).openEffectScope()
+			theta, err := nc.CheckAgainst(vexpr.Body, lty.Codomain)
 			if err != nil {
 				c.debugRuleFail("->l")
 				return c, err
 			}
+			effects, delta := theta.closeEffectScope(scope)
+			subcheck, err := delta.SubEffects(effects, lty.Row())
+			if err != nil {
+				c.debugRuleFail("->l")
+				return c, err
+			}
+			// The argument is converted to the annotation
+			if vexpr.ParamType != nil {
+				subcheck.narrow(vexpr, &ast.LambdaType{Domain: domain, Codomain: lty.Codomain, Effects: lty.Effects}, lty)
+			}
 			// outc := subcheck.Drop(typedvar)
 			c.debugRuleOut("->l")
 			return subcheck, nil
@@ -104,9 +187,17 @@ func (c Context) CheckAgainst(expr ast.Expression, ty ast.TypeValue) (Context, e
 	}
 
 	if fty, ok := ty.(*ast.ForAllType); ok {
-		// Rule ∀l
+		// Rule ∀l. Constrained type variables must
+		// have local instances, see synthConstrainedAnno
 		c.debugRule("∀I")
 
+		for _, name := range fty.Constraints {
+			if c.GetInstance(name, &ast.VariableType{Identifier: fty.Identifier}) == nil {
+				c.debugRuleFail("∀I")
+				return c, c.constrainedTypeError(fty)
+			}
+		}
+
 		uv := &UniversalVariable{Identifier: fty.Identifier}
 		nc := c.InsertHead(uv)
 		subcheck, err := nc.CheckAgainst(expr, fty.Type)
@@ -118,6 +209,14 @@ func (c Context) CheckAgainst(expr ast.Expression, ty ast.TypeValue) (Context, e
 		c.debugRuleOut("∀I")
 		return subcheck.Drop(uv), nil
 	}
+	if mty, ok := ty.(*ast.MuType); ok && !isVariable(expr) {
+		// Rule μI. A value of a recursive type is a value of its
+		// unfolding. A variable is checked by rule Sub, so that a
+		// variable whose type is not known yet has the recursive type
+		c.debugRule("μI")
+
+		return c.CheckAgainst(expr, Unfold(mty))
+	}
 	// Rule Sub
 	c.debugRule("Sub")
 
@@ -128,7 +227,12 @@ func (c Context) CheckAgainst(expr ast.Expression, ty ast.TypeValue) (Context, e
 		return c, err
 	}
 
+	delta, err := theta.Subtype(theta.Apply(a), theta.Apply(ty))
+	if err != nil {
+		return c, err
+	}
+	delta.narrow(expr, a, ty)
 	c.debugRuleOut("Sub")
-	return theta.Subtype(theta.Apply(a), theta.Apply(ty))
+	return delta, nil
 
 }
@@ -0,0 +1,198 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the coercions of values used as values of a
+// supertype of their type. Numbers keep the representation of their
+// type at runtime: an int used as a float, as in (1 : float), is
+// converted where subsumption crosses the numeric tower. So are the
+// numbers in the components of a value, and the arguments and results
+// of functions, as in (fun(x: float) {x} : int -> float). Values used
+// as values of a record type with fewer fields are restricted to the
+// fields of the type. The coercions are shapes, see ast.Shape, made
+// explicit by the elaboration of the program
+
+// Record that the value of an expression of type a is used as a
+// value of its supertype b. The value is converted to the shape
+// of the coercion from a to b
+func (c Context) narrow(exp ast.Expression, a, b ast.TypeValue) {
+	a, b = c.Apply(a), c.Apply(b)
+	if ast.CompareTypeValues(a, b) {
+		return
+	}
+	c.elab.narrow(exp, c.coercion(a, b, map[string]*ast.Shape{}))
+}
+
+// Get the position of a type in the numeric tower, -1 if it is
+// not a number type
+func towerRank(t ast.TypeValue) int {
+	for i, nt := range ast.NumericTower {
+		if ast.CompareTypeValues(t, nt) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get the number types of the numeric tower that are types of
+// the values of a type or of the sides of a union type, in
+// the order of the tower
+func towerMembers(t ast.TypeValue) []string {
+	ranks := map[int]bool{}
+	var visit func(t ast.TypeValue)
+	visit = func(t ast.TypeValue) {
+		if ut, ok := t.(*ast.UnionType); ok {
+			visit(ut.Left)
+			visit(ut.Right)
+		} else if r := towerRank(t); r >= 0 {
+			ranks[r] = true
+		}
+	}
+	visit(t)
+	numbers := []string{}
+	for i, nt := range ast.NumericTower {
+		if ranks[i] {
+			numbers = append(numbers, nt.Identifier.Value)
+		}
+	}
+	return numbers
+}
+
+// Get the coercion of the values of type a to their supertype b.
+// a is nil when the type of the values is not known, as in unions
+// and instantiated polymorphic types: the coercion then converts every
+// component of the values that b fixes, but the arguments of functions.
+// Returns nil if the values are used as they are. The shapes of the
+// recursive types and data types in seen are being built
+func (c Context) coercion(a, b ast.TypeValue, seen map[string]*ast.Shape) *ast.Shape {
+	if a != nil && ast.CompareTypeValues(a, b) {
+		return nil
+	}
+	switch va := a.(type) {
+	case *ast.ForAllType:
+		return c.coercion(va.Type, b, seen)
+	case *ast.ExistsType, *ast.UnionType:
+		a = nil
+	case *ast.MuType:
+		if _, ok := b.(*ast.MuType); !ok {
+			return c.coercion(Unfold(va), b, seen)
+		}
+	}
+
+	switch vb := b.(type) {
+	case *ast.ForAllType:
+		return c.coercion(a, vb.Type, seen)
+
+	case *ast.VariableType, *ast.UnionType:
+		// Numbers are converted to the smallest number type of b
+		// that is not smaller than their own type. Nothing is
+		// converted if b has all the number types up to its largest
+		numbers := towerMembers(b)
+		for i, n := range numbers {
+			if n != ast.NumericTower[i].Identifier.Value {
+				return &ast.Shape{Numbers: numbers}
+			}
+		}
+		return nil
+
+	case *ast.MatrixType:
+		if am, ok := a.(*ast.MatrixType); vb.Complex && (!ok || !am.Complex) {
+			return &ast.Shape{Numbers: []string{ast.TCOMPLEX.Identifier.Value}}
+		}
+		return nil
+
+	case *ast.LambdaType:
+		// Functions are contravariant in the domain: the argument
+		// is converted to the domain of the function
+		s := &ast.Shape{Codomain: c.coercion(nil, c.Apply(vb.Codomain), seen)}
+		if la, ok := a.(*ast.LambdaType); ok {
+			s.Domain = c.coercion(c.Apply(vb.Domain), c.Apply(la.Domain), seen)
+			s.Codomain = c.coercion(c.Apply(la.Codomain), c.Apply(vb.Codomain), seen)
+		}
+		if s.Domain == nil && s.Codomain == nil {
+			return nil
+		}
+		return s
+
+	case *ast.RecordType:
+		ra, ok := a.(*ast.RecordType)
+		narrower := !ok || len(ra.Fields) > len(vb.Fields)
+		s := &ast.Shape{Fields: make(map[string]*ast.Shape, len(vb.Fields))}
+		for label, ft := range vb.Fields {
+			var at ast.TypeValue
+			if ok {
+				at = c.Apply(ra.Fields[label])
+			}
+			s.Fields[label] = c.coercion(at, c.Apply(ft), seen)
+			narrower = narrower || s.Fields[label] != nil
+		}
+		if !narrower {
+			return nil
+		}
+		return s
+
+	case *ast.TupleType:
+		ta, ok := a.(*ast.TupleType)
+		s := &ast.Shape{Elements: make([]*ast.Shape, len(vb.Elements))}
+		converted := false
+		for i, et := range vb.Elements {
+			var at ast.TypeValue
+			if ok && len(ta.Elements) == len(vb.Elements) {
+				at = c.Apply(ta.Elements[i])
+			}
+			s.Elements[i] = c.coercion(at, c.Apply(et), seen)
+			converted = converted || s.Elements[i] != nil
+		}
+		if !converted {
+			return nil
+		}
+		return s
+
+	case *ast.ListType, *ast.ArrayType:
+		var at ast.TypeValue
+		switch vva := a.(type) {
+		case *ast.ListType:
+			at = vva.Element
+		case *ast.ArrayType:
+			at = vva.Element
+		}
+		if at != nil {
+			at = c.Apply(at)
+		}
+		if es := c.coercion(at, c.Apply(c.shapeComponents(b)[0]), seen); es != nil {
+			return &ast.Shape{Element: es}
+		}
+		return nil
+
+	case *ast.MuType:
+		if a != nil {
+			// Recursive types are only subtypes of recursive types
+			// that are equivalent, see rule <:μ
+			if _, ok := a.(*ast.MuType); ok {
+				return nil
+			}
+			return c.coercion(a, Unfold(vb), seen)
+		}
+		if s, ok := seen[b.FullString()]; ok {
+			return s
+		}
+		s := &ast.Shape{}
+		seen[b.FullString()] = s
+		us := c.coercion(nil, c.Apply(Unfold(vb)), seen)
+		if us == nil {
+			return nil
+		}
+		*s = *us
+		return s
+
+	case *ast.DataType:
+		// The arguments of data types are invariant
+		if a != nil {
+			return nil
+		}
+		return c.shape(b, map[string]*ast.Shape{})
+	}
+	return nil
+}
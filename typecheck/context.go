@@ -14,6 +14,8 @@ import (
 type ContextValue interface {
 	contextValue()
 	String() string
+	// Print types like ast.TypeValue.FancyString
+	FancyString(map[ast.UniqueIdentifier]int) string
 }
 
 // ======================================================================
@@ -30,6 +32,10 @@ func (v *UniversalVariable) String() string {
 	return v.Identifier.FullString()
 }
 
+func (v *UniversalVariable) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return v.Identifier.String()
+}
+
 // Unsolved existential variable α^
 // solved when Value is not nil
 type ExistentialVariable struct {
@@ -53,6 +59,18 @@ func (v *ExistentialVariable) String() string {
 	return b.String()
 }
 
+func (v *ExistentialVariable) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	var b bytes.Buffer
+
+	b.WriteString((&ast.ExistsType{Identifier: v.Identifier}).FancyString(occ))
+
+	if v.Value != nil {
+		b.WriteString(" = ")
+		b.WriteString((*v.Value).FancyString(occ))
+	}
+	return b.String()
+}
+
 // Denoted with |>α^ in the paper
 type Marker struct {
 	Identifier ast.UniqueIdentifier
@@ -63,6 +81,10 @@ func (v *Marker) String() string {
 	return "►" + v.Identifier.FullString()
 }
 
+func (v *Marker) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return "►" + (&ast.ExistsType{Identifier: v.Identifier}).FancyString(occ)
+}
+
 // Denoted with x : A in the paper
 type TypeAnnotation struct {
 	Identifier ast.UniqueIdentifier
@@ -74,6 +96,154 @@ func (v *TypeAnnotation) String() string {
 	return v.Identifier.FullString() + ": " + v.Value.FullString()
 }
 
+func (v *TypeAnnotation) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return v.Identifier.String() + " : " + v.Value.FancyString(occ)
+}
+
+// ADDITION: the declaration of an algebraic data type
+type TypeDefinition struct {
+	Identifier   ast.UniqueIdentifier
+	Params       []ast.UniqueIdentifier
+	Constructors []*ast.ConstructorDecl
+}
+
+func (v *TypeDefinition) contextValue() {}
+func (v *TypeDefinition) String() string {
+	return "type " + v.Type().FullString()
+}
+
+func (v *TypeDefinition) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return "type " + v.Type().FancyString(occ)
+}
+
+// The data type applied to its own parameters
+func (v *TypeDefinition) Type() *ast.DataType {
+	params := make([]ast.TypeValue, len(v.Params))
+	for i, p := range v.Params {
+		params[i] = &ast.VariableType{Identifier: p}
+	}
+	return &ast.DataType{Identifier: v.Identifier, Args: params}
+}
+
+// ADDITION: the declaration of a type alias, the type that
+// it stands for is expanded and well formed
+type AliasDefinition struct {
+	Identifier ast.UniqueIdentifier
+	Params     []ast.UniqueIdentifier
+	Type       ast.TypeValue
+}
+
+func (v *AliasDefinition) contextValue() {}
+func (v *AliasDefinition) String() string {
+	return "type " + v.Identifier.FullString() + " = " + v.Type.FullString()
+}
+
+func (v *AliasDefinition) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return "type " + v.Identifier.String() + " = " + v.Type.FancyString(occ)
+}
+
+// ADDITION: the declaration of a nominal type, distinct
+// from the type whose values it wraps
+type NewtypeDefinition struct {
+	Identifier ast.UniqueIdentifier
+	Type       ast.TypeValue
+}
+
+func (v *NewtypeDefinition) contextValue() {}
+func (v *NewtypeDefinition) String() string {
+	return "newtype " + v.Identifier.FullString() + " = " + v.Type.FullString()
+}
+
+func (v *NewtypeDefinition) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return "newtype " + v.Identifier.String() + " = " + v.Type.FancyString(occ)
+}
+
+// ADDITION: the declaration of a usage policy, with the states of
+// the policy reached by the events of the programs checked so far and
+// the shortest sequence of events reaching each of them
+type PolicyDefinition struct {
+	Policy *ast.PolicyStatement
+	States map[string][]string
+}
+
+func (v *PolicyDefinition) contextValue() {}
+func (v *PolicyDefinition) String() string {
+	return "policy " + v.Policy.Name
+}
+
+func (v *PolicyDefinition) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return v.String()
+}
+
+// ADDITION: the effects performed in the body of a function or of an
+// effect annotation. Applications add the effects of the applied
+// function to the nearest scope in the context
+type EffectScope struct {
+	Identifier ast.UniqueIdentifier
+	Effects    *ast.EffectRow
+}
+
+func (v *EffectScope) contextValue() {}
+func (v *EffectScope) String() string {
+	return "!" + v.Identifier.FullString() + ": " + v.Effects.FullString()
+}
+
+func (v *EffectScope) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return "! " + v.Effects.FancyString(occ)
+}
+
+// ADDITION: the declaration of an interface
+type InterfaceDefinition struct {
+	Interface *ast.InterfaceStatement
+}
+
+func (v *InterfaceDefinition) contextValue() {}
+func (v *InterfaceDefinition) String() string {
+	return "interface " + v.Interface.Name
+}
+
+func (v *InterfaceDefinition) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return v.String()
+}
+
+// ADDITION: an instance of an interface for a type, whose methods are
+// in the dictionary bound to Dictionary. The type variables of
+// constrained types have local instances, whose dictionaries
+// are the parameters of the function
+type InstanceDefinition struct {
+	Interface  string
+	Type       ast.TypeValue
+	Dictionary ast.UniqueIdentifier
+}
+
+func (v *InstanceDefinition) contextValue() {}
+func (v *InstanceDefinition) String() string {
+	return "instance " + v.Interface + "(" + v.Type.FullString() + ")"
+}
+
+func (v *InstanceDefinition) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return "instance " + v.Interface + "(" + v.Type.FancyString(occ) + ")"
+}
+
+// ADDITION: a type that must implement an interface, added when an
+// overloaded value is used. Dictionary is the placeholder of the
+// dictionary passed to the value, that is found when the type is
+// known. It is nil for the interfaces without methods
+type InterfaceConstraint struct {
+	Interface  string
+	Type       ast.TypeValue
+	Dictionary *ast.UniqueIdentifier
+}
+
+func (v *InterfaceConstraint) contextValue() {}
+func (v *InterfaceConstraint) String() string {
+	return v.Interface + "(" + v.Type.FullString() + ")"
+}
+
+func (v *InterfaceConstraint) FancyString(occ map[ast.UniqueIdentifier]int) string {
+	return v.Interface + "(" + v.Type.FancyString(occ) + ")"
+}
+
 // Returns true if two values implementing ContextValue are equal
 func CompareContextValues(a, b ContextValue) bool {
 	switch va := a.(type) {
@@ -93,6 +263,38 @@ func CompareContextValues(a, b ContextValue) bool {
 		if vb, ok := b.(*TypeAnnotation); ok {
 			return *va == *vb
 		}
+	case *TypeDefinition:
+		if vb, ok := b.(*TypeDefinition); ok {
+			return va.Identifier == vb.Identifier
+		}
+	case *AliasDefinition:
+		if vb, ok := b.(*AliasDefinition); ok {
+			return va.Identifier == vb.Identifier
+		}
+	case *NewtypeDefinition:
+		if vb, ok := b.(*NewtypeDefinition); ok {
+			return va.Identifier == vb.Identifier
+		}
+	case *EffectScope:
+		if vb, ok := b.(*EffectScope); ok {
+			return va.Identifier == vb.Identifier
+		}
+	case *PolicyDefinition:
+		if vb, ok := b.(*PolicyDefinition); ok {
+			return va.Policy.Name == vb.Policy.Name
+		}
+	case *InterfaceDefinition:
+		if vb, ok := b.(*InterfaceDefinition); ok {
+			return va.Interface.Name == vb.Interface.Name
+		}
+	case *InstanceDefinition:
+		if vb, ok := b.(*InstanceDefinition); ok {
+			return va.Dictionary == vb.Dictionary
+		}
+	case *InterfaceConstraint:
+		if vb, ok := b.(*InterfaceConstraint); ok {
+			return va == vb
+		}
 
 	}
 
@@ -106,6 +308,9 @@ func CompareContextValues(a, b ContextValue) bool {
 
 type Context struct {
 	Contents []ContextValue
+	// The evidence recorded for the elaboration of the
+	// program being checked, shared by its contexts
+	elab *elaboration
 }
 
 // Creates a new empty context
@@ -128,6 +333,22 @@ func (c Context) String() string {
 	return b.String()
 }
 
+// Print the term bindings and the type definitions of the context one
+// per line, from the oldest to the most recent one. The type variables
+// of every line are named independently
+func (c Context) FancyString() string {
+	var b bytes.Buffer
+
+	for i := len(c.Contents) - 1; i >= 0; i-- {
+		switch c.Contents[i].(type) {
+		case *TypeAnnotation, *TypeDefinition, *AliasDefinition, *NewtypeDefinition:
+			b.WriteString(c.Contents[i].FancyString(map[ast.UniqueIdentifier]int{}))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
 // Sorted insertion after element el in the context
 // Return a new context after insertion
 func (c Context) Insert(el ContextValue, values []ContextValue) Context {
@@ -141,6 +362,7 @@ func (c Context) Insert(el ContextValue, values []ContextValue) Context {
 		}
 	}
 
+	nc.elab = c.elab
 	nc.Contents = append(nc.Contents, c.Contents[:i]...)
 	nc.Contents = append(nc.Contents, values...)
 	if i < len(c.Contents) {
@@ -153,6 +375,7 @@ func (c Context) Insert(el ContextValue, values []ContextValue) Context {
 // Insert at head and return a new context
 func (c Context) InsertHead(el ContextValue) Context {
 	nc := NewContext()
+	nc.elab = c.elab
 	nc.Contents = append(nc.Contents, el)
 	nc.Contents = append(nc.Contents, c.Contents...)
 	return *nc
@@ -161,6 +384,7 @@ func (c Context) InsertHead(el ContextValue) Context {
 // Remove an element from a context and return a new one
 func (c Context) Drop(el ContextValue) Context {
 	nc := NewContext()
+	nc.elab = c.elab
 	for _, old := range c.Contents {
 		if !CompareContextValues(old, el) {
 			nc.Contents = append(nc.Contents, old)
@@ -171,6 +395,7 @@ func (c Context) Drop(el ContextValue) Context {
 
 func (c Context) Concat(rc Context) Context {
 	nc := NewContext()
+	nc.elab = c.elab
 	copy(nc.Contents, c.Contents)
 	nc.Contents = append(nc.Contents, rc.Contents...)
 
@@ -184,7 +409,7 @@ func (c Context) HasTypeVar(alpha ast.UniqueIdentifier) bool {
 	}
 	for _, c := range c.Contents {
 		if v, ok := c.(*UniversalVariable); ok {
-			if v.Identifier.Value == alpha.Value {
+			if v.Identifier == alpha {
 				return true
 			}
 		}
@@ -241,10 +466,105 @@ func (c Context) GetAnnotation(alpha ast.UniqueIdentifier) *ast.TypeValue {
 	return nil
 }
 
+// Return the definition of a data type
+func (c Context) GetTypeDefinition(alpha ast.UniqueIdentifier) *TypeDefinition {
+	for _, c := range c.Contents {
+		if v, ok := c.(*TypeDefinition); ok {
+			if v.Identifier == alpha {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// Return the definition of a type alias
+func (c Context) GetAliasDefinition(alpha ast.UniqueIdentifier) *AliasDefinition {
+	for _, c := range c.Contents {
+		if v, ok := c.(*AliasDefinition); ok {
+			if v.Identifier == alpha {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// Return the definition of a nominal type
+func (c Context) GetNewtypeDefinition(alpha ast.UniqueIdentifier) *NewtypeDefinition {
+	for _, c := range c.Contents {
+		if v, ok := c.(*NewtypeDefinition); ok {
+			if v.Identifier == alpha {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// Return the definition of a usage policy
+func (c Context) GetPolicyDefinition(name string) *PolicyDefinition {
+	for _, c := range c.Contents {
+		if v, ok := c.(*PolicyDefinition); ok {
+			if v.Policy.Name == name {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// Return the declaration of an interface, declared in the
+// context or builtin. Returns nil if there is none
+func (c Context) GetInterfaceDefinition(name string) *ast.InterfaceStatement {
+	for _, c := range c.Contents {
+		if v, ok := c.(*InterfaceDefinition); ok {
+			if v.Interface.Name == name {
+				return v.Interface
+			}
+		}
+	}
+	return ast.BuiltinInterface(name)
+}
+
+// Return the instance of an interface for a type
+func (c Context) GetInstance(name string, t ast.TypeValue) *InstanceDefinition {
+	for _, c := range c.Contents {
+		if v, ok := c.(*InstanceDefinition); ok {
+			if v.Interface == name && ast.CompareTypeValues(v.Type, t) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// Return the interface constraints of the context
+func (c Context) GetConstraints() []*InterfaceConstraint {
+	res := []*InterfaceConstraint{}
+	for _, c := range c.Contents {
+		if v, ok := c.(*InterfaceConstraint); ok {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Return the nearest effect scope, nil at the top level
+func (c Context) GetEffectScope() *EffectScope {
+	for _, c := range c.Contents {
+		if v, ok := c.(*EffectScope); ok {
+			return v
+		}
+	}
+	return nil
+}
+
 // Split a context in two left and right context when a value is encountered
 func (c Context) SplitAt(el ContextValue) (Context, Context) {
 	left := NewContext()
 	right := NewContext()
+	left.elab, right.elab = c.elab, c.elab
 	found := false
 	for _, old := range c.Contents {
 		if CompareContextValues(old, el) {
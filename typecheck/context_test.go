@@ -104,3 +104,24 @@ func TestDrop(t *testing.T) {
 		assert.Equal(t, tt.Result, nc)
 	}
 }
+
+func TestContextFancyString(t *testing.T) {
+	var inttype ast.TypeValue = ast.NewVariableType("int")
+	c := Context{
+		Contents: []ContextValue{
+			&TypeAnnotation{
+				Identifier: ast.UniqueIdentifier{Value: "g", Id: 0},
+				Value:      &ast.LambdaType{Domain: &ast.ExistsType{Identifier: betaid}, Codomain: &ast.ExistsType{Identifier: alphaid}},
+			},
+			&TypeAnnotation{
+				Identifier: ast.UniqueIdentifier{Value: "f", Id: 0},
+				Value:      &ast.LambdaType{Domain: &ast.ExistsType{Identifier: alphaid}, Codomain: inttype},
+			},
+			&ExistentialVariable{Identifier: betaid, Value: &inttype},
+			alphaext,
+			gammauniv,
+		},
+	}
+	// Only the bindings are printed, with their own names
+	assert.Equal(t, "f : 'a -> int\ng : 'a -> 'b\n", c.FancyString())
+}
@@ -0,0 +1,126 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of the declarations
+// of algebraic data types
+
+// Build the signature of a constructor of a data type. A constructor
+// is a curried function from the types of its arguments to the data
+// type, polymorphic in the parameters of the data type, such as
+// ∀a.tree(a) -> a -> tree(a) -> tree(a)
+func (def *TypeDefinition) ConstructorSignature(ctor *ast.ConstructorDecl) ast.TypeValue {
+	var t ast.TypeValue = def.Type()
+	for i := len(ctor.Args) - 1; i >= 0; i-- {
+		t = &ast.LambdaType{Domain: ctor.Args[i], Codomain: t}
+	}
+	for i := len(def.Params) - 1; i >= 0; i-- {
+		t = &ast.ForAllType{Identifier: def.Params[i], Type: t}
+	}
+	return t
+}
+
+// Extend the context with the definition of a data type and with the
+// signatures of its constructors. The type can be used in the types
+// of its own constructors. The aliases in the types of the arguments
+// of the constructors are expanded in the definition
+func (c Context) synthTypeStatement(stmt *ast.TypeStatement) (ast.TypeValue, Context, error) {
+	c.debugRule("Type")
+
+	if isBuiltinTypeName(stmt.Name) {
+		c.debugRuleFail("Type")
+		return nil, c, c.builtinTypeNameError(stmt.Name)
+	}
+	def := &TypeDefinition{
+		Identifier: stmt.Name,
+		Params:     stmt.Params,
+	}
+	for _, ctor := range stmt.Constructors {
+		nctor := *ctor
+		nctor.Args = make([]ast.TypeValue, len(ctor.Args))
+		for i, arg := range ctor.Args {
+			t, err := c.ExpandAliases(arg)
+			if err != nil {
+				c.debugRuleFail("Type")
+				return nil, c, err
+			}
+			nctor.Args[i] = t
+		}
+		def.Constructors = append(def.Constructors, &nctor)
+	}
+	theta := c.InsertHead(def)
+	for _, ctor := range def.Constructors {
+		sig := def.ConstructorSignature(ctor)
+		if !theta.IsWellFormed(sig) {
+			c.debugRuleFail("Type")
+			return nil, c, c.malformedConstructorError(ctor, sig)
+		}
+		theta = theta.InsertHead(&TypeAnnotation{
+			Identifier: ctor.Name.Identifier,
+			Value:      sig,
+		})
+	}
+
+	theta.debugRuleOut("Type")
+	return &ast.UnitType{}, theta, nil
+}
+
+// Rule Data<=. A constructor applied to all of its arguments is
+// checked against a data type by solving the parameters of the data
+// type to the type arguments first, then checking the arguments
+// against the types of the arguments of the constructor: Some(1) has
+// type option(float), and 1 is converted to a float. Returns false
+// if the expression is not the application of a constructor of the
+// data type
+func (c Context) checkConstruction(exp *ast.ApplyExpr, ty *ast.DataType) (Context, bool, error) {
+	args := []ast.Expression{}
+	var f ast.Expression = exp
+	for {
+		app, ok := f.(*ast.ApplyExpr)
+		if !ok {
+			break
+		}
+		args = append([]ast.Expression{app.Arg}, args...)
+		f = app.Function
+	}
+	id, ok := f.(*ast.IdentifierExpr)
+	def := c.GetTypeDefinition(ty.Identifier)
+	if !ok || def == nil {
+		return c, false, nil
+	}
+	var ctor *ast.ConstructorDecl
+	for _, cd := range def.Constructors {
+		if cd.Name.Identifier == id.Identifier && len(cd.Args) == len(args) {
+			ctor = cd
+		}
+	}
+	if ctor == nil {
+		return c, false, nil
+	}
+	c.debugRule("Data<=")
+
+	t, theta := c.instantiateForAll(def.ConstructorSignature(ctor))
+	domains := []ast.TypeValue{}
+	for range args {
+		lty := t.(*ast.LambdaType)
+		domains = append(domains, lty.Domain)
+		t = lty.Codomain
+	}
+	theta, err := theta.Subtype(t, ty)
+	if err != nil {
+		c.debugRuleFail("Data<=")
+		return c, true, err
+	}
+	for i, arg := range args {
+		theta, err = theta.CheckAgainst(arg, theta.Apply(domains[i]))
+		if err != nil {
+			c.debugRuleFail("Data<=")
+			return c, true, err
+		}
+	}
+
+	theta.debugRuleOut("Data<=")
+	return theta, true, nil
+}
@@ -0,0 +1,286 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+// This file contains the rules of the effect system. The effects of
+// the applications in an expression are collected in the nearest
+// effect scope of the context. A function literal opens a new scope:
+// the effects of its body are the latent effects of its type, that
+// are performed where the function is applied. Effects performed at
+// the top level, outside of any scope, are not tracked.
+// The history of a scope is the sequence of the events performed in
+// it, the branches of conditional expressions are a choice between
+// their histories.
+
+// Open a new, pure effect scope
+func (c Context) openEffectScope() (Context, *EffectScope) {
+	scope := &EffectScope{Identifier: ast.GenUID("ε"), Effects: ast.NewEffectRow(nil, nil)}
+	return c.InsertHead(scope), scope
+}
+
+// Close an effect scope, returning the effects performed in the scope
+func (c Context) closeEffectScope(scope *EffectScope) (*ast.EffectRow, Context) {
+	effects := scope.Effects
+	for _, v := range c.Contents {
+		if s, ok := v.(*EffectScope); ok && s.Identifier == scope.Identifier {
+			effects = s.Effects
+			break
+		}
+	}
+	delta := c.Drop(scope)
+	return effects.Map(delta.Apply), delta
+}
+
+// Add the effects of a row to the nearest effect scope
+func (c Context) perform(effects *ast.EffectRow) Context {
+	scope := c.GetEffectScope()
+	if scope == nil || effects.IsPure() {
+		return c
+	}
+	c.debugSection("perform", effects.FullString())
+	return c.Insert(scope, []ContextValue{&EffectScope{
+		Identifier: scope.Identifier,
+		Effects:    scope.Effects.Union(effects),
+	}})
+}
+
+// Perform the fail effect, for the expressions that can fail at
+// runtime other than the applications of functions that fail
+func (c Context) performFail() Context {
+	return c.perform(ast.NewEffectRow([]string{token.EFAIL}, nil))
+}
+
+// Take the history of the nearest effect scope, leaving it empty.
+// Used to type the branches of conditional expressions apart
+func (c Context) takeHistory() (ast.History, Context) {
+	scope := c.GetEffectScope()
+	if scope == nil {
+		return nil, c
+	}
+	return scope.Effects.History, c.Insert(scope, []ContextValue{&EffectScope{
+		Identifier: scope.Identifier,
+		Effects:    scope.Effects.WithHistory(nil),
+	}})
+}
+
+// Set the history of the nearest effect scope to a history h0 followed
+// by a choice between the histories of the branches of an expression
+func (c Context) joinHistories(h0 ast.History, branches []ast.History) Context {
+	scope := c.GetEffectScope()
+	if scope == nil {
+		return c
+	}
+	var h ast.History
+	for i, b := range branches {
+		if i == 0 {
+			h = b
+			continue
+		}
+		h = ast.NewChoiceHistory(h, b)
+	}
+	return c.Insert(scope, []ContextValue{&EffectScope{
+		Identifier: scope.Identifier,
+		Effects:    scope.Effects.WithHistory(ast.NewSeqHistory(h0, h)),
+	}})
+}
+
+// Solve the existential variable α^ standing for a row
+func (c Context) solveRow(alpha ast.UniqueIdentifier, effects *ast.EffectRow) Context {
+	var row ast.TypeValue = effects
+	return c.Insert(&ExistentialVariable{Identifier: alpha}, []ContextValue{
+		&ExistentialVariable{Identifier: alpha, Value: &row},
+	})
+}
+
+// Rule <:Row. A row is a subrow of another row when all of its labels
+// and row variables are in the other row. The row on the right grows
+// through one of its existential variables, otherwise the existential
+// variables of the row on the left are solved to the row on the right.
+// The events of the row on the left cannot be forgotten: they are the
+// history of the existential variable of the row on the right
+func (c Context) SubEffects(a, b *ast.EffectRow) (Context, error) {
+	c.debugRule("<:Row")
+	a = a.Map(c.Apply)
+	b = b.Map(c.Apply)
+	events := ast.HasEvents(a.History) && !ast.CompareHistories(a.History, b.History)
+
+	labels := []string{}
+	for _, l := range a.Labels {
+		if !b.Has(l) {
+			labels = append(labels, l)
+		}
+	}
+	tails := []ast.TypeValue{}
+	for _, t := range a.Tails {
+		if !b.HasTail(t) {
+			tails = append(tails, t)
+		}
+	}
+	missing := ast.NewEffectRow(labels, tails)
+	if missing.IsPure() && !events {
+		c.debugRuleOut("<:Row")
+		return c, nil
+	}
+
+	for _, t := range b.Tails {
+		if ext, ok := t.(*ast.ExistsType); ok {
+			if h, rec := recursiveHistory(ext.Identifier, a.History); events || rec {
+				missing.History = h
+			}
+			theta := c.solveRow(ext.Identifier, missing)
+			theta.debugRuleOut("<:Row")
+			return theta, nil
+		}
+	}
+
+	if len(missing.Labels) > 0 {
+		c.debugRuleFail("<:Row")
+		return c, c.effectsError(a, b)
+	}
+	if events {
+		c.debugRuleFail("<:Row")
+		return c, c.historyError(a, b)
+	}
+	theta := c
+	for _, t := range missing.Tails {
+		ext, ok := t.(*ast.ExistsType)
+		if !ok {
+			c.debugRuleFail("<:Row")
+			return c, c.effectsError(a, b)
+		}
+		theta = theta.solveRow(ext.Identifier, b)
+	}
+	theta.debugRuleOut("<:Row")
+	return theta, nil
+}
+
+// The history of a row variable solved to a history where the
+// variable occurs, that is the history of a recursive function whose
+// recursive calls perform the row variable, is μh.H[h/α^]. Returns
+// true if the history is recursive
+func recursiveHistory(alpha ast.UniqueIdentifier, h ast.History) (ast.History, bool) {
+	rec := ast.GenUID("h")
+	found := false
+	body := ast.MapRowHistories(h, func(t ast.TypeValue) ast.History {
+		if ext, ok := t.(*ast.ExistsType); ok && ext.Identifier == alpha {
+			found = true
+			return &ast.RecVarHistory{Identifier: rec}
+		}
+		return &ast.RowHistory{Row: t}
+	})
+	if !found {
+		return h, false
+	}
+	return &ast.RecHistory{Identifier: rec, Body: body}, true
+}
+
+// Rule Event=>. An event performs io, its history is the event itself
+func (c Context) synthEvent(exp *ast.EventExpr) (ast.TypeValue, Context, error) {
+	c.debugRuleOut("Event=>")
+	effects := ast.NewEffectRow([]string{token.EIO}, nil).WithHistory(&ast.EventHistory{Name: exp.Name})
+	return &ast.UnitType{}, c.perform(effects), nil
+}
+
+// Rule Effect=>
+func (c Context) synthEffectExpr(exp *ast.EffectExpr) (ast.TypeValue, Context, error) {
+	c.debugRule("Effect=>")
+	t, delta, err := c.restrictEffects(exp, func(gamma Context) (ast.TypeValue, Context, error) {
+		return gamma.SynthesizesTo(exp.Body)
+	})
+	if err != nil {
+		c.debugRuleFail("Effect=>")
+		return nil, c, err
+	}
+	delta.debugRuleOut("Effect=>")
+	return t, delta, nil
+}
+
+// Rule Effect<=
+func (c Context) checkEffectExpr(exp *ast.EffectExpr, ty ast.TypeValue) (Context, error) {
+	c.debugRule("Effect<=")
+	_, delta, err := c.restrictEffects(exp, func(gamma Context) (ast.TypeValue, Context, error) {
+		theta, err := gamma.CheckAgainst(exp.Body, ty)
+		return ty, theta, err
+	})
+	if err != nil {
+		c.debugRuleFail("Effect<=")
+		return c, err
+	}
+	delta.debugRuleOut("Effect<=")
+	return delta, nil
+}
+
+// Type the body of an effect annotation in a new effect scope. The
+// effects performed by the body and the latent effects of the
+// function it evaluates to must be allowed by the restriction.
+// The effects of the body are then performed in the enclosing scope
+func (c Context) restrictEffects(exp *ast.EffectExpr,
+	body func(Context) (ast.TypeValue, Context, error)) (ast.TypeValue, Context, error) {
+	gamma, scope := c.openEffectScope()
+	t, theta, err := body(gamma)
+	if err != nil {
+		return nil, c, err
+	}
+	effects, delta := theta.closeEffectScope(scope)
+	t = delta.Apply(t)
+
+	for _, row := range append([]*ast.EffectRow{effects}, latentEffects(t)...) {
+		for _, label := range row.Labels {
+			if !exp.Restriction.Allows(label) {
+				return nil, c, c.restrictionError(exp, label)
+			}
+		}
+	}
+	return t, delta.perform(effects), nil
+}
+
+// Get the latent effects of a function type, and of the
+// functions returned by it when it is curried
+func latentEffects(t ast.TypeValue) []*ast.EffectRow {
+	switch vt := t.(type) {
+	case *ast.LambdaType:
+		return append([]*ast.EffectRow{vt.Row()}, latentEffects(vt.Codomain)...)
+	case *ast.ForAllType:
+		return latentEffects(vt.Type)
+	}
+	return nil
+}
+
+// Returns true if an existential variable occurs in a type only in
+// the effects of the function and of the functions it returns, and
+// not in the effects of its arguments. Such a row variable can always
+// be instantiated to the pure row: it is closed when generalizing,
+// this is the case of the row of the recursive calls of a function
+func isLatentRow(alpha ast.UniqueIdentifier, t ast.TypeValue) bool {
+	switch vt := t.(type) {
+	case *ast.LambdaType:
+		return !OccursIn(alpha, vt.Domain) && isLatentRow(alpha, vt.Codomain)
+	case *ast.ForAllType:
+		return isLatentRow(alpha, vt.Type)
+	}
+	return !OccursIn(alpha, t)
+}
+
+// Restrict the effects of a top level statement of a package: the
+// effects of its expression or of the values bound by a let statement
+func restrictStatement(stmt ast.Statement, r *ast.EffectRestriction) ast.Statement {
+	switch vs := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return &ast.ExpressionStatement{
+			Token:      vs.Token,
+			Expression: &ast.EffectExpr{Token: r.Token, Restriction: r, Body: vs.Expression},
+		}
+	case *ast.LetStatement:
+		asss := make([]*ast.Assignment, len(vs.Assignments))
+		for i, ass := range vs.Assignments {
+			nass := *ass
+			nass.Value = &ast.EffectExpr{Token: r.Token, Restriction: r, Body: ass.Value}
+			asss[i] = &nass
+		}
+		return &ast.LetStatement{Token: vs.Token, Assignments: asss}
+	}
+	return stmt
+}
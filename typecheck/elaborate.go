@@ -0,0 +1,217 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+)
+
+// This file contains the elaboration of programs. The typechecker
+// does not change the programs it checks: the dictionaries passed to
+// overloaded values and taken by the values whose constraints are
+// generalized are recorded while checking, and then made explicit
+// in a copy of the program, as arguments and parameters of functions.
+// Dictionaries are passed before their instances are known: their
+// placeholders are replaced by the dictionaries found for them.
+// Equalities are given the shape of the values they compare. Values
+// used as values of a supertype with fewer record fields are restricted
+// to the shape of the supertype, and their numbers are converted where
+// the supertype has larger number types, see coercions.go. Arithmetical
+// operations on types that are not number types are applications of
+// the methods of the Num instances of the types.
+
+// The evidence found by the typechecker in a program
+type elaboration struct {
+	// The placeholders of the dictionaries passed to the uses of
+	// overloaded values and to the constrained annotations
+	dictionaries map[ast.Expression][]ast.UniqueIdentifier
+	// The dictionaries taken as parameters by let-bound values,
+	// expression statements and bodies of constrained annotations
+	params map[ast.Expression][]ast.UniqueIdentifier
+	// The dictionaries found for the placeholders: dictionaries
+	// of instances or parameters of generalized values
+	solutions map[ast.UniqueIdentifier]ast.UniqueIdentifier
+	// The shapes of the values compared by equalities
+	shapes map[*ast.InfixExpression]*ast.Shape
+	// The shapes the values of expressions are restricted to
+	narrowings map[ast.Expression]*ast.Shape
+	// The arithmetical operations applied by the methods of Num
+	operations map[ast.Expression]*numOperation
+	// The types of the instances found for the placeholders
+	instances map[ast.UniqueIdentifier]ast.TypeValue
+}
+
+// An arithmetical operation applied by a method of the Num instance
+// of the type of its operands, see synthNumMethod
+type numOperation struct {
+	Method string
+	// The placeholder of the dictionary of the instance
+	Dictionary ast.UniqueIdentifier
+	// The operand that is an int converted by fromint, -1 if none
+	Convert int
+}
+
+func newElaboration() *elaboration {
+	return &elaboration{
+		dictionaries: map[ast.Expression][]ast.UniqueIdentifier{},
+		params:       map[ast.Expression][]ast.UniqueIdentifier{},
+		solutions:    map[ast.UniqueIdentifier]ast.UniqueIdentifier{},
+		shapes:       map[*ast.InfixExpression]*ast.Shape{},
+		narrowings:   map[ast.Expression]*ast.Shape{},
+		operations:   map[ast.Expression]*numOperation{},
+		instances:    map[ast.UniqueIdentifier]ast.TypeValue{},
+	}
+}
+
+// Record the dictionaries passed to an expression. Evidence is only
+// recorded in contexts checking a program, see CheckProgram
+func (e *elaboration) pass(exp ast.Expression, dicts []ast.UniqueIdentifier) {
+	if e != nil && len(dicts) > 0 {
+		e.dictionaries[exp] = dicts
+	}
+}
+
+// Record the dictionaries taken as parameters by an expression
+func (e *elaboration) take(exp ast.Expression, params []ast.UniqueIdentifier) {
+	if e != nil && len(params) > 0 {
+		e.params[exp] = params
+	}
+}
+
+// Record the dictionary found for a placeholder
+func (e *elaboration) solve(placeholder, dict ast.UniqueIdentifier) {
+	if e != nil {
+		e.solutions[placeholder] = dict
+	}
+}
+
+// Record the dictionary of the instance found for a
+// placeholder, and the type of the instance
+func (e *elaboration) resolve(placeholder, dict ast.UniqueIdentifier, t ast.TypeValue) {
+	if e != nil {
+		e.solutions[placeholder] = dict
+		e.instances[placeholder] = t
+	}
+}
+
+// Record an arithmetical operation applied by a method of Num
+func (e *elaboration) operate(exp ast.Expression, op *numOperation) {
+	if e != nil {
+		e.operations[exp] = op
+	}
+}
+
+// Record the shape of the values compared by an equality
+func (e *elaboration) restrict(exp *ast.InfixExpression, s *ast.Shape) {
+	if e != nil && s != nil {
+		e.shapes[exp] = s
+	}
+}
+
+// Record the shape the value of an expression is restricted to
+func (e *elaboration) narrow(exp ast.Expression, s *ast.Shape) {
+	if e != nil && s != nil {
+		e.narrowings[exp] = s
+	}
+}
+
+// Copy a statement, making the dictionaries explicit
+func (e *elaboration) statement(stmt ast.Statement) ast.Statement {
+	switch vs := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return &ast.ExpressionStatement{Token: vs.Token, Expression: e.expression(vs.Expression)}
+	case *ast.LetStatement:
+		return &ast.LetStatement{Token: vs.Token, Assignments: ast.RewriteAssignments(vs.Assignments, e.rewrite)}
+	case *ast.InstanceStatement:
+		ns := *vs
+		ns.Methods = ast.RewriteAssignments(vs.Methods, e.rewrite)
+		return &ns
+	}
+	return stmt
+}
+
+// Copy an expression, making the dictionaries explicit
+func (e *elaboration) expression(exp ast.Expression) ast.Expression {
+	return ast.Rewrite(exp, e.rewrite)
+}
+
+// Pass the dictionaries to the copy of an expression, restrict
+// it, then wrap it in functions taking its dictionary parameters.
+// Equalities compare the values restricted to their shape
+func (e *elaboration) rewrite(old, value ast.Expression) ast.Expression {
+	if exp, ok := old.(*ast.InfixExpression); ok && e.shapes[exp] != nil {
+		value.(*ast.InfixExpression).Shape = e.shapes[exp]
+	}
+	if op, ok := e.operations[old]; ok {
+		value = e.operation(op, value)
+	}
+	for _, placeholder := range e.dictionaries[old] {
+		dict, ok := e.solutions[placeholder]
+		if !ok {
+			dict = placeholder
+		}
+		value = &ast.ApplyExpr{
+			Token:    token.Token{Type: token.LPAREN, Literal: "("},
+			Function: value,
+			Arg:      &ast.IdentifierExpr{Identifier: dict},
+		}
+	}
+	if s, ok := e.narrowings[old]; ok {
+		value = &ast.RestrictExpr{Token: token.Token{Type: token.ANNOT, Literal: ":"}, Body: value, Shape: s}
+	}
+	return dictionaryFunction(value, e.params[old])
+}
+
+// Apply the copy of an arithmetical operation by the method of the
+// instance of Num found for its type. Operations on the number types
+// are applied by the strict operators of the types instead
+func (e *elaboration) operation(op *numOperation, value ast.Expression) ast.Expression {
+	var operands []*ast.Expression
+	switch v := value.(type) {
+	case *ast.InfixExpression:
+		operands = []*ast.Expression{&v.Left, &v.Right}
+	case *ast.PrefixExpression:
+		operands = []*ast.Expression{&v.Right}
+	}
+
+	if t, ok := e.instances[op.Dictionary]; ok && towerRank(t) >= 0 {
+		name := t.(*ast.VariableType).Identifier.Value
+		switch v := value.(type) {
+		case *ast.InfixExpression:
+			v.Instance = ast.NumericInstances[v.Operator][name]
+		case *ast.PrefixExpression:
+			v.Instance = ast.NegationInstances[name]
+		}
+		if op.Convert >= 0 {
+			*operands[op.Convert] = &ast.RestrictExpr{
+				Token: token.Token{Type: token.ANNOT, Literal: ":"},
+				Body:  *operands[op.Convert],
+				Shape: &ast.Shape{Numbers: []string{name}},
+			}
+		}
+		return value
+	}
+
+	dict, ok := e.solutions[op.Dictionary]
+	if !ok {
+		dict = op.Dictionary
+	}
+	method := func(name string) ast.Expression {
+		return &ast.AccessExpr{
+			Token:  token.Token{Type: token.ACCESS, Literal: token.ACCESS},
+			Record: &ast.IdentifierExpr{Identifier: dict},
+			Field:  name,
+		}
+	}
+	apply := func(f, arg ast.Expression) ast.Expression {
+		return &ast.ApplyExpr{Token: token.Token{Type: token.LPAREN, Literal: "("}, Function: f, Arg: arg}
+	}
+	value = method(op.Method)
+	for i, operand := range operands {
+		if i == op.Convert {
+			value = apply(value, apply(method("fromint"), *operand))
+		} else {
+			value = apply(value, *operand)
+		}
+	}
+	return value
+}
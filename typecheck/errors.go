@@ -3,6 +3,7 @@ package typecheck
 import (
 	"fmt"
 	"github.com/0x0f0f0f/gobba-golang/ast"
+	"strings"
 )
 
 // This file contains definitions for type checker errors
@@ -19,17 +20,46 @@ func (te TypeError) Error() string {
 
 // TODO how to handle errors
 func (c *Context) malformedError(t ast.TypeValue) *TypeError {
+	if alpha := c.unboundTypeVariable(t); alpha != nil {
+		return &TypeError{fmt.Sprintf("unbound type variable %s in type %s", alpha, t)}
+	}
 	return &TypeError{fmt.Sprintf("type %s is not well formed", t)}
 }
 
 func (c *Context) subtypeError(a, b ast.TypeValue) *TypeError {
+	if a.String() == b.String() {
+		// Distinct types with the same name, one declared after the other
+		return &TypeError{fmt.Sprintf("type '%s' cannot be used as type '%s', a distinct type declared with the same name", a.FullString(), b.FullString())}
+	}
 	return &TypeError{fmt.Sprintf("type '%s' cannot be used as type '%s'", a, b)}
 }
 
+func (c *Context) effectsError(a, b *ast.EffectRow) *TypeError {
+	return &TypeError{fmt.Sprintf("a function performing effects %s cannot be used as a function performing effects %s", a, b)}
+}
+
+func (c *Context) historyError(a, b *ast.EffectRow) *TypeError {
+	return &TypeError{fmt.Sprintf("a function performing events %s cannot be used as a function performing effects %s", a, b)}
+}
+
+func (c *Context) policyError(p *ast.PolicyStatement, trace []string) *TypeError {
+	return &TypeError{fmt.Sprintf("policy %s is violated by the events %s", p.Name, strings.Join(trace, ", "))}
+}
+
+func (c *Context) restrictionError(expr *ast.EffectExpr, label string) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("%s performs effect %s, which is not allowed by %s", expr.Body, label, expr.Restriction),
+	}
+}
+
 func (c *Context) synthError(expr ast.Expression) *TypeError {
 	return &TypeError{fmt.Sprintf("failed to infer type for %s", expr)}
 }
 
+func (c *Context) statementError(stmt ast.Statement) *TypeError {
+	return &TypeError{fmt.Sprintf("failed to typecheck statement %s", stmt)}
+}
+
 func (c *Context) notInContextError(id ast.UniqueIdentifier) *TypeError {
 	return &TypeError{fmt.Sprintf("identifier %s not in context", id)}
 }
@@ -40,18 +70,162 @@ func (c *Context) unexpectedType(expected, found ast.TypeValue) *TypeError {
 	}
 }
 
-func (c *Context) expectedSameTypeIfBranches(tt, ft ast.TypeValue) *TypeError {
+func (c *Context) expectedSameTypeComparison(lt, rt ast.TypeValue) *TypeError {
 	return &TypeError{
-		fmt.Sprintf("type mismatch in if expression. then branch "+
-			"has type %s. else branch has type %s", tt, ft),
+		fmt.Sprintf("type mismatch in comparison. "+
+			"left operand has type %s. right operand has type %s", lt, rt),
 	}
 
 }
 
-func (c *Context) expectedSameTypeComparison(lt, rt ast.TypeValue) *TypeError {
+func (c *Context) expectedSameTypeMatchArms(t, u ast.TypeValue) *TypeError {
 	return &TypeError{
-		fmt.Sprintf("type mismatch in comparison. "+
-			"left operand has type %s. right operand has type %s", lt, rt),
+		fmt.Sprintf("type mismatch in match expression. an arm "+
+			"has type %s. another arm has type %s", t, u),
 	}
+}
+
+func (c *Context) patternTypeError(p ast.Pattern, t ast.TypeValue) *TypeError {
+	return &TypeError{fmt.Sprintf("pattern %s cannot match values of type %s", p, t.FancyString(map[ast.UniqueIdentifier]int{}))}
+}
+
+func (c *Context) constructorArityError(p *ast.ConstructorPattern, arity int) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("constructor %s expects %d arguments, pattern %s has %d",
+			p.Constructor, arity, p, len(p.Args)),
+	}
+}
+
+func (c *Context) missingFieldError(t ast.TypeValue, label string) *TypeError {
+	return &TypeError{fmt.Sprintf("type %s has no field %s", t, label)}
+}
+
+func (c *Context) notAListError(expr ast.Expression, t ast.TypeValue) *TypeError {
+	return &TypeError{fmt.Sprintf("%s has type %s, which is not a list type", expr, t)}
+}
+
+func (c *Context) notAMatrixError(expr ast.Expression, t ast.TypeValue) *TypeError {
+	return &TypeError{fmt.Sprintf("%s has type %s, which is not a matrix type", expr, t)}
+}
+
+func (c *Context) notANumberError(expr ast.Expression, t ast.TypeValue) *TypeError {
+	return &TypeError{fmt.Sprintf("%s has type %s, which is not a number", expr, t)}
+}
+
+func (c *Context) matrixOperandsError(expr *ast.InfixExpression, lt, rt ast.TypeValue) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("operator %s cannot be applied to operands of type %s and %s", expr.Operator, lt, rt),
+	}
+}
+
+func (c *Context) dimensionMismatchError(expr *ast.InfixExpression, lt, rt ast.TypeValue) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("dimension mismatch, operator %s cannot be applied to operands of type %s and %s", expr.Operator, lt, rt),
+	}
+}
+
+func (c *Context) matrixSubtypeError(a, b ast.TypeValue) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("dimension mismatch, type %s cannot be used as type %s", a, b),
+	}
+}
+
+func (c *Context) arraySizeError(expr *ast.ListLiteral, t *ast.ArrayType) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("%s has %d elements and cannot be used as an array of type %s", expr, len(expr.Elements), t),
+	}
+}
+
+func (c *Context) notARecordError(expr ast.Expression, t ast.TypeValue) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("cannot access field of %s, it has type %s and not a known record type", expr, t),
+	}
+}
+
+func (c *Context) malformedConstructorError(ctor *ast.ConstructorDecl, sig ast.TypeValue) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("constructor %s has type %s, which is not well formed", ctor, sig),
+	}
+}
+
+func (c *Context) builtinTypeNameError(id ast.UniqueIdentifier) *TypeError {
+	return &TypeError{fmt.Sprintf("cannot declare type %s, it is a builtin type", id)}
+}
+
+func (c *Context) malformedAliasError(stmt *ast.AliasStatement) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("type alias %s stands for %s, which is not well formed", stmt.Name, stmt.Type),
+	}
+}
+
+func (c *Context) aliasArityError(t *ast.DataType, arity int) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("type alias %s expects %d arguments, type %s has %d", t.Identifier.Value, arity, t, len(t.Args)),
+	}
+}
+
+func (c *Context) malformedNewtypeError(stmt *ast.NewtypeStatement) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("newtype %s wraps %s, which is not a well formed type without type variables", stmt.Name, stmt.Type),
+	}
+}
+
+func (c *Context) instanceError(name string, t ast.TypeValue) *TypeError {
+	return &TypeError{fmt.Sprintf("type %s does not implement the interface %s", t, name)}
+}
+
+func (c *Context) ambiguityError(k *InterfaceConstraint) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("ambiguous type %s, the instance of the interface %s cannot be found", k.Type, k.Interface),
+	}
+}
+
+func (c *Context) constrainedTypeError(t ast.TypeValue) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("constrained type %s can only be the type of a let binding or of an annotation", t),
+	}
+}
+
+func (c *Context) duplicateInterfaceError(stmt *ast.InterfaceStatement) *TypeError {
+	return &TypeError{fmt.Sprintf("interface %s is already declared", stmt.Name)}
+}
+
+func (c *Context) duplicatePolicyError(stmt *ast.PolicyStatement) *TypeError {
+	return &TypeError{fmt.Sprintf("policy %s is already declared", stmt.Name)}
+}
+
+func (c *Context) interfaceMethodError(stmt *ast.InterfaceStatement, m *ast.MethodDecl) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("method %s of interface %s has type %s, which does not mention %s or is not well formed",
+			m.Name, stmt.Name, m.Type, stmt.Param),
+	}
+}
+
+func (c *Context) derivedInstanceError(stmt *ast.InstanceStatement) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("the instances of interface %s are derived from the structure of types", stmt.Interface),
+	}
+}
+
+func (c *Context) duplicateInstanceError(stmt *ast.InstanceStatement) *TypeError {
+	return &TypeError{fmt.Sprintf("type %s already implements the interface %s", stmt.Type, stmt.Interface)}
+}
+
+func (c *Context) instanceTypeError(stmt *ast.InstanceStatement) *TypeError {
+	return &TypeError{
+		fmt.Sprintf("cannot implement interface %s for type %s, it is not a well formed type without type variables",
+			stmt.Interface, stmt.Type),
+	}
+}
+
+func (c *Context) missingMethodError(stmt *ast.InstanceStatement, name string) *TypeError {
+	return &TypeError{fmt.Sprintf("instance %s(%s) does not define method %s", stmt.Interface, stmt.Type, name)}
+}
+
+func (c *Context) unknownMethodError(stmt *ast.InstanceStatement, name string) *TypeError {
+	return &TypeError{fmt.Sprintf("interface %s has no method %s", stmt.Interface, name)}
+}
 
+func (c *Context) unknownInterfaceError(name string) *TypeError {
+	return &TypeError{fmt.Sprintf("interface %s is not declared", name)}
 }
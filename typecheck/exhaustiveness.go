@@ -0,0 +1,424 @@
+package typecheck
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"sort"
+	"strings"
+)
+
+// This file contains the analysis of the patterns of match
+// expressions, that finds matches that are not exhaustive and arms
+// that can never be reached. The analysis follows the usefulness
+// algorithm of "Warnings for pattern matching" by Luc Maranget
+// http://moscova.inria.fr/~maranget/papers/warn/warn.pdf
+// Matches and patterns of let bindings that are not exhaustive
+// perform the fail effect, see exhaustive and irrefutable
+
+// A problem found in a program that does not prevent it from running
+type Warning struct {
+	Msg string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("warning: %s", w.Msg)
+}
+
+// Find the match expressions of a program that are not exhaustive
+// or that have unreachable arms, and the patterns of let bindings
+// that are not exhaustive
+func ProgramWarnings(p *ast.Program) []Warning {
+	warnings := []Warning{}
+	for _, stmt := range p.Statements {
+		if ls, ok := stmt.(*ast.LetStatement); ok {
+			warnings = append(warnings, assignmentsWarnings(ls.Assignments)...)
+		}
+	}
+	ast.InspectProgram(p, func(exp ast.Expression) bool {
+		switch ve := exp.(type) {
+		case *ast.MatchExpr:
+			warnings = append(warnings, MatchWarnings(ve)...)
+		case *ast.LetExpression:
+			warnings = append(warnings, assignmentsWarnings(ve.Assignments)...)
+		}
+		return true
+	})
+	return warnings
+}
+
+// Check that the patterns taking apart the values of
+// a list of assignments are exhaustive
+func assignmentsWarnings(asss []*ast.Assignment) []Warning {
+	warnings := []Warning{}
+	for _, ass := range asss {
+		if ass.Pattern == nil {
+			continue
+		}
+		rows := [][]*simplePattern{{simplify(ass.Pattern)}}
+		if missing := missingPatterns(rows, 1); missing != nil {
+			warnings = append(warnings, Warning{
+				fmt.Sprintf("pattern %s is not exhaustive, %s is not matched", ass.Pattern, missing[0]),
+			})
+		}
+	}
+	return warnings
+}
+
+// Check that a match expression is exhaustive and that
+// each of its arms matches some value not matched by the previous ones
+func MatchWarnings(m *ast.MatchExpr) []Warning {
+	warnings := []Warning{}
+	rows := [][]*simplePattern{}
+	for _, arm := range m.Arms {
+		row := []*simplePattern{simplify(arm.Pattern)}
+		if !useful(rows, row) {
+			warnings = append(warnings, Warning{fmt.Sprintf("unreachable match arm %s", arm.Pattern)})
+		}
+		rows = append(rows, row)
+	}
+	if missing := missingPatterns(rows, 1); missing != nil {
+		warnings = append(warnings, Warning{
+			fmt.Sprintf("match on %s is not exhaustive, %s is not matched", m.Scrutinee, missing[0]),
+		})
+	}
+	return warnings
+}
+
+// Returns true if the arms of a match expression match every value
+// of the type of the matched value
+func exhaustive(m *ast.MatchExpr) bool {
+	rows := make([][]*simplePattern, len(m.Arms))
+	for i, arm := range m.Arms {
+		rows[i] = []*simplePattern{simplify(arm.Pattern)}
+	}
+	return missingPatterns(rows, 1) == nil
+}
+
+// Returns true if a pattern matches every value of its type
+func irrefutable(p ast.Pattern) bool {
+	return missingPatterns([][]*simplePattern{{simplify(p)}}, 1) == nil
+}
+
+// ======================================================================
+// Simplified patterns
+// ======================================================================
+
+type patternKind int
+
+const (
+	wildcardKind    patternKind = iota
+	constructorKind             // Constructors of data types and booleans
+	literalKind                 // Literals of types with infinitely many values
+	recordKind
+)
+
+// A constructor and its number of arguments
+type constructorInfo struct {
+	name  string
+	arity int
+}
+
+// A pattern where variables are wildcards, and constructors are
+// resolved to all the constructors of their type
+type simplePattern struct {
+	kind   patternKind
+	name   string // Name of the constructor, or the literal
+	args   []*simplePattern
+	fields map[string]*simplePattern
+	family []constructorInfo // All the constructors of the type of a constructor
+}
+
+var boolFamily = []constructorInfo{{"true", 0}, {"false", 0}}
+
+// Lists are the values of a type with the constructors [] and ::
+var listFamily = []constructorInfo{{"[]", 0}, {"::", 2}}
+
+// Tuples of n elements are the values of a type with a
+// single constructor, without a name, of n arguments
+func tupleFamily(n int) []constructorInfo {
+	return []constructorInfo{{"", n}}
+}
+
+var wildcard = &simplePattern{kind: wildcardKind}
+
+func simplify(p ast.Pattern) *simplePattern {
+	switch vp := p.(type) {
+	case *ast.LiteralPattern:
+		switch lit := vp.Value.(type) {
+		case *ast.UnitLiteral:
+			// unit has a single value
+			return wildcard
+		case *ast.BoolLiteral:
+			return &simplePattern{kind: constructorKind, name: lit.String(), family: boolFamily}
+		}
+		return &simplePattern{kind: literalKind, name: vp.Value.String()}
+	case *ast.ConstructorPattern:
+		sp := &simplePattern{kind: constructorKind, name: vp.Constructor.Identifier.Value}
+		if vp.Definition != nil {
+			for _, ctor := range vp.Definition.Constructors {
+				sp.family = append(sp.family, constructorInfo{ctor.Name.Identifier.Value, len(ctor.Args)})
+			}
+		}
+		for _, arg := range vp.Args {
+			sp.args = append(sp.args, simplify(arg))
+		}
+		return sp
+	case *ast.TuplePattern:
+		sp := &simplePattern{kind: constructorKind, family: tupleFamily(len(vp.Elements))}
+		for _, e := range vp.Elements {
+			sp.args = append(sp.args, simplify(e))
+		}
+		return sp
+	case *ast.EmptyListPattern:
+		return &simplePattern{kind: constructorKind, name: "[]", family: listFamily}
+	case *ast.ConsPattern:
+		return &simplePattern{
+			kind:   constructorKind,
+			name:   "::",
+			args:   []*simplePattern{simplify(vp.Head), simplify(vp.Tail)},
+			family: listFamily,
+		}
+	case *ast.RecordPattern:
+		sp := &simplePattern{kind: recordKind, fields: map[string]*simplePattern{}}
+		for _, f := range vp.Fields {
+			sp.fields[f.Label] = simplify(f.Pattern)
+		}
+		return sp
+	}
+	return wildcard
+}
+
+func (p *simplePattern) String() string {
+	switch p.kind {
+	case constructorKind, literalKind:
+		if len(p.args) == 0 {
+			return p.name
+		}
+		if p.name == "::" {
+			return "(" + p.args[0].String() + " :: " + p.args[1].String() + ")"
+		}
+		args := make([]string, len(p.args))
+		for i, a := range p.args {
+			args[i] = a.String()
+		}
+		return p.name + "(" + strings.Join(args, ", ") + ")"
+	case recordKind:
+		labels := make([]string, 0, len(p.fields))
+		for label := range p.fields {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		fields := make([]string, len(labels))
+		for i, label := range labels {
+			fields[i] = label + " = " + p.fields[label].String()
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return "_"
+}
+
+// ======================================================================
+// Usefulness
+// ======================================================================
+
+// Rows of a pattern matrix are vectors of patterns matched against
+// vectors of values. Only the first column is inspected at each step
+
+// Get the non wildcard patterns in the first column of a matrix
+func heads(rows [][]*simplePattern) []*simplePattern {
+	hs := []*simplePattern{}
+	for _, row := range rows {
+		if row[0].kind != wildcardKind {
+			hs = append(hs, row[0])
+		}
+	}
+	return hs
+}
+
+// Get the labels of all the record patterns in the first column
+func recordLabels(hs []*simplePattern) []string {
+	set := map[string]bool{}
+	for _, h := range hs {
+		for label := range h.fields {
+			set[label] = true
+		}
+	}
+	labels := make([]string, 0, len(set))
+	for label := range set {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func wildcards(n int) []*simplePattern {
+	ws := make([]*simplePattern, n)
+	for i := range ws {
+		ws[i] = wildcard
+	}
+	return ws
+}
+
+// Expand the first pattern of a row, if it matches the values built by
+// the constructor c, into the patterns of the arguments of c
+func specializeRow(row []*simplePattern, c *simplePattern, labels []string) ([]*simplePattern, bool) {
+	h := row[0]
+	var args []*simplePattern
+	switch {
+	case c.kind == recordKind:
+		args = make([]*simplePattern, len(labels))
+		for i, label := range labels {
+			args[i] = wildcard
+			if f, ok := h.fields[label]; ok {
+				args[i] = f
+			}
+		}
+	case h.kind == wildcardKind:
+		args = wildcards(c.arity())
+	case h.kind == c.kind && h.name == c.name:
+		args = h.args
+	default:
+		return nil, false
+	}
+	return append(append([]*simplePattern{}, args...), row[1:]...), true
+}
+
+// The number of arguments of a constructor pattern
+func (p *simplePattern) arity() int {
+	for _, c := range p.family {
+		if c.name == p.name {
+			return c.arity
+		}
+	}
+	return len(p.args)
+}
+
+// Keep the rows matching the values built by c, expanded by specializeRow
+func specialize(rows [][]*simplePattern, c *simplePattern, labels []string) [][]*simplePattern {
+	srows := [][]*simplePattern{}
+	for _, row := range rows {
+		if srow, ok := specializeRow(row, c, labels); ok {
+			srows = append(srows, srow)
+		}
+	}
+	return srows
+}
+
+// Keep the rows whose first pattern is a wildcard, without it
+func defaultRows(rows [][]*simplePattern) [][]*simplePattern {
+	drows := [][]*simplePattern{}
+	for _, row := range rows {
+		if row[0].kind == wildcardKind {
+			drows = append(drows, row[1:])
+		}
+	}
+	return drows
+}
+
+// Get the constructors of the type of the patterns in the first column
+// that do not appear in the column. Returns nil if the type has
+// infinitely many values, that no set of literals can cover
+func missingConstructors(hs []*simplePattern) []constructorInfo {
+	if len(hs) == 0 || hs[0].kind == literalKind || hs[0].family == nil {
+		return nil
+	}
+	present := map[string]bool{}
+	for _, h := range hs {
+		present[h.name] = true
+	}
+	missing := []constructorInfo{}
+	for _, c := range hs[0].family {
+		if !present[c.name] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// Returns true if some vector of values matched by q
+// is not matched by any of the rows
+func useful(rows [][]*simplePattern, q []*simplePattern) bool {
+	if len(q) == 0 {
+		return len(rows) == 0
+	}
+
+	hs := heads(rows)
+	switch q[0].kind {
+	case constructorKind, literalKind:
+		return useful(specialize(rows, q[0], nil), specializeOne(q, q[0], nil))
+	case recordKind:
+		labels := recordLabels(append(hs, q[0]))
+		return useful(specialize(rows, q[0], labels), specializeOne(q, q[0], labels))
+	}
+
+	// The first pattern of q is a wildcard
+	if len(hs) > 0 && hs[0].kind == recordKind {
+		labels := recordLabels(hs)
+		return useful(specialize(rows, hs[0], labels), specializeOne(q, hs[0], labels))
+	}
+	if missing := missingConstructors(hs); missing != nil && len(missing) == 0 {
+		for _, c := range hs[0].family {
+			cp := &simplePattern{kind: constructorKind, name: c.name, family: hs[0].family}
+			if useful(specialize(rows, cp, nil), specializeOne(q, cp, nil)) {
+				return true
+			}
+		}
+		return false
+	}
+	return useful(defaultRows(rows), q[1:])
+}
+
+func specializeOne(q []*simplePattern, c *simplePattern, labels []string) []*simplePattern {
+	sq, _ := specializeRow(q, c, labels)
+	return sq
+}
+
+// Find a vector of n patterns matching values that are not matched
+// by any of the rows. Returns nil if the rows are exhaustive
+func missingPatterns(rows [][]*simplePattern, n int) []*simplePattern {
+	if n == 0 {
+		if len(rows) == 0 {
+			return []*simplePattern{}
+		}
+		return nil
+	}
+
+	hs := heads(rows)
+	if len(hs) > 0 && hs[0].kind == recordKind {
+		labels := recordLabels(hs)
+		w := missingPatterns(specialize(rows, hs[0], labels), len(labels)+n-1)
+		if w == nil {
+			return nil
+		}
+		rec := &simplePattern{kind: recordKind, fields: map[string]*simplePattern{}}
+		for i, label := range labels {
+			rec.fields[label] = w[i]
+		}
+		return append([]*simplePattern{rec}, w[len(labels):]...)
+	}
+
+	missing := missingConstructors(hs)
+	if missing != nil && len(missing) == 0 {
+		// Every constructor of the type appears in the column
+		for _, c := range hs[0].family {
+			cp := &simplePattern{kind: constructorKind, name: c.name, family: hs[0].family}
+			w := missingPatterns(specialize(rows, cp, nil), c.arity+n-1)
+			if w != nil {
+				cp.args = w[:c.arity]
+				return append([]*simplePattern{cp}, w[c.arity:]...)
+			}
+		}
+		return nil
+	}
+
+	w := missingPatterns(defaultRows(rows), n-1)
+	if w == nil {
+		return nil
+	}
+	if len(missing) > 0 {
+		c := missing[0]
+		cp := &simplePattern{kind: constructorKind, name: c.name, args: wildcards(c.arity)}
+		return append([]*simplePattern{cp}, w...)
+	}
+	return append([]*simplePattern{wildcard}, w...)
+}
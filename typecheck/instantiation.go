@@ -4,6 +4,15 @@ import (
 	"github.com/0x0f0f0f/gobba-golang/ast"
 )
 
+// Returns true if a type is a universal type variable declared before
+// an existential variable, in the part of the context where the
+// variable is. The existential variables instantiating constrained
+// type variables are solved with it, see instantiateConstraints
+func isScopedTypeVar(ty ast.TypeValue, rightc Context) bool {
+	_, ok := ty.(*ast.VariableType)
+	return ok && rightc.IsWellFormed(ty)
+}
+
 // Defined in the Instantiation paragraph:
 // α^ :=< A, instantiate α^ such that α^ <: A
 func (c Context) InstantiateL(alpha ast.UniqueIdentifier, ty ast.TypeValue) Context {
@@ -11,7 +20,7 @@ func (c Context) InstantiateL(alpha ast.UniqueIdentifier, ty ast.TypeValue) Cont
 	exv := &ExistentialVariable{alpha, nil}
 	leftc, rightc := c.SplitAt(exv)
 
-	if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+	if ty.IsMonotype() && (leftc.IsWellFormed(ty) || isScopedTypeVar(ty, rightc)) {
 		// Rule InstLSolve
 		c.debugRule("InstLSolve")
 
@@ -23,7 +32,8 @@ func (c Context) InstantiateL(alpha ast.UniqueIdentifier, ty ast.TypeValue) Cont
 
 	switch vty := ty.(type) {
 	case *ast.LambdaType:
-		// Rule InstLArr
+		// Rule InstLArr. The articulated function type
+		// performs the same effects
 		c.debugRule("InstLArr")
 
 		alpha1 := ast.GenUID("α")
@@ -32,6 +42,7 @@ func (c Context) InstantiateL(alpha ast.UniqueIdentifier, ty ast.TypeValue) Cont
 		var arrow ast.TypeValue = &ast.LambdaType{
 			Domain:   &ast.ExistsType{Identifier: alpha1},
 			Codomain: &ast.ExistsType{Identifier: alpha2},
+			Effects:  vty.Effects,
 		}
 
 		// First premise
@@ -51,6 +62,114 @@ func (c Context) InstantiateL(alpha ast.UniqueIdentifier, ty ast.TypeValue) Cont
 		delta.debugRuleOut("InstLArr")
 		return delta
 
+	case *ast.DataType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLData
+		c.debugRule("InstLData")
+
+		args, gamma := c.articulateData(alpha, vty)
+		delta := gamma
+		for i, arg := range vty.Args {
+			delta = delta.InstantiateL(args[i], delta.Apply(arg))
+		}
+		delta.debugRuleOut("InstLData")
+		return delta
+
+	case *ast.MuType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLMu. Recursive types are compared by their
+		// bodies, α^ is solved to the recursive type itself
+		c.debugRule("InstLMu")
+
+		delta := c.articulateMu(alpha, vty)
+		delta.debugRuleOut("InstLMu")
+		return delta
+
+	case *ast.RecordType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLRecord
+		c.debugRule("InstLRecord")
+
+		labels, gamma := c.articulateRecord(alpha, vty)
+		delta := gamma
+		for _, label := range vty.Labels() {
+			delta = delta.InstantiateL(labels[label], delta.Apply(vty.Fields[label]))
+		}
+		delta.debugRuleOut("InstLRecord")
+		return delta
+
+	case *ast.TupleType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLTuple
+		c.debugRule("InstLTuple")
+
+		elems, gamma := c.articulateTuple(alpha, len(vty.Elements))
+		delta := gamma
+		for i, et := range vty.Elements {
+			delta = delta.InstantiateL(elems[i], delta.Apply(et))
+		}
+		delta.debugRuleOut("InstLTuple")
+		return delta
+
+	case *ast.ListType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLList
+		c.debugRule("InstLList")
+
+		elem, gamma := c.articulateList(alpha)
+		delta := gamma.InstantiateL(elem, gamma.Apply(vty.Element))
+		delta.debugRuleOut("InstLList")
+		return delta
+
+	case *ast.ArrayType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLArray
+		c.debugRule("InstLArray")
+
+		size, elem, gamma := c.articulateArray(alpha)
+		theta := gamma.InstantiateL(size, gamma.Apply(vty.Size))
+		delta := theta.InstantiateL(elem, theta.Apply(vty.Element))
+		delta.debugRuleOut("InstLArray")
+		return delta
+
+	case *ast.MatrixType:
+		if leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLMatrix
+		c.debugRule("InstLMatrix")
+
+		rows, cols, gamma := c.articulateMatrix(alpha, vty)
+		theta := gamma.InstantiateL(rows, gamma.Apply(vty.Rows))
+		delta := theta.InstantiateL(cols, theta.Apply(vty.Cols))
+		delta.debugRuleOut("InstLMatrix")
+		return delta
+
+	case *ast.UnionType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstLUnion
+		c.debugRule("InstLUnion")
+
+		left, right, gamma := c.articulateUnion(alpha)
+		theta := gamma.InstantiateL(left, gamma.Apply(vty.Left))
+		delta := theta.InstantiateL(right, theta.Apply(vty.Right))
+		delta.debugRuleOut("InstLUnion")
+		return delta
+
 	case *ast.ForAllType:
 		// Rule InstLAllR
 		c.debugRule("InstLAllR")
@@ -90,7 +209,7 @@ func (c Context) InstantiateR(ty ast.TypeValue, alpha ast.UniqueIdentifier) Cont
 
 	exv := &ExistentialVariable{alpha, nil}
 	leftc, rightc := c.SplitAt(exv)
-	if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+	if ty.IsMonotype() && (leftc.IsWellFormed(ty) || isScopedTypeVar(ty, rightc)) {
 		// Rule InstRSolve
 		c.debugRule("InstRSolve")
 
@@ -102,7 +221,8 @@ func (c Context) InstantiateR(ty ast.TypeValue, alpha ast.UniqueIdentifier) Cont
 
 	switch va := ty.(type) {
 	case *ast.LambdaType:
-		// Rule InstRArr
+		// Rule InstRArr. The articulated function type
+		// performs the same effects
 		c.debugRule("InstRArr")
 
 		alpha1 := ast.GenUID("α")
@@ -111,6 +231,7 @@ func (c Context) InstantiateR(ty ast.TypeValue, alpha ast.UniqueIdentifier) Cont
 		var arrow ast.TypeValue = &ast.LambdaType{
 			Domain:   &ast.ExistsType{Identifier: alpha1},
 			Codomain: &ast.ExistsType{Identifier: alpha2},
+			Effects:  va.Effects,
 		}
 
 		gamma := c.InsertHead(&ExistentialVariable{
@@ -126,6 +247,113 @@ func (c Context) InstantiateR(ty ast.TypeValue, alpha ast.UniqueIdentifier) Cont
 		delta := theta.InstantiateR(theta.Apply(va.Codomain), alpha2)
 		delta.debugRuleOut("InstRArr")
 		return delta
+	case *ast.DataType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRData
+		c.debugRule("InstRData")
+
+		args, gamma := c.articulateData(alpha, va)
+		delta := gamma
+		for i, arg := range va.Args {
+			delta = delta.InstantiateR(delta.Apply(arg), args[i])
+		}
+		delta.debugRuleOut("InstRData")
+		return delta
+
+	case *ast.MuType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRMu
+		c.debugRule("InstRMu")
+
+		delta := c.articulateMu(alpha, va)
+		delta.debugRuleOut("InstRMu")
+		return delta
+
+	case *ast.RecordType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRRecord
+		c.debugRule("InstRRecord")
+
+		labels, gamma := c.articulateRecord(alpha, va)
+		delta := gamma
+		for _, label := range va.Labels() {
+			delta = delta.InstantiateR(delta.Apply(va.Fields[label]), labels[label])
+		}
+		delta.debugRuleOut("InstRRecord")
+		return delta
+
+	case *ast.TupleType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRTuple
+		c.debugRule("InstRTuple")
+
+		elems, gamma := c.articulateTuple(alpha, len(va.Elements))
+		delta := gamma
+		for i, et := range va.Elements {
+			delta = delta.InstantiateR(delta.Apply(et), elems[i])
+		}
+		delta.debugRuleOut("InstRTuple")
+		return delta
+
+	case *ast.ListType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRList
+		c.debugRule("InstRList")
+
+		elem, gamma := c.articulateList(alpha)
+		delta := gamma.InstantiateR(gamma.Apply(va.Element), elem)
+		delta.debugRuleOut("InstRList")
+		return delta
+
+	case *ast.ArrayType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRArray
+		c.debugRule("InstRArray")
+
+		size, elem, gamma := c.articulateArray(alpha)
+		theta := gamma.InstantiateR(gamma.Apply(va.Size), size)
+		delta := theta.InstantiateR(theta.Apply(va.Element), elem)
+		delta.debugRuleOut("InstRArray")
+		return delta
+
+	case *ast.MatrixType:
+		if leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRMatrix
+		c.debugRule("InstRMatrix")
+
+		rows, cols, gamma := c.articulateMatrix(alpha, va)
+		theta := gamma.InstantiateR(gamma.Apply(va.Rows), rows)
+		delta := theta.InstantiateR(theta.Apply(va.Cols), cols)
+		delta.debugRuleOut("InstRMatrix")
+		return delta
+
+	case *ast.UnionType:
+		if ty.IsMonotype() && leftc.IsWellFormed(ty) {
+			return c
+		}
+		// Rule InstRUnion
+		c.debugRule("InstRUnion")
+
+		left, right, gamma := c.articulateUnion(alpha)
+		theta := gamma.InstantiateR(gamma.Apply(va.Left), left)
+		delta := theta.InstantiateR(theta.Apply(va.Right), right)
+		delta.debugRuleOut("InstRUnion")
+		return delta
+
 	case *ast.ForAllType:
 		// Rule InstRAllL
 		c.debugRule("InstRAllL")
@@ -162,3 +390,144 @@ func (c Context) InstantiateR(ty ast.TypeValue, alpha ast.UniqueIdentifier) Cont
 	}
 	return c
 }
+
+// Solve an existential variable to a record type with the labels of a
+// given record type, whose fields are fresh existential variables
+// inserted before it. Returns the fresh variables indexed by label
+func (c Context) articulateRecord(alpha ast.UniqueIdentifier, rt *ast.RecordType) (map[string]ast.UniqueIdentifier, Context) {
+	labels := map[string]ast.UniqueIdentifier{}
+	fields := map[string]ast.TypeValue{}
+	values := []ContextValue{}
+	for _, label := range rt.Labels() {
+		alphai := ast.GenUID("α")
+		labels[label] = alphai
+		fields[label] = &ast.ExistsType{Identifier: alphai}
+		values = append([]ContextValue{&ExistentialVariable{alphai, nil}}, values...)
+	}
+
+	var rec ast.TypeValue = &ast.RecordType{Fields: fields}
+	values = append(values, &ExistentialVariable{Identifier: alpha, Value: &rec})
+	return labels, c.Insert(&ExistentialVariable{alpha, nil}, values)
+}
+
+// Solve an existential variable to a recursive type. The existential
+// variables of the recursive type declared after it are solved to
+// fresh existential variables inserted before it
+func (c Context) articulateMu(alpha ast.UniqueIdentifier, mt *ast.MuType) Context {
+	exv := &ExistentialVariable{alpha, nil}
+	_, rightc := c.SplitAt(exv)
+	older := rightc.Drop(exv)
+
+	var t ast.TypeValue = c.Apply(mt)
+	values := []ContextValue{}
+	for _, beta := range FreeExistentials(t) {
+		betat := &ast.ExistsType{Identifier: beta}
+		if older.IsWellFormed(betat) {
+			continue
+		}
+		var gammat ast.TypeValue = &ast.ExistsType{Identifier: ast.GenUID("α")}
+		c = c.Insert(&ExistentialVariable{beta, nil}, []ContextValue{&ExistentialVariable{beta, &gammat}})
+		values = append([]ContextValue{&ExistentialVariable{gammat.(*ast.ExistsType).Identifier, nil}}, values...)
+		t = Substitution(t, gammat, beta)
+	}
+
+	values = append(values, &ExistentialVariable{Identifier: alpha, Value: &t})
+	return c.Insert(exv, values)
+}
+
+// Solve an existential variable to a data type applied to fresh
+// existential variables inserted before it
+func (c Context) articulateData(alpha ast.UniqueIdentifier, dt *ast.DataType) ([]ast.UniqueIdentifier, Context) {
+	ids := make([]ast.UniqueIdentifier, len(dt.Args))
+	args := make([]ast.TypeValue, len(dt.Args))
+	values := []ContextValue{}
+	for i := range dt.Args {
+		ids[i] = ast.GenUID("α")
+		args[i] = &ast.ExistsType{Identifier: ids[i]}
+		values = append([]ContextValue{&ExistentialVariable{ids[i], nil}}, values...)
+	}
+
+	var data ast.TypeValue = &ast.DataType{Identifier: dt.Identifier, Args: args}
+	values = append(values, &ExistentialVariable{Identifier: alpha, Value: &data})
+	return ids, c.Insert(&ExistentialVariable{alpha, nil}, values)
+}
+
+// Solve an existential variable to a tuple type of n elements
+// whose types are fresh existential variables inserted before it
+func (c Context) articulateTuple(alpha ast.UniqueIdentifier, n int) ([]ast.UniqueIdentifier, Context) {
+	ids := make([]ast.UniqueIdentifier, n)
+	elems := make([]ast.TypeValue, n)
+	values := []ContextValue{}
+	for i := range ids {
+		ids[i] = ast.GenUID("α")
+		elems[i] = &ast.ExistsType{Identifier: ids[i]}
+		values = append([]ContextValue{&ExistentialVariable{ids[i], nil}}, values...)
+	}
+
+	var tuple ast.TypeValue = &ast.TupleType{Elements: elems}
+	values = append(values, &ExistentialVariable{Identifier: alpha, Value: &tuple})
+	return ids, c.Insert(&ExistentialVariable{alpha, nil}, values)
+}
+
+// Solve an existential variable to a list type whose elements
+// have the type of a fresh existential variable inserted before it
+func (c Context) articulateList(alpha ast.UniqueIdentifier) (ast.UniqueIdentifier, Context) {
+	elem := ast.GenUID("α")
+	var list ast.TypeValue = &ast.ListType{Element: &ast.ExistsType{Identifier: elem}}
+	return elem, c.Insert(&ExistentialVariable{alpha, nil}, []ContextValue{
+		&ExistentialVariable{elem, nil},
+		&ExistentialVariable{Identifier: alpha, Value: &list},
+	})
+}
+
+// Solve an existential variable to an array type whose size and
+// elements have the types of fresh existential variables
+// inserted before it
+func (c Context) articulateArray(alpha ast.UniqueIdentifier) (ast.UniqueIdentifier, ast.UniqueIdentifier, Context) {
+	size := ast.GenUID("α")
+	elem := ast.GenUID("α")
+	var array ast.TypeValue = &ast.ArrayType{
+		Size:    &ast.ExistsType{Identifier: size},
+		Element: &ast.ExistsType{Identifier: elem},
+	}
+	return size, elem, c.Insert(&ExistentialVariable{alpha, nil}, []ContextValue{
+		&ExistentialVariable{elem, nil},
+		&ExistentialVariable{size, nil},
+		&ExistentialVariable{Identifier: alpha, Value: &array},
+	})
+}
+
+// Solve an existential variable to a matrix type of the density
+// and numbers of a given matrix type, whose dimensions are fresh
+// existential variables inserted before it
+func (c Context) articulateMatrix(alpha ast.UniqueIdentifier, mt *ast.MatrixType) (ast.UniqueIdentifier, ast.UniqueIdentifier, Context) {
+	rows := ast.GenUID("α")
+	cols := ast.GenUID("α")
+	var matrix ast.TypeValue = &ast.MatrixType{
+		Sparse:  mt.Sparse,
+		Complex: mt.Complex,
+		Rows:    &ast.ExistsType{Identifier: rows},
+		Cols:    &ast.ExistsType{Identifier: cols},
+	}
+	return rows, cols, c.Insert(&ExistentialVariable{alpha, nil}, []ContextValue{
+		&ExistentialVariable{cols, nil},
+		&ExistentialVariable{rows, nil},
+		&ExistentialVariable{Identifier: alpha, Value: &matrix},
+	})
+}
+
+// Solve an existential variable to the union of two fresh
+// existential variables inserted before it
+func (c Context) articulateUnion(alpha ast.UniqueIdentifier) (ast.UniqueIdentifier, ast.UniqueIdentifier, Context) {
+	left := ast.GenUID("α")
+	right := ast.GenUID("α")
+	var union ast.TypeValue = &ast.UnionType{
+		Left:  &ast.ExistsType{Identifier: left},
+		Right: &ast.ExistsType{Identifier: right},
+	}
+	return left, right, c.Insert(&ExistentialVariable{alpha, nil}, []ContextValue{
+		&ExistentialVariable{right, nil},
+		&ExistentialVariable{left, nil},
+		&ExistentialVariable{Identifier: alpha, Value: &union},
+	})
+}
@@ -0,0 +1,479 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"sort"
+)
+
+// This file contains the typing rules of interfaces and instances.
+// Overloaded values have constrained types, such as ∀(a: Show).a -> string.
+// Using one adds to the context a constraint on the existential
+// variable instantiating a, that is resolved when the type is known by
+// finding the instance of the interface for the type. The dictionary of
+// the instance, a record of its methods, is passed to the value. Let
+// bindings generalize the constraints on their type variables: the
+// generalized value takes the dictionaries as parameters. Eq and Ord
+// have no methods, their instances are derived from the structure of
+// types: values can be compared if they do not contain functions.
+
+// Returns true if the instances of an interface are derived
+// from the structure of types
+func isDerived(name string) bool {
+	return name == token.IEQ || name == token.IORD
+}
+
+// Returns the number of outermost quantifiers of a type to
+// instantiate to get rid of its constraints, 0 if there are none
+func constrainedPrefix(t ast.TypeValue) int {
+	n := 0
+	for i := 1; ; i++ {
+		fty, ok := t.(*ast.ForAllType)
+		if !ok {
+			return n
+		}
+		if len(fty.Constraints) > 0 {
+			n = i
+		}
+		t = fty.Type
+	}
+}
+
+// Returns true if a type has constrained type variables
+func isConstrained(t ast.TypeValue) bool {
+	switch vt := t.(type) {
+	case *ast.ForAllType:
+		return len(vt.Constraints) > 0 || isConstrained(vt.Type)
+	case *ast.LambdaType:
+		return isConstrained(vt.Domain) || isConstrained(vt.Codomain)
+	case *ast.RecordType:
+		for _, ft := range vt.Fields {
+			if isConstrained(ft) {
+				return true
+			}
+		}
+	case *ast.DataType:
+		for _, at := range vt.Args {
+			if isConstrained(at) {
+				return true
+			}
+		}
+	case *ast.TupleType:
+		for _, et := range vt.Elements {
+			if isConstrained(et) {
+				return true
+			}
+		}
+	case *ast.ListType:
+		return isConstrained(vt.Element)
+	case *ast.ArrayType:
+		return isConstrained(vt.Element)
+	case *ast.UnionType:
+		return isConstrained(vt.Left) || isConstrained(vt.Right)
+	case *ast.MuType:
+		return isConstrained(vt.Type)
+	}
+	return false
+}
+
+// Instantiate the constrained quantifiers of a type with new existential
+// variables, adding their constraints to the context. Returns the
+// placeholders of the dictionaries to pass to the value, in the order
+// of the quantifiers and of the constraints. Interfaces without
+// methods have no dictionary
+func (c Context) instantiateConstraints(t ast.TypeValue) (ast.TypeValue, []ast.UniqueIdentifier, Context) {
+	var dicts []ast.UniqueIdentifier
+	for i := constrainedPrefix(t); i > 0; i-- {
+		fty := t.(*ast.ForAllType)
+		alpha := ast.GenUID("α")
+		alphaext := &ast.ExistsType{Identifier: alpha}
+		c = c.InsertHead(&ExistentialVariable{Identifier: alpha})
+		for _, name := range fty.Constraints {
+			k := &InterfaceConstraint{Interface: name, Type: alphaext}
+			if len(c.GetInterfaceDefinition(name).Methods) > 0 {
+				placeholder := ast.GenUID("dict")
+				k.Dictionary = &placeholder
+				dicts = append(dicts, placeholder)
+			}
+			c = c.InsertHead(k)
+		}
+		t = Substitution(fty.Type, alphaext, fty.Identifier)
+	}
+	return t, dicts, c
+}
+
+// Derive the instance of Eq or Ord for a type from its structure.
+// Returns the constraints on the existential variables of the type,
+// and false if the type does not implement the interface. The data
+// types and recursive types in seen are the ones being derived, that
+// are assumed to implement the interface in their recursive occurrences
+func (c Context) deriveInstance(name string, t ast.TypeValue, seen map[ast.UniqueIdentifier]bool) ([]*InterfaceConstraint, bool) {
+	if c.GetInstance(name, t) != nil {
+		return nil, true
+	}
+	switch vt := t.(type) {
+	case *ast.ExistsType:
+		return []*InterfaceConstraint{{Interface: name, Type: vt}}, true
+	case *ast.VariableType:
+		// Nominal types are compared like the types they wrap
+		if def := c.GetNewtypeDefinition(vt.Identifier); def != nil {
+			return c.deriveInstance(name, def.Type, seen)
+		}
+		if name == token.IORD {
+			for _, ot := range ast.OrderedTypes {
+				if ot.Identifier == vt.Identifier {
+					return nil, true
+				}
+			}
+			return nil, false
+		}
+		pt, ok := ast.DefaultVariableTypes[vt.Identifier.Value]
+		return nil, ok && pt.Identifier == vt.Identifier
+	case *ast.ForAllType:
+		// Values of polymorphic types, such as [], do not depend
+		// on the type variable, that can be any comparable type
+		return c.deriveInstance(name, Substitution(vt.Type, ast.TINT, vt.Identifier), seen)
+	}
+	// Only primitive types are ordered
+	if name == token.IORD {
+		return nil, false
+	}
+
+	var components []ast.TypeValue
+	switch vt := t.(type) {
+	case *ast.UnitType, *ast.MatrixType:
+		return nil, true
+	case *ast.RecordType:
+		for _, label := range vt.Labels() {
+			components = append(components, vt.Fields[label])
+		}
+	case *ast.TupleType:
+		components = vt.Elements
+	case *ast.ListType:
+		components = []ast.TypeValue{vt.Element}
+	case *ast.ArrayType:
+		components = []ast.TypeValue{vt.Element}
+	case *ast.UnionType:
+		components = []ast.TypeValue{vt.Left, vt.Right}
+	case *ast.MuType:
+		// The recursive occurrences are
+		// compared by the instance being derived
+		if seen[vt.Identifier] {
+			return nil, true
+		}
+		seen[vt.Identifier] = true
+		defer delete(seen, vt.Identifier)
+		components = []ast.TypeValue{Unfold(vt)}
+	case *ast.DataType:
+		def := c.GetTypeDefinition(vt.Identifier)
+		if def == nil {
+			return nil, false
+		}
+		if seen[def.Identifier] {
+			return nil, true
+		}
+		seen[def.Identifier] = true
+		defer delete(seen, def.Identifier)
+		for _, ctor := range def.Constructors {
+			for _, arg := range ctor.Args {
+				for i, param := range def.Params {
+					arg = Substitution(arg, vt.Args[i], param)
+				}
+				components = append(components, arg)
+			}
+		}
+	default:
+		// Functions cannot be compared
+		return nil, false
+	}
+
+	residual := []*InterfaceConstraint{}
+	for _, ct := range components {
+		r, ok := c.deriveInstance(name, c.Apply(ct), seen)
+		if !ok {
+			return nil, false
+		}
+		residual = append(residual, r...)
+	}
+	return residual, true
+}
+
+// Find the instance resolving a constraint. Returns the dictionary
+// of the instance, and the constraints that are left on existential
+// variables. A constraint on an existential variable is left as is
+func (c Context) findInstance(k *InterfaceConstraint) (*ast.UniqueIdentifier, []*InterfaceConstraint, error) {
+	t := c.Apply(k.Type)
+	if _, ok := t.(*ast.ExistsType); ok {
+		return nil, []*InterfaceConstraint{{Interface: k.Interface, Type: t, Dictionary: k.Dictionary}}, nil
+	}
+	if inst := c.GetInstance(k.Interface, t); inst != nil {
+		return &inst.Dictionary, nil, nil
+	}
+	// The number types implement Num in every context
+	if k.Interface == token.INUM && towerRank(t) >= 0 {
+		dict := ast.InstanceIdentifier(token.INUM, t)
+		return &dict, nil, nil
+	}
+	if isDerived(k.Interface) {
+		if residual, ok := c.deriveInstance(k.Interface, t, map[ast.UniqueIdentifier]bool{}); ok {
+			return nil, residual, nil
+		}
+	}
+	return nil, nil, c.instanceError(k.Interface, t)
+}
+
+// Resolve the constraints of the part of a context introduced by a value.
+// The constraints on the existential variables in keep are removed from
+// the context and returned, to be generalized. The ones on existential
+// variables of the enclosing scopes are left in the context until the
+// variables are known. The remaining ones are ambiguous: they are
+// discharged if the interface has no methods, otherwise the type
+// defaults to int when int implements the interface
+func (c Context) solveConstraints(introduced Context, keep map[ast.UniqueIdentifier]bool) (Context, map[ast.UniqueIdentifier][]*InterfaceConstraint, error) {
+	c.debugRule("Resolve")
+
+	theta := c
+	deferred := map[ast.UniqueIdentifier][]*InterfaceConstraint{}
+	worklist := introduced.GetConstraints()
+	for len(worklist) > 0 {
+		k := worklist[0]
+		worklist = worklist[1:]
+		theta = theta.Drop(k)
+
+		dict, residual, err := theta.findInstance(k)
+		if err != nil {
+			c.debugRuleFail("Resolve")
+			return c, nil, err
+		}
+		if dict != nil && k.Dictionary != nil {
+			c.elab.resolve(*k.Dictionary, *dict, theta.Apply(k.Type))
+		}
+
+		for _, r := range residual {
+			alpha := r.Type.(*ast.ExistsType).Identifier
+			switch {
+			case keep[alpha]:
+				deferred[alpha] = append(deferred[alpha], r)
+			case !introduced.HasExistentialVariable(alpha):
+				theta = theta.InsertHead(r)
+			case r.Dictionary == nil:
+				// Nothing to pass to the value
+			case r.Interface == token.INUM || theta.GetInstance(r.Interface, ast.TINT) != nil:
+				var tint ast.TypeValue = ast.TINT
+				theta = theta.Insert(&ExistentialVariable{Identifier: alpha}, []ContextValue{
+					&ExistentialVariable{Identifier: alpha, Value: &tint},
+				})
+				worklist = append(worklist, r)
+			default:
+				c.debugRuleFail("Resolve")
+				return c, nil, c.ambiguityError(r)
+			}
+		}
+	}
+
+	theta.debugRuleOut("Resolve")
+	return theta, deferred, nil
+}
+
+// Resolve all the constraints left in a context, at the end of a program
+func (c Context) solveAllConstraints() (Context, error) {
+	theta, _, err := c.solveConstraints(c, nil)
+	return theta, err
+}
+
+// Get the interfaces of the generalized constraints on an existential
+// variable, sorted by name. The dictionaries of the constraints are
+// new parameters, one for every interface with methods
+func (c Context) constraintParams(ks []*InterfaceConstraint) ([]string, []ast.UniqueIdentifier) {
+	byName := map[string][]*InterfaceConstraint{}
+	for _, k := range ks {
+		byName[k.Interface] = append(byName[k.Interface], k)
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := []ast.UniqueIdentifier{}
+	for _, name := range names {
+		var param *ast.UniqueIdentifier
+		for _, k := range byName[name] {
+			if k.Dictionary == nil {
+				continue
+			}
+			if param == nil {
+				uid := ast.GenUID("dict")
+				param = &uid
+				params = append(params, uid)
+			}
+			c.elab.solve(*k.Dictionary, *param)
+		}
+	}
+	return names, params
+}
+
+// Wrap a value in functions taking the dictionaries as parameters
+func dictionaryFunction(value ast.Expression, params []ast.UniqueIdentifier) ast.Expression {
+	for i := len(params) - 1; i >= 0; i-- {
+		value = &ast.FunctionLiteral{
+			Token: token.Token{Type: token.LAMBDA, Literal: "fun"},
+			Param: &ast.IdentifierExpr{Identifier: params[i]},
+			Body:  value,
+		}
+	}
+	return value
+}
+
+// Rule AnnoI. A value annotated with a constrained type is checked
+// with local instances of the interfaces for the type variables, that
+// are bound to the dictionaries taken by the value. The annotation is
+// then used like an overloaded value
+func (c Context) synthConstrainedAnno(exp *ast.AnnotExpr) (ast.TypeValue, Context, error) {
+	c.debugRule("AnnoI")
+
+	marker := &Marker{Identifier: ast.GenUID("anno")}
+	gamma := c.InsertHead(marker)
+	locals := []*InstanceDefinition{}
+	params := []ast.UniqueIdentifier{}
+	for i, t := constrainedPrefix(exp.Type), exp.Type; i > 0; i-- {
+		fty := t.(*ast.ForAllType)
+		for _, name := range fty.Constraints {
+			inst := &InstanceDefinition{
+				Interface:  name,
+				Type:       &ast.VariableType{Identifier: fty.Identifier},
+				Dictionary: ast.GenUID("dict"),
+			}
+			if len(c.GetInterfaceDefinition(name).Methods) > 0 {
+				params = append(params, inst.Dictionary)
+			}
+			locals = append(locals, inst)
+			gamma = gamma.InsertHead(inst)
+		}
+		t = fty.Type
+	}
+
+	theta, err := gamma.CheckAgainst(exp.Body, exp.Type)
+	if err != nil {
+		c.debugRuleFail("AnnoI")
+		return nil, c, err
+	}
+	introduced, _ := theta.SplitAt(marker)
+	delta, _, err := theta.solveConstraints(introduced, nil)
+	if err != nil {
+		c.debugRuleFail("AnnoI")
+		return nil, c, err
+	}
+	for _, inst := range locals {
+		delta = delta.Drop(inst)
+	}
+	delta = delta.Drop(marker)
+
+	t, dicts, delta := delta.instantiateConstraints(exp.Type)
+	c.elab.take(exp.Body, params)
+	c.elab.pass(exp, dicts)
+
+	delta.debugRuleOut("AnnoI")
+	return t, delta, nil
+}
+
+// Extend the context with the declaration of an interface and the
+// types of its methods. Methods are overloaded values, their types are
+// constrained by the interface and must mention its type variable
+func (c Context) synthInterfaceStatement(stmt *ast.InterfaceStatement) (ast.TypeValue, Context, error) {
+	c.debugRule("Interface")
+
+	if c.GetInterfaceDefinition(stmt.Name) != nil {
+		c.debugRuleFail("Interface")
+		return nil, c, c.duplicateInterfaceError(stmt)
+	}
+	theta := c.InsertHead(&InterfaceDefinition{Interface: stmt})
+	for _, m := range stmt.Methods {
+		mt, err := c.ExpandAliases(m.Type)
+		if err != nil {
+			c.debugRuleFail("Interface")
+			return nil, c, err
+		}
+		m.Type = mt
+		t := stmt.MethodType(m)
+		if !OccursIn(stmt.Param, m.Type) || isConstrained(m.Type) || !theta.IsWellFormed(t) {
+			c.debugRuleFail("Interface")
+			return nil, c, c.interfaceMethodError(stmt, m)
+		}
+		theta = theta.InsertHead(&TypeAnnotation{Identifier: m.Name.Identifier, Value: t})
+	}
+
+	theta.debugRuleOut("Interface")
+	return &ast.UnitType{}, theta, nil
+}
+
+// Extend the context with an instance of an interface for a type
+// without type variables. The methods of the instance are checked
+// against the types of the methods of the interface, they can use
+// the instance itself
+func (c Context) synthInstanceStatement(stmt *ast.InstanceStatement) (ast.TypeValue, Context, error) {
+	c.debugRule("Instance")
+
+	iface := c.GetInterfaceDefinition(stmt.Interface)
+	t, err := c.ExpandAliases(stmt.Type)
+	if err != nil {
+		c.debugRuleFail("Instance")
+		return nil, c, err
+	}
+	stmt.Type = t
+	switch {
+	case iface == nil:
+		c.debugRuleFail("Instance")
+		return nil, c, c.unknownInterfaceError(stmt.Interface)
+	case isDerived(stmt.Interface):
+		c.debugRuleFail("Instance")
+		return nil, c, c.derivedInstanceError(stmt)
+	case !stmt.Type.IsMonotype() || !c.IsWellFormed(stmt.Type) || len(FreeExistentials(stmt.Type)) > 0:
+		c.debugRuleFail("Instance")
+		return nil, c, c.instanceTypeError(stmt)
+	case c.GetInstance(stmt.Interface, stmt.Type) != nil:
+		c.debugRuleFail("Instance")
+		return nil, c, c.duplicateInstanceError(stmt)
+	}
+
+	defined := map[string]bool{}
+	for _, m := range stmt.Methods {
+		name := m.Name.Identifier.Value
+		if iface.Method(name) == nil {
+			c.debugRuleFail("Instance")
+			return nil, c, c.unknownMethodError(stmt, name)
+		}
+		defined[name] = true
+	}
+	for _, m := range iface.Methods {
+		if !defined[m.Name.Identifier.Value] {
+			c.debugRuleFail("Instance")
+			return nil, c, c.missingMethodError(stmt, m.Name.Identifier.Value)
+		}
+	}
+
+	theta := c.InsertHead(&InstanceDefinition{
+		Interface:  stmt.Interface,
+		Type:       stmt.Type,
+		Dictionary: stmt.Identifier,
+	})
+	for _, m := range stmt.Methods {
+		decl := iface.Method(m.Name.Identifier.Value)
+		marker := &Marker{Identifier: ast.GenUID("instance")}
+		delta, err := theta.InsertHead(marker).CheckAgainst(m.Value, Substitution(decl.Type, stmt.Type, iface.Param))
+		if err != nil {
+			c.debugRuleFail("Instance")
+			return nil, c, err
+		}
+		introduced, _ := delta.SplitAt(marker)
+		delta, _, err = delta.solveConstraints(introduced, nil)
+		if err != nil {
+			c.debugRuleFail("Instance")
+			return nil, c, err
+		}
+		theta = delta.Drop(marker)
+	}
+
+	theta.debugRuleOut("Instance")
+	return &ast.UnitType{}, theta, nil
+}
@@ -0,0 +1,454 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of let bindings. The types of
+// let-bound values are generalized (let-polymorphism), so that
+// `let id = fun(x) {x} in (id(1); id(true))` is well typed.
+
+// Synthesize the type of a let-bound value and generalize it. A marker
+// is inserted before synthesizing, so that only the unsolved existential
+// variables introduced by the value are turned into universally
+// quantified type variables. Existential variables of the enclosing
+// scopes stay monomorphic, and so do the introduced ones they reach. The constraints on the generalized variables
+// become constraints of the quantifiers, the value takes the dictionaries
+// of the instances as the returned parameters. The part of the context
+// introduced by the value is dropped with the marker
+func (c Context) generalize(value ast.Expression) (ast.TypeValue, []ast.UniqueIdentifier, Context, error) {
+	c.debugRule("Gen")
+
+	marker := &Marker{Identifier: ast.GenUID("let")}
+	gamma := c.InsertHead(marker)
+	t, delta, err := gamma.SynthesizesTo(value)
+	if err != nil {
+		c.debugRuleFail("Gen")
+		return nil, nil, c, err
+	}
+	introduced := delta.generalizable(marker)
+	delta, deferred, err := delta.solveConstraints(introduced, introducedExistentials(delta.Apply(t), introduced))
+	if err != nil {
+		c.debugRuleFail("Gen")
+		return nil, nil, c, err
+	}
+	t, params := delta.quantify(delta.Apply(t), introduced, deferred)
+
+	delta = delta.dropScope(marker, nil)
+	delta.debugRuleOut("Gen")
+	return t, params, delta, nil
+}
+
+// Synthesize the type of the expression of a top level statement.
+// Its constrained existential variables are generalized like the ones
+// of a let-bound value, instead of being resolved at the end of the
+// program: the expression takes the dictionaries as parameters. The
+// other existential variables are left in the type
+func (c Context) generalizeConstraints(exp ast.Expression) (ast.TypeValue, Context, error) {
+	c.debugRule("GenConstraints")
+
+	marker := &Marker{Identifier: ast.GenUID("stmt")}
+	gamma := c.InsertHead(marker)
+	t, delta, err := gamma.SynthesizesTo(exp)
+	if err != nil {
+		c.debugRuleFail("GenConstraints")
+		return nil, c, err
+	}
+	introduced := delta.generalizable(marker)
+	delta, deferred, err := delta.solveConstraints(introduced, introducedExistentials(delta.Apply(t), introduced))
+	if err != nil {
+		c.debugRuleFail("GenConstraints")
+		return nil, c, err
+	}
+	constrained := NewContext()
+	for alpha := range deferred {
+		constrained.Contents = append(constrained.Contents, &ExistentialVariable{Identifier: alpha})
+	}
+	t, params := delta.quantify(delta.Apply(t), *constrained, deferred)
+	c.elab.take(exp, params)
+
+	delta = delta.dropScope(marker, nil)
+	delta.debugRuleOut("GenConstraints")
+	return t, delta, nil
+}
+
+// Get the part of a context introduced after a marker, without the
+// existential variables that the rest of the context still reaches.
+// In `fun(x) { let f = fun(z) { x } in f }` the type of x may be solved
+// to an existential variable introduced by the value of f, that must
+// not be generalized
+func (c Context) generalizable(marker *Marker) Context {
+	introduced, outer := c.SplitAt(marker)
+	for alpha := range introduced.reachedExistentials(outer, nil, nil) {
+		introduced = introduced.Drop(&ExistentialVariable{Identifier: alpha})
+	}
+	return introduced
+}
+
+// Drop a marker and the part of a context introduced after it. The
+// constraints on existential variables of the enclosing scopes, that
+// are left after the marker until the variables are known, are kept.
+// So are the existential variables of the part that are still reached
+// by the rest of the context or by the constraints, and the ones in
+// keep, that stay monomorphic in the types of the names bound by the scope
+func (c Context) dropScope(marker *Marker, keep map[ast.UniqueIdentifier]bool) Context {
+	introduced, delta := c.SplitAt(marker)
+	delta = delta.Drop(marker)
+	ks := introduced.GetConstraints()
+	reached := introduced.reachedExistentials(delta, ks, keep)
+	for i := len(introduced.Contents) - 1; i >= 0; i-- {
+		if v, ok := introduced.Contents[i].(*ExistentialVariable); ok && reached[v.Identifier] {
+			delta = delta.InsertHead(v)
+		}
+	}
+	for i := len(ks) - 1; i >= 0; i-- {
+		delta = delta.InsertHead(ks[i])
+	}
+	return delta
+}
+
+// Get the existential variables of a part of a context that occur in
+// the types of another context, of the constraints or in keep, and the
+// ones occurring in their solutions
+func (c Context) reachedExistentials(other Context, ks []*InterfaceConstraint, keep map[ast.UniqueIdentifier]bool) map[ast.UniqueIdentifier]bool {
+	worklist := []ast.UniqueIdentifier{}
+	for alpha := range keep {
+		worklist = append(worklist, alpha)
+	}
+	for _, v := range other.Contents {
+		switch vv := v.(type) {
+		case *TypeAnnotation:
+			worklist = append(worklist, FreeExistentials(vv.Value)...)
+		case *ExistentialVariable:
+			if vv.solved() {
+				worklist = append(worklist, FreeExistentials(*vv.Value)...)
+			}
+		case *EffectScope:
+			worklist = append(worklist, FreeExistentials(vv.Effects)...)
+		}
+	}
+	for _, k := range ks {
+		worklist = append(worklist, FreeExistentials(k.Type)...)
+	}
+
+	res := map[ast.UniqueIdentifier]bool{}
+	for len(worklist) > 0 {
+		alpha := worklist[0]
+		worklist = worklist[1:]
+		if res[alpha] {
+			continue
+		}
+		if c.HasExistentialVariable(alpha) {
+			res[alpha] = true
+		} else if t := c.GetSolvedVariable(alpha); t != nil {
+			res[alpha] = true
+			worklist = append(worklist, FreeExistentials(*t)...)
+		}
+	}
+	return res
+}
+
+// Get the existential variables whose constraints were deferred
+func monomorphic(deferred map[ast.UniqueIdentifier][]*InterfaceConstraint) map[ast.UniqueIdentifier]bool {
+	res := map[ast.UniqueIdentifier]bool{}
+	for alpha := range deferred {
+		res[alpha] = true
+	}
+	return res
+}
+
+// Get the existential variables of a type that were
+// introduced in a given part of a context
+func introducedExistentials(t ast.TypeValue, introduced Context) map[ast.UniqueIdentifier]bool {
+	res := map[ast.UniqueIdentifier]bool{}
+	for _, alpha := range FreeExistentials(t) {
+		if introduced.HasExistentialVariable(alpha) {
+			res[alpha] = true
+		}
+	}
+	return res
+}
+
+// Turn the unsolved existential variables of a type that were
+// introduced in a given part of a context into universally quantified
+// type variables, constrained by the interfaces in constraints. Quantify
+// in reverse order, so that the outermost quantifier binds the existential
+// variable occurring first in the type. Rows that are only performed by
+// the function are closed instead. Returns the parameters of the
+// dictionaries of the constraints, in the order of the quantifiers
+func (c Context) quantify(t ast.TypeValue, introduced Context, constraints map[ast.UniqueIdentifier][]*InterfaceConstraint) (ast.TypeValue, []ast.UniqueIdentifier) {
+	params := []ast.UniqueIdentifier{}
+	free := FreeExistentials(t)
+	for i := len(free) - 1; i >= 0; i-- {
+		alpha := free[i]
+		if !introduced.HasExistentialVariable(alpha) {
+			continue
+		}
+		if isLatentRow(alpha, t) {
+			t = Substitution(t, ast.NewEffectRow(nil, nil), alpha)
+			continue
+		}
+		beta := ast.GenUID(alpha.Value)
+		names, dicts := c.constraintParams(constraints[alpha])
+		params = append(dicts, params...)
+		t = &ast.ForAllType{
+			Identifier:  beta,
+			Constraints: names,
+			Type:        Substitution(t, &ast.VariableType{Identifier: beta}, alpha),
+		}
+	}
+	return t, params
+}
+
+// Synthesize the type of a value taken apart by a pattern, as in
+// `let (x, y) = v`, and generalize the types of the variables of the
+// pattern. Returns their annotations, that are not in the context.
+// Variables of the pattern are not overloaded: the existential variables
+// with constraints stay monomorphic, their constraints are resolved later.
+// Patterns that are not exhaustive perform fail
+func (c Context) generalizePattern(p ast.Pattern, value ast.Expression) ([]*TypeAnnotation, Context, error) {
+	c.debugRule("GenPattern")
+
+	marker := &Marker{Identifier: ast.GenUID("let")}
+	gamma := c.InsertHead(marker)
+	t, theta, err := gamma.synthScrutinee(value)
+	if err != nil {
+		c.debugRuleFail("GenPattern")
+		return nil, c, err
+	}
+	delta, pannots, err := theta.CheckPattern(p, theta.Apply(t))
+	if err != nil {
+		c.debugRuleFail("GenPattern")
+		return nil, c, err
+	}
+	if !irrefutable(p) {
+		delta = delta.performFail()
+	}
+
+	introduced := delta.generalizable(marker)
+	keep := map[ast.UniqueIdentifier]bool{}
+	for _, annot := range pannots {
+		for alpha := range introducedExistentials(delta.Apply(annot.Value), introduced) {
+			keep[alpha] = true
+		}
+	}
+	delta, deferred, err := delta.solveConstraints(introduced, keep)
+	if err != nil {
+		c.debugRuleFail("GenPattern")
+		return nil, c, err
+	}
+	for alpha, ks := range deferred {
+		introduced = introduced.Drop(&ExistentialVariable{Identifier: alpha})
+		for _, k := range ks {
+			delta = delta.InsertHead(k)
+		}
+	}
+
+	annots := make([]*TypeAnnotation, len(pannots))
+	for i, annot := range pannots {
+		delta = delta.Drop(annot)
+		t, _ := delta.quantify(delta.Apply(annot.Value), introduced, nil)
+		annots[i] = &TypeAnnotation{
+			Identifier: annot.Identifier,
+			Value:      t,
+		}
+	}
+
+	delta = delta.dropScope(marker, monomorphic(deferred))
+	delta.debugRuleOut("GenPattern")
+	return annots, delta, nil
+}
+
+// Synthesize the types of a group of mutually recursive functions,
+// then generalize them. The names of the functions are bound in the
+// values to existential variables, so that the functions are monomorphic
+// in the group. Like the variables of patterns, the functions are
+// not overloaded: the existential variables with constraints stay
+// monomorphic, their constraints are resolved later
+func (c Context) generalizeGroup(asss []*ast.Assignment) ([]*TypeAnnotation, Context, error) {
+	c.debugRule("GenGroup")
+
+	marker := &Marker{Identifier: ast.GenUID("let")}
+	gamma := c.InsertHead(marker)
+	recs := make([]*TypeAnnotation, len(asss))
+	for i, ass := range asss {
+		alpha := ast.GenUID(ass.Name.Identifier.Value)
+		gamma = gamma.InsertHead(&ExistentialVariable{Identifier: alpha})
+		recs[i] = &TypeAnnotation{
+			Identifier: ass.Name.Identifier,
+			Value:      &ast.ExistsType{Identifier: alpha},
+		}
+	}
+	for _, rec := range recs {
+		gamma = gamma.InsertHead(rec)
+	}
+	for i, ass := range asss {
+		t, theta, err := gamma.SynthesizesTo(ass.Value)
+		if err != nil {
+			c.debugRuleFail("GenGroup")
+			return nil, c, err
+		}
+		gamma, err = theta.Subtype(theta.Apply(t), theta.Apply(recs[i].Value))
+		if err != nil {
+			c.debugRuleFail("GenGroup")
+			return nil, c, err
+		}
+	}
+
+	delta := gamma
+	for _, rec := range recs {
+		delta = delta.Drop(rec)
+	}
+	introduced := delta.generalizable(marker)
+	keep := map[ast.UniqueIdentifier]bool{}
+	for _, rec := range recs {
+		for alpha := range introducedExistentials(delta.Apply(rec.Value), introduced) {
+			keep[alpha] = true
+		}
+	}
+	delta, deferred, err := delta.solveConstraints(introduced, keep)
+	if err != nil {
+		c.debugRuleFail("GenGroup")
+		return nil, c, err
+	}
+	for alpha, ks := range deferred {
+		introduced = introduced.Drop(&ExistentialVariable{Identifier: alpha})
+		for _, k := range ks {
+			delta = delta.InsertHead(k)
+		}
+	}
+
+	annots := make([]*TypeAnnotation, len(recs))
+	for i, rec := range recs {
+		t, _ := delta.quantify(delta.Apply(rec.Value), introduced, nil)
+		annots[i] = &TypeAnnotation{
+			Identifier: rec.Identifier,
+			Value:      t,
+		}
+	}
+
+	delta = delta.dropScope(marker, monomorphic(deferred))
+	delta.debugRuleOut("GenGroup")
+	return annots, delta, nil
+}
+
+// Synthesize and generalize the types of the values of a list of
+// assignments, then extend the context with the annotations of the
+// names and of the variables of the patterns. The names are not
+// visible in the values, but the ones of mutually recursive functions.
+// Overloaded values take the dictionaries of their instances as
+// parameters.
+func (c Context) bindAssignments(asss []*ast.Assignment) (Context, []*TypeAnnotation, error) {
+	theta := c
+	annots := make([]*TypeAnnotation, 0, len(asss))
+	group := ast.RecursiveGroup(asss)
+	if len(group) > 0 {
+		gannots, delta, err := theta.generalizeGroup(group)
+		if err != nil {
+			return c, nil, err
+		}
+		annots = append(annots, gannots...)
+		theta = delta
+	}
+	for _, ass := range asss {
+		if len(group) > 0 && ass.Pattern == nil && ass.RecursiveFunction() != nil {
+			continue
+		}
+		if ass.Pattern != nil {
+			pannots, delta, err := theta.generalizePattern(ass.Pattern, ass.Value)
+			if err != nil {
+				return c, nil, err
+			}
+			annots = append(annots, pannots...)
+			theta = delta
+			continue
+		}
+		t, params, delta, err := theta.generalize(ass.Value)
+		if err != nil {
+			return c, nil, err
+		}
+		c.elab.take(ass.Value, params)
+		annots = append(annots, &TypeAnnotation{
+			Identifier: ass.Name.Identifier,
+			Value:      t,
+		})
+		theta = delta
+	}
+
+	for _, annot := range annots {
+		theta = theta.InsertHead(annot)
+	}
+	return theta, annots, nil
+}
+
+// Rule let=>
+func (c Context) synthLet(exp *ast.LetExpression) (ast.TypeValue, Context, error) {
+	c.debugRule("let=>")
+
+	gamma, annots, err := c.bindAssignments(exp.Assignments)
+	if err != nil {
+		c.debugRuleFail("let=>")
+		return nil, c, err
+	}
+	t, delta, err := gamma.SynthesizesTo(exp.Body)
+	if err != nil {
+		c.debugRuleFail("let=>")
+		return nil, c, err
+	}
+	for _, annot := range annots {
+		delta = delta.Drop(annot)
+	}
+
+	delta.debugRuleOut("let=>")
+	return t, delta, nil
+}
+
+// Rule let<=
+func (c Context) checkLet(exp *ast.LetExpression, ty ast.TypeValue) (Context, error) {
+	c.debugRule("let<=")
+
+	gamma, annots, err := c.bindAssignments(exp.Assignments)
+	if err != nil {
+		c.debugRuleFail("let<=")
+		return c, err
+	}
+	delta, err := gamma.CheckAgainst(exp.Body, ty)
+	if err != nil {
+		c.debugRuleFail("let<=")
+		return c, err
+	}
+	for _, annot := range annots {
+		delta = delta.Drop(annot)
+	}
+
+	delta.debugRuleOut("let<=")
+	return delta, nil
+}
+
+// Rule Redex=>. The immediate application of a function literal binds
+// the parameter to the type of the argument, like a let binding
+// without generalization. The type of the argument is then known
+// in the body. Functions with an annotated parameter are applied
+// by rule ->E, the argument is checked against the annotation
+func (c Context) synthRedex(fn *ast.FunctionLiteral, arg ast.Expression) (ast.TypeValue, Context, error) {
+	c.debugRule("Redex=>")
+
+	a, theta, err := c.SynthesizesTo(arg)
+	if err != nil {
+		c.debugRuleFail("Redex=>")
+		return nil, c, err
+	}
+	annot := &TypeAnnotation{
+		Identifier: fn.Param.Identifier,
+		Value:      theta.Apply(a),
+	}
+	t, delta, err := theta.InsertHead(annot).SynthesizesTo(fn.Body)
+	if err != nil {
+		c.debugRuleFail("Redex=>")
+		return nil, c, err
+	}
+
+	delta = delta.Drop(annot)
+	delta.debugRuleOut("Redex=>")
+	return t, delta, nil
+}
@@ -0,0 +1,195 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of list literals
+// and of the list operators :: and ++
+
+// Rule List=>. The types of the elements are joined as the types of
+// the branches of an if expression. The empty list is a list of
+// elements of a fresh existential type
+func (c Context) synthList(exp *ast.ListLiteral) (ast.TypeValue, Context, error) {
+	c.debugRule("List=>")
+
+	if len(exp.Elements) == 0 {
+		alpha := ast.GenUID("α")
+		delta := c.InsertHead(&ExistentialVariable{Identifier: alpha})
+		delta.debugRuleOut("List=>")
+		return &ast.ListType{Element: &ast.ExistsType{Identifier: alpha}}, delta, nil
+	}
+
+	elemt, theta, err := c.SynthesizesTo(exp.Elements[0])
+	if err != nil {
+		c.debugRuleFail("List=>")
+		return nil, c, err
+	}
+	elemts := []ast.TypeValue{elemt}
+	for _, e := range exp.Elements[1:] {
+		t, delta, err := theta.SynthesizesTo(e)
+		if err != nil {
+			c.debugRuleFail("List=>")
+			return nil, c, err
+		}
+		elemt, theta = delta.join(delta.Apply(elemt), delta.Apply(t))
+		elemts = append(elemts, t)
+	}
+	for i, t := range elemts {
+		theta.narrow(exp.Elements[i], t, elemt)
+	}
+
+	list := theta.Apply(&ast.ListType{Element: elemt})
+	theta.debugRuleOut("List=>")
+	return list, theta, nil
+}
+
+// Rule List<=. Every element is checked against the type of the
+// elements of the list type. When the type of the elements is not
+// known yet, checking would solve it to the type of the first element:
+// the list is synthesized instead, joining the types of its elements
+func (c Context) checkList(exp *ast.ListLiteral, ty *ast.ListType) (Context, error) {
+	if _, ok := c.Apply(ty.Element).(*ast.ExistsType); ok && len(exp.Elements) > 0 {
+		t, theta, err := c.synthList(exp)
+		if err != nil {
+			return c, err
+		}
+		return theta.Subtype(theta.Apply(t), theta.Apply(ty))
+	}
+	c.debugRule("List<=")
+
+	theta := c
+	for _, e := range exp.Elements {
+		var err error
+		theta, err = theta.CheckAgainst(e, theta.Apply(ty.Element))
+		if err != nil {
+			c.debugRuleFail("List<=")
+			return c, err
+		}
+	}
+
+	theta.debugRuleOut("List<=")
+	return theta, nil
+}
+
+// Rule Array<=. A list literal is an array of the size of the
+// list literal, every element is checked against the type of the
+// elements of the array type
+func (c Context) checkArray(exp *ast.ListLiteral, ty *ast.ArrayType) (Context, error) {
+	c.debugRule("Array<=")
+
+	size := &ast.DimensionType{Size: int64(len(exp.Elements))}
+	gamma, err := c.Subtype(size, c.Apply(ty.Size))
+	if err != nil {
+		c.debugRuleFail("Array<=")
+		return c, c.arraySizeError(exp, ty)
+	}
+	delta, err := gamma.checkList(exp, &ast.ListType{Element: ty.Element})
+	if err != nil {
+		c.debugRuleFail("Array<=")
+		return c, err
+	}
+
+	delta.debugRuleOut("Array<=")
+	return delta, nil
+}
+
+// Rule ::=>. The right operand is a list, the type of its elements
+// is joined with the type of the left operand
+func (c Context) synthCons(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	c.debugRule("::=>")
+
+	elemt, theta, err := c.listElement(exp.Right, rightt)
+	if err != nil {
+		c.debugRuleFail("::=>")
+		return nil, c, err
+	}
+	t, delta := theta.join(theta.Apply(leftt), elemt)
+	delta.narrow(exp.Left, leftt, t)
+	delta.narrow(exp.Right, rightt, &ast.ListType{Element: t})
+
+	list := delta.Apply(&ast.ListType{Element: t})
+	delta.debugRuleOut("::=>")
+	return list, delta, nil
+}
+
+// Rule ++=>. Both operands are lists, the types
+// of their elements are joined
+func (c Context) synthConcat(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	c.debugRule("++=>")
+
+	lelemt, gamma, err := c.listElement(exp.Left, leftt)
+	if err != nil {
+		c.debugRuleFail("++=>")
+		return nil, c, err
+	}
+	relemt, theta, err := gamma.listElement(exp.Right, gamma.Apply(rightt))
+	if err != nil {
+		c.debugRuleFail("++=>")
+		return nil, c, err
+	}
+	t, delta := theta.join(theta.Apply(lelemt), relemt)
+	delta.narrow(exp.Left, leftt, &ast.ListType{Element: t})
+	delta.narrow(exp.Right, rightt, &ast.ListType{Element: t})
+
+	list := delta.Apply(&ast.ListType{Element: t})
+	delta.debugRuleOut("++=>")
+	return list, delta, nil
+}
+
+// Get the type of the elements of the list type of an expression.
+// If the type is not known yet, it is solved to a list of elements
+// of a fresh existential type
+func (c Context) listElement(exp ast.Expression, t ast.TypeValue) (ast.TypeValue, Context, error) {
+	beta := ast.GenUID("β")
+	gamma := c.InsertHead(&ExistentialVariable{Identifier: beta})
+	delta, err := gamma.Subtype(t, &ast.ListType{Element: &ast.ExistsType{Identifier: beta}})
+	if err != nil {
+		return nil, c, c.notAListError(exp, t)
+	}
+	return delta.Apply(&ast.ExistsType{Identifier: beta}), delta, nil
+}
+
+// Join the types of two values that can be the value of the same
+// expression: the type that is a supertype of the other one, the
+// join of two function types, or the union of the two types
+func (c Context) join(a, b ast.TypeValue) (ast.TypeValue, Context) {
+	if delta, err := c.Subtype(a, b); err == nil {
+		return delta.Apply(b), delta
+	}
+	if delta, err := c.Subtype(b, a); err == nil {
+		return delta.Apply(a), delta
+	}
+	if t, delta, ok := c.joinFunctions(a, b); ok {
+		return t, delta
+	}
+	return ast.NewUnionType(a, b), c
+}
+
+// Join two function types that differ in their effects: the function
+// type from the smaller domain to the join of the codomains, that
+// performs the effects of both functions. Its history is a choice
+// between their histories. Returns false if the types are not
+// function types or if their domains are not comparable
+func (c Context) joinFunctions(a, b ast.TypeValue) (ast.TypeValue, Context, bool) {
+	fa, ok := a.(*ast.LambdaType)
+	if !ok {
+		return nil, c, false
+	}
+	fb, ok := b.(*ast.LambdaType)
+	if !ok {
+		return nil, c, false
+	}
+	domain := fa.Domain
+	theta, err := c.Subtype(fa.Domain, fb.Domain)
+	if err != nil {
+		if theta, err = c.Subtype(fb.Domain, fa.Domain); err != nil {
+			return nil, c, false
+		}
+		domain = fb.Domain
+	}
+	codomain, delta := theta.join(theta.Apply(fa.Codomain), theta.Apply(fb.Codomain))
+	ra, rb := fa.Row().Map(delta.Apply), fb.Row().Map(delta.Apply)
+	effects := ra.Union(rb).WithHistory(ast.NewChoiceHistory(ra.History, rb.History))
+	return delta.Apply(&ast.LambdaType{Domain: domain, Codomain: codomain, Effects: effects}), delta, true
+}
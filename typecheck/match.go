@@ -0,0 +1,293 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of match expressions. Patterns
+// are checked against the type of the matched value, and extend the
+// context with the annotations of the variables they bind
+
+// Replace the outermost universally quantified type variables of a
+// type with new existential variables, added to the context
+func (c Context) instantiateForAll(t ast.TypeValue) (ast.TypeValue, Context) {
+	for {
+		fty, ok := t.(*ast.ForAllType)
+		if !ok {
+			return t, c
+		}
+		alpha := ast.GenUID("α")
+		c = c.InsertHead(&ExistentialVariable{Identifier: alpha})
+		t = Substitution(fty.Type, &ast.ExistsType{Identifier: alpha}, fty.Identifier)
+	}
+}
+
+// Synthesize the type of the matched value of a match expression.
+// Polymorphic values are instantiated
+func (c Context) synthScrutinee(exp ast.Expression) (ast.TypeValue, Context, error) {
+	t, theta, err := c.SynthesizesTo(exp)
+	if err != nil {
+		return nil, c, err
+	}
+	t, theta = theta.instantiateForAll(theta.Apply(t))
+	return t, theta, nil
+}
+
+// Rule Match=>. Like the branches of an if expression, the type of
+// the match is the type of an arm that is a supertype of the others.
+// Matches that are not exhaustive perform fail
+func (c Context) synthMatch(exp *ast.MatchExpr) (ast.TypeValue, Context, error) {
+	c.debugRule("Match=>")
+
+	a, theta, err := c.synthScrutinee(exp.Scrutinee)
+	if err != nil {
+		c.debugRuleFail("Match=>")
+		return nil, c, err
+	}
+	h0, theta := theta.takeHistory()
+	histories := []ast.History{}
+	armts := []ast.TypeValue{}
+
+	var t ast.TypeValue
+	for _, arm := range exp.Arms {
+		gamma, annots, err := theta.CheckPattern(arm.Pattern, theta.Apply(a))
+		if err != nil {
+			c.debugRuleFail("Match=>")
+			return nil, c, err
+		}
+		armt, delta, err := gamma.SynthesizesTo(arm.Body)
+		if err != nil {
+			c.debugRuleFail("Match=>")
+			return nil, c, err
+		}
+		for _, annot := range annots {
+			delta = delta.Drop(annot)
+		}
+		h, delta := delta.takeHistory()
+		histories = append(histories, h)
+		armts = append(armts, armt)
+
+		if t == nil {
+			t, theta = armt, delta
+			continue
+		}
+		if theta, err = delta.Subtype(delta.Apply(armt), delta.Apply(t)); err == nil {
+			continue
+		}
+		if theta, err = delta.Subtype(delta.Apply(t), delta.Apply(armt)); err == nil {
+			t = armt
+			continue
+		}
+		c.debugRuleFail("Match=>")
+		return nil, c, c.expectedSameTypeMatchArms(delta.Apply(t), delta.Apply(armt))
+	}
+
+	for i, armt := range armts {
+		theta.narrow(exp.Arms[i].Body, armt, t)
+	}
+	theta = theta.joinHistories(h0, histories)
+	if !exhaustive(exp) {
+		theta = theta.performFail()
+	}
+	theta.debugRuleOut("Match=>")
+	return t, theta, nil
+}
+
+// Rule Match<=
+func (c Context) checkMatch(exp *ast.MatchExpr, ty ast.TypeValue) (Context, error) {
+	c.debugRule("Match<=")
+
+	a, theta, err := c.synthScrutinee(exp.Scrutinee)
+	if err != nil {
+		c.debugRuleFail("Match<=")
+		return c, err
+	}
+	h0, theta := theta.takeHistory()
+	histories := []ast.History{}
+
+	for _, arm := range exp.Arms {
+		gamma, annots, err := theta.CheckPattern(arm.Pattern, theta.Apply(a))
+		if err != nil {
+			c.debugRuleFail("Match<=")
+			return c, err
+		}
+		delta, err := gamma.CheckAgainst(arm.Body, gamma.Apply(ty))
+		if err != nil {
+			c.debugRuleFail("Match<=")
+			return c, err
+		}
+		for _, annot := range annots {
+			delta = delta.Drop(annot)
+		}
+		h, delta := delta.takeHistory()
+		histories = append(histories, h)
+		theta = delta
+	}
+
+	theta = theta.joinHistories(h0, histories)
+	if !exhaustive(exp) {
+		theta = theta.performFail()
+	}
+	theta.debugRuleOut("Match<=")
+	return theta, nil
+}
+
+// Check that a pattern can match values of type a. Returns the context
+// extended with the annotations of the variables bound by the pattern,
+// that are also returned so that they can be dropped out of their scope
+func (c Context) CheckPattern(p ast.Pattern, a ast.TypeValue) (Context, []*TypeAnnotation, error) {
+	if mt, ok := a.(*ast.MuType); ok {
+		// Patterns other than variables match the
+		// values of a recursive type by its unfolding
+		if _, ok := p.(*ast.VariablePattern); !ok {
+			return c.CheckPattern(p, Unfold(mt))
+		}
+	}
+	switch vp := p.(type) {
+	case *ast.WildcardPattern:
+		return c, []*TypeAnnotation{}, nil
+
+	case *ast.VariablePattern:
+		annot := &TypeAnnotation{Identifier: vp.Identifier, Value: a}
+		return c.InsertHead(annot), []*TypeAnnotation{annot}, nil
+
+	case *ast.LiteralPattern:
+		// The literal must have the same type as the matched value
+		t, theta, err := c.SynthesizesTo(vp.Value)
+		if err != nil {
+			return c, nil, err
+		}
+		theta, err = theta.Subtype(theta.Apply(t), theta.Apply(a))
+		if err == nil {
+			theta, err = theta.Subtype(theta.Apply(a), theta.Apply(t))
+		}
+		if err != nil {
+			return c, nil, c.patternTypeError(p, a)
+		}
+		return theta, []*TypeAnnotation{}, nil
+
+	case *ast.RecordPattern:
+		if ext, ok := a.(*ast.ExistsType); ok && c.HasExistentialVariable(ext.Identifier) {
+			// The matched value is a record with the fields of the pattern
+			fields := make(map[string]ast.TypeValue, len(vp.Fields))
+			for _, f := range vp.Fields {
+				fields[f.Label] = &ast.UnitType{}
+			}
+			_, theta := c.articulateRecord(ext.Identifier, &ast.RecordType{Fields: fields})
+			return theta.CheckPattern(p, theta.Apply(a))
+		}
+		rt, ok := a.(*ast.RecordType)
+		if !ok {
+			return c, nil, c.patternTypeError(p, a)
+		}
+		theta := c
+		annots := []*TypeAnnotation{}
+		for _, f := range vp.Fields {
+			ft, ok := rt.Fields[f.Label]
+			if !ok {
+				return c, nil, c.missingFieldError(rt, f.Label)
+			}
+			var fannots []*TypeAnnotation
+			var err error
+			theta, fannots, err = theta.CheckPattern(f.Pattern, theta.Apply(ft))
+			if err != nil {
+				return c, nil, err
+			}
+			annots = append(annots, fannots...)
+		}
+		return theta, annots, nil
+
+	case *ast.TuplePattern:
+		if ext, ok := a.(*ast.ExistsType); ok && c.HasExistentialVariable(ext.Identifier) {
+			// The matched value is a tuple of the length of the pattern
+			_, theta := c.articulateTuple(ext.Identifier, len(vp.Elements))
+			return theta.CheckPattern(p, theta.Apply(a))
+		}
+		tt, ok := a.(*ast.TupleType)
+		if !ok || len(tt.Elements) != len(vp.Elements) {
+			return c, nil, c.patternTypeError(p, a)
+		}
+		theta := c
+		annots := []*TypeAnnotation{}
+		for i, e := range vp.Elements {
+			var eannots []*TypeAnnotation
+			var err error
+			theta, eannots, err = theta.CheckPattern(e, theta.Apply(tt.Elements[i]))
+			if err != nil {
+				return c, nil, err
+			}
+			annots = append(annots, eannots...)
+		}
+		return theta, annots, nil
+
+	case *ast.EmptyListPattern, *ast.ConsPattern:
+		if ext, ok := a.(*ast.ExistsType); ok && c.HasExistentialVariable(ext.Identifier) {
+			_, theta := c.articulateList(ext.Identifier)
+			return theta.CheckPattern(p, theta.Apply(a))
+		}
+		lt, ok := a.(*ast.ListType)
+		if !ok {
+			return c, nil, c.patternTypeError(p, a)
+		}
+		cp, ok := vp.(*ast.ConsPattern)
+		if !ok {
+			return c, []*TypeAnnotation{}, nil
+		}
+		theta, annots, err := c.CheckPattern(cp.Head, lt.Element)
+		if err != nil {
+			return c, nil, err
+		}
+		theta, tannots, err := theta.CheckPattern(cp.Tail, theta.Apply(lt))
+		if err != nil {
+			return c, nil, err
+		}
+		return theta, append(annots, tannots...), nil
+
+	case *ast.ConstructorPattern:
+		return c.checkConstructorPattern(vp, a)
+	}
+
+	return c, nil, c.patternTypeError(p, a)
+}
+
+// The parameters of the signature of the constructor are instantiated
+// with new existential variables, then the data type built by the
+// constructor is unified with the type of the matched value, so
+// that the types of the arguments are known when they are checked
+func (c Context) checkConstructorPattern(p *ast.ConstructorPattern, a ast.TypeValue) (Context, []*TypeAnnotation, error) {
+	annot := c.GetAnnotation(p.Constructor.Identifier)
+	if annot == nil {
+		return c, nil, c.notInContextError(p.Constructor.Identifier)
+	}
+
+	t, theta := c.instantiateForAll(*annot)
+
+	args := []ast.TypeValue{}
+	for {
+		lty, ok := t.(*ast.LambdaType)
+		if !ok {
+			break
+		}
+		args = append(args, lty.Domain)
+		t = lty.Codomain
+	}
+	if len(args) != len(p.Args) {
+		return c, nil, c.constructorArityError(p, len(args))
+	}
+
+	theta, err := theta.Subtype(t, a)
+	if err != nil {
+		return c, nil, c.patternTypeError(p, a)
+	}
+
+	annots := []*TypeAnnotation{}
+	for i, arg := range p.Args {
+		var argannots []*TypeAnnotation
+		theta, argannots, err = theta.CheckPattern(arg, theta.Apply(args[i]))
+		if err != nil {
+			return c, nil, err
+		}
+		annots = append(annots, argannots...)
+	}
+	return theta, annots, nil
+}
@@ -0,0 +1,227 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of matrix literals and of the
+// operators on matrices. A matrix of complex numbers is obtained as
+// soon as one of the operands holds complex numbers. Scalars are
+// broadcast: they are combined with every element of a matrix.
+// The dimensions of the operands are checked when their size is
+// known, dimension variables are solved as existential variables
+
+// Returns true if an infix operator is applied to a matrix,
+// and must be typed by the rules of this file
+func isMatrixOperation(leftt, rightt ast.TypeValue) bool {
+	_, lok := leftt.(*ast.MatrixType)
+	_, rok := rightt.(*ast.MatrixType)
+	return lok || rok
+}
+
+// Check that an expression is a number that can be stored in a
+// matrix, an integer is converted to a float. Returns true if the
+// number is complex
+func (c Context) scalarKind(exp ast.Expression, t ast.TypeValue) (bool, Context, error) {
+	if delta, err := c.Subtype(t, ast.TFLOAT); err == nil {
+		delta.narrow(exp, t, ast.TFLOAT)
+		return false, delta, nil
+	}
+	if delta, err := c.Subtype(t, ast.TCOMPLEX); err == nil {
+		delta.narrow(exp, t, ast.TCOMPLEX)
+		return true, delta, nil
+	}
+	return false, c, c.notANumberError(exp, t)
+}
+
+// Unify two dimensions of the operands of a matrix operator
+func (c Context) unifyDimensions(a, b ast.TypeValue) (Context, bool) {
+	delta, err := c.Subtype(c.Apply(a), c.Apply(b))
+	return delta, err == nil
+}
+
+// Rule Matrix=>. The elements of a matrix literal are numbers,
+// the matrix holds complex numbers if one of them is complex
+func (c Context) synthMatrix(exp *ast.MatrixLiteral) (ast.TypeValue, Context, error) {
+	c.debugRule("Matrix=>")
+
+	mt := &ast.MatrixType{
+		Sparse: exp.Sparse,
+		Rows:   &ast.DimensionType{Size: int64(len(exp.Rows))},
+		Cols:   &ast.DimensionType{Size: int64(len(exp.Rows[0]))},
+	}
+	theta := c
+	for _, row := range exp.Rows {
+		for _, e := range row {
+			t, delta, err := theta.SynthesizesTo(e)
+			if err != nil {
+				c.debugRuleFail("Matrix=>")
+				return nil, c, err
+			}
+			complex, delta, err := delta.scalarKind(e, delta.Apply(t))
+			if err != nil {
+				c.debugRuleFail("Matrix=>")
+				return nil, c, err
+			}
+			mt.Complex = mt.Complex || complex
+			theta = delta
+		}
+	}
+
+	theta.debugRuleOut("Matrix=>")
+	return mt, theta, nil
+}
+
+// Split the operands of an operator between a matrix and a scalar.
+// Returns the matrix type, the scalar operand and its type, or
+// false if both operands are matrices
+func splitScalar(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (*ast.MatrixType, ast.Expression, ast.TypeValue, bool) {
+	lm, lok := leftt.(*ast.MatrixType)
+	rm, rok := rightt.(*ast.MatrixType)
+	switch {
+	case lok && rok:
+		return nil, nil, nil, false
+	case lok:
+		return lm, exp.Right, rightt, true
+	}
+	return rm, exp.Left, leftt, true
+}
+
+// Rules Matrix+Matrix=> and Matrix+Scalar=>, for + and -. The sum of
+// two sparse matrices is sparse. Two matrices of known size have
+// the same dimensions, the sum of matrices of unknown size performs
+// fail. Adding a scalar to every element of a matrix
+// gives a dense matrix
+func (c Context) synthMatrixSum(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	m, scalar, scalart, ok := splitScalar(exp, leftt, rightt)
+	if !ok {
+		c.debugRule("Matrix+Matrix=>")
+		l, r := leftt.(*ast.MatrixType), rightt.(*ast.MatrixType)
+		mt := &ast.MatrixType{Sparse: l.Sparse && r.Sparse, Complex: l.Complex || r.Complex}
+		if !l.IsSized() || !r.IsSized() {
+			c.debugRuleOut("Matrix+Matrix=>")
+			return mt, c.performFail(), nil
+		}
+		gamma, rok := c.unifyDimensions(l.Rows, r.Rows)
+		delta, cok := gamma.unifyDimensions(l.Cols, r.Cols)
+		if !rok || !cok {
+			c.debugRuleFail("Matrix+Matrix=>")
+			return nil, c, c.dimensionMismatchError(exp, leftt, rightt)
+		}
+		mt.Rows, mt.Cols = delta.Apply(l.Rows), delta.Apply(l.Cols)
+		delta.debugRuleOut("Matrix+Matrix=>")
+		return mt, delta, nil
+	}
+
+	c.debugRule("Matrix+Scalar=>")
+	complex, delta, err := c.scalarKind(scalar, scalart)
+	if err != nil {
+		c.debugRuleFail("Matrix+Scalar=>")
+		return nil, c, c.matrixOperandsError(exp, leftt, rightt)
+	}
+	delta.debugRuleOut("Matrix+Scalar=>")
+	return &ast.MatrixType{Sparse: false, Complex: m.Complex || complex, Rows: m.Rows, Cols: m.Cols}, delta, nil
+}
+
+// Rules Matrix*Matrix=> and Matrix*Scalar=>. The product of two
+// sparse matrices is sparse. When the size of the matrices is known,
+// the columns of the left matrix are the rows of the right one: the
+// product of a matrix(m, n) and a matrix(n, p) is a matrix(m, p),
+// the product of matrices of unknown size performs fail.
+// Multiplying every element of a matrix by a scalar keeps the
+// density of the matrix
+func (c Context) synthMatrixProduct(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	m, scalar, scalart, ok := splitScalar(exp, leftt, rightt)
+	if !ok {
+		c.debugRule("Matrix*Matrix=>")
+		l, r := leftt.(*ast.MatrixType), rightt.(*ast.MatrixType)
+		mt := &ast.MatrixType{Sparse: l.Sparse && r.Sparse, Complex: l.Complex || r.Complex}
+		if !l.IsSized() || !r.IsSized() {
+			c.debugRuleOut("Matrix*Matrix=>")
+			return mt, c.performFail(), nil
+		}
+		delta, ok := c.unifyDimensions(l.Cols, r.Rows)
+		if !ok {
+			c.debugRuleFail("Matrix*Matrix=>")
+			return nil, c, c.dimensionMismatchError(exp, leftt, rightt)
+		}
+		mt.Rows, mt.Cols = delta.Apply(l.Rows), delta.Apply(r.Cols)
+		delta.debugRuleOut("Matrix*Matrix=>")
+		return mt, delta, nil
+	}
+
+	c.debugRule("Matrix*Scalar=>")
+	complex, delta, err := c.scalarKind(scalar, scalart)
+	if err != nil {
+		c.debugRuleFail("Matrix*Scalar=>")
+		return nil, c, c.matrixOperandsError(exp, leftt, rightt)
+	}
+	delta.debugRuleOut("Matrix*Scalar=>")
+	return &ast.MatrixType{Sparse: m.Sparse, Complex: m.Complex || complex, Rows: m.Rows, Cols: m.Cols}, delta, nil
+}
+
+// Rule Matrix/Scalar=>. A matrix can only be divided by a scalar,
+// every element is divided. The density of the matrix is kept
+func (c Context) synthMatrixQuotient(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	c.debugRule("Matrix/Scalar=>")
+
+	m, ok := leftt.(*ast.MatrixType)
+	if _, rok := rightt.(*ast.MatrixType); !ok || rok {
+		c.debugRuleFail("Matrix/Scalar=>")
+		return nil, c, c.matrixOperandsError(exp, leftt, rightt)
+	}
+	complex, delta, err := c.scalarKind(exp.Right, rightt)
+	if err != nil {
+		c.debugRuleFail("Matrix/Scalar=>")
+		return nil, c, c.matrixOperandsError(exp, leftt, rightt)
+	}
+	delta.debugRuleOut("Matrix/Scalar=>")
+	return &ast.MatrixType{Sparse: m.Sparse, Complex: m.Complex || complex, Rows: m.Rows, Cols: m.Cols}, delta, nil
+}
+
+// Rule Matrix@=>. The element of a matrix at a row and a column,
+// m @ (i, j), is a float or a complex number. The access performs
+// fail, the indices may be out of bounds. The types of the operands
+// are already synthesized
+func (c Context) synthMatrixAccess(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	c.debugRule("Matrix@=>")
+
+	m, ok := leftt.(*ast.MatrixType)
+	if !ok {
+		c.debugRuleFail("Matrix@=>")
+		return nil, c, c.notAMatrixError(exp.Left, leftt)
+	}
+	delta, err := c.Subtype(rightt, &ast.TupleType{Elements: []ast.TypeValue{ast.TINT, ast.TINT}})
+	if err != nil {
+		c.debugRuleFail("Matrix@=>")
+		return nil, c, err
+	}
+
+	delta = delta.performFail()
+	delta.debugRuleOut("Matrix@=>")
+	if m.Complex {
+		return ast.TCOMPLEX, delta, nil
+	}
+	return ast.TFLOAT, delta, nil
+}
+
+// Rule Matrix'=>. The transpose of a matrix has the type of the
+// matrix, with the number of rows and columns swapped
+func (c Context) synthTranspose(exp *ast.PostfixExpression) (ast.TypeValue, Context, error) {
+	c.debugRule("Matrix'=>")
+
+	t, delta, err := c.SynthesizesTo(exp.Left)
+	if err != nil {
+		c.debugRuleFail("Matrix'=>")
+		return nil, c, err
+	}
+	t = delta.Apply(t)
+	m, ok := t.(*ast.MatrixType)
+	if !ok {
+		c.debugRuleFail("Matrix'=>")
+		return nil, c, c.notAMatrixError(exp.Left, t)
+	}
+
+	delta.debugRuleOut("Matrix'=>")
+	return &ast.MatrixType{Sparse: m.Sparse, Complex: m.Complex, Rows: m.Cols, Cols: m.Rows}, delta, nil
+}
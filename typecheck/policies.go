@@ -0,0 +1,167 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the verification of usage policies. A policy is
+// a finite automaton over events, the history of a program respects
+// the policy when none of the sequences of events it describes leads
+// the automaton to an offending state. The states reached by a
+// history are computed with the shortest sequence of events reaching
+// them, that is reported when an offending state is reached.
+// Recursive histories μh.H are computed as a fixed point: for every
+// state entering the recursion, the states leaving it are found by
+// iterating over H until no new state is found. Offending states are
+// sinks: the sequences of events leading to them are violations even
+// if the recursion does not terminate.
+
+// The states of a policy reached by the recursive calls of a μh.H
+type recursionSummary struct {
+	// The states entering the recursion, with the events reaching them
+	entries map[string][]string
+	// The states leaving the recursion from each entry, with
+	// the events performed by the recursion
+	exits   map[string]map[string][]string
+	changed bool
+}
+
+type policyChecker struct {
+	policy     *ast.PolicyStatement
+	recursions map[ast.UniqueIdentifier]*recursionSummary
+	violation  []string
+}
+
+// Add a state reached by a sequence of events, keeping
+// the shortest sequence when the state is already reached
+func addState(states map[string][]string, state string, trace []string) bool {
+	old, ok := states[state]
+	if ok && len(old) <= len(trace) {
+		return false
+	}
+	states[state] = trace
+	return !ok
+}
+
+// Record a sequence of events leading to an offending state
+func (pc *policyChecker) violate(trace []string) {
+	if pc.violation == nil || len(trace) < len(pc.violation) {
+		pc.violation = trace
+	}
+}
+
+// Compute the states reached by a history from a set of states
+func (pc *policyChecker) step(h ast.History, states map[string][]string) map[string][]string {
+	switch vh := h.(type) {
+	case *ast.EventHistory:
+		res := map[string][]string{}
+		for state, trace := range states {
+			ntrace := append(append([]string{}, trace...), vh.Name)
+			nstate := pc.policy.Step(state, vh.Name)
+			if pc.policy.IsOffending(nstate) {
+				pc.violate(ntrace)
+				continue
+			}
+			addState(res, nstate, ntrace)
+		}
+		return res
+	case *ast.SeqHistory:
+		return pc.step(vh.Right, pc.step(vh.Left, states))
+	case *ast.ChoiceHistory:
+		res := pc.step(vh.Left, states)
+		for state, trace := range pc.step(vh.Right, states) {
+			addState(res, state, trace)
+		}
+		return res
+	case *ast.RecHistory:
+		rec := &recursionSummary{
+			entries: map[string][]string{},
+			exits:   map[string]map[string][]string{},
+		}
+		pc.recursions[vh.Identifier] = rec
+		pc.call(rec, states)
+		for rec.changed {
+			rec.changed = false
+			for entry, prefix := range copyStates(rec.entries) {
+				if rec.exits[entry] == nil {
+					rec.exits[entry] = map[string][]string{}
+				}
+				for state, trace := range pc.step(vh.Body, map[string][]string{entry: prefix}) {
+					if addState(rec.exits[entry], state, trace[len(prefix):]) {
+						rec.changed = true
+					}
+				}
+			}
+		}
+		return pc.call(rec, states)
+	case *ast.RecVarHistory:
+		return pc.call(pc.recursions[vh.Identifier], states)
+	}
+	// The empty history, and the histories of row
+	// variables that are still unknown
+	return states
+}
+
+// Compute the states reached by a recursive call. New entry
+// states are recorded, their exits are found by the fixed point
+func (pc *policyChecker) call(rec *recursionSummary, states map[string][]string) map[string][]string {
+	res := map[string][]string{}
+	for state, trace := range states {
+		if _, ok := rec.entries[state]; !ok {
+			rec.entries[state] = trace
+			rec.changed = true
+		}
+		for nstate, suffix := range rec.exits[state] {
+			addState(res, nstate, append(append([]string{}, trace...), suffix...))
+		}
+	}
+	return res
+}
+
+func copyStates(states map[string][]string) map[string][]string {
+	res := make(map[string][]string, len(states))
+	for state, trace := range states {
+		res[state] = trace
+	}
+	return res
+}
+
+// Check a history against a policy, starting from a set of states.
+// Returns the states reached by the history and the shortest sequence
+// of events reaching an offending state, nil if the policy is respected
+func CheckPolicy(p *ast.PolicyStatement, h ast.History, states map[string][]string) (map[string][]string, []string) {
+	pc := &policyChecker{policy: p, recursions: map[ast.UniqueIdentifier]*recursionSummary{}}
+	res := pc.step(h, states)
+	return res, pc.violation
+}
+
+// Synthesize the type of a policy declaration, that is unit. Policies
+// cannot be redeclared
+func (c Context) synthPolicyStatement(stmt *ast.PolicyStatement) (ast.TypeValue, Context, error) {
+	if c.GetPolicyDefinition(stmt.Name) != nil {
+		return nil, c, c.duplicatePolicyError(stmt)
+	}
+	def := &PolicyDefinition{
+		Policy: stmt,
+		States: map[string][]string{stmt.Init: {}},
+	}
+	return &ast.UnitType{}, c.InsertHead(def), nil
+}
+
+// Check the history of a program against the policies in the
+// context. Returns the context with the states reached by the program
+func (c Context) checkPolicies(h ast.History) (Context, error) {
+	theta := c
+	for _, v := range c.Contents {
+		def, ok := v.(*PolicyDefinition)
+		if !ok {
+			continue
+		}
+		states, violation := CheckPolicy(def.Policy, h, def.States)
+		if violation != nil {
+			return c, c.policyError(def.Policy, violation)
+		}
+		theta = theta.Insert(def, []ContextValue{&PolicyDefinition{Policy: def.Policy, States: states}})
+	}
+	return theta, nil
+}
@@ -0,0 +1,213 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of record literals
+// and of the projection of their fields
+
+// Rule Record=>
+func (c Context) synthRecord(exp *ast.RecordLiteral) (ast.TypeValue, Context, error) {
+	c.debugRule("Record=>")
+
+	theta := c
+	fields := make(map[string]ast.TypeValue, len(exp.Fields))
+	for _, f := range exp.Fields {
+		t, delta, err := theta.SynthesizesTo(f.Value)
+		if err != nil {
+			c.debugRuleFail("Record=>")
+			return nil, c, err
+		}
+		fields[f.Label] = t
+		theta = delta
+	}
+
+	rec := theta.Apply(&ast.RecordType{Fields: fields})
+	theta.debugRuleOut("Record=>")
+	return rec, theta, nil
+}
+
+// Rule Record<=. The fields of the literal that are in the record
+// type are checked against the type of the field, the others are
+// only synthesized, as allowed by width subtyping, and dropped from
+// the value
+func (c Context) checkRecord(exp *ast.RecordLiteral, ty *ast.RecordType) (Context, error) {
+	c.debugRule("Record<=")
+
+	labels := make(map[string]bool, len(exp.Fields))
+	for _, f := range exp.Fields {
+		labels[f.Label] = true
+	}
+	for _, label := range ty.Labels() {
+		if !labels[label] {
+			c.debugRuleFail("Record<=")
+			return c, c.missingFieldError(ty, label)
+		}
+	}
+
+	theta := c
+	for _, f := range exp.Fields {
+		var err error
+		if ft, ok := ty.Fields[f.Label]; ok {
+			theta, err = theta.CheckAgainst(f.Value, theta.Apply(ft))
+		} else {
+			_, theta, err = theta.SynthesizesTo(f.Value)
+		}
+		if err != nil {
+			c.debugRuleFail("Record<=")
+			return c, err
+		}
+	}
+
+	if len(exp.Fields) > len(ty.Fields) {
+		theta.elab.narrow(exp, theta.shape(theta.Apply(ty), map[string]*ast.Shape{}))
+	}
+	theta.debugRuleOut("Record<=")
+	return theta, nil
+}
+
+// Rule Access=>. A value whose type is still an existential variable
+// is a record with the accessed field only: fun(r) { r.x } has type
+// {x: 'a} -> 'a, and accepts records with more fields by width
+// subtyping. Records are not extensible, accessing another field of
+// r in the same function is an error unless r is annotated
+func (c Context) synthAccess(exp *ast.AccessExpr) (ast.TypeValue, Context, error) {
+	c.debugRule("Access=>")
+
+	t, theta, err := c.SynthesizesTo(exp.Record)
+	if err != nil {
+		c.debugRuleFail("Access=>")
+		return nil, c, err
+	}
+	t = theta.Apply(t)
+
+	// Instantiate polymorphic records, unfold recursive records
+	t, theta = theta.instantiateForAll(t)
+	if mt, ok := t.(*ast.MuType); ok {
+		t = Unfold(mt)
+	}
+
+	if ext, ok := t.(*ast.ExistsType); ok && theta.HasExistentialVariable(ext.Identifier) {
+		labels, delta := theta.articulateRecord(ext.Identifier, &ast.RecordType{
+			Fields: map[string]ast.TypeValue{exp.Field: &ast.UnitType{}},
+		})
+		delta.debugRuleOut("Access=>")
+		return &ast.ExistsType{Identifier: labels[exp.Field]}, delta, nil
+	}
+
+	rt, ok := t.(*ast.RecordType)
+	if !ok {
+		c.debugRuleFail("Access=>")
+		return nil, c, c.notARecordError(exp.Record, t)
+	}
+	ft, ok := rt.Fields[exp.Field]
+	if !ok {
+		c.debugRuleFail("Access=>")
+		return nil, c, c.missingFieldError(rt, exp.Field)
+	}
+
+	theta.debugRuleOut("Access=>")
+	return ft, theta, nil
+}
+
+// Returns true if the values of a type can hold records. The data
+// types and recursive types in seen are the ones being visited
+func (c Context) hasRecords(t ast.TypeValue, seen map[string]bool) bool {
+	if seen[t.FullString()] {
+		return false
+	}
+	switch vt := t.(type) {
+	case *ast.RecordType:
+		return true
+	case *ast.VariableType:
+		if def := c.GetNewtypeDefinition(vt.Identifier); def != nil {
+			return c.hasRecords(def.Type, seen)
+		}
+		return false
+	case *ast.MuType, *ast.DataType:
+		seen[t.FullString()] = true
+	}
+	for _, ct := range c.shapeComponents(t) {
+		if c.hasRecords(c.Apply(ct), seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the types of the components of the values of a type, in the
+// order of their shapes: the unfolding of a recursive type, and the
+// arguments of the constructors of a data type in declaration order
+func (c Context) shapeComponents(t ast.TypeValue) []ast.TypeValue {
+	switch vt := t.(type) {
+	case *ast.TupleType:
+		return vt.Elements
+	case *ast.ListType:
+		return []ast.TypeValue{vt.Element}
+	case *ast.ArrayType:
+		return []ast.TypeValue{vt.Element}
+	case *ast.MuType:
+		return []ast.TypeValue{Unfold(vt)}
+	case *ast.DataType:
+		def := c.GetTypeDefinition(vt.Identifier)
+		if def == nil {
+			return nil
+		}
+		var components []ast.TypeValue
+		for _, ctor := range def.Constructors {
+			for _, arg := range ctor.Args {
+				for i, param := range def.Params {
+					arg = Substitution(arg, vt.Args[i], param)
+				}
+				components = append(components, arg)
+			}
+		}
+		return components
+	}
+	return nil
+}
+
+// Get the shape of the values of a type, nil if they cannot hold
+// records. The shapes of the data types and recursive types in
+// seen are being built, they are the shapes of their occurrences
+func (c Context) shape(t ast.TypeValue, seen map[string]*ast.Shape) *ast.Shape {
+	if s, ok := seen[t.FullString()]; ok {
+		return s
+	}
+	if !c.hasRecords(t, map[string]bool{}) {
+		return nil
+	}
+	s := &ast.Shape{}
+	switch vt := t.(type) {
+	case *ast.RecordType:
+		s.Fields = make(map[string]*ast.Shape, len(vt.Fields))
+		for label, ft := range vt.Fields {
+			s.Fields[label] = c.shape(c.Apply(ft), seen)
+		}
+	case *ast.TupleType:
+		for _, et := range vt.Elements {
+			s.Elements = append(s.Elements, c.shape(c.Apply(et), seen))
+		}
+	case *ast.ListType, *ast.ArrayType:
+		s.Element = c.shape(c.Apply(c.shapeComponents(t)[0]), seen)
+	case *ast.VariableType:
+		return c.shape(c.GetNewtypeDefinition(vt.Identifier).Type, seen)
+	case *ast.MuType:
+		seen[t.FullString()] = s
+		*s = *c.shape(c.Apply(Unfold(vt)), seen)
+	case *ast.DataType:
+		seen[t.FullString()] = s
+		s.Constructors = map[string][]*ast.Shape{}
+		components := c.shapeComponents(t)
+		for _, ctor := range c.GetTypeDefinition(vt.Identifier).Constructors {
+			shapes := make([]*ast.Shape, len(ctor.Args))
+			for i := range ctor.Args {
+				shapes[i] = c.shape(c.Apply(components[0]), seen)
+				components = components[1:]
+			}
+			s.Constructors[ctor.Name.Identifier.Value] = shapes
+		}
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the synthesization of top level statements
+
+// Synthesize the type of a top level statement. The constraints of
+// the type of an expression statement are generalized. Let statements have
+// type unit and return a context extended with the generalized
+// annotations of the names they bind. Type declarations have type
+// unit and extend the context with the new type and its constructors,
+// or with the alias. Newtype declarations have type unit and extend
+// the context with the new type and its wrapping functions.
+// Policy declarations have type unit and extend the context with
+// the policy. Interface and instance declarations have type unit and
+// extend the context with the interface and the types of its methods,
+// or with the instance.
+func (c Context) SynthStatement(stmt ast.Statement) (ast.TypeValue, Context, error) {
+	c.debugSection("statement", stmt.String())
+	switch vs := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return c.generalizeConstraints(vs.Expression)
+	case *ast.LetStatement:
+		theta, _, err := c.bindAssignments(vs.Assignments)
+		if err != nil {
+			return nil, c, err
+		}
+		return &ast.UnitType{}, theta, nil
+	case *ast.TypeStatement:
+		return c.synthTypeStatement(vs)
+	case *ast.AliasStatement:
+		return c.synthAliasStatement(vs)
+	case *ast.NewtypeStatement:
+		return c.synthNewtypeStatement(vs)
+	case *ast.PolicyStatement:
+		return c.synthPolicyStatement(vs)
+	case *ast.InterfaceStatement:
+		return c.synthInterfaceStatement(vs)
+	case *ast.InstanceStatement:
+		return c.synthInstanceStatement(vs)
+	}
+	return nil, c, c.statementError(stmt)
+}
+
+// Synthesize the type of a program, that is the type of its last
+// statement, or unit when the program is empty. The returned context
+// holds the annotations of all the names bound by the program. The
+// history of the program is checked against the policies declared
+// in the context, including the ones declared by the program itself.
+// The constraints left by overloaded values are resolved at the end.
+func (c Context) SynthProgram(p *ast.Program) (ast.TypeValue, Context, error) {
+	t, _, theta, err := c.CheckProgram(p)
+	return t, theta, err
+}
+
+// Synthesize the type of a program like SynthProgram, and return its
+// elaborated copy, where the dictionaries of the instances are passed
+// to overloaded values. The program itself is left unchanged
+func (c Context) CheckProgram(p *ast.Program) (ast.TypeValue, *ast.Program, Context, error) {
+	var t ast.TypeValue = &ast.UnitType{}
+	gamma := c
+	gamma.elab = newElaboration()
+	theta, scope := gamma.openEffectScope()
+	stmts := make([]ast.Statement, len(p.Statements))
+	for i, stmt := range p.Statements {
+		if p.Package != nil && p.Package.Restriction != nil {
+			stmt = restrictStatement(stmt, p.Package.Restriction)
+		}
+		var err error
+		t, theta, err = theta.SynthStatement(stmt)
+		if err != nil {
+			c.debugErr(err)
+			return nil, nil, c, err
+		}
+		stmts[i] = stmt
+	}
+
+	effects, theta := theta.closeEffectScope(scope)
+	theta, err := theta.solveAllConstraints()
+	if err != nil {
+		c.debugErr(err)
+		return nil, nil, c, err
+	}
+	theta, err = theta.checkPolicies(effects.History)
+	if err != nil {
+		c.debugErr(err)
+		return nil, nil, c, err
+	}
+
+	elaborated := &ast.Program{Package: p.Package, Statements: make([]ast.Statement, len(stmts))}
+	for i, stmt := range stmts {
+		elaborated.Statements[i] = theta.elab.statement(stmt)
+	}
+	theta.elab = nil
+	return theta.Apply(t), elaborated, theta, nil
+}
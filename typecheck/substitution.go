@@ -13,9 +13,47 @@ func OccursIn(alpha ast.UniqueIdentifier, a ast.TypeValue) bool {
 	case *ast.ExistsType:
 		return va.Identifier == alpha
 	case *ast.LambdaType:
-		return OccursIn(alpha, va.Domain) || OccursIn(alpha, va.Codomain)
+		return OccursIn(alpha, va.Domain) || OccursIn(alpha, va.Codomain) || OccursIn(alpha, va.Row())
+	case *ast.EffectRow:
+		for _, t := range va.Tails {
+			if OccursIn(alpha, t) {
+				return true
+			}
+		}
+		return false
 	case *ast.ForAllType:
 		return va.Identifier == alpha || OccursIn(alpha, va.Type)
+	case *ast.MuType:
+		return va.Identifier == alpha || OccursIn(alpha, va.Type)
+	case *ast.RecordType:
+		for _, t := range va.Fields {
+			if OccursIn(alpha, t) {
+				return true
+			}
+		}
+		return false
+	case *ast.DataType:
+		for _, t := range va.Args {
+			if OccursIn(alpha, t) {
+				return true
+			}
+		}
+		return false
+	case *ast.TupleType:
+		for _, t := range va.Elements {
+			if OccursIn(alpha, t) {
+				return true
+			}
+		}
+		return false
+	case *ast.ListType:
+		return OccursIn(alpha, va.Element)
+	case *ast.ArrayType:
+		return OccursIn(alpha, va.Size) || OccursIn(alpha, va.Element)
+	case *ast.MatrixType:
+		return va.IsSized() && (OccursIn(alpha, va.Rows) || OccursIn(alpha, va.Cols))
+	case *ast.UnionType:
+		return OccursIn(alpha, va.Left) || OccursIn(alpha, va.Right)
 	default:
 		// Type variables do not occur in monotypes
 		return false
@@ -23,6 +61,63 @@ func OccursIn(alpha ast.UniqueIdentifier, a ast.TypeValue) bool {
 
 }
 
+// Returns the existential variables occurring in a given type, in
+// order of first occurrence
+func FreeExistentials(a ast.TypeValue) []ast.UniqueIdentifier {
+	res := []ast.UniqueIdentifier{}
+	var collect func(a ast.TypeValue)
+	collect = func(a ast.TypeValue) {
+		switch va := a.(type) {
+		case *ast.ExistsType:
+			for _, id := range res {
+				if id == va.Identifier {
+					return
+				}
+			}
+			res = append(res, va.Identifier)
+		case *ast.LambdaType:
+			collect(va.Domain)
+			collect(va.Row())
+			collect(va.Codomain)
+		case *ast.EffectRow:
+			for _, t := range va.Tails {
+				collect(t)
+			}
+		case *ast.ForAllType:
+			collect(va.Type)
+		case *ast.MuType:
+			collect(va.Type)
+		case *ast.RecordType:
+			for _, label := range va.Labels() {
+				collect(va.Fields[label])
+			}
+		case *ast.DataType:
+			for _, t := range va.Args {
+				collect(t)
+			}
+		case *ast.TupleType:
+			for _, t := range va.Elements {
+				collect(t)
+			}
+		case *ast.ListType:
+			collect(va.Element)
+		case *ast.ArrayType:
+			collect(va.Size)
+			collect(va.Element)
+		case *ast.MatrixType:
+			if va.IsSized() {
+				collect(va.Rows)
+				collect(va.Cols)
+			}
+		case *ast.UnionType:
+			collect(va.Left)
+			collect(va.Right)
+		}
+	}
+	collect(a)
+	return res
+}
+
 // TODO document
 func Substitution(a, b ast.TypeValue, alpha ast.UniqueIdentifier) ast.TypeValue {
 	switch va := a.(type) {
@@ -40,18 +135,56 @@ func Substitution(a, b ast.TypeValue, alpha ast.UniqueIdentifier) ast.TypeValue
 		}
 	case *ast.ForAllType:
 		if va.Identifier == alpha {
-			return &ast.ForAllType{va.Identifier, b}
+			return &ast.ForAllType{Identifier: va.Identifier, Constraints: va.Constraints, Type: b}
 		} else {
 			return &ast.ForAllType{
-				Identifier: va.Identifier,
-				Type:       Substitution(va.Type, b, alpha),
+				Identifier:  va.Identifier,
+				Constraints: va.Constraints,
+				Type:        Substitution(va.Type, b, alpha),
 			}
 		}
-	case *ast.LambdaType:
-		return &ast.LambdaType{
-			Domain:   Substitution(va.Domain, b, alpha),
-			Codomain: Substitution(va.Codomain, b, alpha),
+	case *ast.MuType:
+		// The type variable of a recursive type shadows alpha
+		if va.Identifier == alpha {
+			return a
 		}
+		return &ast.MuType{Identifier: va.Identifier, Type: Substitution(va.Type, b, alpha)}
+	case *ast.LambdaType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.EffectRow:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.RecordType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.DataType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.TupleType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.ListType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.ArrayType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.MatrixType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
+	case *ast.UnionType:
+		return va.Map(func(t ast.TypeValue) ast.TypeValue {
+			return Substitution(t, b, alpha)
+		})
 	default:
 		return a
 
@@ -59,6 +192,12 @@ func Substitution(a, b ast.TypeValue, alpha ast.UniqueIdentifier) ast.TypeValue
 
 }
 
+// Unfold a recursive type μt. A into A[μt. A/t], the type
+// of its values once the outermost recursion is opened
+func Unfold(a *ast.MuType) ast.TypeValue {
+	return Substitution(a.Type, a, a.Identifier)
+}
+
 // Apply a context as a substitution to a value
 func (c *Context) Apply(a ast.TypeValue) ast.TypeValue {
 	switch va := a.(type) {
@@ -73,19 +212,55 @@ func (c *Context) Apply(a ast.TypeValue) ast.TypeValue {
 			return ret
 		}
 	case *ast.LambdaType:
-		ret := &ast.LambdaType{
-			Domain:   c.Apply(va.Domain),
-			Codomain: c.Apply(va.Codomain),
-		}
+		ret := va.Map(c.Apply)
 		c.debugSection("apply", a.FullString(), "=", ret.FullString())
 		return ret
 	case *ast.ForAllType:
 		ret := &ast.ForAllType{
-			Identifier: va.Identifier,
-			Type:       c.Apply(va.Type),
+			Identifier:  va.Identifier,
+			Constraints: va.Constraints,
+			Type:        c.Apply(va.Type),
 		}
 		c.debugSection("apply", a.FullString(), "=", ret.FullString())
 		return ret
+	case *ast.MuType:
+		ret := &ast.MuType{Identifier: va.Identifier, Type: c.Apply(va.Type)}
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.RecordType:
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.DataType:
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.TupleType:
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.ListType:
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.ArrayType:
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.MatrixType:
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.EffectRow:
+		// Solved row variables are merged in the row
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
+	case *ast.UnionType:
+		// Sides that become the same type are merged
+		ret := va.Map(c.Apply)
+		c.debugSection("apply", a.FullString(), "=", ret.FullString())
+		return ret
 	}
 	c.debugSection("apply", a.FullString(), "=", a.FullString())
 	return a
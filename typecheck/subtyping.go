@@ -18,6 +18,13 @@ func (c Context) Subtype(a, b ast.TypeValue) (Context, error) {
 	if !c.IsWellFormed(b) {
 		return c, c.malformedError(b)
 	}
+	// Constrained types are instantiated when used
+	if isConstrained(a) {
+		return c, c.constrainedTypeError(a)
+	}
+	if isConstrained(b) {
+		return c, c.constrainedTypeError(b)
+	}
 
 	switch va := a.(type) {
 	case *ast.UnitType:
@@ -81,20 +88,207 @@ func (c Context) Subtype(a, b ast.TypeValue) (Context, error) {
 			return res, nil
 		}
 
+	case *ast.MuType:
+		if vb, ok := b.(*ast.MuType); ok {
+			// Rule <:μ. The bodies of two recursive types are
+			// compared with a new type variable for both. They must
+			// be equivalent, as the type variable can occur in the
+			// domain of a function
+			c.debugRule("<:μ")
+
+			// The errors mention the recursive types, the new type
+			// variable is not bound outside of this rule
+			u := &UniversalVariable{ast.GenUID(va.Identifier.Value)}
+			ut := &ast.VariableType{Identifier: u.Identifier}
+			at := Substitution(va.Type, ut, va.Identifier)
+			bt := Substitution(vb.Type, ut, vb.Identifier)
+			theta, err := c.InsertHead(u).Subtype(at, bt)
+			if err != nil {
+				return c, c.subtypeError(a, b)
+			}
+			theta, err = theta.Subtype(theta.Apply(bt), theta.Apply(at))
+			if err != nil {
+				return c, c.subtypeError(a, b)
+			}
+			return theta.Drop(u), nil
+		}
+		if vb, ok := b.(*ast.ExistsType); ok && !OccursIn(vb.Identifier, a) {
+			// Solved by <:InstantiateR
+			break
+		}
+		// Rule <:Unfold. A recursive type is a subtype
+		// of a type if its unfolding is
+		c.debugRule("<:Unfold")
+
+		return c.Subtype(Unfold(va), b)
+
 	case *ast.LambdaType:
 		switch vb := b.(type) {
 		case *ast.LambdaType:
-			// Rule <:->
+			// Rule <:->. Functions are contravariant in the domain
+			// and covariant in the codomain and in the effects
 			c.debugRule("<:->")
 
-			theta, err := c.Subtype(va.Domain, vb.Domain)
+			theta, err := c.Subtype(vb.Domain, va.Domain)
 			if err != nil {
 				return c, err
 			}
-			return theta.Subtype(theta.Apply(va.Codomain),
+			delta, err := theta.Subtype(theta.Apply(va.Codomain),
 				theta.Apply(vb.Codomain))
+			if err != nil {
+				return c, err
+			}
+			// A function performing less effects can be used
+			// as a function performing more effects
+			return delta.SubEffects(va.Row(), vb.Row())
+		}
+
+	case *ast.DataType:
+		switch vb := b.(type) {
+		case *ast.DataType:
+			// Rule <:Data. Type arguments are invariant
+			c.debugRule("<:Data")
+
+			if va.Identifier != vb.Identifier || len(va.Args) != len(vb.Args) {
+				break
+			}
+			theta := c
+			for i := range va.Args {
+				var err error
+				theta, err = theta.Subtype(theta.Apply(va.Args[i]), theta.Apply(vb.Args[i]))
+				if err != nil {
+					return c, err
+				}
+				theta, err = theta.Subtype(theta.Apply(vb.Args[i]), theta.Apply(va.Args[i]))
+				if err != nil {
+					return c, err
+				}
+			}
+			return theta, nil
+		}
+
+	case *ast.RecordType:
+		switch vb := b.(type) {
+		case *ast.RecordType:
+			// Rule <:Record. Width subtyping: a may have more
+			// fields than b. Depth subtyping: the common fields
+			// are covariant
+			c.debugRule("<:Record")
+
+			theta := c
+			for _, label := range vb.Labels() {
+				at, ok := va.Fields[label]
+				if !ok {
+					return c, c.missingFieldError(a, label)
+				}
+				var err error
+				theta, err = theta.Subtype(theta.Apply(at), theta.Apply(vb.Fields[label]))
+				if err != nil {
+					return c, err
+				}
+			}
+			return theta, nil
+		}
+
+	case *ast.TupleType:
+		switch vb := b.(type) {
+		case *ast.TupleType:
+			// Rule <:Tuple. Tuples of the same length are
+			// covariant in the types of their elements
+			c.debugRule("<:Tuple")
+
+			if len(va.Elements) != len(vb.Elements) {
+				break
+			}
+			theta := c
+			for i := range va.Elements {
+				var err error
+				theta, err = theta.Subtype(theta.Apply(va.Elements[i]), theta.Apply(vb.Elements[i]))
+				if err != nil {
+					return c, err
+				}
+			}
+			return theta, nil
+		}
+
+	case *ast.ListType:
+		switch vb := b.(type) {
+		case *ast.ListType:
+			// Rule <:List. Lists are immutable and covariant
+			// in the type of their elements
+			c.debugRule("<:List")
+
+			return c.Subtype(va.Element, vb.Element)
 		}
 
+	case *ast.ArrayType:
+		switch vb := b.(type) {
+		case *ast.ArrayType:
+			// Rule <:Array. Arrays have the same size and
+			// are covariant in the type of their elements
+			c.debugRule("<:Array")
+
+			theta, err := c.Subtype(va.Size, vb.Size)
+			if err != nil {
+				return c, err
+			}
+			return theta.Subtype(theta.Apply(va.Element), theta.Apply(vb.Element))
+		case *ast.ListType:
+			// Rule <:Array[]. Arrays are lists whose
+			// length is known
+			c.debugRule("<:Array[]")
+
+			return c.Subtype(va.Element, vb.Element)
+		}
+
+	case *ast.DimensionType:
+		if vb, ok := b.(*ast.DimensionType); ok && va.Size == vb.Size {
+			// Rule <:Dim
+			c.debugRule("<:Dim")
+
+			return c, nil
+		}
+
+	case *ast.MatrixType:
+		if vb, ok := b.(*ast.MatrixType); ok && va.Sparse == vb.Sparse && (vb.Complex || !va.Complex) {
+			// Rule <:Matrix. Matrices of floats can be used as
+			// matrices of complex numbers of the same density,
+			// as float <: complex. A matrix of known size can be
+			// used where the size is not known, the dimensions
+			// are the same otherwise
+			c.debugRule("<:Matrix")
+
+			if !vb.IsSized() {
+				return c, nil
+			}
+			if !va.IsSized() {
+				break
+			}
+			theta, err := c.Subtype(va.Rows, vb.Rows)
+			if err == nil {
+				theta, err = theta.Subtype(theta.Apply(va.Cols), theta.Apply(vb.Cols))
+			}
+			if err != nil {
+				return c, c.matrixSubtypeError(c.Apply(a), c.Apply(b))
+			}
+			return theta, nil
+		}
+
+	case *ast.UnionType:
+		if vb, ok := b.(*ast.ExistsType); ok && !OccursIn(vb.Identifier, a) {
+			// Solved by <:InstantiateR
+			break
+		}
+		// Rule <:∪L. A union is a subtype of a type if
+		// both of its sides are
+		c.debugRule("<:∪L")
+
+		theta, err := c.Subtype(va.Left, b)
+		if err != nil {
+			return c, err
+		}
+		return theta.Subtype(theta.Apply(va.Right), theta.Apply(b))
+
 	case *ast.ForAllType:
 		// Rule <:∀L
 		c.debugRule("<:∀L")
@@ -113,6 +307,27 @@ func (c Context) Subtype(a, b ast.TypeValue) (Context, error) {
 
 	}
 
+	if vb, ok := b.(*ast.MuType); ok {
+		// Rule <:Fold. A type is a subtype of a
+		// recursive type if it is a subtype of its unfolding
+		c.debugRule("<:Fold")
+
+		return c.Subtype(a, Unfold(vb))
+	}
+
+	if vb, ok := b.(*ast.UnionType); ok {
+		// Rules <:∪R1 and <:∪R2. A type is a subtype of
+		// a union if it is a subtype of one of its sides
+		c.debugRule("<:∪R1")
+		if theta, err := c.Subtype(a, vb.Left); err == nil {
+			return theta, nil
+		}
+		c.debugRule("<:∪R2")
+		if theta, err := c.Subtype(a, vb.Right); err == nil {
+			return theta, nil
+		}
+	}
+
 	if vb, ok := b.(*ast.ExistsType); ok {
 		if !OccursIn(vb.Identifier, a) {
 			// Rule <:InstantiateR
@@ -130,7 +345,7 @@ func (c Context) Subtype(a, b ast.TypeValue) (Context, error) {
 
 		u := &UniversalVariable{vb.Identifier}
 		theta := c.InsertHead(u)
-		delta, err := theta.Subtype(a, vb)
+		delta, err := theta.Subtype(a, vb.Type)
 		if err != nil {
 			return c, err
 		}
@@ -37,15 +37,18 @@ func (c Context) SynthesizesTo(exp ast.Expression) (ast.TypeValue, Context, erro
 		return ast.NewVariableType("rune"), c, nil
 
 	case *ast.IdentifierExpr:
-		// Rule Var
+		// Rule Var. Overloaded values are instantiated, the
+		// dictionaries of the instances are passed to them
 		c.debugRuleOut("Var")
 		annot := c.GetAnnotation(ve.Identifier)
 		if annot == nil {
 			c.debugRuleFail("Var")
 			return nil, c, c.notInContextError(ve.Identifier)
 		}
-		c.debugRuleOut("Var")
-		return *annot, c, nil
+		t, dicts, theta := c.instantiateConstraints(*annot)
+		c.elab.pass(ve, dicts)
+		theta.debugRuleOut("Var")
+		return t, theta, nil
 	case *ast.IfExpression:
 		// Rules ifthen<:else=> and ifelse<:then=> share the first
 		// 3 premises
@@ -56,16 +59,21 @@ func (c Context) SynthesizesTo(exp ast.Expression) (ast.TypeValue, Context, erro
 			c.debugRuleFail("ifthen<:else=> or ifelse<:then=>")
 			return nil, c, err
 		}
+		// Only one of the branches is evaluated
+		h0, gamma1 := gamma1.takeHistory()
 		thent, theta, err := gamma1.SynthesizesTo(ve.Consequence)
 		if err != nil {
 			c.debugRuleFail("ifthen<:else=> or ifelse<:then=>")
 			return nil, c, err
 		}
+		h1, theta := theta.takeHistory()
 		elset, theta1, err := theta.SynthesizesTo(ve.Alternative)
 		if err != nil {
 			c.debugRuleFail("ifthen<:else=> or ifelse<:then=>")
 			return nil, c, err
 		}
+		h2, theta1 := theta1.takeHistory()
+		theta1 = theta1.joinHistories(h0, []ast.History{h1, h2})
 
 		// Try to see if thent <: elset
 		var delta Context
@@ -74,25 +82,58 @@ func (c Context) SynthesizesTo(exp ast.Expression) (ast.TypeValue, Context, erro
 			// Try other case where elset <: thent
 			delta, err = theta1.Subtype(elset, thent)
 			if err != nil {
-				c.debugRuleFail("ifthen<:else=> or ifelse<:then=>")
-				return nil, c, c.expectedSameTypeIfBranches(thent, elset)
+				// Rule ifthen⊔else=>
+				// The branches are functions performing different
+				// effects, the expression performs either of them
+				if t, delta, ok := theta1.joinFunctions(theta1.Apply(thent), theta1.Apply(elset)); ok {
+					delta.narrow(ve.Consequence, thent, t)
+					delta.narrow(ve.Alternative, elset, t)
+					delta.debugRuleOut("ifthen⊔else=>")
+					return t, delta, nil
+				}
+				// Rule ifthen∪else=>
+				// Neither branch is a subtype of the other, the
+				// expression has the union of their types
+				theta1.debugRuleOut("ifthen∪else=>")
+				return ast.NewUnionType(theta1.Apply(thent), theta1.Apply(elset)), theta1, nil
 			}
 			// Rule ifelse<:then=>
 			// thent is a supertype of elset
+			delta.narrow(ve.Alternative, elset, thent)
 			delta.debugRuleOut("ifelse<:then=>")
 
 			return thent, delta, nil
 		}
 		// Rule ifthen<:else=>
 		// elset is a supertype of thent
+		delta.narrow(ve.Consequence, thent, elset)
 		delta.debugRuleOut("ifthen<:else=>")
 		return elset, delta, nil
 
+	case *ast.LetExpression:
+		return c.synthLet(ve)
+	case *ast.RecordLiteral:
+		return c.synthRecord(ve)
+	case *ast.TupleLiteral:
+		return c.synthTuple(ve)
+	case *ast.ListLiteral:
+		return c.synthList(ve)
+	case *ast.AccessExpr:
+		return c.synthAccess(ve)
+	case *ast.MatchExpr:
+		return c.synthMatch(ve)
 	case *ast.InfixExpression:
 		return c.synthInfixExpr(ve)
 	case *ast.PrefixExpression:
 		return c.synthPrefixExpr(ve)
+	case *ast.PostfixExpression:
+		return c.synthPostfixExpr(ve)
+	case *ast.MatrixLiteral:
+		return c.synthMatrix(ve)
 	case *ast.FunctionLiteral:
+		if ve.ParamType != nil {
+			return c.synthAnnotatedFunction(ve)
+		}
 		// Rule ->l=>
 		c.debugRule("->I=>")
 
@@ -114,53 +155,55 @@ func (c Context) SynthesizesTo(exp ast.Expression) (ast.TypeValue, Context, erro
 			Identifier: ve.Param.Identifier,
 			Value:      alphaext,
 		}
-		gamma := c.InsertHead(annot).InsertHead(betaexv).InsertHead(alphaexv)
-		delta, err := gamma.CheckAgainst(ve.Body, betaext)
+		// The effects of the body are the effects of the function
+		gamma, scope := c.InsertHead(annot).InsertHead(betaexv).InsertHead(alphaexv).openEffectScope()
+		theta, err := gamma.CheckAgainst(ve.Body, betaext)
 		if err != nil {
 			c.debugRuleFail("->I=>")
 			return nil, c, err
 		}
+		effects, delta := theta.closeEffectScope(scope)
 
-		funtype := &ast.LambdaType{Domain: alphaext, Codomain: betaext}
+		funtype := &ast.LambdaType{Domain: alphaext, Codomain: betaext, Effects: effects}
 		deltadrop := delta.Drop(annot)
 		deltadrop.debugRuleOut("->I=>")
 
 		return funtype, deltadrop, nil
 
 	case *ast.FixExpr:
+		if fn, ok := ve.Body.(*ast.FunctionLiteral); ok && fn.ParamType != nil {
+			return c.synthAnnotatedFix(ve, fn)
+		}
 		// Rule fixI=>
+		// The recursive name has the type of the body, so that
+		// recursive calls are typed like the function itself
 		c.debugRule("fixI=>")
 
 		alpha := ast.GenUID("α")
-		beta := ast.GenUID("β")
 		alphaext := &ast.ExistsType{
 			Identifier: alpha,
 		}
-		betaext := &ast.ExistsType{
-			Identifier: beta,
-		}
 		alphaexv := &ExistentialVariable{
 			Identifier: alpha,
 		}
-		betaexv := &ExistentialVariable{
-			Identifier: beta,
-		}
 		annot := &TypeAnnotation{
 			Identifier: ve.Param.Identifier,
 			Value:      alphaext,
 		}
-		gamma := c.InsertHead(annot).InsertHead(betaexv).InsertHead(alphaexv)
-		delta, err := gamma.CheckAgainst(ve.Body, betaext)
+		gamma := c.InsertHead(alphaexv).InsertHead(annot)
+		delta, err := gamma.CheckAgainst(ve.Body, alphaext)
 		if err != nil {
 			c.debugRuleFail("fixI=>")
 			return nil, c, err
 		}
-		// funtype := &ast.LambdaType{Domain: alphaext, Codomain: betaext}
 		deltadrop := delta.Drop(annot)
 		deltadrop.debugRuleOut("fixI=>")
 
-		return betaext, deltadrop, nil
+		return alphaext, deltadrop, nil
 	case *ast.ApplyExpr:
+		if fn, ok := ve.Function.(*ast.FunctionLiteral); ok && fn.ParamType == nil {
+			return c.synthRedex(fn, ve.Arg)
+		}
 		// Rule ->E
 		c.debugRule("->E")
 
@@ -172,7 +215,19 @@ func (c Context) SynthesizesTo(exp ast.Expression) (ast.TypeValue, Context, erro
 		}
 		theta.debugRuleOut("->E")
 		return theta.ApplicationSynthesizesTo(theta.Apply(a), ve.Arg)
+	case *ast.EffectExpr:
+		return c.synthEffectExpr(ve)
+	case *ast.EventExpr:
+		return c.synthEvent(ve)
 	case *ast.AnnotExpr:
+		t, err := c.ExpandAliases(ve.Type)
+		if err != nil {
+			return nil, c, err
+		}
+		ve.Type = t
+		if c.IsWellFormed(ve.Type) && constrainedPrefix(ve.Type) > 0 {
+			return c.synthConstrainedAnno(ve)
+		}
 		if c.IsWellFormed(ve.Type) {
 			// Rule Anno
 			c.debugRule("Anno")
@@ -186,6 +241,7 @@ func (c Context) SynthesizesTo(exp ast.Expression) (ast.TypeValue, Context, erro
 			return ve.Type, delta, nil
 
 		}
+		return nil, c, c.malformedError(ve.Type)
 	}
 	return nil, c, c.synthError(exp)
 }
@@ -206,10 +262,16 @@ func (c Context) ApplicationSynthesizesTo(
 		alpha2exv := &ExistentialVariable{Identifier: alpha2}
 		alpha1ext := &ast.ExistsType{Identifier: alpha1}
 		alpha2ext := &ast.ExistsType{Identifier: alpha2}
+		// The effects of the function are not known, they are a
+		// row variable ρ^ performed after evaluating the argument
+		rho := ast.GenUID("ρ")
+		rhoexv := &ExistentialVariable{Identifier: rho}
+		effects := ast.NewEffectRow(nil, []ast.TypeValue{&ast.ExistsType{Identifier: rho}})
 
 		var funt ast.TypeValue = &ast.LambdaType{
 			Domain:   alpha1ext,
 			Codomain: alpha2ext,
+			Effects:  effects,
 		}
 		solvedexv := &ExistentialVariable{
 			Identifier: vty.Identifier,
@@ -219,6 +281,7 @@ func (c Context) ApplicationSynthesizesTo(
 		gamma := c.Insert(idexv, []ContextValue{
 			alpha2exv,
 			alpha1exv,
+			rhoexv,
 			solvedexv,
 		})
 
@@ -229,7 +292,7 @@ func (c Context) ApplicationSynthesizesTo(
 		}
 
 		delta.debugRuleOut("α^App")
-		return alpha2ext, delta, nil
+		return alpha2ext, delta.perform(effects), nil
 	case *ast.ForAllType:
 		// Rule ∀App
 		c.debugRule("∀App")
@@ -242,6 +305,11 @@ func (c Context) ApplicationSynthesizesTo(
 
 		gamma.debugRuleOut("∀App")
 		return gamma.ApplicationSynthesizesTo(sub_a, exp)
+	case *ast.MuType:
+		// Rule μApp. Recursive function types are unfolded
+		c.debugRule("μApp")
+
+		return c.ApplicationSynthesizesTo(Unfold(vty), exp)
 	case *ast.LambdaType:
 		// Rule ->App
 		c.debugRule("->App")
@@ -251,7 +319,7 @@ func (c Context) ApplicationSynthesizesTo(
 			c.debugRuleFail("->App")
 			return nil, c, err
 		}
-		return vty.Codomain, delta, nil
+		return vty.Codomain, delta.perform(vty.Row()), nil
 	}
 
 	return nil, c, c.synthError(exp)
@@ -259,14 +327,105 @@ func (c Context) ApplicationSynthesizesTo(
 
 // TODO add types to AST nodes
 func (c Context) SynthExpr(exp ast.Expression) (ast.TypeValue, error) {
-	t, nc, err := c.SynthesizesTo(exp)
+	t, _, err := c.CheckExpr(exp)
+	return t, err
+}
+
+// Synthesize the type of an expression like SynthExpr, and return
+// its elaborated copy, see CheckProgram
+func (c Context) CheckExpr(exp ast.Expression) (ast.TypeValue, ast.Expression, error) {
+	gamma := c
+	gamma.elab = newElaboration()
+	t, nc, err := gamma.SynthesizesTo(exp)
+	if err == nil {
+		nc, err = nc.solveAllConstraints()
+	}
 	if err != nil {
 		c.debugErr(err)
-		return nil, err
+		return nil, nil, err
 	}
 	nc.debugSynth(exp, t, true)
 
 	t = nc.Apply(t)
 	nc.debugSynth(exp, t, false)
-	return t, nil
+	return t, nc.elab.expression(exp), nil
+}
+
+// Get the type of the annotated parameter of a function literal. The
+// annotation must be well formed, and cannot have constrained type
+// variables: there is no instance to pass for them
+func (c Context) paramType(fn *ast.FunctionLiteral) (ast.TypeValue, error) {
+	pt, err := c.ExpandAliases(fn.ParamType)
+	if err != nil {
+		return nil, err
+	}
+	if !c.IsWellFormed(pt) {
+		return nil, c.malformedError(pt)
+	}
+	if isConstrained(pt) {
+		return nil, c.constrainedTypeError(pt)
+	}
+	return pt, nil
+}
+
+// Rule ->I=>Annot. The domain of a function whose parameter is
+// annotated is the type of the annotation, that can be polymorphic
+func (c Context) synthAnnotatedFunction(fn *ast.FunctionLiteral) (ast.TypeValue, Context, error) {
+	c.debugRule("->I=>Annot")
+
+	pt, err := c.paramType(fn)
+	if err != nil {
+		c.debugRuleFail("->I=>Annot")
+		return nil, c, err
+	}
+	beta := ast.GenUID("β")
+	betaext := &ast.ExistsType{Identifier: beta}
+	annot := &TypeAnnotation{Identifier: fn.Param.Identifier, Value: pt}
+	// The effects of the body are the effects of the function
+	gamma, scope := c.InsertHead(annot).InsertHead(&ExistentialVariable{Identifier: beta}).openEffectScope()
+	theta, err := gamma.CheckAgainst(fn.Body, betaext)
+	if err != nil {
+		c.debugRuleFail("->I=>Annot")
+		return nil, c, err
+	}
+	effects, delta := theta.closeEffectScope(scope)
+
+	funtype := &ast.LambdaType{Domain: pt, Codomain: betaext, Effects: effects}
+	deltadrop := delta.Drop(annot)
+	deltadrop.debugRuleOut("->I=>Annot")
+	return funtype, deltadrop, nil
+}
+
+// Rule fixI=>Annot. The domain of a recursive function whose parameter
+// is annotated is known before typing its body: the recursive name is
+// a function from the annotation to β^, whose effects are a row
+// variable ρ^. Solving an existential variable to the function type
+// would make the domain monomorphic
+func (c Context) synthAnnotatedFix(fix *ast.FixExpr, fn *ast.FunctionLiteral) (ast.TypeValue, Context, error) {
+	c.debugRule("fixI=>Annot")
+
+	pt, err := c.paramType(fn)
+	if err != nil {
+		c.debugRuleFail("fixI=>Annot")
+		return nil, c, err
+	}
+	beta := ast.GenUID("β")
+	rho := ast.GenUID("ρ")
+	funt := &ast.LambdaType{
+		Domain:   pt,
+		Codomain: &ast.ExistsType{Identifier: beta},
+		Effects:  ast.NewEffectRow(nil, []ast.TypeValue{&ast.ExistsType{Identifier: rho}}),
+	}
+	annot := &TypeAnnotation{Identifier: fix.Param.Identifier, Value: funt}
+	gamma := c.InsertHead(&ExistentialVariable{Identifier: rho}).
+		InsertHead(&ExistentialVariable{Identifier: beta}).
+		InsertHead(annot)
+	delta, err := gamma.CheckAgainst(fn, funt)
+	if err != nil {
+		c.debugRuleFail("fixI=>Annot")
+		return nil, c, err
+	}
+	deltadrop := delta.Drop(annot)
+	deltadrop.debugRuleOut("fixI=>Annot")
+	return deltadrop.Apply(funt), deltadrop, nil
 }
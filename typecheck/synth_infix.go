@@ -6,19 +6,37 @@ import (
 	"github.com/0x0f0f0f/gobba-golang/token"
 )
 
-func (Γ Context) synthComparison(leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
-	// TODO, when interfaces are implemented, check if exp
-	// implements the `comparable` interface
+// Operands of the same type can be compared if the type implements Eq,
+// or Ord for the ordering operators. The constraints on the existential
+// variables of the type are resolved when the variables are known.
+// Values of record types are compared by the fields of their type
+func (Γ Context) synthComparison(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	op := exp.Operator
 	Γ1, err := Γ.Subtype(leftt, rightt)
 	if err != nil {
 		return nil, Γ, Γ.expectedSameTypeComparison(leftt, rightt)
 	}
 
-	Γ2, err := Γ1.Subtype(rightt, leftt)
+	Γ2, err := Γ1.Subtype(Γ1.Apply(rightt), Γ1.Apply(leftt))
 	if err != nil {
 		return nil, Γ, Γ.expectedSameTypeComparison(leftt, rightt)
 	}
 
+	name := token.IORD
+	if op == token.EQUALS || op == token.DIFFERS {
+		name = token.IEQ
+	}
+	_, residual, err := Γ2.findInstance(&InterfaceConstraint{Interface: name, Type: leftt})
+	if err != nil {
+		return nil, Γ, err
+	}
+	for _, k := range residual {
+		Γ2 = Γ2.InsertHead(k)
+	}
+	if name == token.IEQ {
+		Γ2.elab.restrict(exp, Γ2.shape(Γ2.Apply(leftt), map[string]*ast.Shape{}))
+	}
+
 	return ast.TBOOL, Γ2, nil
 
 }
@@ -29,10 +47,40 @@ func (Γ Context) synthInfixExpr(exp *ast.InfixExpression) (ast.TypeValue, Conte
 	if err != nil {
 		return nil, Γ, err
 	}
+	// The right operand of a boolean operator may not be evaluated
+	shortCircuit := exp.Operator == token.LAND || exp.Operator == token.OR
+	var h0 ast.History
+	if shortCircuit {
+		h0, Γ1 = Γ1.takeHistory()
+	}
 	rightt, Θ, err := Γ1.SynthesizesTo(exp.Right)
 	if err != nil {
 		return nil, Γ, err
 	}
+	if shortCircuit {
+		var h ast.History
+		h, Θ = Θ.takeHistory()
+		Θ = Θ.joinHistories(h0, []ast.History{nil, h})
+	}
+
+	// ======================================================================
+	// Matrix operators
+	// ======================================================================
+	if isMatrixOperation(Θ.Apply(leftt), Θ.Apply(rightt)) {
+		exp.Instance = ast.MatrixInstances[exp.Operator]
+		switch exp.Operator {
+		case token.PLUS, token.MINUS:
+			return Θ.synthMatrixSum(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+		case token.TIMES:
+			return Θ.synthMatrixProduct(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+		case token.DIVIDE:
+			return Θ.synthMatrixQuotient(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+		}
+	}
+
+	if _, ok := ast.NumericInstances[exp.Operator]; ok {
+		return Θ.synthNumericOperation(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+	}
 
 	if resultt, ok := ast.InfixOperatorTypes[exp.Operator]; ok {
 		Θ1, err := Θ.Subtype(leftt, resultt.Left)
@@ -44,6 +92,11 @@ func (Γ Context) synthInfixExpr(exp *ast.InfixExpression) (ast.TypeValue, Conte
 		if err != nil {
 			return nil, Γ, err
 		}
+		Δ.narrow(exp.Left, leftt, resultt.Left)
+		Δ.narrow(exp.Right, rightt, resultt.Right)
+		if ast.PartialOperators[exp.Operator] {
+			Δ = Δ.performFail()
+		}
 
 		return resultt.Result, Δ, err
 
@@ -54,21 +107,187 @@ func (Γ Context) synthInfixExpr(exp *ast.InfixExpression) (ast.TypeValue, Conte
 	// Comparison Operators
 	// ======================================================================
 	case token.EQUALS:
-		return Γ1.synthComparison(leftt, rightt)
+		return Θ.synthComparison(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+	case token.DIFFERS:
+		return Θ.synthComparison(exp, Θ.Apply(leftt), Θ.Apply(rightt))
 	case token.GREATER:
-		return Γ1.synthComparison(leftt, rightt)
+		return Θ.synthComparison(exp, Θ.Apply(leftt), Θ.Apply(rightt))
 	case token.LESS:
-		return Γ1.synthComparison(leftt, rightt)
+		return Θ.synthComparison(exp, Θ.Apply(leftt), Θ.Apply(rightt))
 	case token.LESSEQ:
-		return Γ1.synthComparison(leftt, rightt)
+		return Θ.synthComparison(exp, Θ.Apply(leftt), Θ.Apply(rightt))
 	case token.GREATEREQ:
-		return Γ1.synthComparison(leftt, rightt)
+		return Θ.synthComparison(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+	// ======================================================================
+	// List operators
+	// ======================================================================
+	case token.CONS:
+		return Θ.synthCons(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+	case token.CONCAT:
+		return Θ.synthConcat(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+	// ======================================================================
+	// Element access: m @ (i, j)
+	// ======================================================================
+	case token.AT:
+		return Θ.synthMatrixAccess(exp, Θ.Apply(leftt), Θ.Apply(rightt))
+	// ======================================================================
+	// Sequencing: the value of the left operand is discarded
+	// ======================================================================
+	case token.SEMI:
+		return rightt, Θ, nil
 	}
 
 	return nil, Γ, Γ.synthError(exp)
 }
 
+// Rule Num=>. The overloaded arithmetical operators are applied to
+// the least upper bound of the types of the operands in the numeric
+// tower: 1 + 2.5 is a float, where 1 is converted to a float. An
+// operand whose type is not known yet is solved to the type of the
+// other operand if it is float or complex, otherwise the operation is
+// typed by rule NumI=>. The strict instance of the operator is recorded
+// in the expression, the partial instances, such as the integer
+// division, perform fail
+func (Γ Context) synthNumericOperation(exp *ast.InfixExpression, leftt, rightt ast.TypeValue) (ast.TypeValue, Context, error) {
+	lr, rr := towerRank(leftt), towerRank(rightt)
+	_, lunknown := leftt.(*ast.ExistsType)
+	_, runknown := rightt.(*ast.ExistsType)
+	if !(lr >= 0 && rr >= 0 || lr > 0 && runknown || rr > 0 && lunknown) {
+		return Γ.synthNumMethod(exp, []ast.TypeValue{leftt, rightt})
+	}
+	Γ.debugRule("Num=>")
+
+	lub := 0
+	for _, t := range []ast.TypeValue{leftt, rightt} {
+		for i, nt := range ast.NumericTower {
+			if ast.CompareTypeValues(t, nt) && i > lub {
+				lub = i
+			}
+		}
+	}
+	resultt := ast.NumericTower[lub]
+
+	Θ, err := Γ.Subtype(leftt, resultt)
+	if err != nil {
+		Γ.debugRuleFail("Num=>")
+		return nil, Γ, err
+	}
+	Δ, err := Θ.Subtype(Θ.Apply(rightt), resultt)
+	if err != nil {
+		Γ.debugRuleFail("Num=>")
+		return nil, Γ, err
+	}
+
+	Δ.narrow(exp.Left, leftt, resultt)
+	Δ.narrow(exp.Right, rightt, resultt)
+
+	exp.Instance = ast.NumericInstances[exp.Operator][resultt.Identifier.Value]
+	if ast.PartialOperators[exp.Instance] {
+		Δ = Δ.performFail()
+	}
+	Δ.debugRuleOut("Num=>")
+	return resultt, Δ, nil
+}
+
+// Rule NumI=>. The arithmetical operators on operands whose type is
+// not a number type are applied by the methods of the Num instance of
+// the type, as in Meters(1.0) + Meters(2.0). The operands have the
+// same type, unless one is an int and the type of the other is not
+// known yet: the int is converted to the type by fromint, and
+// fun(x) { x / 2 } has type ∀(a: Num).a -{fail}-> a. The instance of
+// a type that is not known yet is found when the type is known, or
+// passed to the value where the type is generalized
+func (Γ Context) synthNumMethod(exp ast.Expression, operands []ast.TypeValue) (ast.TypeValue, Context, error) {
+	Γ.debugRule("NumI=>")
+
+	op := &numOperation{Convert: -1}
+	var operator string
+	switch vexp := exp.(type) {
+	case *ast.InfixExpression:
+		vexp.Instance = ""
+		operator = vexp.Operator
+		op.Method = ast.NumMethods[operator]
+	case *ast.PrefixExpression:
+		vexp.Instance = ""
+		op.Method = "neg"
+	}
+
+	t, Θ := operands[0], Γ
+	if len(operands) == 2 {
+		_, lunknown := operands[0].(*ast.ExistsType)
+		_, runknown := operands[1].(*ast.ExistsType)
+		switch {
+		case lunknown && towerRank(operands[1]) == 0:
+			op.Convert = 1
+		case runknown && towerRank(operands[0]) == 0:
+			t, op.Convert = operands[1], 0
+		default:
+			var err error
+			if Θ, err = Γ.Subtype(operands[0], operands[1]); err != nil {
+				Γ.debugRuleFail("NumI=>")
+				return nil, Γ, err
+			}
+			if Θ, err = Θ.Subtype(Θ.Apply(operands[1]), Θ.Apply(operands[0])); err != nil {
+				Γ.debugRuleFail("NumI=>")
+				return nil, Γ, err
+			}
+			t = Θ.Apply(operands[0])
+		}
+	}
+
+	op.Dictionary = ast.GenUID("dict")
+	k := &InterfaceConstraint{Interface: token.INUM, Type: t, Dictionary: &op.Dictionary}
+	dict, residual, err := Θ.findInstance(k)
+	if err != nil {
+		Γ.debugRuleFail("NumI=>")
+		return nil, Γ, err
+	}
+	if dict != nil {
+		Θ.elab.resolve(op.Dictionary, *dict, t)
+	}
+	for _, r := range residual {
+		Θ = Θ.InsertHead(r)
+	}
+	Θ.elab.operate(exp, op)
+	if ast.PartialOperators[operator] {
+		Θ = Θ.performFail()
+	}
+
+	Θ.debugRuleOut("NumI=>")
+	return t, Θ, nil
+}
+
+// Rule Neg=>. The negation is applied to the type of its operand in
+// the numeric tower: -(1.5) is a float. The negation of an operand
+// whose type is not a number type is typed by rule NumI=>. The strict
+// instance of the negation is recorded in the expression
+func (Γ Context) synthNegation(exp *ast.PrefixExpression) (ast.TypeValue, Context, error) {
+	rightt, Θ, err := Γ.SynthesizesTo(exp.Right)
+	if err != nil {
+		return nil, Γ, err
+	}
+	if towerRank(Θ.Apply(rightt)) < 0 {
+		return Θ.synthNumMethod(exp, []ast.TypeValue{Θ.Apply(rightt)})
+	}
+	Γ.debugRule("Neg=>")
+
+	resultt := ast.NumericTower[towerRank(Θ.Apply(rightt))]
+
+	Δ, err := Θ.Subtype(rightt, resultt)
+	if err != nil {
+		Γ.debugRuleFail("Neg=>")
+		return nil, Γ, err
+	}
+
+	exp.Instance = ast.NegationInstances[resultt.Identifier.Value]
+	Δ.debugRuleOut("Neg=>")
+	return resultt, Δ, nil
+}
+
 func (Γ Context) synthPrefixExpr(exp *ast.PrefixExpression) (ast.TypeValue, Context, error) {
+	if exp.Operator == token.MINUS {
+		return Γ.synthNegation(exp)
+	}
 	if resultt, ok := ast.PrefixOperatorTypes[exp.Operator]; ok {
 		Δ, err := Γ.CheckAgainst(exp.Right, resultt.Right)
 		if err != nil {
@@ -78,3 +297,11 @@ func (Γ Context) synthPrefixExpr(exp *ast.PrefixExpression) (ast.TypeValue, Con
 	}
 	return nil, Γ, Γ.synthError(exp)
 }
+
+func (Γ Context) synthPostfixExpr(exp *ast.PostfixExpression) (ast.TypeValue, Context, error) {
+	switch exp.Operator {
+	case token.TRANSPOSE:
+		return Γ.synthTranspose(exp)
+	}
+	return nil, Γ, Γ.synthError(exp)
+}
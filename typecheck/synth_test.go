@@ -5,6 +5,7 @@ import (
 	"github.com/0x0f0f0f/gobba-golang/ast"
 	"github.com/0x0f0f0f/gobba-golang/lexer"
 	"github.com/0x0f0f0f/gobba-golang/parser"
+	"github.com/0x0f0f0f/gobba-golang/token"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -34,13 +35,36 @@ func TestSynthExpr(t *testing.T) {
 			"swap(3,\"ciao\",firstid)": "string",
 		// Fixed point combinator
 		"let f = fun (x) {if x <= 1 then 1 else f(x)}; f":             "int -> int",
-		"let id = fun(a){a}; let id1 = fun(b){b}; let f = id(id1); f": "'a -> 'a",
-		"let x = 4 and y = 3.2 and f = fun(x,y) {x}; f(y)":            "'a -> float",
+		"let id = fun(a){a}; let id1 = fun(b){b}; let f = id(id1); f": "∀a.a -> a",
+		// Let-polymorphism
+		"let id = fun(x) {x} in (id(1); id(true))":         "bool",
+		"let id = fun(x) {x}; id":                          "∀a.a -> a",
+		"let k = fun(x, y) {x}; k":                         "∀a.∀b.a -> b -> a",
+		"let k = fun(x, y) {x}; (k(1, true); k(\"a\", 2))": "string",
+		"let x = 4 and y = 3.2 and f = fun(x,y) {x}; f(y)": "'a -> float",
 		// Arithmetic Operators
 		"4.5 +. 4":     "float",
 		"4 +: 3+3i":    "complex",
 		"0 + 1":        "int",
 		"4.5 +: 3+14i": "complex",
+		"2 ^. 0.5":     "float",
+		// Overloaded operators on the numeric tower
+		"1 + 2.5":           "float",
+		"1.0 + 2i":          "complex",
+		"2 ^ 0.5":           "float",
+		"7 / 2 - 1.5 * 2":   "float",
+		"fun (x) {x + 2.5}": "float -> float",
+		"fun (x) {2i * x}":  "complex -> complex",
+		"-(1.5)":            "float",
+		"-(1 + 2i)":         "complex",
+		"fun (x) {-x}":      "int -> int",
+		// Operands of types that are not known are constrained by Num
+		"let f = fun (x) {x+1}; f(3.5)":      "float",
+		"let d = fun(x) {x / 2}; d(7.0)":     "float",
+		"let f = fun(x, y) {x * y}; f(1, 2)": "int",
+		// Comparison and sequencing
+		"1 != 2":  "bool",
+		"1; true": "bool",
 		// Left in binop is existential
 		"fun (x) {x+1}(3)":              "int",
 		"fun (x) {x +. 1.5}(3)":         "float",
@@ -61,6 +85,13 @@ func TestSynthExpr(t *testing.T) {
 		"fun (x) {1.5+3i +: x}(3)":      "complex",
 		"fun (x) {1.5+3i +: x}(3.5)":    "complex",
 		"fun (x) {1.5+3i +: x}(3.5+3i)": "complex",
+		// The operand is promoted to the argument
+		"fun (x) {x+1}(3.5)":      "float",
+		"fun (x) {x+1}(3.5+3i)":   "complex",
+		"fun (x) {x+1.5}(3.5+3i)": "complex",
+		"fun (x) {1+x}(3.5)":      "float",
+		"fun (x) {1+x}(3.5+3i)":   "complex",
+		"fun (x) {1.5+x}(3.5+3i)": "complex",
 
 		// Unary operators
 		"!true": "bool",
@@ -69,16 +100,53 @@ func TestSynthExpr(t *testing.T) {
 		"fun (x: int, y: int) { if x = 2 then y else 0}":                     "int -> int -> int",
 		"fun (x: bool, y) {x = y}":                                           "bool -> bool -> bool",
 		"let fib = fun(n) { if n < 2 then n else fib(n-1) + fib(n-2) }; fib": "int -> int",
+		"fun (f: int -> int) {f(1)}":                                         "(int -> int) -> int",
+		"fun (f: (unit -> int) -> int) {f(fun(x) {1})}":                      "((unit -> int) -> int) -> int",
+		// General annotations
+		"(1 : float)":                                                 "float",
+		"(fun(x) {x} : int -> int)(3)":                                "int",
+		"(fun(x) {x} : forall a. a -> a)":                             "∀a.a -> a",
+		"(fun(x, y) {x} : forall a b. a -> b -> a)":                   "∀a.∀b.a -> b -> a",
+		"let id = (fun(x) {x} : forall a. a -> a); (id(1); id(true))": "bool",
+		// Records
+		"{}":                                      "{}",
+		"{x = 1, y = true}":                       "{x: int, y: bool}",
+		"{x = 1, y = true}.y":                     "bool",
+		"{p = {x = 1.5}}.p.x":                     "float",
+		"let r = {f = fun(x) {x}}; r.f(1)":        "int",
+		"fun (r: {x: int}) {r.x}":                 "{x: int} -> int",
+		"fun (r: {x: int}) {r.x}({x = 1, y = 2})": "int",
+		"fun (r: {x: float}) {r.x}({x = 1})":      "float",
+		"fun (r) {r.x}":                           "{x: 'a} -> 'a",
+		"fun (r) {r.x + 1}({x = 1, y = true})":    "int",
+		"({x = 1, y = 2} : {x: int})":             "{x: int}",
+		"({f = fun(x) {x}} : {f: int -> int})":    "{f: int -> int}",
+		"fun (r: {x: int, y: int}) {r}({y = 2, x = 1}) = {x = 3, y = 4}": "bool",
+		// Annotations in the body see the quantified type variables
+		"(fun(x: a) {x} : forall a. a -> a)": "∀a.a -> a",
+		// Unions
+		"if true then 1 else true":                                 "int | bool",
+		"fun (c) {if c then 1 else \"a\"}":                         "bool -> int | string",
+		"if true then 1 else if false then true else 2":            "bool | int",
+		"(1 : int | bool)":                                         "int | bool",
+		"((1, true) : (int | bool, bool))":                         "(int | bool, bool)",
+		"fun (x: int | bool) {x}(true)":                            "int | bool",
+		"fun (x: forall a. a -> a) {(x(1), x(true))}":              "(∀a.a -> a) -> (int, bool)",
+		"fun (x: forall a. a -> a) {(x(1), x(true))}(fun (y) {y})": "(int, bool)",
+		"(fun (x: int | bool) {x} : int -> int | bool)":            "int -> int | bool",
+		"(fun (x: bool | int) {x} : (int | bool) -> bool | int)":   "int | bool -> bool | int",
+		// Functions are contravariant in the domain
+		"(fun(x: float) {x} : int -> float)": "int -> float",
 	}
 
 	for input, expected := range tests {
 		t.Log("--- TEST CASE", input, "---")
 		l := lexer.New(input)
 		p := parser.New(l)
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		// parser.CheckParseErrors(t, p)
 		assert.Len(t, p.Errors(), 0)
-		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
 		if err != nil {
 			assert.Fail(t, "could not α-convert expression")
 			return
@@ -103,23 +171,43 @@ func TestSynthExprFail(t *testing.T) {
 		"fun (x) {x()}(fun (y) {y+1})",
 		// Impredicativeness
 		"fun (x) {x(x, ())}",
+		// Records
+		"{x = 1}.y",
+		"fun (r) {(r.x, r.y)}",
+		"(1).x",
+		"fun (r: {x: int, y: int}) {r.x}({x = 1})",
+		"fun (r: {x: int}) {r.x}({x = true})",
+		"({x = 1} : {x: int, y: int})",
+		// Ill-typed annotations
+		"(1 : bool)",
+		"(1 : forall a. a)",
+		"(fun(x) {x + 1} : forall a. a -> a)",
+		// Unbound type variables
+		"fun (x: a) {x}",
+		"(fun(x) {x} : forall a. a -> b)",
+		// Lambda-bound variables are not generalized
+		"fun (f) {let g = f; (g(1); g(true))}",
 		// Arithmetical imprecision
-		"fun (x) {x+1}(3.5)",
-		"fun (x) {x+1}(3.5+3i)",
-		"fun (x) {x+1.5}(3.5+3i)",
-		"fun (x) {1+x}(3.5)",
-		"fun (x) {1+x}(3.5+3i)",
-		"fun (x) {1.5+x}(3.5+3i)",
+		"let f = fun (x) {x+1.5}; f(3.5+3i)",
+		"(1 + 2.5 : int)",
+		"1.5 + 2i +. 1",
+		"true * 1.5",
+		// Unions
+		"(if true then 1 else true : int)",
+		"fun (x: int | bool) {x + 1}",
+		"fun (x: int | bool) {x}(\"a\")",
+		"(if true then 1 else true) = 1",
+		"(fun(x: int) {x} : float -> float)",
 	}
 
 	for _, input := range tests {
 		t.Log("--- TEST CASE", input, "---")
 		l := lexer.New(input)
 		p := parser.New(l)
-		program := p.ParseProgram()
+		program := p.ParseSingleExpression()
 		// parser.CheckParseErrors(t, p)
 		assert.Len(t, p.Errors(), 0)
-		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
 		if err != nil {
 			assert.Fail(t, "could not α-convert expression")
 			return
@@ -136,3 +224,556 @@ func TestSynthExprFail(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestUnboundTypeVariable(t *testing.T) {
+	tests := map[string]string{
+		"(1 : a)":                         "type error: unbound type variable a in type a",
+		"(fun(x) {x} : forall a. b -> a)": "type error: unbound type variable b in type ∀a.b -> a",
+		"fun (x: [](int, c)) {x}":         "type error: unbound type variable c in type [](int, c)",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New(input))
+		program := p.ParseSingleExpression()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		_, err = NewContext().SynthExpr(*alphaconv_program)
+		if assert.Error(t, err) {
+			assert.Equal(t, expected, err.Error(), input)
+		}
+	}
+}
+
+func TestAliasArity(t *testing.T) {
+	tests := map[string]string{
+		"package main; type f(x) = x -> x; (fun(y) {y} : f)":           "type error: type alias f expects 1 arguments, type f has 0",
+		"package main; type f(x) = x -> x; (fun(y) {y} : f(int, int))": "type error: type alias f expects 1 arguments, type f(int, int) has 2",
+		"package main; type v = (int, int); fun(x: [](v(int))) {x}":    "type error: type alias v expects 0 arguments, type v(int) has 1",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		_, _, err = NewContext().SynthProgram(alphaconv_program)
+		if assert.Error(t, err) {
+			assert.Equal(t, expected, err.Error(), input)
+		}
+	}
+}
+
+func TestSynthProgram(t *testing.T) {
+	tests := map[string]string{
+		"package main;":                                         "unit",
+		"package main; let x = 1;":                              "unit",
+		"package main; let x = 1; x":                            "int",
+		"package main; let x = 1 and y = 2.5; y":                "float",
+		"package main; let x = 1; let x = x < 2; x":             "bool",
+		"package main; let f = fun(x) {x + 1}; let y = f(2); y": "int",
+		"package main; let fact = fun(n) {if n <= 1 then 1 else n * fact(n - 1)}; fact": "int -> int",
+		"package main; let id = fun(x) {x}; id(1); id(true)":                            "bool",
+		// Algebraic data types
+		"package main; type color = Red | Green; Red":                                                               "color",
+		"package main; type option(a) = None | Some(a); Some":                                                       "∀a.a -> option(a)",
+		"package main; type option(a) = None | Some(a); None":                                                       "∀a.option(a)",
+		"package main; type option(a) = None | Some(a); Some(1)":                                                    "option(int)",
+		"package main; type option(a) = None | Some(a); (Some(1) : option(float))":                                  "option(float)",
+		"package main; type option(a) = None | Some(a); (Some(Some(1)) : option(option(complex)))":                  "option(option(complex))",
+		"package main; type pair(a, b) = Pair(a, b); let f = fun(p: pair(float, int)) {p}; f(Pair(1, 2))":           "pair(float, int)",
+		"package main; type pair(a, b) = Pair(a, b); Pair(1, true)":                                                 "pair(int, bool)",
+		"package main; type pair(a, b) = Pair(a, b); Pair":                                                          "∀a.∀b.a -> b -> pair(a, b)",
+		"package main; type tree(a) = Leaf | Node(tree(a), a, tree(a)); Node(Leaf, 1, Leaf)":                        "tree(int)",
+		"package main; type option(a) = None | Some(a); let get = fun(o: option(int), d: int) {d}; get(Some(3), 0)": "int",
+		"package main; type option(a) = None | Some(a); (None : option(bool))":                                      "option(bool)",
+		"package main; type option(a) = None | Some(a); Some(1) = Some(2)":                                          "bool",
+		"package main; type box = Box({x: int}); Box({x = 1})":                                                      "box",
+		// Match expressions
+		"package main; type option(a) = None | Some(a); let get = fun(o, d) {match o with | None -> d | Some(x) -> x}; get":                                      "∀a.option(a) -> a -> a",
+		"package main; type tree(a) = Leaf | Node(tree(a), a, tree(a)); let sum = fun(t) {match t with | Leaf -> 0 | Node(l, v, r) -> sum(l) + v + sum(r)}; sum": "tree(int) -> int",
+		"package main; type option(a) = None | Some(a); (fun(o) {match o with | Some(x) -> x | None -> 0} : option(int) -> int)":                                 "option(int) -> int",
+		"package main; match 1 with | 0 -> 1 | _ -> 2.5":                                                       "float",
+		"package main; match {x = 1, y = \"a\"} with | {y = s} -> s":                                           "string",
+		"package main; type box = Box({x: int}); match Box({x = 1}) with | Box({x = -1}) -> true | _ -> false": "bool",
+		// Tuples
+		"package main; (1, true)":                                                            "(int, bool)",
+		"package main; ((1, 2) : (float, complex))":                                          "(float, complex)",
+		"package main; let swap = fun((x, y)) {(y, x)}; swap":                                "∀a.∀b.(a, b) -> (b, a)",
+		"package main; let (a, b) = (1, 2.5); a +. b":                                        "float",
+		"package main; let (f, n) = (fun(x) {x}, 1); (f(true), f(n))":                        "(bool, int)",
+		"package main; let add = fun((x, y): (int, int)) {x + y}; add((3, 4))":               "int",
+		"package main; match (1, true) with | (0, b) -> b | (_, c) -> c":                     "bool",
+		"package main; let f = fun(l) {match l with | [] -> 0 | x :: _ -> x}; f":             "[]int -> int",
+		"package main; let f = fun(l) {match l with | x :: _ :: [] -> x | _ -> 1.5}; f([1])": "float",
+		// Lists
+		"package main; [1, 2.5]":                        "[]float",
+		"package main; []":                              "[]'a",
+		"package main; 1 :: [2]":                        "[]int",
+		"package main; [1] ++ [true]":                   "[](int | bool)",
+		"package main; ([] : []int)":                    "[]int",
+		"package main; let l = []; (1 :: l, true :: l)": "([]int, []bool)",
+		"package main; let f = fun(x, l) {x :: l}; f":   "∀a.a -> []a -> []a",
+		"package main; let fld = fun(f, z, n) {if n = 0 then z else fld(f, f(z, n), n - 1)}; fld": "∀a.∀b.∀c.(a -{b}-> int -{c}-> a) -> a -> int -{b, c}-> a",
+		// Matrices
+		"package main; [|1, 2.5; 3, 4|]":                                   "dense matrix(2, 2)",
+		"package main; sparse [|1, 0; 0, 2+1i|]":                           "sparse cmatrix(2, 2)",
+		"package main; let m = [|1, 2|]; (m * m', m + 1, 2 * m, m / 2)":    "(dense matrix(1, 1), dense matrix(1, 2), dense matrix(1, 2), dense matrix(1, 2))",
+		"package main; let s = sparse [|1|]; (s * s, s + s, s * 2, s + 1)": "(sparse matrix(1, 1), sparse matrix(1, 1), sparse matrix(1, 1), dense matrix(1, 1))",
+		"package main; sparse [|1|] + [|1|]":                               "dense matrix(1, 1)",
+		"package main; [|1|] * (1+1i)":                                     "dense cmatrix(1, 1)",
+		"package main; ([|1|] @ (0, 0), [|0+1i|] @ (0, 0))":                "(float, complex)",
+		"package main; ([|1|] : dense cmatrix)":                            "dense cmatrix",
+		"package main; fun(m: sparse matrix) {m'}":                         "sparse matrix -> sparse matrix",
+		"package main; fun(x) {[|x|]}":                                     "float -> dense matrix(1, 1)",
+		"package main; fun(m: matrix(2, 3)) {m'}":                          "dense matrix(2, 3) -> dense matrix(3, 2)",
+		"package main; ([|1, 2|] : matrix) * [|1, 2|]":                     "dense matrix",
+		"package main; let mul = (fun(a, b) {a * b} : forall m n p. matrix(m, n) -> matrix(n, p) -> matrix(m, p)); mul([|1, 2|], [|1; 2|])": "dense matrix(1, 1)",
+		"package main; ([1, 2] : [2]int)":                                                 "[2]int",
+		"package main; let f = (fun(v) {v} : forall n. [n]int -> [n]int); f([1, 2, 3])":   "[3]int",
+		"package main; fun(v: [2]int) {1 :: v}":                                           "[2]int -> []int",
+		"package main; fun(f: int -{io}-> int) {f(1)}":                                    "(int -{io}-> int) -{io}-> int",
+		"package main; fun(f: int -{io}-> int, g: int -{fail}-> int) {f(g(1))}":           "(int -{io}-> int) -> (int -{fail}-> int) -{fail, io}-> int",
+		"package main; let f = fun(g, x) {g(x)}; f":                                       "∀a.∀b.∀c.(a -{b}-> c) -> a -{b}-> c",
+		"package main; let f = fun(g, x) {g(x)}; f(fun(y: int -{io}-> int) {y(1)})":       "(int -{io}-> int) -{io}-> int",
+		"package main; let f = fun(n) {if n = 0 then 0 else f(n - 1)}; f":                 "int -> int",
+		"package main; (fun(x) {x} : int -{io}-> int)":                                    "int -{io}-> int",
+		"package main; (fun(f, x) {f(x)} : forall e. (int -{e}-> int) -> int -{e}-> int)": "∀a.(int -{a}-> int) -> int -{a}-> int",
+		"package main; pure fun(x) {x + 1}":                                               "∀(a: Num).a -> a",
+		"package main; deny io in fun(f: int -{fail}-> int) {f(1)}":                       "(int -{fail}-> int) -{fail}-> int",
+		"package main; allow io, fail in fun(f: int -{fail}-> int) {f(1)}":                "(int -{fail}-> int) -{fail}-> int",
+		"package main; allow state, nondet in 1":                                          "int",
+		"package main; let f = pure fun(n) {if n = 0 then 1 else n * f(n - 1)}; f":        "int -> int",
+		// Arithmetic on types that are not known is overloaded by Num
+		"package main; fun(x, y) {x + y}":                                 "∀(a: Num).a -> a -> a",
+		"package main; let f = fun(x, y) {x - y}; (f(1, 2), f(1.5, 2.0))": "(int, float)",
+		"package main; fun(x) {-x}":                                       "∀(a: Num).a -> a",
+		"package main; newtype M = float; instance Num(M) {add = fun(x, y) {M(unM(x) + unM(y))}; sub = fun(x, y) {x}; mul = fun(x, y) {x}; div = fun(x, y) {x}; pow = fun(x, y) {x}; neg = fun(x) {x}; zero = M(0.0); fromint = fun(n) {M(0.0)}; }; M(1.0) + M(2.0)": "M",
+		// Partial operators and patterns that are not exhaustive fail
+		"package main; fun(x) {1 / x}":                                    "∀(a: Num).a -{fail}-> a",
+		"package main; fun(x: int) {1 / x}":                               "int -{fail}-> int",
+		"package main; fun(x) {1.0 / x}":                                  "float -> float",
+		"package main; fun(x) {match x with | 1 -> true}":                 "int -{fail}-> bool",
+		"package main; fun(x) {match x with | 1 -> true | _ -> false}":    "int -> bool",
+		"package main; let f = fun(l) {match l with | _ :: _ -> true}; f": "∀a.[]a -{fail}-> bool",
+		"package main; fun(p) {let (1, y) = p in y}":                      "(int, 'a) -{fail}-> 'a",
+		"package main; fun(m: matrix(2, 2)) {(m * m, m @ (0, 0))}":        "dense matrix(2, 2) -{fail}-> (dense matrix(2, 2), float)",
+		"package main; fun(m: matrix) {m * m}":                            "dense matrix -{fail}-> dense matrix",
+		"package main deny io; let f = fun(x) {x + 1}; f(2)":              "int",
+		"package main; let even = fun(n) {if n = 0 then true else odd(n - 1)} and odd = fun(n) {if n = 0 then false else even(n - 1)}; odd": "int -> bool",
+		"package main; let f = fun(x) {x} and g = fun(x) {f(x)}; g":                                                                         "∀a.a -> a",
+		// Histories of events
+		"package main; let f = fun(x) {event open; event read}; f":                                                                                             "∀a.a -{io | open · read}-> unit",
+		"package main; fun(x) {if x then event open else ()}":                                                                                                  "bool -{io | (open + ε)}-> unit",
+		"package main; let f = fun(n) {if n = 0 then () else (event tick; f(n - 1))}; f":                                                                       "int -{io | μh.(ε + tick · h)}-> unit",
+		"package main; let twice = fun(f, x) {f(x); f(x)}; twice(fun(x) {event a})":                                                                            "'a -{io | a · a}-> unit",
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; event a":                                                              "unit",
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; [|1|] @ (event a; (0, 0))":                                            "float",
+		"package main; policy order { init closed; closed -read-> bad; closed -open-> opened; offending bad; }; let r = fun(x) {event read}; event open; r(1)": "unit",
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; let f = fun(n) {event a; f(n)}; 1":                                    "int",
+		"package main; (if true then fun(u) {event a} else fun(u) {event b})(())":                                                                              "unit",
+		"package main; if true then fun(u) {event a} else fun(u) {event b}":                                                                                    "'a -{io | (a + b)}-> unit",
+		"package main; [fun(u) {event a}, fun(u) {event b}]":                                                                                                   "[]('a -{io | (a + b)}-> unit)",
+		// Interfaces
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; size(true)":                                                                                                "int",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size((bool, bool)) { size = fun(p) {let (x, y) = p in size(x) + size(y)}; }; size((true, false))": "int",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; let twice = fun(x) {size(x) + size(x)}; twice(false)":                                                      "int",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size(int) { size = fun(n) {n}; }; size(1) + size(true)":                                           "int",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; let f = (fun(x) {size(x)} : forall (a: Size). a -> int); f(true)":                                          "int",
+		"package main; let eq = fun(x, y) {x = y}; eq(1, 2) && eq(\"a\", \"b\")":                                                                                                                                                       "bool",
+		"package main; let lt = fun(x, y) {x < y}; lt(\"a\", \"b\")":                                                                                                                                                                   "bool",
+		"package main; type option(a) = None | Some(a); let same = fun(x) {x = Some(1)}; same":                                                                                                                                         "option(int) -> bool",
+		"package main; type option(a) = None | Some(a); None = None":                                                                                                                                                                   "bool",
+		"package main; (1, {a = true}) = (2, {a = false})":                                                                                                                                                                             "bool",
+		// List literals passed to polymorphic functions
+		"package main; let f = (fun(l) {l} : forall a. []a -> []a); f([1, 2.5])":  "[]float",
+		"package main; let f = (fun(l) {l} : forall a. []a -> []a); f([1, true])": "[](int | bool)",
+		// Type aliases and newtypes
+		"package main; type vec = (float, float); ((1.0, 2.0) : vec)":                                           "(float, float)",
+		"package main; type pair(a) = (a, a); let swap = (fun((x, y)) {(y, x)} : pair(int) -> pair(int)); swap": "(int, int) -> (int, int)",
+		"package main; type vec = (float, float); type path = []vec; ([(1.0, 2.0)] : path)":                     "[](float, float)",
+		"package main; type u = forall a. a -> a; let f = (fun(x) {x} : u); f(1)":                               "int",
+		"package main; newtype Meters = float; Meters(1.5)":                                                     "Meters",
+		"package main; newtype Meters = float; unMeters(Meters(1.5)) + 1.0":                                     "float",
+		"package main; newtype Meters = float; Meters(1.5) < Meters(2.0)":                                       "bool",
+		"package main; type vec = (float, float); newtype Point = vec; Point((1.0, 2.0))":                       "Point",
+		// Recursive types
+		"package main; type l = mu l. unit | (int, l); ((1, (2, ())) : l)":                                                                                                                                                 "μa.unit | (int, a)",
+		"package main; type l = mu l. unit | (int, l); let x = ((1, ()) : l); (x : mu m. unit | (int, m))":                                                                                                                 "μa.unit | (int, a)",
+		"package main; type l = mu l. unit | (int, l); let x = ((1, ()) : l); x = x":                                                                                                                                       "bool",
+		"package main; type s = mu s. (int, unit -> s); let from = fun(n) {((n, fun(u) {from(n + 1)}) : s)}; let (x, next) = from(1) in next(())":                                                                          "μa.(int, unit -> a)",
+		"package main; type f = mu f. int -> f; let g = fun(x: int) {(g : f)}; g(1)(2)":                                                                                                                                    "μa.int -> a",
+		"package main; type r = mu r. {value: int, children: []r}; let t = ({value = 1, children = []} : r); t.children":                                                                                                   "[](μa.{children: []a, value: int})",
+		"package main; type option(a) = None | Some(a); type tree = mu t. option((t, int, t)); let size = fun(t: tree) {match t with | None -> 0 | Some((l, _, r)) -> size(l) + 1 + size(r)}; size(Some((None, 1, None)))": "int",
+		"package main; type option(a) = None | Some(a); type nat = mu t. option(t); fun(n) {(None : nat)}":                                                                                                                 "'a -> μb.option(b)",
+		"package main; type option(a) = None | Some(a); type nat = mu t. option(t); let g = fun(n) {(Some(n) : nat)}; g":                                                                                                   "(μa.option(a)) -> μa.option(a)",
+		"package main; type option(a) = None | Some(a); type nat = mu t. option(t); let f = fun(n: nat) {n}; f":                                                                                                            "(μa.option(a)) -> μa.option(a)",
+		"package main; type option(a) = None | Some(a); type nat = mu t. option(t); let g = fun(n) {(Some(n) : nat)}; g(g(None))":                                                                                          "μa.option(a)",
+		"package main; let f = fun(x: forall a. a -> a) {(x(1), x(true))}; f(fun(y) {y})":                                                                                                                                  "(int, bool)",
+		"package main; interface Size(a) { size : a -> int; }; let twice = fun(x) {size(x) + size(x)}; twice":                                                                                                              "∀(a: Size).a -> int",
+		"package main; interface Size(a) { size : a -> int; }; fun(x, y) {(size(x), y)}":                                                                                                                                   "∀(a: Size).a -> 'b -> (int, 'b)",
+		"package main; let f = fun(x, y) {x = y}; f":                                                                                                                                                                       "∀(a: Eq).a -> a -> bool",
+		"package main; let f = fun(x: forall a. a -> a) {(x(1), x(true))}; f":                                                                                                                                              "(∀a.a -> a) -> (int, bool)",
+		"package main; fun(x) { let f = fun(z) { x } in (f(1), f(true)) }":                                                                                                                                                 "'a -> ('a, 'a)",
+		// Annotated functions are recursive, with the type of their annotation
+		"package main; let fact = (fun(n) {if n <= 1 then 1 else n * fact(n - 1)} : int -> int); fact(3)":                       "int",
+		"package main; let f = (fun(x, n) {if n = 0 then x else f(x, n - 1)} : forall a. a -> int -> a); (f(1, 2), f(true, 2))": "(int, bool)",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		ctx := NewContext()
+		ty, _, err := ctx.SynthProgram(alphaconv_program)
+		if assert.Nil(t, err) {
+			assert.Equal(t, expected, ty.FancyString(map[ast.UniqueIdentifier]int{}), input)
+		}
+	}
+}
+
+func TestCheckProgram(t *testing.T) {
+	input := "package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; let twice = fun(x) {size(x) + size(x)}; twice(true)"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if !assert.Len(t, p.Errors(), 0) {
+		return
+	}
+	alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	before := alphaconv_program.String()
+	_, elaborated, _, err := NewContext().CheckProgram(alphaconv_program)
+	if assert.Nil(t, err) {
+		// The dictionaries are only passed in the elaborated copy
+		assert.Equal(t, before, alphaconv_program.String())
+		assert.NotEqual(t, before, elaborated.String())
+	}
+}
+
+func TestCheckProgramKeepsDeclarations(t *testing.T) {
+	input := "package main; type vec = (int, int); type t = C(vec); C((1, 2))"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if !assert.Len(t, p.Errors(), 0) {
+		return
+	}
+	alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	before := alphaconv_program.String()
+	_, _, _, err = NewContext().CheckProgram(alphaconv_program)
+	if assert.Nil(t, err) {
+		// The aliases are only expanded in the definition of the type
+		assert.Equal(t, before, alphaconv_program.String())
+	}
+}
+
+func TestOperatorInstances(t *testing.T) {
+	tests := map[string]string{
+		"1 + 2":          token.PLUS,
+		"1 + 2.5":        token.FPLUS,
+		"[|1|] + [|2|]":  token.MPLUS,
+		"[|1|] * [|2|]":  token.MTIMES,
+		"2 * [|1|]":      token.MTIMES,
+		"[|1|] / (0+1i)": token.MDIVIDE,
+		"[|1|] - 1":      token.MMINUS,
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New("package main; " + input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		_, elaborated, _, err := NewContext().CheckProgram(alphaconv_program)
+		if assert.Nil(t, err) {
+			exp := elaborated.Statements[0].(*ast.ExpressionStatement).Expression
+			assert.Equal(t, expected, exp.(*ast.InfixExpression).ResolvedOperator(), input)
+		}
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	tests := map[string]string{
+		"[|1, 2|] * [|1, 2|]": "dimension mismatch, operator * cannot be applied to operands of type dense matrix(1, 2) and dense matrix(1, 2)",
+		"let mv = (fun(a, b) {a * b} : forall n k. matrix(n, k) -> matrix(k, 1) -> matrix(n, 1)); mv([|1, 2|], [|1, 2|])": "dimension mismatch, type dense matrix(1, 2) cannot be used as type dense matrix(2, 1)",
+		"([|1|] : matrix(1, 2))": "dimension mismatch, type dense matrix(1, 1) cannot be used as type dense matrix(1, 2)",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New("package main; " + input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		_, _, _, err = NewContext().CheckProgram(alphaconv_program)
+		if assert.Error(t, err, input) {
+			assert.Contains(t, err.Error(), expected, input)
+		}
+	}
+}
+
+func TestGeneralizeDropsScope(t *testing.T) {
+	input := "package main; let f = fun(x) {x}; let g = fun(x, y) {(f(x), y)}"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if !assert.Len(t, p.Errors(), 0) {
+		return
+	}
+	alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, _, ctx, err := NewContext().CheckProgram(alphaconv_program)
+	if assert.Nil(t, err) {
+		// The existential variables of the values are not left
+		// in the context after generalizing their types
+		for _, v := range ctx.Contents {
+			assert.IsType(t, &TypeAnnotation{}, v)
+		}
+	}
+}
+
+func TestSynthProgramFail(t *testing.T) {
+	tests := []string{
+		"package main; let x = 1; x + true",
+		"package main; newtype M = float; M(1.0) + M(2.0)",
+		"package main; newtype M = float; -M(1.0)",
+		"package main; fun(x) {x + 1}(\"a\")",
+		"package main; let f = fun(x, y) {x + y}; f(1, 1.5)",
+		"package main; let f = fun(x) {g(x) + 1} and g = fun(x) {true}; f",
+		// Names of a let statement are not visible in its own values
+		"package main; let x = 1 and y = x; y",
+		// Let-bound values do not generalize the types of the enclosing scopes
+		"package main; let h = fun(x) { let f = fun(z) { x } in f(1) + 1 }; h(\"s\")",
+		"package main; let g = fun(x) { let f = fun(z) { x } in (f(1), f(true)) }; let (a, b) = g(1); b && true",
+		// Algebraic data types
+		"package main; type option(a) = None | Some(a); (Some(1) : option(bool))",
+		"package main; type option(a) = None | Some(a); Some(1) = Some(true)",
+		"package main; type option(a) = None | Some(a); (None : option)",
+		"package main; type option(a) = None | Some(a); (None : option(int, int))",
+		"package main; type t = C(undefined)",
+		"package main; type t = C(a)",
+		"package main; type color = Red | Green; Red + 1",
+		"package main; type int = I",
+		// Match expressions
+		"package main; type option(a) = None | Some(a); match Some(1) with | Some(true) -> 1 | _ -> 2",
+		"package main; type option(a) = None | Some(a); (Some(1.5) : option(int))",
+		"package main; type option(a) = None | Some(a); let x = Some(1); (x : option(float))",
+		"package main; type option(a) = None | Some(a); match Some(1) with | Some(x, y) -> x",
+		"package main; type option(a) = None | Some(a); match Some(1) with | Some(x) -> x | None -> true",
+		"package main; type color = Red | Green; type option(a) = None | Some(a); match Red with | Some(x) -> x",
+		"package main; match {a = 1} with | {b = x} -> x",
+		"package main; match 1 with | {a = x} -> x",
+		"package main; (match 1 with | x -> x : bool)",
+		"package main; match 1 with | Undefined(x) -> x",
+		"package main; type pair(a, b) = Pair(a, b); match Pair(1, 2) with | Pair(x, x) -> x",
+		"package main; let x = 1; match x with | y -> x + y; y",
+		// Tuples
+		"package main; ((1, 2) : (int, int, int))",
+		"package main; (1, true) = (1, 2)",
+		"package main; let (a, b) = 1; a",
+		"package main; let (x, x) = (1, 2); x",
+		"package main; let f = fun((x, y)) {x + y}; f((1, 2, 3))",
+		"package main; match (1, 2) with | (x, y, z) -> x",
+		"package main; match (1, 2) with | x :: _ -> x",
+		"package main; match [1] with | true :: _ -> 1",
+		// Lists
+		"package main; 1 :: 2",
+		"package main; 1 ++ [1]",
+		"package main; ([true] : []int)",
+		"package main; [1] = [true]",
+		"package main; let f = fun(n) {if n = 0 then 1 else f(n - 1) = true}; f",
+		// Matrices
+		"package main; [|1, true|]",
+		"package main; ([|1|] : sparse matrix)",
+		"package main; ([|0+1i|] : dense matrix)",
+		"package main; [|1|] / [|1|]",
+		"package main; 1 / [|1|]",
+		"package main; \"a\" * [|1|]",
+		"package main; [|1|] @ (0, 1.5)",
+		"package main; [|1, 2|] * [|1, 2|]",
+		"package main; [|1, 2|] + [|1; 2|]",
+		"package main; let mul = (fun(a, b) {a * b} : forall m n p. matrix(m, n) -> matrix(n, p) -> matrix(m, p)); mul([|1, 2|], [|1, 2|])",
+		"package main; (fun(a, b) {a * b} : forall m n. matrix(m, n) -> matrix(m, n) -> matrix(m, n))",
+		"package main; fun(m: matrix) {(m : matrix(1, 1))}",
+		"package main; ([1, 2] : [3]int)",
+		"package main; ([1, 2] : [2]bool)",
+		"package main; fun(m: matrix(int, bool)) {m}",
+		"package main; ([1, 2] : [bool]int)",
+		"package main; type w = mu t. [int]t",
+		"package main; 1 @ (0, 0)",
+		"package main; 1'",
+		"package main; pure fun(f: int -{io}-> int) {f(1)}",
+		"package main; pure (fun(f: int -{io}-> int) {f})",
+		"package main; allow fail in fun(f: int -{io}-> int) {f(1)}",
+		"package main; fun(f: int -{io}-> int) {deny io in f(1)}",
+		"package main; (fun(f: int -{io}-> int) {f} : (int -{io}-> int) -> int -> int)",
+		"package main; (fun(f, x) {f(x)} : forall e. (int -{e}-> int) -> int -> int)",
+		"package main; (fun(x) {x} : int -{e}-> int)",
+		"package main deny io; let f = fun(g: int -{io}-> int) {g(1)}; f",
+		"package main pure; fun(g: int -{fail}-> int) {g(1)}",
+		"package main; pure fun(x) {1 / x}",
+		"package main; pure fun(x) {x % 2}",
+		"package main; pure fun(x) {2 ^ x}",
+		"package main; pure fun(x) {match x with | 1 -> true}",
+		"package main; pure fun(p) {let (1, y) = p in y}",
+		"package main; deny fail in fun(m: matrix) {m + m}",
+		// Usage policies
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; event a; event a",
+		"package main; policy order { init closed; closed -read-> bad; closed -open-> opened; offending bad; }; let r = fun(x) {event read}; r(1); event open",
+		"package main; policy order { init closed; closed -read-> bad; closed -open-> opened; offending bad; }; if true then event open else (); event read",
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; let f = fun(n) {if n = 0 then () else (event a; f(n - 1))}; f(2)",
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; let twice = fun(f, x) {f(x); f(x)}; twice(fun(x) {event a}, 1)",
+		"package main; policy leak { init q0; q0 -secret-> s; s -net-> bad; offending bad; }; let x = true && (event secret; true); event net",
+		"package main; (fun(x) {event a} : int -{io}-> unit)",
+		"package main; policy once { init q0; q0 -a-> q1; q1 -a-> bad; offending bad; }; policy once { init q0; offending q0; }",
+		"package main; pure fun(x) {event a}",
+		// Interfaces
+		"package main; fun(x) {x} = fun(x) {x}",
+		"package main; let f = fun(x) {x}; f = f",
+		"package main; (1, fun(x) {x}) = (1, fun(x) {x})",
+		"package main; (1, 2) < (3, 4)",
+		"package main; let lt = fun(x, y) {x < y}; lt(fun(x) {x}, fun(x) {x})",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; size(1)",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; let twice = fun(x) {size(x) + size(x)}; twice(\"a\")",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size(bool) { size = fun(b) {2}; }",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; interface Size(b) { size : b -> int; }",
+		"package main; interface Size(a) { size : int; }",
+		"package main; interface Size(a) { size : a -> undefined; }",
+		"package main; instance Undefined(int) { f = 1; }",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size(int) { }",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size(int) { size = fun(n) {n}; length = 1; }",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size(int) { size = fun(n) {true}; }",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; instance Size([]a) { size = fun(n) {1}; }",
+		"package main; instance Eq(int) { }",
+		"package main; interface Size(a) { size : a -> int; }; instance Size(bool) { size = fun(b) {if b then 1 else 0}; }; (fun(x) {size(x)} : forall a. a -> int)",
+		"package main; (fun(x) {x} : forall (a: Undefined). a -> a)",
+		// Type aliases and newtypes
+		"package main; type pair(a) = (a, a); ((1, 1) : pair)",
+		"package main; type pair(a) = (a, a); ((1, true) : pair(int))",
+		"package main; type t = []t; 1",
+		"package main; type t = b; 1",
+		"package main; type int = float; 1",
+		"package main; newtype int = float; 1",
+		"package main; newtype Meters = float; Meters(1.0) + 1.0",
+		"package main; newtype Meters = float; (Meters(1.0) : float)",
+		"package main; newtype Meters = float; newtype Feet = float; Meters(1.0) = Feet(1.0)",
+		"package main; newtype Box = forall a. a -> a; 1",
+		"package main; newtype Box = undefined; 1",
+		// Recursive types
+		"package main; (1 : mu t. t)",
+		"package main; (1 : mu t. t | int)",
+		"package main; (1 : mu t. mu u. t)",
+		"package main; type l = mu l. unit | (int, l); ((1, (true, ())) : l)",
+		"package main; type l = mu l. unit | (int, l); let x = ((1, ()) : l); (x : mu m. unit | (float, m))",
+		"package main; type l = mu l. unit | (int, l); let x = ((1, ()) : l); x < x",
+		"package main; type f = mu f. f -> int; let g = fun(x: f) {x(x)}; (g : mu f. f -> float)",
+		"package main; (fun(x) {x} : mu t. undefined -> t)",
+		"package main; type option(a) = None | Some(a); type nat = mu t. option(t); let g = fun(n) {(Some(n) : nat)}; g(None) + 1",
+		"package main; type option(a) = None | Some(a); type nat = mu t. option(t); let f = fun(n: nat) {n}; f(None) + 1",
+	}
+
+	for _, input := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if err != nil {
+			t.Log(err)
+			continue
+		}
+
+		ctx := NewContext()
+		_, _, err = ctx.SynthProgram(alphaconv_program)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMatchWarnings(t *testing.T) {
+	tests := map[string][]string{
+		"package main; match true with | true -> 1 | false -> 2": {},
+		"package main; match true with | true -> 1":              {"warning: match on true is not exhaustive, false is not matched"},
+		"package main; match 1 with | 1 -> 1 | x -> x | 2 -> 2":  {"warning: unreachable match arm 2"},
+		"package main; match 1 with | 1 -> 1 | 2 -> 2":           {"warning: match on 1 is not exhaustive, _ is not matched"},
+		"package main; match [1] with | [] -> 0 | x :: _ -> x":   {},
+		"package main; match [1] with | x :: [] -> x | [] -> 0":  {"warning: match on [1] is not exhaustive, (_ :: (_ :: _)) is not matched"},
+		"package main; match {a = 1} with | {a = x} -> x":        {},
+		"package main; match {a = true, b = true} with | {a = false} -> 1 | {b = false} -> 2": {
+			"warning: match on {a = true, b = true} is not exhaustive, {a = true, b = true} is not matched",
+		},
+		"package main; type option(a) = None | Some(a); let f = fun(o) {match o with | Some(Some(x)) -> x | None -> 0}; f": {
+			"warning: match on o is not exhaustive, Some(None) is not matched",
+		},
+		"package main; type option(a) = None | Some(a); let f = fun(o) {match o with | Some(x) -> x | Some(1) -> 1 | None -> 0}; f": {
+			"warning: unreachable match arm Some(1)",
+		},
+		"package main; match (true, true) with | (true, _) -> 1 | (_, true) -> 2": {
+			"warning: match on (true, true) is not exhaustive, (false, false) is not matched",
+		},
+		"package main; let (x, y) = (1, 2); x": {},
+		"package main; type option(a) = None | Some(a); let (Some(x), y) = (Some(1), 2); x": {
+			"warning: pattern (Some(x), y) is not exhaustive, (None, _) is not matched",
+		},
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		warnings := []string{}
+		for _, w := range ProgramWarnings(alphaconv_program) {
+			warnings = append(warnings, w.String())
+		}
+		assert.Equal(t, expected, warnings, input)
+	}
+}
@@ -0,0 +1,47 @@
+package typecheck
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/ast"
+)
+
+// This file contains the typing rules of tuple literals
+
+// Rule Tuple=>
+func (c Context) synthTuple(exp *ast.TupleLiteral) (ast.TypeValue, Context, error) {
+	c.debugRule("Tuple=>")
+
+	theta := c
+	elems := make([]ast.TypeValue, len(exp.Elements))
+	for i, e := range exp.Elements {
+		t, delta, err := theta.SynthesizesTo(e)
+		if err != nil {
+			c.debugRuleFail("Tuple=>")
+			return nil, c, err
+		}
+		elems[i] = t
+		theta = delta
+	}
+
+	tuple := theta.Apply(&ast.TupleType{Elements: elems})
+	theta.debugRuleOut("Tuple=>")
+	return tuple, theta, nil
+}
+
+// Rule Tuple<=. Every element is checked against the type
+// in the same position of a tuple type of the same length
+func (c Context) checkTuple(exp *ast.TupleLiteral, ty *ast.TupleType) (Context, error) {
+	c.debugRule("Tuple<=")
+
+	theta := c
+	for i, e := range exp.Elements {
+		var err error
+		theta, err = theta.CheckAgainst(e, theta.Apply(ty.Elements[i]))
+		if err != nil {
+			c.debugRuleFail("Tuple<=")
+			return c, err
+		}
+	}
+
+	theta.debugRuleOut("Tuple<=")
+	return theta, nil
+}
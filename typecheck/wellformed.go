@@ -8,16 +8,81 @@ import (
 // well formed under an algorithmic context
 func (c *Context) IsWellFormed(t ast.TypeValue) bool {
 	switch v := t.(type) {
-	// Rule UvarWF
+	// Rule UvarWF. Nominal types are well
+	// formed where they are declared
 	case *ast.VariableType:
-		return c.HasTypeVar(v.Identifier)
+		return c.HasTypeVar(v.Identifier) || c.GetNewtypeDefinition(v.Identifier) != nil
 	// Rule ArrowWF
 	case *ast.LambdaType:
-		return c.IsWellFormed(v.Domain) && c.IsWellFormed(v.Codomain)
-	// Rule ForallWF
+		return c.IsWellFormed(v.Domain) && c.IsWellFormed(v.Codomain) && c.IsWellFormed(v.Row())
+	// Rule RowWF. Labels are well formed, row variables are
+	// universal or existential variables in the context
+	case *ast.EffectRow:
+		for _, t := range v.Tails {
+			if !c.IsWellFormed(t) {
+				return false
+			}
+		}
+		return true
+	// Rule ForallWF. The interfaces constraining
+	// the type variable must be declared
 	case *ast.ForAllType:
+		for _, name := range v.Constraints {
+			if c.GetInterfaceDefinition(name) == nil {
+				return false
+			}
+		}
+		nc := c.InsertHead(&UniversalVariable{v.Identifier})
+		return nc.IsWellFormed(v.Type)
+	// Rule MuWF. The type variable stands for the recursive type in
+	// its body. The body must not be the type variable itself, as
+	// μt. t or μt. t | int have no unfolding
+	case *ast.MuType:
+		if !isContractive(v.Identifier, v.Type) {
+			return false
+		}
 		nc := c.InsertHead(&UniversalVariable{v.Identifier})
 		return nc.IsWellFormed(v.Type)
+	// Rule DataWF
+	case *ast.DataType:
+		def := c.GetTypeDefinition(v.Identifier)
+		if def == nil || len(def.Params) != len(v.Args) {
+			return false
+		}
+		for _, arg := range v.Args {
+			if !c.IsWellFormed(arg) {
+				return false
+			}
+		}
+		return true
+	// Rule RecordWF
+	case *ast.RecordType:
+		for _, ft := range v.Fields {
+			if !c.IsWellFormed(ft) {
+				return false
+			}
+		}
+		return true
+	// Rule TupleWF
+	case *ast.TupleType:
+		for _, et := range v.Elements {
+			if !c.IsWellFormed(et) {
+				return false
+			}
+		}
+		return true
+	// Rule ListWF
+	case *ast.ListType:
+		return c.IsWellFormed(v.Element)
+	// Rule ArrayWF. The size of an array is a dimension
+	case *ast.ArrayType:
+		return c.isDimension(v.Size) && c.IsWellFormed(v.Element)
+	// Rule MatrixWF. The dimensions of a matrix are well formed
+	case *ast.MatrixType:
+		return !v.IsSized() || c.isDimension(v.Rows) && c.isDimension(v.Cols)
+	// Rule UnionWF
+	case *ast.UnionType:
+		return c.IsWellFormed(v.Left) && c.IsWellFormed(v.Right)
 	// Rules EvarWF and SolvedEvarWF
 	case *ast.ExistsType:
 		return c.HasExistentialVariable(v.Identifier) || nil != c.GetSolvedVariable(v.Identifier)
@@ -26,3 +91,77 @@ func (c *Context) IsWellFormed(t ast.TypeValue) bool {
 		return true
 	}
 }
+
+// Get a type variable of a type that is not in the context, nil if
+// every type variable of the type is bound
+func (c *Context) unboundTypeVariable(t ast.TypeValue) *ast.VariableType {
+	children := []ast.TypeValue{}
+	switch v := t.(type) {
+	case *ast.VariableType:
+		if c.IsWellFormed(v) {
+			return nil
+		}
+		return v
+	case *ast.ForAllType:
+		nc := c.InsertHead(&UniversalVariable{v.Identifier})
+		return nc.unboundTypeVariable(v.Type)
+	case *ast.MuType:
+		nc := c.InsertHead(&UniversalVariable{v.Identifier})
+		return nc.unboundTypeVariable(v.Type)
+	case *ast.LambdaType:
+		children = append(children, v.Domain, v.Codomain)
+	case *ast.DataType:
+		children = v.Args
+	case *ast.RecordType:
+		for _, label := range v.Labels() {
+			children = append(children, v.Fields[label])
+		}
+	case *ast.TupleType:
+		children = v.Elements
+	case *ast.ListType:
+		children = append(children, v.Element)
+	case *ast.ArrayType:
+		children = append(children, v.Element)
+	case *ast.UnionType:
+		children = append(children, v.Left, v.Right)
+	}
+	for _, child := range children {
+		if alpha := c.unboundTypeVariable(child); alpha != nil {
+			return alpha
+		}
+	}
+	return nil
+}
+
+// Rule DimWF. A dimension is a dimension literal, a type variable
+// in the context or an existential variable. The types of values,
+// such as int in [int]bool, are not dimensions
+func (c *Context) isDimension(t ast.TypeValue) bool {
+	switch v := t.(type) {
+	case *ast.DimensionType:
+		return true
+	case *ast.VariableType:
+		if _, ok := ast.DefaultVariableTypes[v.Identifier.Value]; ok {
+			return false
+		}
+		return c.HasTypeVar(v.Identifier)
+	case *ast.ExistsType:
+		return c.IsWellFormed(v)
+	}
+	return false
+}
+
+// Returns true if the body of a recursive type with type variable
+// alpha is not alpha, nor a union or another recursive type with
+// alpha as a side or as its body
+func isContractive(alpha ast.UniqueIdentifier, t ast.TypeValue) bool {
+	switch vt := t.(type) {
+	case *ast.VariableType:
+		return vt.Identifier != alpha
+	case *ast.MuType:
+		return isContractive(alpha, vt.Type)
+	case *ast.UnionType:
+		return isContractive(alpha, vt.Left) && isContractive(alpha, vt.Right)
+	}
+	return true
+}
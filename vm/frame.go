@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/code"
+	"github.com/0x0f0f0f/gobba-golang/compiler"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+)
+
+// A compiled function paired with the values it captured
+// from the enclosing functions when it was created
+type Closure struct {
+	Fn       *compiler.CompiledFunction
+	Upvalues []eval.Value
+}
+
+func (cl *Closure) Type() eval.ValueType { return eval.CLOSURE_VALUE }
+func (cl *Closure) String() string       { return "<fun>" }
+
+// The activation record of a function call
+type Frame struct {
+	cl          *Closure
+	ip          int
+	basePointer int
+	// The shapes the returned value is converted to, in order,
+	// when the function was called as a coerced function
+	results []*ast.Shape
+}
+
+func NewFrame(cl *Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+func (f *Frame) String() string {
+	return fmt.Sprintf("frame(ip=%d, bp=%d)", f.ip, f.basePointer)
+}
@@ -0,0 +1,609 @@
+// Contains the stack based virtual machine that executes
+// the bytecode produced by the compiler
+package vm
+
+import (
+	"fmt"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/code"
+	"github.com/0x0f0f0f/gobba-golang/compiler"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/token"
+	"math"
+	"math/cmplx"
+)
+
+const StackSize = 2048
+const GlobalsSize = compiler.MaxGlobals
+
+// Maximum depth of the call stack
+const MaxFrames = 1 << 20
+
+var unit = &eval.UnitValue{}
+var vTrue = &eval.BoolValue{Value: true}
+var vFalse = &eval.BoolValue{Value: false}
+
+type VM struct {
+	constants []eval.Value
+	globals   []eval.Value
+
+	stack []eval.Value
+	sp    int // Always points to the next free slot. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+}
+
+// Create a new virtual machine ready to execute some bytecode
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &compiler.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFrame := NewFrame(&Closure{Fn: mainFn}, 0)
+
+	frames := make([]*Frame, 1, 64)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]eval.Value, GlobalsSize),
+		stack:       make([]eval.Value, StackSize),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// Create a new virtual machine that shares the global bindings
+// of a previous execution
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []eval.Value) *VM {
+	vm := New(bytecode)
+	vm.globals = globals
+	return vm
+}
+
+// Get the global bindings
+func (vm *VM) Globals() []eval.Value {
+	return vm.globals
+}
+
+// The value left on top of the stack after execution
+func (vm *VM) Result() eval.Value {
+	if vm.sp == 0 {
+		return nil
+	}
+	return vm.stack[vm.sp-1]
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return &eval.RuntimeError{Msg: "stack overflow"}
+	}
+	if vm.framesIndex < len(vm.frames) {
+		vm.frames[vm.framesIndex] = f
+	} else {
+		vm.frames = append(vm.frames, f)
+	}
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(v eval.Value) {
+	if vm.sp >= len(vm.stack) {
+		vm.stack = append(vm.stack, make([]eval.Value, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = v
+	vm.sp++
+}
+
+func (vm *VM) pop() eval.Value {
+	v := vm.stack[vm.sp-1]
+	vm.sp--
+	return v
+}
+
+// Make room on the stack for n values
+func (vm *VM) reserve(n int) {
+	for vm.sp+n > len(vm.stack) {
+		vm.stack = append(vm.stack, make([]eval.Value, len(vm.stack))...)
+	}
+}
+
+// Execute the bytecode
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+		case code.OpUnit:
+			vm.push(unit)
+		case code.OpTrue:
+			vm.push(vTrue)
+		case code.OpFalse:
+			vm.push(vFalse)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpFAdd, code.OpFSub, code.OpFMul, code.OpFDiv, code.OpFPow,
+			code.OpCAdd, code.OpCSub, code.OpCMul, code.OpCDiv, code.OpCPow,
+			code.OpMAdd, code.OpMSub, code.OpMMul, code.OpMDiv,
+			code.OpEqual, code.OpNotEqual,
+			code.OpLess, code.OpLessEq, code.OpGreater, code.OpGreaterEq,
+			code.OpCons, code.OpConcat, code.OpAt:
+			if err := vm.executeInfixOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpMinus, code.OpFMinus, code.OpCMinus:
+			res, err := eval.ApplyPrefix(code.PrefixOperators[op], vm.pop())
+			if err != nil {
+				return err
+			}
+			vm.push(res)
+		case code.OpNot:
+			b, ok := vm.pop().(*eval.BoolValue)
+			if !ok {
+				return &eval.RuntimeError{Msg: "expected a value of type bool"}
+			}
+			vm.push(nativeBool(!b.Value))
+		case code.OpTranspose:
+			res, err := eval.ApplyPostfix(token.TRANSPOSE, vm.pop())
+			if err != nil {
+				return err
+			}
+			vm.push(res)
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTrue:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			b, ok := vm.pop().(*eval.BoolValue)
+			if !ok {
+				return &eval.RuntimeError{Msg: "expected a value of type bool"}
+			}
+			if !b.Value {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.push(vm.globals[globalIndex])
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.push(vm.stack[frame.basePointer+int(localIndex)])
+
+		case code.OpGetUpvalue:
+			upIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			vm.push(vm.currentFrame().cl.Upvalues[upIndex])
+		case code.OpCurrentClosure:
+			vm.push(vm.currentFrame().cl)
+		case code.OpSetUpvalue:
+			upIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			value := vm.pop()
+			vm.pop().(*Closure).Upvalues[upIndex] = value
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numUpvalues := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			if err := vm.pushClosure(int(constIndex), int(numUpvalues)); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			if err := vm.callFunction(); err != nil {
+				return err
+			}
+		case code.OpTailCall:
+			if err := vm.tailCallFunction(); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			vm.returnValue()
+
+		case code.OpRecord:
+			numFields := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			vm.buildRecord(numFields)
+		case code.OpAccess:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			label := vm.constants[constIndex].(*eval.StringValue).Value
+			rec, ok := vm.pop().(*eval.RecordValue)
+			if !ok {
+				return &eval.RuntimeError{Msg: "expected a value of type record"}
+			}
+			v, err := rec.Access(label)
+			if err != nil {
+				return err
+			}
+			vm.push(v)
+
+		case code.OpTuple:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			elems := make([]eval.Value, numElements)
+			copy(elems, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			vm.push(&eval.TupleValue{Elements: elems})
+		case code.OpList:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			elems := make([]eval.Value, numElements)
+			copy(elems, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			vm.push(eval.NewListValue(elems))
+		case code.OpMatrix:
+			rows := int(code.ReadUint16(ins[ip+1:]))
+			cols := int(code.ReadUint16(ins[ip+3:]))
+			sparse := code.ReadUint8(ins[ip+5:]) == 1
+			vm.currentFrame().ip += 5
+			elems := make([]eval.Value, rows*cols)
+			copy(elems, vm.stack[vm.sp-rows*cols:vm.sp])
+			vm.sp -= rows * cols
+			m, err := eval.NewMatrixValue(rows, cols, sparse, elems)
+			if err != nil {
+				return err
+			}
+			vm.push(m)
+
+		case code.OpMatch:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			if err := vm.matchPattern(constIndex); err != nil {
+				return err
+			}
+		case code.OpMatchFailure:
+			return &eval.RuntimeError{Msg: fmt.Sprintf("no pattern matches the value %s", vm.pop())}
+
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("opcode %s not implemented", def.Name)
+		}
+	}
+
+	return nil
+}
+
+// Pop the labels and values of the fields of a record
+// and push the record
+func (vm *VM) buildRecord(numFields int) {
+	fields := make(map[string]eval.Value, numFields)
+	start := vm.sp - 2*numFields
+	for i := start; i < vm.sp; i += 2 {
+		label := vm.stack[i].(*eval.StringValue).Value
+		fields[label] = vm.stack[i+1]
+	}
+	vm.sp = start
+	vm.push(&eval.RecordValue{Fields: fields})
+}
+
+// Pop a value and match it against the pattern in the constant pool.
+// The values bound by the pattern and true are pushed if it matches,
+// the value and false otherwise
+func (vm *VM) matchPattern(constIndex uint16) error {
+	pat := vm.constants[constIndex].(*compiler.CompiledPattern)
+	v := vm.pop()
+	values, ok, err := eval.MatchPattern(pat.Pattern, v)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		vm.push(v)
+		vm.push(vFalse)
+		return nil
+	}
+	for _, bound := range values {
+		vm.push(bound)
+	}
+	vm.push(vTrue)
+	return nil
+}
+
+func nativeBool(b bool) *eval.BoolValue {
+	if b {
+		return vTrue
+	}
+	return vFalse
+}
+
+// Arithmetic and comparisons on two numbers of the same type are
+// executed without going through the generic operator semantics
+func (vm *VM) executeInfixOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	var res eval.Value
+	switch l := left.(type) {
+	case *eval.IntegerValue:
+		if r, ok := right.(*eval.IntegerValue); ok {
+			res = intOperation(op, l.Value, r.Value)
+		}
+	case *eval.FloatValue:
+		if r, ok := right.(*eval.FloatValue); ok {
+			res = floatOperation(op, l.Value, r.Value)
+		}
+	case *eval.ComplexValue:
+		if r, ok := right.(*eval.ComplexValue); ok {
+			res = complexOperation(op, l.Value, r.Value)
+		}
+	}
+
+	if res == nil {
+		var err error
+		res, err = eval.ApplyInfix(code.InfixOperators[op], left, right)
+		if err != nil {
+			return err
+		}
+	}
+	vm.push(res)
+	return nil
+}
+
+// Apply an opcode to two integers. Returns nil for the opcodes
+// left to the generic semantics, such as the division that can fail
+func intOperation(op code.Opcode, l, r int64) eval.Value {
+	switch op {
+	case code.OpAdd:
+		return &eval.IntegerValue{Value: l + r}
+	case code.OpSub:
+		return &eval.IntegerValue{Value: l - r}
+	case code.OpMul:
+		return &eval.IntegerValue{Value: l * r}
+	case code.OpEqual:
+		return nativeBool(l == r)
+	case code.OpNotEqual:
+		return nativeBool(l != r)
+	case code.OpLess:
+		return nativeBool(l < r)
+	case code.OpLessEq:
+		return nativeBool(l <= r)
+	case code.OpGreater:
+		return nativeBool(l > r)
+	case code.OpGreaterEq:
+		return nativeBool(l >= r)
+	}
+	return nil
+}
+
+// Apply an opcode to two floats. Returns nil for the
+// opcodes left to the generic semantics
+func floatOperation(op code.Opcode, l, r float64) eval.Value {
+	switch op {
+	case code.OpFAdd:
+		return &eval.FloatValue{Value: l + r}
+	case code.OpFSub:
+		return &eval.FloatValue{Value: l - r}
+	case code.OpFMul:
+		return &eval.FloatValue{Value: l * r}
+	case code.OpFDiv:
+		return &eval.FloatValue{Value: l / r}
+	case code.OpFPow:
+		return &eval.FloatValue{Value: math.Pow(l, r)}
+	case code.OpEqual:
+		return nativeBool(l == r)
+	case code.OpNotEqual:
+		return nativeBool(l != r)
+	case code.OpLess:
+		return nativeBool(l < r)
+	case code.OpLessEq:
+		return nativeBool(l <= r)
+	case code.OpGreater:
+		return nativeBool(l > r)
+	case code.OpGreaterEq:
+		return nativeBool(l >= r)
+	}
+	return nil
+}
+
+// Apply an opcode to two complex numbers. Returns nil for the
+// opcodes left to the generic semantics
+func complexOperation(op code.Opcode, l, r complex128) eval.Value {
+	switch op {
+	case code.OpCAdd:
+		return &eval.ComplexValue{Value: l + r}
+	case code.OpCSub:
+		return &eval.ComplexValue{Value: l - r}
+	case code.OpCMul:
+		return &eval.ComplexValue{Value: l * r}
+	case code.OpCDiv:
+		return &eval.ComplexValue{Value: l / r}
+	case code.OpCPow:
+		return &eval.ComplexValue{Value: cmplx.Pow(l, r)}
+	case code.OpEqual:
+		return nativeBool(l == r)
+	case code.OpNotEqual:
+		return nativeBool(l != r)
+	}
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex, numUpvalues int) error {
+	fn, ok := vm.constants[constIndex].(*compiler.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+
+	upvalues := make([]eval.Value, numUpvalues)
+	copy(upvalues, vm.stack[vm.sp-numUpvalues:vm.sp])
+	vm.sp = vm.sp - numUpvalues
+
+	vm.push(&Closure{Fn: fn, Upvalues: upvalues})
+	return nil
+}
+
+// Return from the current function with the value on top of the stack
+func (vm *VM) returnValue() {
+	returnValue := vm.pop()
+	frame := vm.popFrame()
+	for _, s := range frame.results {
+		returnValue = eval.Restrict(returnValue, s)
+	}
+	// Also discard the called function
+	vm.sp = frame.basePointer - 1
+	vm.push(returnValue)
+}
+
+// Replace a constructor and the argument on top of the
+// stack with the constructor applied to the argument
+func (vm *VM) applyConstructor(ctor *eval.ConstructorValue) {
+	arg := vm.pop()
+	vm.pop()
+	vm.push(ctor.Apply(arg))
+}
+
+// Replace a builtin and the argument on top of the stack
+// with the builtin applied to the argument
+func (vm *VM) applyBuiltin(builtin *eval.BuiltinValue) error {
+	arg := vm.pop()
+	vm.pop()
+	res, err := builtin.Apply(arg)
+	if err != nil {
+		return err
+	}
+	vm.push(res)
+	return nil
+}
+
+// Replace the coerced functions below the argument on top of the
+// stack with the functions they wrap, converting the argument. Returns
+// the shapes the result is converted to, from the innermost function
+func (vm *VM) uncoerce() []*ast.Shape {
+	var results []*ast.Shape
+	for {
+		coerced, ok := vm.stack[vm.sp-2].(*eval.CoercedFunction)
+		if !ok {
+			return results
+		}
+		vm.stack[vm.sp-2] = coerced.Function
+		vm.stack[vm.sp-1] = eval.Restrict(vm.stack[vm.sp-1], coerced.Shape.Domain)
+		results = append([]*ast.Shape{coerced.Shape.Codomain}, results...)
+	}
+}
+
+// Convert the value on top of the stack to a list of shapes
+func (vm *VM) restrictTop(shapes []*ast.Shape) {
+	for _, s := range shapes {
+		vm.stack[vm.sp-1] = eval.Restrict(vm.stack[vm.sp-1], s)
+	}
+}
+
+// Call the function below the argument on top of the stack
+func (vm *VM) callFunction() error {
+	results := vm.uncoerce()
+	if ctor, ok := vm.stack[vm.sp-2].(*eval.ConstructorValue); ok {
+		vm.applyConstructor(ctor)
+		vm.restrictTop(results)
+		return nil
+	}
+	if builtin, ok := vm.stack[vm.sp-2].(*eval.BuiltinValue); ok {
+		if err := vm.applyBuiltin(builtin); err != nil {
+			return err
+		}
+		vm.restrictTop(results)
+		return nil
+	}
+	cl, ok := vm.stack[vm.sp-2].(*Closure)
+	if !ok {
+		return &eval.RuntimeError{
+			Msg: fmt.Sprintf("cannot apply a value of type %s", vm.stack[vm.sp-2].Type()),
+		}
+	}
+
+	// The argument is the first local of the new frame
+	frame := NewFrame(cl, vm.sp-1)
+	frame.results = results
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	vm.reserve(cl.Fn.NumLocals)
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+// Call the function below the argument on top of the stack, reusing
+// the frame of the current function
+func (vm *VM) tailCallFunction() error {
+	results := vm.uncoerce()
+	if ctor, ok := vm.stack[vm.sp-2].(*eval.ConstructorValue); ok {
+		// Applying a constructor does not need a frame,
+		// return from the current function with the result
+		vm.applyConstructor(ctor)
+		vm.restrictTop(results)
+		vm.returnValue()
+		return nil
+	}
+	if builtin, ok := vm.stack[vm.sp-2].(*eval.BuiltinValue); ok {
+		if err := vm.applyBuiltin(builtin); err != nil {
+			return err
+		}
+		vm.restrictTop(results)
+		vm.returnValue()
+		return nil
+	}
+	cl, ok := vm.stack[vm.sp-2].(*Closure)
+	if !ok {
+		return &eval.RuntimeError{
+			Msg: fmt.Sprintf("cannot apply a value of type %s", vm.stack[vm.sp-2].Type()),
+		}
+	}
+
+	// Move the function and its argument in place of the
+	// function and argument of the current frame
+	frame := vm.currentFrame()
+	vm.stack[frame.basePointer-1] = cl
+	vm.stack[frame.basePointer] = vm.stack[vm.sp-1]
+
+	// The result of the called function is converted
+	// before the conversions of the result of the caller
+	frame.cl = cl
+	frame.ip = -1
+	frame.results = append(results, frame.results...)
+	vm.sp = frame.basePointer + 1
+	vm.reserve(cl.Fn.NumLocals)
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	return nil
+}
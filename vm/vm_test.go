@@ -0,0 +1,252 @@
+package vm
+
+import (
+	"github.com/0x0f0f0f/gobba-golang/alpha"
+	"github.com/0x0f0f0f/gobba-golang/ast"
+	"github.com/0x0f0f0f/gobba-golang/compiler"
+	"github.com/0x0f0f0f/gobba-golang/eval"
+	"github.com/0x0f0f0f/gobba-golang/lexer"
+	"github.com/0x0f0f0f/gobba-golang/parser"
+	"github.com/0x0f0f0f/gobba-golang/typecheck"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Parse, α-convert and typecheck an input program
+func prepareProgram(t *testing.T, input string) ast.Expression {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseSingleExpression()
+	if !assert.Len(t, p.Errors(), 0, input) {
+		return nil
+	}
+	alphaconv_program, err := alpha.ExpressionAlphaConversion(program)
+	if !assert.Nil(t, err, input) {
+		return nil
+	}
+	ctx := typecheck.NewContext()
+	_, elaborated, err := ctx.CheckExpr(*alphaconv_program)
+	if !assert.Nil(t, err, input) {
+		return nil
+	}
+	return elaborated
+}
+
+func runVM(program ast.Expression) (eval.Value, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	return machine.Result(), nil
+}
+
+func TestVM(t *testing.T) {
+	tests := map[string]string{
+		"();":                                "()",
+		"true;":                              "true",
+		"4;":                                 "4",
+		"4.5+3.2e-2i;":                       "4.5+0.032i",
+		"\"ciao\"":                           "\"ciao\"",
+		"fun (x) {x};":                       "<fun>",
+		"fun (x) {x}(2)":                     "2",
+		"fun (x, y) {x - y}(2, 3)":           "-1",
+		"fun (x: int, y: int) {x * y}(4, 3)": "12",
+		"fun (x) {x()}(fun (y) {y})":         "()",
+		"if true then 4 else 4.5":            "4.0",
+		"if false then 4 else 4.5":           "4.5",
+		"7 / 2":                              "3",
+		"7 % 2":                              "1",
+		"2 ^ 10":                             "1024",
+		"4.5 +. 4":                           "8.5",
+		"4 +: 3+3i":                          "7+3i",
+		"-(3 + 4)":                           "-7",
+		"!true":                              "false",
+		"1 != 1":                             "false",
+		"\"a\" < \"b\"":                      "true",
+		"false && 1 / 0 = 0":                 "false",
+		"true || 1 / 0 = 0":                  "true",
+		"false || 2 > 1":                     "true",
+		"1; 2; 3":                            "3",
+		"let x = 4 and y = 3.2 and f = fun(x,y) {x}; f(y, x)":                    "3.2",
+		"let fib = fun(n) { if n < 2 then n else fib(n-1) + fib(n-2) }; fib(20)": "6765",
+		"let swap = fun(x,y,f) {f(y,x)}; " +
+			"let firstid = fun(a,b) {a};" +
+			"swap(3,\"ciao\",firstid)": "\"ciao\"",
+		// Upvalues are captured from every enclosing function
+		"let adder = fun(x) { fun(y) { fun(z) { x + y + z } } }; adder(1)(2)(3)":      "6",
+		"let f = fun(x) { let y = x * 2; let g = fun(z) { y + z + x }; g(1) }; f(10)": "31",
+		// Records
+		"{y = 1 + 1, x = true}":               "{x = true, y = 2}",
+		"let p = {x = 1, y = 2} in p.x + p.y": "3",
+		"let move = fun(p: {x: int, y: int}) {{x = p.x + 1, y = p.y}}; move({x = 1, y = 2, z = 3}).x": "2",
+		"{x = 1, y = 2} = {y = 2, x = 1}": "true",
+		// Let-bound functions are polymorphic
+		"let id = fun(x) {x} in (id(1); id(true))": "true",
+		// Bindings inside functions are kept in local slots
+		"fun(a) { let b = a + 1; let c = b + 1; a + b + c }(1)": "6",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		program := prepareProgram(t, input)
+		if program == nil {
+			continue
+		}
+		v, err := runVM(program)
+		if assert.Nil(t, err, input) {
+			assert.Equal(t, expected, v.String(), input)
+		}
+
+		// The tree walker and the virtual machine must agree
+		ev, err := eval.ExpressionEval(program)
+		if assert.Nil(t, err, input) {
+			assert.Equal(t, ev.String(), v.String(), input)
+		}
+	}
+}
+
+func TestVMFail(t *testing.T) {
+	tests := []string{
+		"1 / 0",
+		"1 % 0",
+		"2 ^ -1",
+		"match 3 with | 1 -> true | 2 -> false",
+		"let (1, x) = (2, 3) in x",
+		"([|1, 2|] : matrix) * [|1, 2|]",
+		"([|1, 2|] : matrix) + [|1; 2|]",
+		"[|1|] @ (0, 1)",
+	}
+
+	for _, input := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		program := prepareProgram(t, input)
+		if program == nil {
+			continue
+		}
+		_, err := runVM(program)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := map[string]string{
+		// Self recursion
+		"let loop = fun(n, acc) { if n = 0 then acc else loop(n - 1, acc + 1) }; loop(1000000, 0)": "1000000",
+		// Mutual recursion
+		"let even = fun(n) {" +
+			"let odd = fun(m) { if m = 0 then false else even(m - 1) };" +
+			"if n = 0 then true else odd(n - 1) }; even(1000001)": "false",
+		// Tail position in sequencing and boolean operators
+		"let count = fun(n) { n; if n = 0 then true else true && count(n - 1) }; count(1000000)": "true",
+	}
+
+	for input, expected := range tests {
+		program := prepareProgram(t, input)
+		if program == nil {
+			continue
+		}
+		comp := compiler.New()
+		if !assert.Nil(t, comp.Compile(program)) {
+			continue
+		}
+		machine := New(comp.Bytecode())
+		if assert.Nil(t, machine.Run(), input) {
+			assert.Equal(t, expected, machine.Result().String(), input)
+		}
+		// The call stack never grew
+		assert.True(t, len(machine.frames) < 8, input)
+	}
+}
+
+func TestVMProgram(t *testing.T) {
+	tests := map[string]string{
+		"package main;":                              "()",
+		"package main; let x = 1;":                   "()",
+		"package main; let x = 1 and y = 2; x + y":   "3",
+		"package main; let x = 1; let x = x + 1; x":  "2",
+		"package main; 1; 2; 3":                      "3",
+		"package main; let f = fun(x) {x * 2}; f(4)": "8",
+		"package main; let fact = fun(n) {if n <= 1 then 1 else n * fact(n - 1)}; fact(5)": "120",
+		// Algebraic data types
+		"package main; type option(a) = None | Some(a); Some(1)":                              "Some(1)",
+		"package main; type option(a) = None | Some(a); None":                                 "None",
+		"package main; type tree(a) = Leaf | Node(tree(a), a, tree(a)); Node(Leaf, 1, Leaf)":  "Node(Leaf, 1, Leaf)",
+		"package main; type pair(a, b) = Pair(a, b); let p = Pair(1); p(true)":                "Pair(1, true)",
+		"package main; type option(a) = None | Some(a); Some(1) = Some(1)":                    "true",
+		"package main; type option(a) = None | Some(a); Some(None) = Some(Some(2))":           "false",
+		"package main; type option(a) = None | Some(a); let wrap = fun(x) {Some(x)}; wrap(2)": "Some(2)",
+		// Match expressions
+		"package main; type option(a) = None | Some(a); let get = fun(o, d) {match o with | None -> d | Some(x) -> x}; get(Some(3), 0) + get(None, 4)":                                               "7",
+		"package main; type tree(a) = Leaf | Node(tree(a), a, tree(a)); let sum = fun(t) {match t with | Leaf -> 0 | Node(l, v, r) -> sum(l) + v + sum(r)}; sum(Node(Node(Leaf, 1, Leaf), 2, Leaf))": "3",
+		"package main; let f = fun(n) {match n with | 0 -> \"zero\" | -1 -> \"minus one\" | _ -> \"other\"}; f(-1)":                                                                                  "\"minus one\"",
+		"package main; match {x = 1, y = true} with | {y = false} -> 0 | {x = x} -> x":                                                                                                               "1",
+		"package main; type option(a) = None | Some(a); match Some(Some(2)) with | Some(None) -> 0 | Some(Some(x)) -> x | None -> 1":                                                                 "2",
+		"package main; type option(a) = None | Some(a); let adder = match Some(2) with | Some(n) -> fun(x) {x + n} | None -> fun(x) {x}; adder(1)":                                                   "3",
+		"package main; let count = fun(n) {match n with | 0 -> true | m -> count(m - 1)}; count(1000000)":                                                                                            "true",
+		// Tuples
+		"package main; let swap = fun((x, y)) {(y, x)}; swap((1, true))":                                   "(true, 1)",
+		"package main; let (a, b) = (1, 2.5); a +. b":                                                      "3.5",
+		"package main; let ((a, b), c) = ((1, 2), 3); a + b + c":                                           "6",
+		"package main; let f = fun(a, (b, c), d) {a + b + c + d}; f(1, (2, 3), 4)":                         "10",
+		"package main; (1, (2, 3)) = (1, (2, 3))":                                                          "true",
+		"package main; match (1, false) with | (1, true) -> 0 | (n, _) -> n":                               "1",
+		"package main; let sum = fun(l) {match l with | [] -> 0 | x :: xs -> x + sum(xs)}; sum([1, 2, 3])": "6",
+		"package main; match [1] with | x :: y :: _ -> y | x :: [] -> x | _ -> 0":                          "1",
+		// Unions
+		"package main; let f = fun(c) {if c then 1 else \"one\"}; f(false)":         "\"one\"",
+		"package main; (if true then 1 else true) = (if false then 2 else false)":   "false",
+		"package main; let g = fun(x: int | bool) {x}; let h = fun(f) {f(1)}; h(g)": "1",
+		// Lists
+		"package main; 1 :: [2, 3]":                                                "[1, 2, 3]",
+		"package main; [1, 2] ++ [] ++ [3]":                                        "[1, 2, 3]",
+		"package main; let l = [2]; (1 :: l, l)":                                   "([1, 2], [2])",
+		"package main; ([1, 2] = 1 :: [2], [1] = [1, 2], [] = [1])":                "(true, false, false)",
+		"package main; let f = fun(n) {if n = 0 then [] else n :: f(n - 1)}; f(3)": "[3, 2, 1]",
+		// Matrices
+		"package main; [|1, 2; 3, 4|] * [|1, 2; 3, 4|]":                                               "[|7.0, 10.0; 15.0, 22.0|]",
+		"package main; let m = [|1, 2|]; (m' * m, m * m')":                                            "([|1.0, 2.0; 2.0, 4.0|], [|5.0|])",
+		"package main; (1 + 2.5, 1.0 + 2i, 7 / 2, 7 / 2.0, 2 ^ 0.5)":                                  "(3.5, 1+2i, 3, 3.5, 1.4142135623730951)",
+		"package main; (1.5 *. 2.0 -. 0.5, 3.0 /. 2.0, 2.0 ^. 3.0, 1.5 < 2.5, 1.5 >= 2.5, 1.5 = 1.5)": "(2.5, 1.5, 8.0, true, false, true)",
+		"package main; ((1+1i) *: (1-1i), 2i /: 1i, 1i -: 1, 1i = 1i, 1i != 1i)":                      "(2+0i, 2+0i, -1+1i, true, false)",
+		"package main; (-(1.5), -(1 + 2i), -(3 - 1))":                                                 "(-1.5, -1-2i, -2)",
+		"package main; let half = fun(x) {x / 2.0}; half(3)":                                          "1.5",
+		"package main; let f = fun(x, y) {x + y}; (f(1, 2), f(1.5, 2.0), -(f(1i, 1)))":                "(3, 3.5, -1-1i)",
+		"package main; let d = fun(x) {x / 2}; (d(7), d(7.0))":                                        "(3, 3.5)",
+		"package main; [|1, 2|] - [|0.5, 1|]":                                                         "[|0.5, 1.0|]",
+		"package main; (2 * [|1, 2|], [|1, 2|] / 2, 1 - [|1, 2|])":                                    "([|2.0, 4.0|], [|0.5, 1.0|], [|0.0, -1.0|])",
+		"package main; let s = sparse [|0, 2; 0, 0|]; (s * s', s + 1, s / 2)":                         "(sparse [|4.0, 0.0; 0.0, 0.0|], [|1.0, 3.0; 1.0, 1.0|], sparse [|0.0, 1.0; 0.0, 0.0|])",
+		"package main; [|1, 0+2i|] @ (0, 1) +: [|1|] @ (0, 0)":                                        "1+2i",
+		"package main; ([|1, 2|] = [|1, 2|], ([|1, 2|] : matrix) = ([|1, 2|]' : matrix))":             "(true, false)",
+		"package main; let f = (fun(v) {v} : forall n. [n]int -> [n]int); (f([1, 2]), 1 :: f([2]))":   "([1, 2], [1, 2])",
+	}
+
+	for input, expected := range tests {
+		t.Log("--- TEST CASE", input, "---")
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if !assert.Len(t, p.Errors(), 0) {
+			continue
+		}
+		alphaconv_program, err := alpha.ProgramAlphaConversion(program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		_, elaborated, _, err := typecheck.NewContext().CheckProgram(alphaconv_program)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		comp := compiler.New()
+		if !assert.Nil(t, comp.CompileProgram(elaborated)) {
+			continue
+		}
+		machine := New(comp.Bytecode())
+		if assert.Nil(t, machine.Run()) {
+			assert.Equal(t, expected, machine.Result().String())
+		}
+	}
+}